@@ -0,0 +1,132 @@
+package ps
+
+import (
+	"mime"
+	"sort"
+	"strings"
+)
+
+// MediaRange is one entry of an Accept header.
+type MediaRange struct {
+	// Type is the lower-cased top-level type, or * for any.
+	Type string
+	// Subtype is the lower-cased subtype, or * for any.
+	Subtype string
+	// Params holds the media type parameters, excluding q.
+	Params map[string]string
+	// Q is the relative preference, between 0 and 1.
+	Q float64
+}
+
+// String returns the media range in header form, without its q-value.
+func (m MediaRange) String() string {
+	return mime.FormatMediaType(m.Type+"/"+m.Subtype, m.Params)
+}
+
+// Matches reports whether mediaType, which may carry parameters, falls within the range. Every parameter of the
+// range must be present in mediaType with the same value.
+func (m MediaRange) Matches(mediaType string) bool {
+	mt, params, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return false
+	}
+	typ, subtype, _ := strings.Cut(mt, "/")
+
+	if m.Type != "*" && m.Type != typ || m.Subtype != "*" && m.Subtype != subtype {
+		return false
+	}
+	for name, value := range m.Params {
+		if !strings.EqualFold(params[name], value) {
+			return false
+		}
+	}
+	return true
+}
+
+// specificity ranks how narrow the range is: */* is 0, type/* is 1, type/subtype is 2, and a range with
+// parameters is 3.
+func (m MediaRange) specificity() int {
+	switch {
+	case m.Type == "*":
+		return 0
+	case m.Subtype == "*":
+		return 1
+	case len(m.Params) == 0:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// ParseAccept parses an Accept header into its media ranges, ordered by precedence: higher q-values first, then
+// more specific ranges (text/plain;format=flowed before text/plain before text/* before */*), then the order they
+// appear in. Malformed entries are dropped.
+func ParseAccept(header string) []MediaRange {
+	var ranges []MediaRange
+
+	for _, part := range strings.Split(header, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+
+		mt, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		typ, subtype, ok := strings.Cut(mt, "/")
+		if !ok || typ == "*" && subtype != "*" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, ok = parseQ("q=" + value); !ok {
+				continue
+			}
+			delete(params, "q")
+		}
+		if len(params) == 0 {
+			params = nil
+		}
+
+		ranges = append(ranges, MediaRange{Type: typ, Subtype: subtype, Params: params, Q: q})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].Q != ranges[j].Q {
+			return ranges[i].Q > ranges[j].Q
+		}
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+
+	return ranges
+}
+
+// NegotiateMediaType returns the entry of offered that the Accept header prefers. Each offered type takes the
+// q-value of the most specific range that matches it, and ties go to the earlier offer. An empty header accepts
+// the first offer. ok is false if none of the offered types is acceptable.
+func NegotiateMediaType(header string, offered []string) (match string, ok bool) {
+	if strings.TrimSpace(header) == "" {
+		if len(offered) == 0 {
+			return "", false
+		}
+		return offered[0], true
+	}
+
+	ranges := ParseAccept(header)
+
+	best, bestQ := "", 0.0
+	for _, offer := range offered {
+		q, specificity := 0.0, -1
+		for _, m := range ranges {
+			if m.specificity() > specificity && m.Matches(offer) {
+				q, specificity = m.Q, m.specificity()
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best, bestQ > 0
+}
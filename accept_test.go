@@ -0,0 +1,56 @@
+package ps
+
+import "testing"
+
+var parseAcceptTests = []struct {
+	name     string
+	header   string
+	expected []string
+}{
+	{name: "empty", header: ""},
+	{name: "ordered by q", header: "text/html;q=0.5, application/json", expected: []string{"application/json", "text/html"}},
+	{name: "ordered by specificity", header: "*/*, text/*, text/plain, text/plain;format=flowed", expected: []string{"text/plain; format=flowed", "text/plain", "text/*", "*/*"}},
+	{name: "q beats specificity", header: "text/plain;q=0.2, */*", expected: []string{"*/*", "text/plain"}},
+	{name: "malformed dropped", header: "application/json;q=2, text, */html, application/xml", expected: []string{"application/xml"}},
+	{name: "case folded", header: "Application/JSON", expected: []string{"application/json"}},
+}
+
+func TestParseAccept(t *testing.T) {
+	for _, e := range parseAcceptTests {
+		ranges := ParseAccept(e.header)
+		if len(ranges) != len(e.expected) {
+			t.Errorf("%s: expected %d ranges, got %v", e.name, len(e.expected), ranges)
+			continue
+		}
+		for i, m := range ranges {
+			if m.String() != e.expected[i] {
+				t.Errorf("%s: expected range %d to be %q, got %q", e.name, i, e.expected[i], m.String())
+			}
+		}
+	}
+}
+
+var negotiateMediaTypeTests = []struct {
+	name     string
+	header   string
+	offered  []string
+	expected string
+	ok       bool
+}{
+	{name: "empty header", header: "", offered: []string{"application/json", "text/csv"}, expected: "application/json", ok: true},
+	{name: "exact", header: "text/csv", offered: []string{"application/json", "text/csv"}, expected: "text/csv", ok: true},
+	{name: "highest q", header: "application/json;q=0.5, text/csv;q=0.9", offered: []string{"application/json", "text/csv"}, expected: "text/csv", ok: true},
+	{name: "most specific range decides", header: "text/*;q=0.9, text/csv;q=0.1, application/json;q=0.5", offered: []string{"text/csv", "application/json"}, expected: "application/json", ok: true},
+	{name: "excluded", header: "*/*, application/json;q=0", offered: []string{"application/json", "text/csv"}, expected: "text/csv", ok: true},
+	{name: "parameters", header: "application/json;version=2", offered: []string{"application/json;version=1", "application/json;version=2"}, expected: "application/json;version=2", ok: true},
+	{name: "not acceptable", header: "application/xml", offered: []string{"application/json"}},
+}
+
+func TestNegotiateMediaType(t *testing.T) {
+	for _, e := range negotiateMediaTypeTests {
+		got, ok := NegotiateMediaType(e.header, e.offered)
+		if got != e.expected || ok != e.ok {
+			t.Errorf("%s: expected %q %t, got %q %t", e.name, e.expected, e.ok, got, ok)
+		}
+	}
+}
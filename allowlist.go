@@ -0,0 +1,93 @@
+package ps
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// DisallowedFields controls what ReadJSON does with keys that AllowedFields does not list.
+type DisallowedFields int
+
+const (
+	// DropDisallowedFields removes the keys before the body is decoded, as though they had not been sent. This is
+	// the default.
+	DropDisallowedFields DisallowedFields = iota
+	// RejectDisallowedFields fails the request with a ValidationError naming each of them.
+	RejectDisallowedFields
+)
+
+// fieldAccess is how far AllowedFields lets a body into one key.
+type fieldAccess int
+
+const (
+	accessNone fieldAccess = iota
+	// accessSome allows the key, but only the keys below it that are listed themselves.
+	accessSome
+	accessAll
+)
+
+// fieldAccess reports how far AllowedFields lets a body into the key at path. Keys match case-insensitively, as
+// encoding/json matches them to fields.
+func (p *Parser) fieldAccess(path string) fieldAccess {
+	access := accessNone
+	for _, allowed := range p.AllowedFields {
+		if strings.EqualFold(allowed, path) {
+			return accessAll
+		}
+		if len(allowed) > len(path) && allowed[len(path)] == '.' && strings.EqualFold(allowed[:len(path)], path) {
+			access = accessSome
+		}
+	}
+	return access
+}
+
+// filterFields applies AllowedFields to body, returning it without the keys the list leaves out, or returning it
+// as it is with an error if there are any and DisallowedFields is RejectDisallowedFields. A body that is not valid
+// JSON is returned as it is, for the decoder to report.
+func (p *Parser) filterFields(body []byte) ([]byte, error) {
+	if len(p.AllowedFields) == 0 {
+		return body, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var tree any
+	if dec.Decode(&tree) != nil || checkEOF(dec) != nil {
+		return body, nil
+	}
+
+	var dropped []FieldError
+	p.dropFields(tree, "", &dropped)
+	if len(dropped) == 0 {
+		return body, nil
+	}
+	if p.DisallowedFields == RejectDisallowedFields {
+		sort.Slice(dropped, func(i, j int) bool { return dropped[i].Field < dropped[j].Field })
+		return body, &ValidationError{Fields: dropped}
+	}
+	return json.Marshal(tree)
+}
+
+// dropFields removes the keys of the objects in node, found at path, that AllowedFields leaves out, and records
+// each in dropped. Arrays are looked through, so that items.sku allows the sku of every item.
+func (p *Parser) dropFields(node any, path string, dropped *[]FieldError) {
+	switch n := node.(type) {
+	case map[string]any:
+		for key, child := range n {
+			childPath := joinPath(path, key)
+			switch p.fieldAccess(childPath) {
+			case accessNone:
+				delete(n, key)
+				*dropped = append(*dropped, FieldError{Field: childPath, Message: "is not an allowed field"})
+			case accessSome:
+				p.dropFields(child, childPath, dropped)
+			}
+		}
+	case []any:
+		for _, elem := range n {
+			p.dropFields(elem, path, dropped)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type accountUpdate struct {
+	Name    string `json:"name"`
+	IsAdmin bool   `json:"is_admin"`
+	Address struct {
+		City    string `json:"city"`
+		Country string `json:"country"`
+	} `json:"address"`
+	Phones []struct {
+		Number   string `json:"number"`
+		Verified bool   `json:"verified"`
+	} `json:"phones"`
+}
+
+var allowedFieldsTests = []struct {
+	name          string
+	json          string
+	policy        DisallowedFields
+	expected      string
+	errorExpected string
+}{
+	{name: "allowed only", json: `{"name": "Ann", "address": {"city": "Oslo"}}`, expected: "Ann false Oslo  0"},
+	{name: "dropped", json: `{"name": "Ann", "is_admin": true, "address": {"city": "Oslo", "country": "NO"}, "phones": [{"number": "555", "verified": true}]}`, expected: "Ann false Oslo  1 555 false"},
+	{name: "case variant", json: `{"NAME": "Ann", "IS_ADMIN": true}`, expected: "Ann false   0"},
+	{name: "rejected", json: `{"name": "Ann", "is_admin": true, "phones": [{"verified": true}], "address": {"country": "NO"}}`, policy: RejectDisallowedFields, errorExpected: "address.country is not an allowed field; is_admin is not an allowed field; phones.verified is not an allowed field"},
+	{name: "rejected clean", json: `{"name": "Ann"}`, policy: RejectDisallowedFields, expected: "Ann false   0"},
+	{name: "syntax error", json: `{"name": `, errorExpected: "body contains badly-formed JSON"},
+}
+
+func TestParser_ReadJSONAllowedFields(t *testing.T) {
+	for _, e := range allowedFieldsTests {
+		testParser := New(WithAllowUnknownFields(true), WithAllowedFields(e.policy, "name", "address.city", "phones.number"))
+
+		var got accountUpdate
+		err := testParser.ReadJSON(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.json)), &got)
+
+		if e.errorExpected != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), e.errorExpected) {
+				t.Errorf("%s: expected error %q, got %v", e.name, e.errorExpected, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+			continue
+		}
+		parts := []string{got.Name, strconv.FormatBool(got.IsAdmin), got.Address.City, got.Address.Country, strconv.Itoa(len(got.Phones))}
+		for _, phone := range got.Phones {
+			parts = append(parts, phone.Number, strconv.FormatBool(phone.Verified))
+		}
+		if s := strings.Join(parts, " "); s != e.expected {
+			t.Errorf("%s: expected %q, got %q", e.name, e.expected, s)
+		}
+	}
+}
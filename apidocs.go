@@ -0,0 +1,117 @@
+package ps
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// apiDocsPage is the interactive documentation page. It loads openapi.json from beside itself.
+//
+//go:embed assets/apidocs.html
+var apiDocsPage []byte
+
+// apiDocsPageTag is the entity tag of apiDocsPage.
+var apiDocsPageTag = entityTag(apiDocsPage)
+
+// APIDocs returns a handler serving doc under prefix: the document itself at prefix/openapi.json, and an
+// interactive page that lists its operations and schemas and can send requests to them at prefix/. A client
+// asking for the page with an Accept header that prefers JSON receives the document instead.
+//
+// The document is written with WriteJSON after any transform registered with RegisterVersion for *OpenAPI and the
+// negotiated version, so it follows the Parser's Pretty and versioning settings. Both responses carry an ETag and
+// are answered with 304 Not Modified when it matches; wrap the handler with Cache to keep them in a ResponseCache
+// as well. The document is encoded on every request, so operations added later are served without a restart.
+func (p *Parser) APIDocs(prefix string, doc *OpenAPI) http.Handler {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			_ = p.MethodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+
+		switch strings.TrimPrefix(r.URL.Path, prefix) {
+		case "/openapi.json":
+			p.serveSpec(w, r, doc)
+		case "", "/":
+			AddVary(w, "Accept")
+			switch mt, _ := NegotiateMediaType(r.Header.Get("Accept"), []string{"text/html", "application/json"}); mt {
+			case "text/html":
+				servePage(w, r)
+			case "application/json":
+				p.serveSpec(w, r, doc)
+			default:
+				_ = p.ErrorJSON(w, errors.New("the documentation is only available as text/html or application/json"), http.StatusNotAcceptable)
+			}
+		default:
+			_ = p.ErrorJSON(w, errors.New("not found"), http.StatusNotFound)
+		}
+	})
+}
+
+// MountAPIDocs registers APIDocs for doc on mux under prefix, such as "/docs".
+func (p *Parser) MountAPIDocs(mux *http.ServeMux, prefix string, doc *OpenAPI) {
+	mux.Handle(strings.TrimSuffix(prefix, "/")+"/", p.APIDocs(prefix, doc))
+}
+
+// serveSpec writes doc as JSON, transformed for the negotiated version.
+func (p *Parser) serveSpec(w http.ResponseWriter, r *http.Request, doc *OpenAPI) {
+	var data any = doc
+	if len(p.versions) > 0 {
+		var err error
+		if data, err = p.transformVersion(p.NegotiateVersion(w, r), doc); err != nil {
+			_ = p.ErrorJSON(w, err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Encode the document with encoding/json, so that settings such as EmptyFields cannot bend it out of shape.
+	b, err := json.Marshal(data)
+	if err != nil {
+		_ = p.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if notModified(w, r, entityTag(b)) {
+		return
+	}
+	_ = p.WriteJSON(w, http.StatusOK, json.RawMessage(b))
+}
+
+// servePage writes the documentation page.
+func servePage(w http.ResponseWriter, r *http.Request) {
+	if notModified(w, r, apiDocsPageTag) {
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(apiDocsPage)
+	}
+}
+
+// notModified sets the ETag header to tag and, if the request's If-None-Match header lists it, answers 304 Not
+// Modified and reports true.
+func notModified(w http.ResponseWriter, r *http.Request, tag string) bool {
+	w.Header().Set("ETag", tag)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	for _, match := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		if match = strings.TrimPrefix(strings.TrimSpace(match), "W/"); match == tag || match == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// entityTag returns a strong entity tag for b.
+func entityTag(b []byte) string {
+	sum := sha256.Sum256(b)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
@@ -0,0 +1,112 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var apiDocsTests = []struct {
+	name             string
+	method           string
+	target           string
+	accept           string
+	expectedStatus   int
+	expectedType     string
+	expectedContains string
+}{
+	{name: "page", method: http.MethodGet, target: "/docs/", accept: "text/html,*/*;q=0.8", expectedStatus: http.StatusOK,
+		expectedType: "text/html; charset=utf-8", expectedContains: `fetch("openapi.json"`},
+	{name: "page without accept", method: http.MethodGet, target: "/docs/", expectedStatus: http.StatusOK, expectedType: "text/html; charset=utf-8"},
+	{name: "document", method: http.MethodGet, target: "/docs/openapi.json", expectedStatus: http.StatusOK, expectedType: "application/json",
+		expectedContains: `"info":{"title":"Orders","version":"1.0.0"}`},
+	{name: "document negotiated", method: http.MethodGet, target: "/docs/", accept: "application/json", expectedStatus: http.StatusOK,
+		expectedType: "application/json", expectedContains: `"openapi":"3.1.0"`},
+	{name: "not acceptable", method: http.MethodGet, target: "/docs/", accept: "image/png", expectedStatus: http.StatusNotAcceptable},
+	{name: "not found", method: http.MethodGet, target: "/docs/other", expectedStatus: http.StatusNotFound},
+	{name: "method", method: http.MethodPost, target: "/docs/openapi.json", expectedStatus: http.StatusMethodNotAllowed},
+}
+
+func TestParser_MountAPIDocs(t *testing.T) {
+	var testParser Parser
+	spec := testParser.NewAPISpec("Orders", "1.0.0")
+	spec.Operation(http.MethodGet, "/owners/{id}", nil, specOwner{})
+
+	mux := http.NewServeMux()
+	testParser.MountAPIDocs(mux, "/docs", spec.Document())
+
+	for _, e := range apiDocsTests {
+		req := httptest.NewRequest(e.method, e.target, nil)
+		if e.accept != "" {
+			req.Header.Set("Accept", e.accept)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatus {
+			t.Errorf("%s: expected status %d, got %d: %s", e.name, e.expectedStatus, rr.Code, rr.Body.String())
+			continue
+		}
+		if e.expectedType != "" && rr.Header().Get("Content-Type") != e.expectedType {
+			t.Errorf("%s: expected Content-Type %s, got %s", e.name, e.expectedType, rr.Header().Get("Content-Type"))
+		}
+		if !strings.Contains(rr.Body.String(), e.expectedContains) {
+			t.Errorf("%s: expected the body to contain %s, got %s", e.name, e.expectedContains, rr.Body.String())
+		}
+	}
+}
+
+func TestParser_APIDocsNotModified(t *testing.T) {
+	var testParser Parser
+	spec := testParser.NewAPISpec("Orders", "1.0.0")
+	handler := testParser.APIDocs("/docs/", spec.Document())
+
+	for _, target := range []string{"/docs/openapi.json", "/docs/"} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		tag := rr.Header().Get("ETag")
+		if rr.Code != http.StatusOK || tag == "" {
+			t.Errorf("%s: expected a 200 response with an ETag, got %d %q", target, rr.Code, tag)
+			continue
+		}
+
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("If-None-Match", `"other", W/`+tag)
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+			t.Errorf("%s: expected 304 with no body, got %d %s", target, rr.Code, rr.Body.String())
+		}
+	}
+
+	// A changed document gets a new tag.
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/docs/openapi.json", nil))
+	before := rr.Header().Get("ETag")
+	spec.Operation(http.MethodDelete, "/owners/{id}", nil, nil)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/docs/openapi.json", nil))
+	if rr.Header().Get("ETag") == before || !strings.Contains(rr.Body.String(), `"delete"`) {
+		t.Errorf("expected the new operation with a new ETag, got %s %s", rr.Header().Get("ETag"), rr.Body.String())
+	}
+}
+
+func TestParser_APIDocsVersion(t *testing.T) {
+	var testParser Parser
+	testParser.RegisterVersion("1", &OpenAPI{}, func(data any) (any, error) {
+		doc := *data.(*OpenAPI)
+		doc.Info.Version = "1.0.0-legacy"
+		return &doc, nil
+	})
+	spec := testParser.NewAPISpec("Orders", "2.0.0")
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	req.Header.Set("API-Version", "1")
+	rr := httptest.NewRecorder()
+	spec.ServeHTTP(rr, req)
+
+	if !strings.Contains(rr.Body.String(), `"version":"1.0.0-legacy"`) || rr.Header().Get("API-Version") != "1" {
+		t.Errorf("expected the version 1 document, got %s", rr.Body.String())
+	}
+}
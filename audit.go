@@ -0,0 +1,159 @@
+package ps
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// redacted replaces the values of the keys an AuditLog redacts.
+const redacted = "[REDACTED]"
+
+// AuditRecord describes one state-changing request and its outcome, for an audit trail.
+type AuditRecord struct {
+	// Time is when the request arrived.
+	Time time.Time
+	// Actor is who made the request, as AuditLog.Actor found it.
+	Actor string
+	// Method and Route say what the request did.
+	Method string
+	Route  string
+	// Status is the status code of the response.
+	Status int
+	// RequestID is the ID the RequestID middleware gave the request, if it ran first.
+	RequestID string
+	// Request and Response are the bodies, with the keys AuditLog.Redact lists hidden. They are only kept if
+	// AuditLog.Payloads is set, and only if they are JSON.
+	Request  json.RawMessage
+	Response json.RawMessage
+}
+
+// AuditSink receives audit records. Implementations must be safe for concurrent use.
+type AuditSink interface {
+	// Record stores rec. ctx is the context of the request, which has been answered by the time it is called.
+	Record(ctx context.Context, rec AuditRecord) error
+}
+
+// AuditSinkFunc adapts a function to an AuditSink.
+type AuditSinkFunc func(ctx context.Context, rec AuditRecord) error
+
+// Record calls f.
+func (f AuditSinkFunc) Record(ctx context.Context, rec AuditRecord) error {
+	return f(ctx, rec)
+}
+
+// AuditLog configures the Audit middleware.
+type AuditLog struct {
+	// Sink receives a record of every POST, PUT, PATCH and DELETE request.
+	Sink AuditSink
+	// Actor returns who is making the request, usually from what authentication middleware stored in ctx.
+	Actor func(ctx context.Context) string
+	// Route returns the route a request is recorded under (default its path).
+	Route func(r *http.Request) string
+	// Payloads makes the records carry the request and response bodies.
+	Payloads bool
+	// Redact lists the keys whose values are hidden in the recorded bodies, with dotted paths such as
+	// card.number for nested ones; a path runs through arrays, applying to each of their elements. Keys are
+	// matched ignoring case, as ReadJSON binds them.
+	Redact []string
+}
+
+// Audit returns middleware that sends a record of every state-changing request to log.Sink once it has been
+// answered, whatever the outcome. The request body is read, subject to the Parser's size limit, and put back for
+// the handler. An error from the sink is logged to the Parser's Logger; the client has its response already.
+func (p *Parser) Audit(log *AuditLog) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			default:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rec := AuditRecord{Time: time.Now(), Method: r.Method, Route: r.URL.Path, RequestID: RequestIDFrom(r.Context())}
+			if log.Route != nil {
+				rec.Route = log.Route(r)
+			}
+			if log.Actor != nil {
+				rec.Actor = log.Actor(r.Context())
+			}
+
+			var body []byte
+			if log.Payloads && r.Body != nil {
+				var err error
+				body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, int64(p.maxPayload(r.Method))))
+				if err != nil {
+					_ = p.ErrorJSON(w, decodeError(err, p.maxPayload(r.Method)))
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			cw := &captureWriter{ResponseWriter: w, skipBody: !log.Payloads}
+			next.ServeHTTP(cw, r)
+
+			resp := cw.response()
+			rec.Status = resp.Status
+			if log.Payloads {
+				rec.Request = redactJSON(body, log.Redact)
+				rec.Response = redactJSON(resp.Body, log.Redact)
+			}
+			if err := log.Sink.Record(r.Context(), rec); err != nil {
+				p.logger().Error("audit record not stored", "error", err, "method", rec.Method, "route", rec.Route)
+			}
+		})
+	}
+}
+
+// redactJSON returns body with the values at paths replaced, or nil if body is not JSON.
+func redactJSON(body []byte, paths []string) json.RawMessage {
+	body = bytes.TrimSpace(body)
+	if !json.Valid(body) {
+		return nil
+	}
+	if len(paths) == 0 {
+		return bytes.Clone(body)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var tree any
+	if err := dec.Decode(&tree); err != nil {
+		return nil
+	}
+	for _, path := range paths {
+		redactPath(tree, strings.Split(path, "."))
+	}
+	out, err := json.Marshal(tree)
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+// redactPath replaces the value at path below node, descending through arrays. Keys are compared ignoring case,
+// so every key encoding/json would bind to a field is hidden.
+func redactPath(node any, path []string) {
+	switch v := node.(type) {
+	case []any:
+		for _, elem := range v {
+			redactPath(elem, path)
+		}
+	case map[string]any:
+		for key, child := range v {
+			if !strings.EqualFold(key, path[0]) {
+				continue
+			}
+			if len(path) == 1 {
+				v[key] = redacted
+			} else {
+				redactPath(child, path[1:])
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package ps
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type actorKey struct{}
+
+var auditTests = []struct {
+	name             string
+	method           string
+	body             string
+	recorded         bool
+	statusExpected   int
+	requestExpected  string
+	responseExpected string
+}{
+	{name: "create", method: http.MethodPost, body: `{"name": "Jack", "card": {"number": "4111", "expiry": "12/30"}}`, recorded: true, statusExpected: http.StatusCreated,
+		requestExpected: `{"card":{"expiry":"12/30","number":"[REDACTED]"},"name":"Jack"}`, responseExpected: `{"card":{"expiry":"12/30","number":"[REDACTED]"},"name":"Jack"}`},
+	{name: "failed", method: http.MethodPut, body: `{"name":`, recorded: true, statusExpected: http.StatusBadRequest,
+		responseExpected: `{"error":true,"message":"body contains badly-formed JSON"}`},
+	{name: "key case", method: http.MethodPost, body: `{"PASSWORD": "hunter2", "Card": {"Number": "4111"}}`, recorded: true, statusExpected: http.StatusCreated,
+		requestExpected: `{"Card":{"Number":"[REDACTED]"},"PASSWORD":"[REDACTED]"}`, responseExpected: `{"Card":{"Number":"[REDACTED]"},"PASSWORD":"[REDACTED]"}`},
+	{name: "delete", method: http.MethodDelete, recorded: true, statusExpected: http.StatusCreated, responseExpected: "null"},
+	{name: "read", method: http.MethodGet},
+}
+
+func TestParser_Audit(t *testing.T) {
+	var testParser Parser
+	var records []AuditRecord
+	audit := &AuditLog{
+		Sink: AuditSinkFunc(func(_ context.Context, rec AuditRecord) error {
+			records = append(records, rec)
+			return nil
+		}),
+		Actor:    func(ctx context.Context) string { s, _ := ctx.Value(actorKey{}).(string); return s },
+		Payloads: true,
+		Redact:   []string{"card.number", "password"},
+	}
+	handler := testParser.Audit(audit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var data map[string]any
+		if r.Method != http.MethodDelete {
+			if err := testParser.ReadJSON(w, r, &data); err != nil {
+				_ = testParser.ErrorJSON(w, err)
+				return
+			}
+		}
+		_ = testParser.WriteJSON(w, http.StatusCreated, data)
+	}))
+
+	for _, e := range auditTests {
+		records = nil
+		req := httptest.NewRequest(e.method, "/users", strings.NewReader(e.body))
+		req = req.WithContext(context.WithValue(req.Context(), actorKey{}, "admin"))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if !e.recorded {
+			if len(records) != 0 {
+				t.Errorf("%s: expected no record, got %+v", e.name, records)
+			}
+			continue
+		}
+		if len(records) != 1 {
+			t.Errorf("%s: expected one record, got %d", e.name, len(records))
+			continue
+		}
+		rec := records[0]
+		if rec.Actor != "admin" || rec.Method != e.method || rec.Route != "/users" || rec.Status != e.statusExpected {
+			t.Errorf("%s: unexpected record %+v", e.name, rec)
+		}
+		if string(rec.Request) != e.requestExpected {
+			t.Errorf("%s: expected request %s, got %s", e.name, e.requestExpected, rec.Request)
+		}
+		if string(rec.Response) != e.responseExpected {
+			t.Errorf("%s: expected response %s, got %s", e.name, e.responseExpected, rec.Response)
+		}
+	}
+}
+
+func TestParser_AuditWithoutPayloads(t *testing.T) {
+	var testParser Parser
+	var records []AuditRecord
+	var captured *captureWriter
+	audit := &AuditLog{Sink: AuditSinkFunc(func(_ context.Context, rec AuditRecord) error {
+		records = append(records, rec)
+		return nil
+	})}
+	handler := testParser.Audit(audit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		captured, _ = w.(*captureWriter)
+		_ = testParser.WriteJSON(w, http.StatusCreated, map[string]string{"password": "hunter2"})
+	}))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{}`)))
+
+	if len(records) != 1 || records[0].Status != http.StatusCreated || records[0].Response != nil {
+		t.Errorf("unexpected records %+v", records)
+	}
+	if captured == nil || captured.body.Len() != 0 {
+		t.Error("expected the response body not to be kept")
+	}
+	if rr.Body.String() != `{"password":"hunter2"}` {
+		t.Errorf("unexpected response %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,104 @@
+package ps
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrUnexpectedBody is returned when a request whose method does not take a body arrives with one.
+var ErrUnexpectedBody = errors.New("request must not have a body")
+
+// UnexpectedBody controls what happens when a GET, HEAD or DELETE request carries a body.
+type UnexpectedBody int
+
+const (
+	// IgnoreUnexpectedBody leaves the body for the handler to ignore. This is the default.
+	IgnoreUnexpectedBody UnexpectedBody = iota
+	// RejectUnexpectedBody fails the request with ErrUnexpectedBody.
+	RejectUnexpectedBody
+	// StripUnexpectedBody discards the body before the handler sees it.
+	StripUnexpectedBody
+)
+
+// bodylessMethods are the methods whose requests are not expected to have a body.
+var bodylessMethods = []string{http.MethodGet, http.MethodHead, http.MethodDelete}
+
+// BodyRule states whether requests using a method must carry a body.
+type BodyRule int
+
+const (
+	// BodyRequired makes an empty body an error. This is the default.
+	BodyRequired BodyRule = iota
+	// BodyOptional lets ReadJSON return without decoding when the body is empty.
+	BodyOptional
+	// BodyForbidden makes any body an error wrapping ErrUnexpectedBody.
+	BodyForbidden
+)
+
+// MethodPolicy overrides the body rules of a Parser for requests using one HTTP method, so that, say, POST can
+// accept large uploads while PATCH stays small and GET takes no body at all.
+type MethodPolicy struct {
+	// MaxJSONSize overrides Parser.MaxJSONSize when it is non-zero.
+	MaxJSONSize int
+	// ContentTypes lists the accepted media types. If it is empty, only application/json is accepted.
+	ContentTypes []string
+	// Body states whether a body is required, optional or forbidden.
+	Body BodyRule
+}
+
+// CheckBody applies the Parser's UnexpectedBody policy to r, along with any BodyForbidden rule configured for
+// its method in Methods. It returns an error wrapping ErrUnexpectedBody if the request is rejected, and replaces
+// the body with http.NoBody if the policy strips it.
+func (p *Parser) CheckBody(r *http.Request) error {
+	forbidden := p.Methods[r.Method].Body == BodyForbidden
+	bodyless := p.UnexpectedBody != IgnoreUnexpectedBody && containsFold(bodylessMethods, r.Method)
+	if !(forbidden || bodyless) || !hasBody(r) {
+		return nil
+	}
+
+	if p.UnexpectedBody == StripUnexpectedBody {
+		_ = r.Body.Close()
+		r.Body = http.NoBody
+		r.ContentLength = 0
+		return nil
+	}
+
+	return fmt.Errorf("%s %w", r.Method, ErrUnexpectedBody)
+}
+
+// CheckBodies returns middleware that runs CheckBody on every request, answering rejected ones with a 400 JSON
+// error.
+func (p *Parser) CheckBodies() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := p.CheckBody(r); err != nil {
+				_ = p.ErrorJSON(w, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// hasBody reports whether r carries a non-empty body. When the length is unknown (e.g. a chunked body) it peeks
+// at the first byte, leaving the body intact for later readers.
+func hasBody(r *http.Request) bool {
+	if r.Body == nil || r.Body == http.NoBody {
+		return false
+	}
+	if r.ContentLength != -1 {
+		return r.ContentLength > 0
+	}
+
+	br := bufio.NewReaderSize(r.Body, 16)
+	_, err := br.Peek(1)
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{br, r.Body}
+
+	return err == nil
+}
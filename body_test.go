@@ -0,0 +1,154 @@
+package ps
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var checkBodyTests = []struct {
+	name          string
+	policy        UnexpectedBody
+	method        string
+	body          string
+	chunked       bool
+	errorExpected bool
+	stripped      bool
+}{
+	{name: "ignored by default", method: http.MethodGet, body: `{"foo": "bar"}`},
+	{name: "reject get", policy: RejectUnexpectedBody, method: http.MethodGet, body: `{"foo": "bar"}`, errorExpected: true},
+	{name: "reject chunked delete", policy: RejectUnexpectedBody, method: http.MethodDelete, body: `{"foo": "bar"}`, chunked: true, errorExpected: true},
+	{name: "reject without body", policy: RejectUnexpectedBody, method: http.MethodGet},
+	{name: "reject empty chunked", policy: RejectUnexpectedBody, method: http.MethodGet, chunked: true},
+	{name: "post is fine", policy: RejectUnexpectedBody, method: http.MethodPost, body: `{"foo": "bar"}`},
+	{name: "strip head", policy: StripUnexpectedBody, method: http.MethodHead, body: `{"foo": "bar"}`, stripped: true},
+}
+
+func TestParser_CheckBody(t *testing.T) {
+	for _, e := range checkBodyTests {
+		testParser := Parser{UnexpectedBody: e.policy}
+
+		req := httptest.NewRequest(e.method, "/", strings.NewReader(e.body))
+		if e.chunked {
+			req.ContentLength = -1
+		}
+
+		err := testParser.CheckBody(req)
+		if e.errorExpected && !errors.Is(err, ErrUnexpectedBody) {
+			t.Errorf("%s: expected ErrUnexpectedBody, got %v", e.name, err)
+		}
+		if !e.errorExpected && err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+		}
+
+		body, _ := io.ReadAll(req.Body)
+		if e.stripped && len(body) != 0 {
+			t.Errorf("%s: expected body to be stripped, got %q", e.name, body)
+		}
+		if !e.stripped && string(body) != e.body {
+			t.Errorf("%s: expected body to be preserved, got %q", e.name, body)
+		}
+	}
+}
+
+func TestParser_CheckBodies(t *testing.T) {
+	testParser := Parser{UnexpectedBody: RejectUnexpectedBody}
+
+	handler := testParser.CheckBodies()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler should not be called")
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", strings.NewReader(`{}`)))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rr.Code)
+	}
+}
+
+var methodPolicyTests = []struct {
+	name          string
+	method        string
+	json          string
+	contentType   string
+	errorExpected bool
+	decoded       bool
+}{
+	{name: "post within limit", method: http.MethodPost, json: `{"foo": "a longer value"}`, decoded: true},
+	{name: "patch over limit", method: http.MethodPatch, json: `{"foo": "a longer value"}`, errorExpected: true},
+	{name: "patch within limit", method: http.MethodPatch, json: `{"foo": "x"}`, decoded: true},
+	{name: "patch merge content type", method: http.MethodPatch, json: `{"foo": "x"}`, contentType: "application/merge-patch+json", decoded: true},
+	{name: "post merge content type", method: http.MethodPost, json: `{"foo": "x"}`, contentType: "application/merge-patch+json", errorExpected: true},
+	{name: "json with charset", method: http.MethodPost, json: `{"foo": "x"}`, contentType: "application/json; charset=utf-8", decoded: true},
+	{name: "optional body missing", method: http.MethodPut},
+	{name: "optional body present", method: http.MethodPut, json: `{"foo": "x"}`, decoded: true},
+	{name: "required body missing", method: http.MethodPost, errorExpected: true},
+	{name: "forbidden body", method: http.MethodGet, json: `{"foo": "x"}`, errorExpected: true},
+	{name: "forbidden body missing", method: http.MethodGet},
+}
+
+func TestParser_ReadJSONMethodPolicies(t *testing.T) {
+	testParser := Parser{
+		Methods: map[string]MethodPolicy{
+			http.MethodPatch: {MaxJSONSize: 16, ContentTypes: []string{"application/json", "application/merge-patch+json"}},
+			http.MethodPut:   {Body: BodyOptional},
+			http.MethodGet:   {Body: BodyForbidden},
+		},
+	}
+
+	for _, e := range methodPolicyTests {
+		var decodedJSON struct {
+			Foo string `json:"foo"`
+		}
+
+		req := httptest.NewRequest(e.method, "/", strings.NewReader(e.json))
+		if e.contentType != "" {
+			req.Header.Set("Content-Type", e.contentType)
+		}
+
+		err := testParser.ReadJSON(httptest.NewRecorder(), req, &decodedJSON)
+		if e.errorExpected && err == nil {
+			t.Errorf("%s: error expected, but none received", e.name)
+		}
+		if !e.errorExpected && err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+		}
+		if e.decoded != (decodedJSON.Foo != "") {
+			t.Errorf("%s: expected decoded to be %t", e.name, e.decoded)
+		}
+	}
+}
+
+func TestParser_ReadJSONAllowEmptyBody(t *testing.T) {
+	testParser := Parser{AllowEmptyBody: true}
+
+	decodedJSON := struct {
+		Foo string `json:"foo"`
+	}{Foo: "stale"}
+	req := httptest.NewRequest(http.MethodPost, "/resources/1/publish", nil)
+	if err := testParser.ReadJSON(httptest.NewRecorder(), req, &decodedJSON); err != nil {
+		t.Fatalf("error not expected, but one received: %v", err)
+	}
+	if decodedJSON.Foo != "" {
+		t.Errorf("expected the zero value, got %+v", decodedJSON)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/resources/1/publish", strings.NewReader(`{"foo": "x"}`))
+	if err := testParser.ReadJSON(httptest.NewRecorder(), req, &decodedJSON); err != nil || decodedJSON.Foo != "x" {
+		t.Errorf("expected a present body to be decoded, got %+v and %v", decodedJSON, err)
+	}
+
+	// Whitespace is not an empty body, so it is still an error.
+	req = httptest.NewRequest(http.MethodPost, "/resources/1/publish", strings.NewReader(" "))
+	if err := testParser.ReadJSON(httptest.NewRecorder(), req, &decodedJSON); err == nil {
+		t.Error("expected an error for a blank body")
+	}
+
+	var notPointer struct{}
+	if err := testParser.ReadJSON(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil), notPointer); err == nil {
+		t.Error("expected an error for a destination that is not a pointer")
+	}
+}
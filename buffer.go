@@ -0,0 +1,76 @@
+package ps
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// maxPooledBuffer is the largest buffer returned to the pool; bigger ones, from rare large bodies, are left to the
+// garbage collector rather than pinned in memory.
+const maxPooledBuffer = 1 << 20
+
+// bodyBuffers holds buffers for reading whole request bodies.
+var bodyBuffers = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// readBody reads all of body into a pooled buffer, which the caller must hand back with releaseBody once nothing
+// refers to its bytes. sizeHint, when positive, is the expected size, such as a request's Content-Length.
+func readBody(body io.Reader, sizeHint int64) (*bytes.Buffer, error) {
+	buf := bodyBuffers.Get().(*bytes.Buffer)
+	if sizeHint > 0 && sizeHint <= maxPooledBuffer {
+		buf.Grow(int(sizeHint) + bytes.MinRead)
+	}
+	if _, err := buf.ReadFrom(body); err != nil {
+		releaseBody(buf)
+		return nil, err
+	}
+	return buf, nil
+}
+
+// releaseBody returns buf to the pool.
+func releaseBody(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	buf.Reset()
+	bodyBuffers.Put(buf)
+}
+
+// readLimitedBody reads the body of r into a pooled buffer, under the size limit for its method, and checks its
+// digest and structure, for readers that must look at a whole envelope before decoding the payload inside it. The
+// caller hands the buffer back with releaseBody. It also returns the limit, for decodeBody.
+func (p *Parser) readLimitedBody(w http.ResponseWriter, r *http.Request) (*bytes.Buffer, int, error) {
+	maxBytes := p.maxPayload(r.Method)
+	if err := p.checkEncoding(r); err != nil {
+		return nil, maxBytes, err
+	}
+	if r.ContentLength > int64(maxBytes) {
+		return nil, maxBytes, decodeError(&http.MaxBytesError{Limit: int64(maxBytes)}, maxBytes)
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+	buf, err := readBody(r.Body, r.ContentLength)
+	if err != nil {
+		return nil, maxBytes, decodeError(err, maxBytes)
+	}
+	p.count(bytesRead, int64(buf.Len()))
+	if err := p.checkDigest(r, buf.Bytes()); err != nil {
+		releaseBody(buf)
+		return nil, maxBytes, err
+	}
+	plain, err := p.decompressBuffer(r, buf, maxBytes)
+	if plain != buf {
+		releaseBody(buf)
+	}
+	if err != nil {
+		return nil, maxBytes, err
+	}
+	buf = plain
+	if err := p.checkStructure(buf.Bytes()); err != nil {
+		releaseBody(buf)
+		return nil, maxBytes, err
+	}
+	return buf, maxBytes, nil
+}
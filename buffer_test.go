@@ -0,0 +1,40 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParser_ReadJSONContentLength(t *testing.T) {
+	testParser := Parser{MaxJSONSize: 16}
+	body := &countingReader{r: strings.NewReader(`{"foo":"` + strings.Repeat("x", 64) + `"}`)}
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.ContentLength = 74
+
+	var decoded map[string]string
+	err := testParser.ReadJSON(httptest.NewRecorder(), req, &decoded)
+	if err == nil || err.Error() != "body must not be larger than 16 bytes" {
+		t.Errorf("expected the body to be refused, got %v", err)
+	}
+	if body.n != 0 {
+		t.Errorf("expected nothing to be read from an oversized body, read %d bytes", body.n)
+	}
+}
+
+func TestReadBody(t *testing.T) {
+	buf, err := readBody(strings.NewReader("hello"), 5)
+	if err != nil || buf.String() != "hello" {
+		t.Fatalf("expected hello, got %q and %v", buf.String(), err)
+	}
+	releaseBody(buf)
+
+	// A reused buffer starts empty.
+	buf, _ = readBody(strings.NewReader("hi"), 0)
+	if buf.String() != "hi" {
+		t.Errorf("expected a reset buffer, got %q", buf.String())
+	}
+	releaseBody(buf)
+
+}
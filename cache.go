@@ -0,0 +1,190 @@
+package ps
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResponseCache stores serialized responses for the Cache middleware. Entries are grouped by URL path, so that
+// every variant of a path can be invalidated at once. Implementations must be safe for concurrent use.
+type ResponseCache interface {
+	// Get returns the response stored for variant of path, if there is one that has not expired.
+	Get(ctx context.Context, path, variant string) (StoredResponse, bool, error)
+	// Set stores the response for variant of path, to expire after ttl.
+	Set(ctx context.Context, path, variant string, resp StoredResponse, ttl time.Duration) error
+	// Invalidate removes every variant stored for path.
+	Invalidate(ctx context.Context, path string) error
+}
+
+// Cache returns middleware that serves GET and HEAD requests from store, so that identical payloads are not
+// serialized again for every request. Responses are cached for ttl, keyed by method, path, query, the headers the
+// Parser negotiates on (Accept, the version header and, if Languages is set, the negotiated language), and every
+// other header the stored response lists in Vary, such as the tenant header RequireTenant adds. Only 200 responses
+// without a Set-Cookie header, and not varying on "*", are stored. Requests with an Authorization or Cookie header
+// may get a response meant for that user alone, so they bypass the cache. Call store.Invalidate when the data
+// behind a path changes.
+//
+// Responses carry an X-Cache header of "hit" or "miss".
+func (p *Parser) Cache(store ResponseCache, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead || r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			variant := p.cacheVariant(r)
+
+			// The headers the response varies on are only known once it has been produced, so they are stored
+			// under the base variant, and the response under the base variant and their values.
+			if vary, ok, err := store.Get(r.Context(), r.URL.Path, "vary\n"+variant); err == nil && ok {
+				key := variant + p.varyValues(r, vary.Header.Values("Vary"))
+				if stored, ok, err := store.Get(r.Context(), r.URL.Path, key); err == nil && ok {
+					w.Header().Set("X-Cache", "hit")
+					_ = stored.replay(w)
+					return
+				}
+			}
+
+			w.Header().Set("X-Cache", "miss")
+			cw := &captureWriter{ResponseWriter: w}
+			next.ServeHTTP(cw, r)
+
+			resp := cw.response()
+			vary := varyFields(resp.Header)
+			if resp.Status == http.StatusOK && resp.Header.Get("Set-Cookie") == "" && !containsFold(vary, "*") {
+				resp.Header.Del("X-Cache")
+				resp.Header.Del("Server-Timing")
+				_ = store.Set(r.Context(), r.URL.Path, "vary\n"+variant, StoredResponse{Header: http.Header{"Vary": vary}}, ttl)
+				_ = store.Set(r.Context(), r.URL.Path, variant+p.varyValues(r, vary), resp, ttl)
+			}
+		})
+	}
+}
+
+// cacheVariant identifies the representation of r's path that r asks for, by the headers the Parser negotiates on.
+func (p *Parser) cacheVariant(r *http.Request) string {
+	var language string
+	if len(p.Languages) > 0 {
+		language, _ = ParseAcceptLanguage(r.Header.Get("Accept-Language")).Match(p.Languages)
+	}
+
+	return strings.Join([]string{
+		r.Method,
+		r.URL.Query().Encode(),
+		r.Header.Get("Accept"),
+		r.Header.Get(p.versionHeader()),
+		language,
+	}, "\n")
+}
+
+// varyValues returns the values r has for the headers in vary that cacheVariant does not already cover.
+func (p *Parser) varyValues(r *http.Request, vary []string) string {
+	var b strings.Builder
+	for _, name := range vary {
+		switch {
+		case strings.EqualFold(name, "Accept"), strings.EqualFold(name, p.versionHeader()),
+			strings.EqualFold(name, "Accept-Language") && len(p.Languages) > 0:
+			continue
+		}
+		b.WriteString("\n" + http.CanonicalHeaderKey(name) + ": " + strings.Join(r.Header.Values(name), ", "))
+	}
+	return b.String()
+}
+
+// defaultMaxCacheEntries is the number of responses a MemoryResponseCache holds if none is given.
+const defaultMaxCacheEntries = 10000
+
+// MemoryResponseCache is a ResponseCache that keeps responses in memory, up to a fixed number, dropping the least
+// recently used one to make room for another. It suits a single instance; deployments with several instances
+// need a shared cache, or must invalidate each instance.
+type MemoryResponseCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]map[string]*list.Element
+	lru        list.List // of *cacheEntry, most recently used first
+}
+
+// cacheEntry is a response held by a MemoryResponseCache.
+type cacheEntry struct {
+	path, variant string
+	memoryEntry
+}
+
+// NewMemoryResponseCache returns an empty MemoryResponseCache that holds up to maxEntries responses, or 10000 if
+// maxEntries is not positive.
+func NewMemoryResponseCache(maxEntries int) *MemoryResponseCache {
+	if maxEntries <= 0 {
+		maxEntries = defaultMaxCacheEntries
+	}
+	return &MemoryResponseCache{maxEntries: maxEntries, entries: make(map[string]map[string]*list.Element)}
+}
+
+// Get returns the response stored for variant of path, if it has not expired.
+func (c *MemoryResponseCache) Get(_ context.Context, path, variant string) (StoredResponse, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[path][variant]
+	if !ok {
+		return StoredResponse{}, false, nil
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(elem)
+		return StoredResponse{}, false, nil
+	}
+
+	c.lru.MoveToFront(elem)
+	return entry.resp, true, nil
+}
+
+// Set stores the response for variant of path, dropping the least recently used response if the cache is full.
+func (c *MemoryResponseCache) Set(_ context.Context, path, variant string, resp StoredResponse, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := memoryEntry{resp: resp, expires: time.Now().Add(ttl)}
+	if elem, ok := c.entries[path][variant]; ok {
+		elem.Value.(*cacheEntry).memoryEntry = entry
+		c.lru.MoveToFront(elem)
+		return nil
+	}
+
+	for c.lru.Len() >= c.maxEntries {
+		c.remove(c.lru.Back())
+	}
+	variants := c.entries[path]
+	if variants == nil {
+		variants = make(map[string]*list.Element)
+		c.entries[path] = variants
+	}
+	variants[variant] = c.lru.PushFront(&cacheEntry{path: path, variant: variant, memoryEntry: entry})
+
+	return nil
+}
+
+// Invalidate removes every variant stored for path.
+func (c *MemoryResponseCache) Invalidate(_ context.Context, path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, elem := range c.entries[path] {
+		c.lru.Remove(elem)
+	}
+	delete(c.entries, path)
+	return nil
+}
+
+// remove drops the response held in elem.
+func (c *MemoryResponseCache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	variants := c.entries[entry.path]
+	delete(variants, entry.variant)
+	if len(variants) == 0 {
+		delete(c.entries, entry.path)
+	}
+}
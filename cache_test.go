@@ -0,0 +1,127 @@
+package ps
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParser_Cache(t *testing.T) {
+	testParser := Parser{Languages: []string{"en", "fr"}}
+	store := NewMemoryResponseCache(0)
+	calls := 0
+
+	handler := testParser.Cache(store, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		language := testParser.NegotiateLanguage(w, r)
+		testParser.WriteJSON(w, http.StatusOK, map[string]any{"language": language, "calls": calls})
+	}))
+
+	get := func(target, language string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Accept-Language", language)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := get("/countries?b=2&a=1", "en")
+	second := get("/countries?a=1&b=2", "en-GB")
+	if second.Header().Get("X-Cache") != "hit" || second.Body.String() != first.Body.String() || calls != 1 {
+		t.Errorf("expected the same variant to be served from the cache, got %s after %d calls", second.Body.String(), calls)
+	}
+	if second.Header().Get("Content-Language") != "en" {
+		t.Error("expected the cached response to keep its headers")
+	}
+
+	if rr := get("/countries?a=1&b=2", "fr"); rr.Header().Get("X-Cache") != "miss" || calls != 2 {
+		t.Errorf("expected a different language to miss the cache, got %s", rr.Body.String())
+	}
+	if rr := get("/countries", "en"); rr.Header().Get("X-Cache") != "miss" || calls != 3 {
+		t.Errorf("expected a different query to miss the cache, got %s", rr.Body.String())
+	}
+
+	store.Invalidate(context.Background(), "/countries")
+	if rr := get("/countries?a=1&b=2", "en"); rr.Header().Get("X-Cache") != "miss" || calls != 4 {
+		t.Errorf("expected invalidation to clear every variant, got %s", rr.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/countries", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Header().Get("X-Cache") != "" || calls != 5 {
+		t.Error("expected an authorized request to bypass the cache")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/countries", nil)
+	req.Header.Set("Cookie", "session=abc")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Header().Get("X-Cache") != "" || calls != 6 {
+		t.Error("expected a request with cookies to bypass the cache")
+	}
+}
+
+func TestParser_CacheVary(t *testing.T) {
+	var testParser Parser
+	guard := &TenantGuard{}
+
+	handler := testParser.RequireTenant(guard)(testParser.Cache(NewMemoryResponseCache(0), time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		testParser.WriteJSON(w, http.StatusOK, TenantFrom(r.Context()))
+	})))
+
+	get := func(tenant string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/invoices", nil)
+		req.Header.Set("X-Tenant-ID", tenant)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	get("acme")
+	if rr := get("globex"); rr.Header().Get("X-Cache") != "miss" || rr.Body.String() != `"globex"` {
+		t.Errorf("expected another tenant to miss the cache, got %s %s", rr.Header().Get("X-Cache"), rr.Body.String())
+	}
+	if rr := get("acme"); rr.Header().Get("X-Cache") != "hit" || rr.Body.String() != `"acme"` {
+		t.Errorf("expected the tenant's own response from the cache, got %s %s", rr.Header().Get("X-Cache"), rr.Body.String())
+	}
+}
+
+func TestMemoryResponseCache_MaxEntries(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryResponseCache(2)
+
+	_ = store.Set(ctx, "/a", "", StoredResponse{Status: http.StatusOK}, time.Minute)
+	_ = store.Set(ctx, "/b", "", StoredResponse{Status: http.StatusOK}, time.Minute)
+	_, _, _ = store.Get(ctx, "/a", "")
+	_ = store.Set(ctx, "/c", "", StoredResponse{Status: http.StatusOK}, time.Minute)
+
+	for path, expected := range map[string]bool{"/a": true, "/b": false, "/c": true} {
+		if _, ok, _ := store.Get(ctx, path, ""); ok != expected {
+			t.Errorf("%s: expected stored to be %t", path, expected)
+		}
+	}
+	if len(store.entries) != 2 || store.lru.Len() != 2 {
+		t.Errorf("expected two entries, got %d paths and %d responses", len(store.entries), store.lru.Len())
+	}
+}
+
+func TestParser_CacheRequestID(t *testing.T) {
+	var testParser Parser
+	handler := testParser.RequestID()(testParser.Cache(NewMemoryResponseCache(0), time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = testParser.WriteJSON(w, http.StatusOK, "ok")
+	})))
+
+	for _, id := range []string{"req-1", "req-2"} {
+		req := httptest.NewRequest(http.MethodGet, "/countries", nil)
+		req.Header.Set("X-Request-ID", id)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if got := rr.Header().Values("X-Request-ID"); len(got) != 1 || got[0] != id {
+			t.Errorf("%s: expected the current request ID, got %q (X-Cache %s)", id, got, rr.Header().Get("X-Cache"))
+		}
+	}
+}
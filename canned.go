@@ -0,0 +1,86 @@
+package ps
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// builtinCanned are the messages of the errors ReadJSON returns most often, whose responses ErrorJSON encodes
+// once and reuses. Errors naming a size limit, a *RequestTooLargeError, are reused too, as a Parser has only a
+// few limits.
+var builtinCanned = map[string]bool{
+	"body contains badly-formed JSON":                 true,
+	"body must not be empty":                          true,
+	"body must only contain a single JSON value":      true,
+	"the Content-Type header is missing":              true,
+	"the Content-Type header is not application/json": true,
+}
+
+// cannedKey identifies an error response: the same message with the same status always encodes the same way,
+// as long as the settings that shape the body are the same too. They are part of the key so that a response
+// encoded before one of them changed is not sent after.
+type cannedKey struct {
+	status     int
+	message    string
+	disclosure Disclosure
+	encoding   encodeOptions
+	canonical  bool
+	pretty     bool
+	sortKeys   bool
+}
+
+// RegisterCannedError has ErrorJSON encode the response for errors with the message of err once per status, and
+// reuse the bytes, as it does for the commonest errors ReadJSON returns. It suits sentinel errors that are sent
+// often, such as when a dependency is down and every request fails the same way, so that an error storm does not
+// add encoding load.
+//
+// Responses are only reused when nothing varies between them: not under DebugDisclosure, and not while any
+// BeforeEncode hook or API version transform is registered, or for a response carrying warnings or field errors.
+func (p *Parser) RegisterCannedError(err error) {
+	p.mustNotBeFrozen("RegisterCannedError")
+	if p.canned == nil {
+		p.canned = make(map[string]bool)
+	}
+	p.canned[err.Error()] = true
+}
+
+// cannedError returns the encoded response for err with status, encoding it on first use, or nil if the response
+// cannot be reused.
+func (p *Parser) cannedError(w http.ResponseWriter, err error, status int) (*PrecomputedJSON, error) {
+	message := err.Error()
+	var tooLarge *RequestTooLargeError
+	if !builtinCanned[message] && !p.canned[message] && !errors.As(err, &tooLarge) {
+		return nil, nil
+	}
+	if p.Disclosure == DebugDisclosure || len(p.beforeEncode) > 0 || len(p.versions) > 0 ||
+		len(w.Header()["Warning"]) > 0 || fieldErrors(err) != nil {
+		return nil, nil
+	}
+
+	cache := p.cannedCache()
+	key := cannedKey{status: status, message: message, disclosure: p.Disclosure, encoding: p.encodeOptions(),
+		canonical: p.Canonical, pretty: p.Pretty, sortKeys: p.SortKeys}
+	if pre, ok := cache.Load(key); ok {
+		if p.Disclosure == ProductionDisclosure {
+			p.logHidden(err, status)
+		}
+		return pre.(*PrecomputedJSON), nil
+	}
+	pre, merr := p.MarshalOnce(p.disclose(JSONResponse{Error: true, Message: message}, err, status))
+	if merr != nil {
+		return nil, merr
+	}
+	actual, _ := cache.LoadOrStore(key, pre)
+	return actual.(*PrecomputedJSON), nil
+}
+
+// cannedCache returns the Parser's encoded error responses, allocating the cache on first use. Clone starts the
+// copy afresh, as its settings may differ.
+func (p *Parser) cannedCache() *sync.Map {
+	if c := p.cannedBodies.Load(); c != nil {
+		return c
+	}
+	p.cannedBodies.CompareAndSwap(nil, new(sync.Map))
+	return p.cannedBodies.Load()
+}
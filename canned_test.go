@@ -0,0 +1,76 @@
+package ps
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var errUpstreamDown = errors.New("the billing service is unavailable")
+
+func TestParser_RegisterCannedError(t *testing.T) {
+	testParser := New(WithPretty(true))
+	testParser.RegisterCannedError(errUpstreamDown)
+
+	var bodies []string
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		if err := testParser.ErrorJSON(rr, errUpstreamDown, http.StatusServiceUnavailable); err != nil {
+			t.Fatal(err)
+		}
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("expected status 503, got %d", rr.Code)
+		}
+		bodies = append(bodies, rr.Body.String())
+	}
+	if expected := "{\n  \"error\": true,\n  \"message\": \"the billing service is unavailable\"\n}"; bodies[0] != expected || bodies[1] != expected {
+		t.Errorf("expected %q twice, got %q", expected, bodies)
+	}
+
+	n := 0
+	testParser.cannedCache().Range(func(any, any) bool { n++; return true })
+	if n != 1 {
+		t.Errorf("expected the response to be cached once, got %d entries", n)
+	}
+
+	// Changing a setting that shapes the body must not serve the bytes encoded before.
+	testParser.Pretty = false
+	rr := httptest.NewRecorder()
+	_ = testParser.ErrorJSON(rr, errUpstreamDown, http.StatusServiceUnavailable)
+	if expected := `{"error":true,"message":"the billing service is unavailable"}`; rr.Body.String() != expected {
+		t.Errorf("expected %s after turning Pretty off, got %s", expected, rr.Body.String())
+	}
+	testParser.Pretty = true
+
+	// A response carrying warnings differs from the canned one, so it is encoded afresh.
+	rr = httptest.NewRecorder()
+	AddWarning(rr, "retrying later may help")
+	_ = testParser.ErrorJSON(rr, errUpstreamDown, http.StatusServiceUnavailable)
+	if !strings.Contains(rr.Body.String(), "retrying later may help") {
+		t.Errorf("expected the warning in the body, got %s", rr.Body.String())
+	}
+}
+
+func TestParser_ErrorJSONCannedBuiltin(t *testing.T) {
+	var logs bytes.Buffer
+	testParser := New(WithDisclosure(ProductionDisclosure), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":`))
+		rr := httptest.NewRecorder()
+		err := testParser.ReadJSON(rr, req, &struct{ Name string }{})
+		_ = testParser.ErrorJSON(rr, err)
+		if expected := `{"error":true,"message":"Bad Request"}`; rr.Body.String() != expected {
+			t.Errorf("expected %s, got %s", expected, rr.Body.String())
+		}
+	}
+
+	// Every hidden error is still logged, though only the first was encoded.
+	if n := strings.Count(logs.String(), "body contains badly-formed JSON"); n != 2 {
+		t.Errorf("expected the error to be logged twice, got %d times:\n%s", n, logs.String())
+	}
+}
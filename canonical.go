@@ -0,0 +1,164 @@
+package ps
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+)
+
+// canonicalize rewrites the JSON document b in the canonical form described by RFC 8785 (JSON Canonicalization
+// Scheme): no insignificant white space, object keys sorted by their UTF-16 code units, strings with only the
+// escapes JSON requires, and numbers read as IEEE-754 doubles and written in the shortest form that round-trips,
+// formatted as JavaScript formats them. Like any RFC 8785 implementation, it loses the precision of integers beyond
+// 2^53, which should be sent as strings if they must survive intact.
+func canonicalize(b []byte) ([]byte, error) {
+	return rewriter{canonical: true}.rewrite(b)
+}
+
+// sortKeys rewrites the JSON document b with the keys of every object in sorted order, and without insignificant
+// white space. Strings are escaped as encoding/json escapes them, and numbers are kept as written.
+func sortKeys(b []byte) ([]byte, error) {
+	return rewriter{}.rewrite(b)
+}
+
+// rewriter writes a JSON document again with its object keys sorted, in canonical form or otherwise.
+type rewriter struct {
+	canonical bool
+}
+
+// rewrite parses the JSON document b and writes it again.
+func (rw rewriter) rewrite(b []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return rw.append(make([]byte, 0, len(b)), v)
+}
+
+// append appends v, a value decoded with UseNumber.
+func (rw rewriter) append(dst []byte, v any) ([]byte, error) {
+	var err error
+
+	switch v := v.(type) {
+	case nil:
+		dst = append(dst, "null"...)
+
+	case bool:
+		dst = strconv.AppendBool(dst, v)
+
+	case json.Number:
+		if !rw.canonical {
+			return append(dst, v...), nil
+		}
+		return appendCanonicalNumber(dst, v)
+
+	case string:
+		dst = rw.appendString(dst, v)
+
+	case []any:
+		dst = append(dst, '[')
+		for i, elem := range v {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			if dst, err = rw.append(dst, elem); err != nil {
+				return nil, err
+			}
+		}
+		dst = append(dst, ']')
+
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		if rw.canonical {
+			sort.Slice(keys, func(i, j int) bool { return utf16Less(keys[i], keys[j]) })
+		} else {
+			sort.Strings(keys)
+		}
+
+		dst = append(dst, '{')
+		for i, key := range keys {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = rw.appendString(dst, key)
+			dst = append(dst, ':')
+			if dst, err = rw.append(dst, v[key]); err != nil {
+				return nil, err
+			}
+		}
+		dst = append(dst, '}')
+	}
+
+	return dst, nil
+}
+
+// appendString appends s as a JSON string.
+func (rw rewriter) appendString(dst []byte, s string) []byte {
+	if rw.canonical {
+		return appendCanonicalString(dst, s)
+	}
+	return appendString(dst, s, true)
+}
+
+// appendCanonicalNumber appends n in canonical form: the JavaScript form of the double nearest to it.
+func appendCanonicalNumber(dst []byte, n json.Number) ([]byte, error) {
+	f, err := n.Float64()
+	if err != nil {
+		return nil, err
+	}
+	if f == 0 {
+		// Negative zero is written as 0.
+		return append(dst, '0'), nil
+	}
+	return appendFloat(dst, f, 64)
+}
+
+// appendCanonicalString appends s as a JSON string, escaping only quotation marks, backslashes and control
+// characters.
+func appendCanonicalString(dst []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+
+	dst = append(dst, '"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			dst = append(dst, '\\', c)
+		case c == '\b':
+			dst = append(dst, '\\', 'b')
+		case c == '\f':
+			dst = append(dst, '\\', 'f')
+		case c == '\n':
+			dst = append(dst, '\\', 'n')
+		case c == '\r':
+			dst = append(dst, '\\', 'r')
+		case c == '\t':
+			dst = append(dst, '\\', 't')
+		case c < 0x20:
+			dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xF])
+		default:
+			dst = append(dst, c)
+		}
+	}
+
+	return append(dst, '"')
+}
+
+// utf16Less orders strings by their UTF-16 code units, as RFC 8785 requires for object keys.
+func utf16Less(a, b string) bool {
+	x, y := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(x) && i < len(y); i++ {
+		if x[i] != y[i] {
+			return x[i] < y[i]
+		}
+	}
+	return len(x) < len(y)
+}
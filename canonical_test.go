@@ -0,0 +1,102 @@
+package ps
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var canonicalTests = []struct {
+	name     string
+	input    string
+	expected string
+}{
+	{
+		name:     "numbers",
+		input:    `[333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001, -0, 12345678901234567890, -0.0, 9007199254740993, 1e21]`,
+		expected: `[333333333.3333333,1e+30,4.5,0.002,1e-27,0,12345678901234567000,0,9007199254740992,1e+21]`,
+	},
+	{
+		name:     "strings",
+		input:    `{"s": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/<>&\u2028"}`,
+		expected: "{\"s\":\"\u20ac$\\u000f\\nA'B\\\"\\\\\\\\\\\"/<>&\u2028\"}",
+	},
+	{
+		name:     "key order",
+		input:    `{"\u20ac":1,"\r":2,"\ufb33":3,"1":4,"\ud83d\ude00":5,"\u0080":6,"\u00f6":7}`,
+		expected: "{\"\\r\":2,\"1\":4,\"\u0080\":6,\"\u00f6\":7,\"\u20ac\":1,\"\U0001f600\":5,\"\ufb33\":3}",
+	},
+	{
+		name:     "nested",
+		input:    ` { "b" : [ true , null , { "d" : 1 , "c" : "x" } ] , "a" : { } } `,
+		expected: `{"a":{},"b":[true,null,{"c":"x","d":1}]}`,
+	},
+}
+
+func TestCanonicalize(t *testing.T) {
+	for _, e := range canonicalTests {
+		out, err := canonicalize([]byte(e.input))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+		if string(out) != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, out)
+		}
+	}
+}
+
+func TestParser_WriteJSONCanonical(t *testing.T) {
+	testParser := Parser{Canonical: true, Pretty: true}
+
+	rr := httptest.NewRecorder()
+	err := testParser.WriteJSON(rr, http.StatusOK, JSONResponse{Message: "<ok>", Data: json.RawMessage(`{"z": 1.50, "a": [1e2]}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"data":{"a":[100],"z":1.5},"error":false,"message":"<ok>"}`
+	if rr.Body.String() != expected {
+		t.Errorf("expected %s, got %s", expected, rr.Body.String())
+	}
+}
+
+type sortKeysMarshaler map[string]int
+
+func (m sortKeysMarshaler) MarshalJSON() ([]byte, error) {
+	// Write the keys in reverse order, as a hand-written marshaler might.
+	return []byte(`{"z":1,"m":2,"a":3}`), nil
+}
+
+func TestParser_WriteJSONSortKeys(t *testing.T) {
+	testParser := Parser{SortKeys: true, Pretty: true}
+
+	rr := httptest.NewRecorder()
+	err := testParser.WriteJSON(rr, http.StatusOK, JSONResponse{
+		Message: "<ok>",
+		Data:    []any{sortKeysMarshaler{}, json.RawMessage(`{"b": 1.50, "a": 1e2}`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{
+  "data": [
+    {
+      "a": 3,
+      "m": 2,
+      "z": 1
+    },
+    {
+      "a": 1e2,
+      "b": 1.50
+    }
+  ],
+  "error": false,
+  "message": "\u003cok\u003e"
+}`
+	if rr.Body.String() != expected {
+		t.Errorf("expected %s, got %s", expected, rr.Body.String())
+	}
+}
@@ -0,0 +1,81 @@
+package ps
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// StoredResponse is a complete response kept so that it can be replayed later.
+type StoredResponse struct {
+	// Status is the response status code.
+	Status int
+	// Header holds the response headers.
+	Header http.Header
+	// Body is the serialized response body.
+	Body []byte
+	// Fingerprint identifies the request that produced the response, where the store needs one.
+	Fingerprint string
+}
+
+// replay writes the stored response to w. Headers already set on w, such as the request ID outer middleware gave
+// the current request, are kept rather than replaced with those of the request that was stored; Vary is merged.
+func (s StoredResponse) replay(w http.ResponseWriter) error {
+	h := w.Header()
+	for key, value := range s.Header {
+		switch {
+		case key == "Vary":
+			AddVary(w, varyFields(s.Header)...)
+		case len(h[key]) == 0:
+			h[key] = append([]string(nil), value...)
+		}
+	}
+	w.WriteHeader(s.Status)
+	_, err := w.Write(s.Body)
+	return err
+}
+
+// captureWriter passes a response through to the client while keeping a copy of it.
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+	// skipBody keeps only the status and headers, for callers that have no use for the body.
+	skipBody bool
+}
+
+// WriteHeader records the status code before passing it on.
+func (c *captureWriter) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+// Write records b before passing it on.
+func (c *captureWriter) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	if !c.skipBody {
+		c.body.Write(b)
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the wrapped ResponseWriter, for http.ResponseController.
+func (c *captureWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// response returns what has been written so far.
+func (c *captureWriter) response() StoredResponse {
+	status := c.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return StoredResponse{
+		Status: status,
+		Header: c.Header().Clone(),
+		Body:   bytes.Clone(c.body.Bytes()),
+	}
+}
@@ -0,0 +1,319 @@
+package ps
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CloudEventsMediaType is the media type of a CloudEvent in structured mode.
+const CloudEventsMediaType = "application/cloudevents+json"
+
+// cloudEventsVersion is the version of the CloudEvents specification supported.
+const cloudEventsVersion = "1.0"
+
+// CloudEventMode is the way a CloudEvent is carried in an HTTP message.
+type CloudEventMode int
+
+const (
+	// StructuredMode sends the event as a single application/cloudevents+json object, with the payload in its data
+	// member. This is the default.
+	StructuredMode CloudEventMode = iota
+	// BinaryMode sends the event's attributes as ce-* headers and the payload as the body.
+	BinaryMode
+)
+
+// CloudEvent holds the context attributes of a CloudEvents 1.0 event. The payload is read and written separately.
+type CloudEvent struct {
+	ID              string
+	Source          string
+	SpecVersion     string
+	Type            string
+	DataContentType string
+	DataSchema      string
+	Subject         string
+	// Time is the zero time if the event has none.
+	Time time.Time
+	// Extensions holds the extension attributes, in their string form, by name.
+	Extensions map[string]string
+}
+
+// cloudEventAttributes are the context attributes defined by the specification, which cannot be extensions.
+var cloudEventAttributes = []string{"id", "source", "specversion", "type", "datacontenttype", "dataschema", "subject", "time", "data", "data_base64"}
+
+// ReadCloudEvent reads a CloudEvent sent in structured or binary mode, decoding its payload into data as ReadJSON
+// would decode a body, with the same size limit, strictness and hooks. A request that is neither an
+// application/cloudevents+json body nor has a ce-specversion header is refused. Missing or malformed attributes
+// are reported together in a *ValidationError.
+func (p *Parser) ReadCloudEvent(w http.ResponseWriter, r *http.Request, data any) (*CloudEvent, error) {
+	event, err := p.readCloudEvent(w, r, data)
+	p.countDecode(err)
+	p.notifyDecode(r, data, err)
+	return event, err
+}
+
+// readCloudEvent does the work of ReadCloudEvent.
+func (p *Parser) readCloudEvent(w http.ResponseWriter, r *http.Request, data any) (*CloudEvent, error) {
+	if strings.EqualFold(mediaType(r.Header.Get("Content-Type")), CloudEventsMediaType) {
+		return p.readStructuredEvent(w, r, data)
+	}
+	if r.Header.Get("Ce-Specversion") == "" {
+		return nil, fmt.Errorf("the request is not a CloudEvent: it has neither a %s body nor a ce-specversion header", CloudEventsMediaType)
+	}
+
+	event := &CloudEvent{DataContentType: r.Header.Get("Content-Type")}
+	var errs []FieldError
+	for name, values := range r.Header {
+		lower := strings.ToLower(name)
+		attr, ok := strings.CutPrefix(lower, "ce-")
+		if !ok || len(values) == 0 {
+			continue
+		}
+		// Header values are percent-encoded where they are not printable ASCII.
+		value, err := url.PathUnescape(values[0])
+		if err != nil {
+			value = values[0]
+		}
+		if err := event.set(attr, value); err != nil {
+			errs = append(errs, FieldError{Field: lower, Message: err.Error()})
+		}
+	}
+	if err := event.check(errs, "ce-"); err != nil {
+		return nil, err
+	}
+
+	// The body is the payload, read as any other JSON body.
+	if err := p.readJSON(w, r, data); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// readStructuredEvent reads an event sent in structured mode.
+func (p *Parser) readStructuredEvent(w http.ResponseWriter, r *http.Request, data any) (*CloudEvent, error) {
+	if p.ReplayGuard != nil {
+		if err := p.ReplayGuard.Check(r); err != nil {
+			return nil, err
+		}
+	}
+
+	buf, maxBytes, err := p.readLimitedBody(w, r)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseBody(buf)
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(buf.Bytes(), &members); err != nil {
+		return nil, decodeError(err, maxBytes)
+	}
+
+	event := &CloudEvent{}
+	var errs []FieldError
+	for name, raw := range members {
+		if name == "data" || name == "data_base64" {
+			continue
+		}
+		var value any
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, decodeError(err, maxBytes)
+		}
+		var text string
+		switch v := value.(type) {
+		case string:
+			text = v
+		case float64, bool:
+			// Extensions may also be numbers and booleans; keep their JSON form.
+			text = string(raw)
+		default:
+			errs = append(errs, FieldError{Field: name, Message: "must be a string, number or boolean"})
+			continue
+		}
+		if err := event.set(name, text); err != nil {
+			errs = append(errs, FieldError{Field: name, Message: err.Error()})
+		}
+	}
+	if err := event.check(errs, ""); err != nil {
+		return nil, err
+	}
+
+	payload, hasData := members["data"]
+	if encoded, ok := members["data_base64"]; ok {
+		if hasData {
+			return nil, errors.New("event must not have both data and data_base64")
+		}
+		var s string
+		if err := json.Unmarshal(encoded, &s); err != nil {
+			return nil, &FieldError{Field: "data_base64", Message: "must be a base64 string"}
+		}
+		if payload, err = base64.StdEncoding.DecodeString(s); err != nil {
+			return nil, &FieldError{Field: "data_base64", Message: "must be a base64 string"}
+		}
+		hasData = true
+	}
+	if !hasData {
+		payload = json.RawMessage("null")
+	}
+	if err := p.decodeBody(r, bytes.NewReader(payload), data, maxBytes); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// set sets the attribute name to value.
+func (e *CloudEvent) set(name, value string) error {
+	switch name {
+	case "id":
+		e.ID = value
+	case "source":
+		e.Source = value
+	case "specversion":
+		e.SpecVersion = value
+	case "type":
+		e.Type = value
+	case "datacontenttype":
+		e.DataContentType = value
+	case "dataschema":
+		e.DataSchema = value
+	case "subject":
+		e.Subject = value
+	case "time":
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return errors.New("must be an RFC 3339 timestamp")
+		}
+		e.Time = t
+	default:
+		if !validExtensionName(name) {
+			return errors.New("is not a valid attribute name")
+		}
+		if e.Extensions == nil {
+			e.Extensions = map[string]string{}
+		}
+		e.Extensions[name] = value
+	}
+	return nil
+}
+
+// check adds to errs the problems with the required attributes, naming each with prefix, and returns them as a
+// *ValidationError if there are any.
+func (e *CloudEvent) check(errs []FieldError, prefix string) error {
+	for _, required := range []struct{ name, value string }{
+		{"id", e.ID}, {"source", e.Source}, {"specversion", e.SpecVersion}, {"type", e.Type},
+	} {
+		if required.value == "" {
+			errs = append(errs, FieldError{Field: prefix + required.name, Message: "is required"})
+		}
+	}
+	if e.SpecVersion != "" && e.SpecVersion != cloudEventsVersion {
+		errs = append(errs, FieldError{Field: prefix + "specversion", Message: "must be " + cloudEventsVersion})
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return &ValidationError{Fields: errs}
+}
+
+// validExtensionName reports whether name is a valid extension attribute name: lower-case letters and digits,
+// not one of the attributes the specification defines.
+func validExtensionName(name string) bool {
+	if name == "" || containsFold(cloudEventAttributes, name) {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteCloudEvent writes event, with data as its payload, in the given mode. data is encoded as WriteJSON would
+// encode it. SpecVersion defaults to 1.0 and DataContentType to application/json; ID, Source and Type are
+// required.
+func (p *Parser) WriteCloudEvent(w http.ResponseWriter, status int, event CloudEvent, data any, mode CloudEventMode) error {
+	if event.SpecVersion == "" {
+		event.SpecVersion = cloudEventsVersion
+	}
+	if event.DataContentType == "" {
+		event.DataContentType = "application/json"
+	}
+	var errs []FieldError
+	for name := range event.Extensions {
+		if !validExtensionName(name) {
+			errs = append(errs, FieldError{Field: name, Message: "is not a valid attribute name"})
+		}
+	}
+	if err := event.check(errs, ""); err != nil {
+		p.count(encodeFailures, 1)
+		return err
+	}
+
+	attrs := event.attributes()
+	if mode == BinaryMode {
+		h := w.Header()
+		for _, a := range attrs {
+			if a.name != "datacontenttype" {
+				h.Set("Ce-"+a.name, percentEncode(a.value))
+			}
+		}
+		return p.writeAs(w, event.DataContentType, status, data, nil)
+	}
+
+	out, err := p.marshal(data)
+	if err != nil {
+		p.count(encodeFailures, 1)
+		return err
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, a := range attrs {
+		writeObjectKey(&buf, a.name)
+		value, _ := json.Marshal(a.value)
+		buf.Write(value)
+		buf.WriteByte(',')
+	}
+	buf.WriteString(`"data":`)
+	if err := json.Compact(&buf, out); err != nil {
+		p.count(encodeFailures, 1)
+		return err
+	}
+	buf.WriteByte('}')
+	return p.writeAs(w, CloudEventsMediaType, status, json.RawMessage(buf.Bytes()), nil)
+}
+
+// attributes returns the attributes of e that are set, the required ones first and extensions last, by name.
+func (e *CloudEvent) attributes() []struct{ name, value string } {
+	attrs := []struct{ name, value string }{
+		{"specversion", e.SpecVersion}, {"id", e.ID}, {"source", e.Source}, {"type", e.Type},
+		{"datacontenttype", e.DataContentType}, {"dataschema", e.DataSchema}, {"subject", e.Subject},
+	}
+	if !e.Time.IsZero() {
+		attrs = append(attrs, struct{ name, value string }{"time", e.Time.Format(time.RFC3339Nano)})
+	}
+
+	names := make([]string, 0, len(e.Extensions))
+	for name := range e.Extensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		attrs = append(attrs, struct{ name, value string }{name, e.Extensions[name]})
+	}
+
+	set := attrs[:0]
+	for _, a := range attrs {
+		if a.value != "" {
+			set = append(set, a)
+		}
+	}
+	return set
+}
@@ -0,0 +1,107 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type eventOrder struct {
+	ID int `json:"id"`
+}
+
+var readCloudEventTests = []struct {
+	name          string
+	contentType   string
+	headers       map[string]string
+	body          string
+	expectedError string
+}{
+	{name: "structured", contentType: CloudEventsMediaType,
+		body: `{"specversion":"1.0","id":"e1","source":"/shop","type":"order.paid","time":"2024-05-01T10:00:00Z","tenant":"acme","priority":5,"data":{"id":7}}`},
+	{name: "structured base64", contentType: CloudEventsMediaType + "; charset=utf-8",
+		body: `{"specversion":"1.0","id":"e1","source":"/shop","type":"order.paid","time":"2024-05-01T10:00:00Z","tenant":"acme","priority":5,"data_base64":"eyJpZCI6N30="}`},
+	{name: "binary", contentType: "application/json", body: `{"id":7}`,
+		headers: map[string]string{"Ce-Specversion": "1.0", "Ce-Id": "e1", "Ce-Source": "/shop", "Ce-Type": "order.paid", "Ce-Time": "2024-05-01T10:00:00Z", "Ce-Tenant": "acme", "Ce-Priority": "5"}},
+	{name: "missing attributes", contentType: CloudEventsMediaType, body: `{"specversion":"0.3","id":"e1","data":{"id":7}}`,
+		expectedError: "source is required; specversion must be 1.0; type is required"},
+	{name: "binary missing attributes", contentType: "application/json", body: `{"id":7}`,
+		headers:       map[string]string{"Ce-Specversion": "1.0", "Ce-Time": "yesterday", "Ce-Bad_name": "x"},
+		expectedError: "ce-bad_name is not a valid attribute name; ce-id is required; ce-source is required; ce-time must be an RFC 3339 timestamp; ce-type is required"},
+	{name: "payload", contentType: CloudEventsMediaType, body: `{"specversion":"1.0","id":"e1","source":"/shop","type":"t","data":{"id":"7"}}`,
+		expectedError: `incorrect JSON type for field "id"`},
+	{name: "not an event", contentType: "application/json", body: `{"id":7}`, expectedError: "the request is not a CloudEvent"},
+}
+
+func TestParser_ReadCloudEvent(t *testing.T) {
+	var testParser Parser
+
+	for _, e := range readCloudEventTests {
+		req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(e.body))
+		req.Header.Set("Content-Type", e.contentType)
+		for k, v := range e.headers {
+			req.Header.Set(k, v)
+		}
+
+		var order eventOrder
+		event, err := testParser.ReadCloudEvent(httptest.NewRecorder(), req, &order)
+
+		if e.expectedError != "" {
+			if err == nil || !strings.Contains(err.Error(), e.expectedError) {
+				t.Errorf("%s: expected an error containing %q, got %v", e.name, e.expectedError, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", e.name, err)
+			continue
+		}
+		if event.ID != "e1" || event.Source != "/shop" || event.Type != "order.paid" || event.SpecVersion != "1.0" ||
+			!event.Time.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) ||
+			event.Extensions["tenant"] != "acme" || event.Extensions["priority"] != "5" || order.ID != 7 {
+			t.Errorf("%s: unexpected event %+v with payload %+v", e.name, event, order)
+		}
+	}
+}
+
+func TestParser_WriteCloudEvent(t *testing.T) {
+	var testParser Parser
+	event := CloudEvent{ID: "e1", Source: "/shop", Type: "order.paid", Subject: "orders/7",
+		Time: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), Extensions: map[string]string{"tenant": "acme é"}}
+
+	rr := httptest.NewRecorder()
+	if err := testParser.WriteCloudEvent(rr, http.StatusOK, event, eventOrder{ID: 7}, StructuredMode); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"specversion":"1.0","id":"e1","source":"/shop","type":"order.paid","datacontenttype":"application/json",` +
+		`"subject":"orders/7","time":"2024-05-01T10:00:00Z","tenant":"acme é","data":{"id":7}}`
+	if rr.Header().Get("Content-Type") != CloudEventsMediaType || rr.Body.String() != expected {
+		t.Errorf("expected %s, got %s %s", expected, rr.Header().Get("Content-Type"), rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	if err := testParser.WriteCloudEvent(rr, http.StatusOK, event, eventOrder{ID: 7}, BinaryMode); err != nil {
+		t.Fatal(err)
+	}
+	h := rr.Header()
+	if h.Get("Ce-Id") != "e1" || h.Get("Ce-Specversion") != "1.0" || h.Get("Ce-Tenant") != "acme %C3%A9" ||
+		h.Get("Ce-Datacontenttype") != "" || h.Get("Content-Type") != "application/json" || rr.Body.String() != `{"id":7}` {
+		t.Errorf("unexpected binary event %v %s", h, rr.Body.String())
+	}
+
+	// A binary event read back has the same attributes.
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(rr.Body.String()))
+	req.Header = h.Clone()
+	var order eventOrder
+	read, err := testParser.ReadCloudEvent(httptest.NewRecorder(), req, &order)
+	if err != nil || read.Extensions["tenant"] != "acme é" || read.Subject != "orders/7" {
+		t.Errorf("expected the event back, got %+v: %v", read, err)
+	}
+
+	if err := testParser.WriteCloudEvent(httptest.NewRecorder(), http.StatusOK, CloudEvent{ID: "e1"}, nil, StructuredMode); err == nil ||
+		err.Error() != "source is required; type is required" {
+		t.Errorf("expected the missing attributes, got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package ps
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"time"
+)
+
+// Decode reads the single JSON value in src into data, a pointer, with the same rules ReadJSON applies to a
+// request body: the size limit, unknown, required, read-only and allowed fields, MaxDepth, RejectDuplicateKeys,
+// decoders and validation, with Validator given ctx. It suits message-queue consumers, CLI tools and other code
+// that reads JSON outside an HTTP handler. Errors read as ReadJSON's do, and are counted in Stats.
+//
+// The size limit is MaxJSONSize, or the default one. Things that belong to HTTP requests are left out: headers,
+// content codings, digests, replay checks, per-method policies and the BeforeDecode and AfterDecode hooks, which
+// take the request. The OnDecodeError and OnBind callbacks run, with a nil request.
+func (p *Parser) Decode(ctx context.Context, src io.Reader, data any) error {
+	err := p.decode(ctx, src, data)
+	p.countDecode(err)
+	p.notifyDecode(nil, data, err)
+	return err
+}
+
+// decode does the work of Decode.
+func (p *Parser) decode(ctx context.Context, src io.Reader, data any) error {
+	maxBytes := p.maxPayload("")
+
+	// Read one byte more than the limit, to tell a body that fills it from one that exceeds it.
+	counted := &countingReader{r: io.LimitReader(src, int64(maxBytes)+1)}
+	defer func() { p.count(bytesRead, counted.n) }()
+	return p.decodeJSON(decodeInput{body: counted, maxBytes: maxBytes, size: -1, whole: true,
+		after: func(data any) error { return validate(ctx, data) }}, data)
+}
+
+// decodeInput is a body for decodeJSON, with what its caller knows about it.
+type decodeInput struct {
+	// body holds the JSON, read under maxBytes.
+	body io.Reader
+	// maxBytes is the limit body is read under.
+	maxBytes int
+	// size is the announced length of body, or -1.
+	size int64
+	// whole has the body read whole before it is decoded even when nothing else needs it, as Decode must to tell
+	// a body over maxBytes from malformed JSON.
+	whole bool
+	// prepare, if set, checks or rewrites the whole body before it is decoded.
+	prepare func(b []byte) ([]byte, error)
+	// after runs on the decoded value: the AfterDecode hooks, if any, then validation.
+	after func(data any) error
+}
+
+// decodeJSON is the core of ReadJSON and Decode, which leaves the transport to them: it decodes the single JSON
+// value in in.body into data with the Parser's rules for empty bodies, MaxDepth, RejectDuplicateKeys,
+// AllowedFields, unknown, required and read-only fields and decoders, then runs in.after, reporting errors with
+// their context if Diagnostics is set. The body is streamed to the decoder when nothing needs it whole.
+func (p *Parser) decodeJSON(in decodeInput, data any) error {
+	if !in.whole && in.prepare == nil && !p.needsWholeBody() {
+		if err := p.decodeValue(in.body, data, in.maxBytes); err != nil {
+			return err
+		}
+		return in.after(data)
+	}
+
+	buf, err := readBody(in.body, in.size)
+	if err != nil {
+		return decodeError(err, in.maxBytes)
+	}
+	defer releaseBody(buf)
+	if buf.Len() > in.maxBytes {
+		return &RequestTooLargeError{Limit: int64(in.maxBytes)}
+	}
+
+	b := buf.Bytes()
+	if in.prepare != nil {
+		if b, err = in.prepare(b); err != nil {
+			return err
+		}
+	}
+	if p.AllowEmptyBody && len(b) == 0 {
+		if err := setZero(data); err != nil {
+			return err
+		}
+		return in.after(data)
+	}
+
+	err = p.checkStructure(b)
+	if err == nil {
+		b, err = p.filterFields(b)
+	}
+	if err == nil {
+		err = p.decodeValue(bytes.NewReader(b), data, in.maxBytes)
+	}
+	if err == nil {
+		err = in.after(data)
+	}
+	if err != nil && p.Diagnostics {
+		return p.diagnose(err, b, data)
+	}
+	return err
+}
+
+// needsWholeBody reports whether the Parser's rules need a body read whole before it is decoded.
+func (p *Parser) needsWholeBody() bool {
+	return p.checksStructure() || p.Diagnostics || len(p.AllowedFields) > 0
+}
+
+// Encode writes data to dst as JSON, encoded as WriteJSON would encode it, following the Parser's settings for
+// formats, empty fields, non-finite floats, key order and indentation, and sending pre-serialized payloads as they
+// are. A value larger than a positive MaxResponseSize is not written, and a *ResponseTooLargeError is returned.
+// It is counted in Stats, and the OnWrite callbacks run, with a nil ResponseWriter.
+//
+// Like Decode, it leaves out what belongs to HTTP responses: headers, digests, version transforms, warnings and
+// the BeforeEncode and AfterEncode hooks.
+func (p *Parser) Encode(dst io.Writer, data any) error {
+	start := time.Now()
+	n, err := p.encode(dst, data)
+	err = p.countWrite(err)
+	p.notifyWrite(nil, WriteInfo{Size: n, Duration: time.Since(start), Err: err})
+	return err
+}
+
+// encode does the work of Encode, returning the number of bytes written.
+func (p *Parser) encode(dst io.Writer, data any) (int64, error) {
+	out, src, err := p.encodeValue(data)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	switch {
+	case src != nil:
+		n, err = src.WriteTo(dst)
+	case p.oversized(out):
+		return 0, &ResponseTooLargeError{Size: len(out), Limit: p.MaxResponseSize}
+	default:
+		var written int
+		written, err = dst.Write(out)
+		n = int64(written)
+	}
+	p.count(bytesWritten, n)
+	return n, err
+}
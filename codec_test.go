@@ -0,0 +1,79 @@
+package ps
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var decodeTests = []struct {
+	name          string
+	parser        *Parser
+	json          string
+	errorExpected string
+}{
+	{name: "valid", parser: New(), json: `{"from": 1, "to": 2, "guest": "Ann"}`},
+	{name: "unknown field", parser: New(), json: `{"from": 1, "to": 2, "guest": "Ann", "room": 4}`, errorExpected: `body contains unknown key "room"`},
+	{name: "validation", parser: New(), json: `{"from": 2, "to": 1}`, errorExpected: "to must not be before from; guest must not be empty"},
+	{name: "too large", parser: New(WithMaxJSONSize(16)), json: `{"from": 1, "to": 2, "guest": "Ann"}`, errorExpected: "body must not be larger than 16 bytes"},
+	{name: "exactly the limit", parser: New(WithMaxJSONSize(30)), json: `{"from":1,"to":2,"guest":"Bo"}`},
+	{name: "duplicate keys", parser: New(WithRejectDuplicateKeys(true)), json: `{"from": 1, "from": 2, "to": 3, "guest": "Ann"}`, errorExpected: `body contains duplicate key "from"`},
+	{name: "empty", parser: New(), json: ``, errorExpected: "body must not be empty"},
+	{name: "two values", parser: New(), json: `{"from": 1, "to": 2, "guest": "Ann"} {}`, errorExpected: "body must only contain a single JSON value"},
+	{name: "allowed fields", parser: New(WithAllowedFields(DropDisallowedFields, "from", "to")), json: `{"from": 1, "to": 2, "guest": "Ann"}`, errorExpected: "guest must not be empty"},
+	{name: "empty allowed", parser: New(WithAllowEmptyBody(true)), json: ``, errorExpected: "guest must not be empty"},
+}
+
+func TestParser_Decode(t *testing.T) {
+	for _, e := range decodeTests {
+		var got bookingRequest
+		err := e.parser.Decode(context.Background(), strings.NewReader(e.json), &got)
+
+		if e.errorExpected == "" && err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+		}
+		if e.errorExpected != "" && (err == nil || err.Error() != e.errorExpected) {
+			t.Errorf("%s: expected error %q, got %v", e.name, e.errorExpected, err)
+		}
+	}
+}
+
+// TestParser_DecodeMatchesReadJSON checks that Decode and ReadJSON, which share their decoding, agree.
+func TestParser_DecodeMatchesReadJSON(t *testing.T) {
+	for _, e := range decodeTests {
+		var decoded, read bookingRequest
+		decodeErr := e.parser.Decode(context.Background(), strings.NewReader(e.json), &decoded)
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.json))
+		req.Header.Set("Content-Type", "application/json")
+		readErr := e.parser.ReadJSON(httptest.NewRecorder(), req, &read)
+
+		if fmt.Sprint(decodeErr) != fmt.Sprint(readErr) || decoded != read {
+			t.Errorf("%s: Decode gave %v and %+v, ReadJSON %v and %+v", e.name, decodeErr, decoded, readErr, read)
+		}
+	}
+}
+
+func TestParser_Encode(t *testing.T) {
+	testParser := New(WithDurationFormat(DurationString), WithSortKeys(true))
+
+	var buf bytes.Buffer
+	if err := testParser.Encode(&buf, map[string]any{"timeout": 90 * time.Second, "name": "job"}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"name":"job","timeout":"1m30s"}`; buf.String() != expected {
+		t.Errorf("expected %s, got %s", expected, buf.String())
+	}
+
+	buf.Reset()
+	var tooLarge *ResponseTooLargeError
+	if err := testParser.With(WithMaxResponseSize(8, RejectOversizedResponse)).Encode(&buf, "a long string"); !errors.As(err, &tooLarge) || buf.Len() != 0 {
+		t.Errorf("expected a *ResponseTooLargeError and nothing written, got %v and %q", err, buf.String())
+	}
+}
@@ -0,0 +1,112 @@
+package ps
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Validate reports configuration that cannot work, or does not do what it appears to: negative sizes and
+// timeouts, policies with values outside their constants, invalid header and tag names, and settings that have no
+// effect without another, such as RejectDisallowedFields without AllowedFields. Call it once the Parser is set up,
+// before serving requests, so that a mistake fails at start-up rather than misbehaving on some later request.
+// Every problem found is reported in the returned error. NewFromEnv runs the same checks.
+func (p *Parser) Validate() error {
+	if errs := p.configErrors(); len(errs) > 0 {
+		return fmt.Errorf("invalid parser configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// configErrors lists the problems Validate reports.
+func (p *Parser) configErrors() []error {
+	var errs []error
+	problem := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	nonNegative := func(name string, n int) {
+		if n < 0 {
+			problem("%s must not be negative, got %d", name, n)
+		}
+	}
+	nonNegative("MaxJSONSize", p.MaxJSONSize)
+	nonNegative("MaxDepth", p.MaxDepth)
+	nonNegative("MaxDecompressedSize", p.MaxDecompressedSize)
+	nonNegative("MaxExpansionRatio", p.MaxExpansionRatio)
+	nonNegative("MaxResponseSize", p.MaxResponseSize)
+	if p.WriteTimeout < 0 {
+		problem("WriteTimeout must not be negative, got %s", p.WriteTimeout)
+	}
+	if p.FloatFormat != nil {
+		nonNegative("FloatFormat.Decimals", p.FloatFormat.Decimals)
+	}
+	for _, method := range sortedKeys(p.Methods) {
+		policy := p.Methods[method]
+		nonNegative("Methods["+method+"].MaxJSONSize", policy.MaxJSONSize)
+		if policy.Body < BodyRequired || policy.Body > BodyForbidden {
+			problem("Methods[%s].Body has unknown value %d", method, policy.Body)
+		}
+	}
+
+	inRange := func(name string, v, last int) {
+		if v < 0 || v > last {
+			problem("%s has unknown value %d", name, v)
+		}
+	}
+	inRange("DisallowedFields", int(p.DisallowedFields), int(RejectDisallowedFields))
+	inRange("Disclosure", int(p.Disclosure), int(DebugDisclosure))
+	inRange("EmptyFields", int(p.EmptyFields), int(OmitEmptyFields))
+	inRange("NonFinite", int(p.NonFinite), int(StringNonFinite))
+	inRange("OversizedResponse", int(p.OversizedResponse), int(StreamOversizedResponse))
+	inRange("PollTimeout", int(p.PollTimeout), int(EnvelopePollTimeout))
+	inRange("ReadOnlyFields", int(p.ReadOnlyFields), int(RejectReadOnlyFields))
+	inRange("RequestDigest", int(p.RequestDigest), int(RequireDigest))
+	inRange("UnexpectedBody", int(p.UnexpectedBody), int(StripUnexpectedBody))
+	inRange("Warnings", int(p.Warnings), int(HeaderWarnings))
+
+	switch p.DurationFormat {
+	case "", DurationString, DurationSeconds:
+	default:
+		problem("DurationFormat must be empty, %s or %s, got %q", DurationString, DurationSeconds, p.DurationFormat)
+	}
+	if strings.ContainsAny(p.TagName, " \t:\"") {
+		problem("TagName %q is not a valid struct tag name", p.TagName)
+	}
+	headerName := func(name, header string) {
+		if strings.ContainsAny(header, " \t:") {
+			problem("%s %q is not a valid header name", name, header)
+		}
+	}
+	headerName("RequestIDHeader", p.RequestIDHeader)
+	headerName("VersionHeader", p.VersionHeader)
+	for _, tag := range p.Languages {
+		if !validLanguageTag(tag) {
+			problem("Languages holds the invalid language tag %q", tag)
+		}
+	}
+	seen := make(map[string]bool, len(p.APIVersions))
+	for _, version := range p.APIVersions {
+		if version == "" || seen[version] {
+			problem("APIVersions must not hold empty or repeated versions, got %q", p.APIVersions)
+			break
+		}
+		seen[version] = true
+	}
+
+	// Settings that only make sense alongside another.
+	if p.DisallowedFields == RejectDisallowedFields && len(p.AllowedFields) == 0 {
+		problem("DisallowedFields is RejectDisallowedFields, but AllowedFields is empty")
+	}
+	if p.OversizedResponse != RejectOversizedResponse && p.MaxResponseSize == 0 {
+		problem("OversizedResponse is set, but MaxResponseSize is not")
+	}
+	if (p.MaxDecompressedSize > 0 || p.MaxExpansionRatio > 0) && !p.Decompress {
+		problem("MaxDecompressedSize or MaxExpansionRatio is set, but Decompress is not")
+	}
+	if p.Canonical && p.Pretty {
+		problem("Canonical and Pretty are both set, and Canonical output cannot be indented")
+	}
+
+	return errs
+}
@@ -0,0 +1,58 @@
+package ps
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+var validateTests = []struct {
+	name           string
+	parser         *Parser
+	errorsExpected []string
+}{
+	{name: "zero value", parser: &Parser{}},
+	{name: "configured", parser: New(WithMaxJSONSize(1024), WithAllowedFields(RejectDisallowedFields, "name"), WithMaxResponseSize(4096, StreamOversizedResponse),
+		WithDecompression(1<<20, 50), WithLanguages("en", "fr"), WithAPIVersions("1", "2"))},
+	{name: "negative sizes", parser: New(WithMaxJSONSize(-1), WithMaxDepth(-2), WithWriteTimeout(-time.Second), WithMethodPolicy(http.MethodPost, MethodPolicy{MaxJSONSize: -3})),
+		errorsExpected: []string{"MaxJSONSize must not be negative, got -1", "MaxDepth must not be negative, got -2", "WriteTimeout must not be negative, got -1s", "Methods[POST].MaxJSONSize must not be negative, got -3"}},
+	{name: "unknown policy", parser: &Parser{Disclosure: Disclosure(7)}, errorsExpected: []string{"Disclosure has unknown value 7"}},
+	{name: "strict allowlist without fields", parser: New(WithAllowedFields(RejectDisallowedFields)),
+		errorsExpected: []string{"DisallowedFields is RejectDisallowedFields, but AllowedFields is empty"}},
+	{name: "policy without limit", parser: &Parser{OversizedResponse: TruncateOversizedResponse}, errorsExpected: []string{"OversizedResponse is set, but MaxResponseSize is not"}},
+	{name: "conflicting output", parser: New(WithCanonical(true), WithPretty(true)), errorsExpected: []string{"Canonical and Pretty are both set"}},
+	{name: "names", parser: New(WithTagName("json:x"), WithVersionHeader("API Version"), WithDurationFormat("minutes"), WithAPIVersions("1", "1")),
+		errorsExpected: []string{`TagName "json:x"`, `VersionHeader "API Version"`, `DurationFormat must be empty, string or seconds, got "minutes"`, "APIVersions must not hold empty or repeated versions"}},
+}
+
+func TestParser_Validate(t *testing.T) {
+	for _, e := range validateTests {
+		err := e.parser.Validate()
+
+		if len(e.errorsExpected) == 0 {
+			if err != nil {
+				t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: error expected, but none received", e.name)
+			continue
+		}
+		for _, expected := range e.errorsExpected {
+			if !strings.Contains(err.Error(), expected) {
+				t.Errorf("%s: expected %q to be reported, got %v", e.name, expected, err)
+			}
+		}
+	}
+}
+
+func TestNewFromEnv_Conflicting(t *testing.T) {
+	t.Setenv("PS_CANONICAL", "true")
+	t.Setenv("PS_PRETTY", "true")
+
+	if p, err := NewFromEnv(); err == nil || p != nil || !strings.Contains(err.Error(), "Canonical and Pretty") {
+		t.Errorf("expected the conflict to be reported, got %v", err)
+	}
+}
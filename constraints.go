@@ -0,0 +1,198 @@
+package ps
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// constraintPatterns caches the compiled regex constraints, or nil for those that do not compile.
+var constraintPatterns sync.Map
+
+// constraints are the rules of a validate struct tag, such as "min=1,max=100", "enum=asc|desc" or
+// "regex=^[a-z]+$". The regex rule must come last, as its pattern may hold commas. Rules that do not parse are
+// ignored, as decimal tags are.
+type constraints struct {
+	min, max     *string
+	enum         []string
+	pattern      *regexp.Regexp
+	requirements []requirement
+}
+
+// requirement is a conditional required rule of a validate struct tag. "required_if=delivery_method ship|courier"
+// requires the field when its sibling delivery_method is sent with one of the values listed, and
+// "required_without=phone" requires it when phone is not sent. Siblings are named as they are sent. JSON null, and
+// a blank form or query value, count as not sent.
+type requirement struct {
+	other   string
+	values  []string
+	without bool
+}
+
+// parseConstraints parses a validate struct tag.
+func parseConstraints(tag string) constraints {
+	var c constraints
+	for tag != "" {
+		var rule string
+		if strings.HasPrefix(tag, "regex=") {
+			rule, tag = tag, ""
+		} else {
+			rule, tag, _ = strings.Cut(tag, ",")
+		}
+		name, value, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case "min":
+			c.min = &value
+		case "max":
+			c.max = &value
+		case "enum":
+			c.enum = strings.Split(value, "|")
+		case "regex":
+			c.pattern = compileConstraint(value)
+		case "required_if":
+			if other, values, ok := strings.Cut(value, " "); ok && other != "" {
+				c.requirements = append(c.requirements, requirement{other: other, values: strings.Split(values, "|")})
+			}
+		case "required_without":
+			if value != "" {
+				c.requirements = append(c.requirements, requirement{other: value, without: true})
+			}
+		}
+	}
+	return c
+}
+
+// compileConstraint compiles pattern, once.
+func compileConstraint(pattern string) *regexp.Regexp {
+	if re, ok := constraintPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		re = nil
+	}
+	constraintPatterns.Store(pattern, re)
+	return re
+}
+
+// check reports a FieldError if v breaks a rule. Pointers are followed, a nil one passing, and each element of a
+// slice is checked. min and max bound numbers and durations by value, and strings by their length in characters.
+func (c constraints) check(v reflect.Value, path string) *FieldError {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Slice {
+		for i := 0; i < v.Len(); i++ {
+			if err := c.check(v.Index(i), path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if c.min != nil {
+		if below, unit := c.compare(v, *c.min); below < 0 {
+			return &FieldError{Field: path, Message: "must be at least " + *c.min + unit}
+		}
+	}
+	if c.max != nil {
+		if above, unit := c.compare(v, *c.max); above > 0 {
+			return &FieldError{Field: path, Message: "must be at most " + *c.max + unit}
+		}
+	}
+
+	text := fmt.Sprint(v.Interface())
+	if len(c.enum) > 0 && !slices.Contains(c.enum, text) {
+		return &FieldError{Field: path, Message: "must be one of " + joinChoices(c.enum)}
+	}
+	if c.pattern != nil && !c.pattern.MatchString(text) {
+		return &FieldError{Field: path, Message: "must match the pattern " + c.pattern.String()}
+	}
+	return nil
+}
+
+// compare compares v with bound, returning -1, 0 or 1, and the unit to describe the bound with. It returns 0 if
+// the bound does not parse or v is not a number, duration or string.
+func (c constraints) compare(v reflect.Value, bound string) (int, string) {
+	var x, limit float64
+	var err error
+	unit := ""
+	switch {
+	case v.Type() == durationType:
+		var d time.Duration
+		d, err = time.ParseDuration(bound)
+		x, limit = float64(v.Int()), float64(d)
+	case v.Kind() == reflect.String:
+		var n int
+		n, err = strconv.Atoi(bound)
+		x, limit, unit = float64(utf8.RuneCountInString(v.String())), float64(n), " characters long"
+	case v.CanInt():
+		limit, err = strconv.ParseFloat(bound, 64)
+		x = float64(v.Int())
+	case v.CanUint():
+		limit, err = strconv.ParseFloat(bound, 64)
+		x = float64(v.Uint())
+	case v.CanFloat():
+		limit, err = strconv.ParseFloat(bound, 64)
+		x = v.Float()
+	default:
+		return 0, ""
+	}
+	switch {
+	case err != nil:
+		return 0, ""
+	case x < limit:
+		return -1, unit
+	case x > limit:
+		return 1, unit
+	}
+	return 0, unit
+}
+
+// checkRequirements reports the fields of info that were not sent although one of their conditional rules
+// requires them, naming them below path. sent returns the value sent for a field as text, and whether one was.
+func checkRequirements(info *structInfo, path string, sent func(f *field) (string, bool)) []FieldError {
+	var errs []FieldError
+	for i := range info.fields {
+		f := &info.fields[i]
+		if len(f.requirements) == 0 {
+			continue
+		}
+		if _, ok := sent(f); ok {
+			continue
+		}
+		for _, req := range f.requirements {
+			other := info.byName[req.other]
+			if other == nil {
+				continue
+			}
+			text, ok := sent(other)
+			if req.without && !ok {
+				errs = append(errs, FieldError{Field: joinPath(path, f.name), Message: "is required when " + req.other + " is not given"})
+				break
+			}
+			if !req.without && ok && slices.Contains(req.values, text) {
+				errs = append(errs, FieldError{Field: joinPath(path, f.name), Message: "is required when " + req.other + " is " + text})
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// joinChoices lists choices as "a, b or c".
+func joinChoices(choices []string) string {
+	if len(choices) == 1 {
+		return choices[0]
+	}
+	return strings.Join(choices[:len(choices)-1], ", ") + " or " + choices[len(choices)-1]
+}
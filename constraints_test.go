@@ -0,0 +1,124 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type constrainedQuery struct {
+	Limit  *int     `query:"limit" validate:"min=1,max=100"`
+	Sort   string   `query:"sort" validate:"enum=asc|desc"`
+	Code   string   `query:"code" validate:"min=2,max=4,regex=^[A-Z,]+$"`
+	Tags   []string `query:"tag" validate:"max=3"`
+	Ratio  float64  `query:"ratio" validate:"min=0.5"`
+	Broken int      `query:"broken" validate:"min=x"`
+}
+
+var constraintTests = []struct {
+	name          string
+	query         string
+	errorExpected string
+}{
+	{name: "valid", query: "limit=10&sort=asc&code=AB,C&tag=a&tag=abc&ratio=0.5&broken=-1"},
+	{name: "absent", query: ""},
+	{name: "below min", query: "limit=0", errorExpected: "limit must be at least 1"},
+	{name: "above max", query: "limit=101", errorExpected: "limit must be at most 100"},
+	{name: "float below min", query: "ratio=0.25", errorExpected: "ratio must be at least 0.5"},
+	{name: "enum", query: "sort=up", errorExpected: "sort must be one of asc or desc"},
+	{name: "string too short", query: "code=A", errorExpected: "code must be at least 2 characters long"},
+	{name: "pattern", query: "code=ab", errorExpected: "code must match the pattern ^[A-Z,]+$"},
+	{name: "slice element", query: "tag=a&tag=abcd", errorExpected: "tag must be at most 3 characters long"},
+	{name: "type error first", query: "limit=many", errorExpected: "limit must be an integer"},
+	{name: "several", query: "limit=0&sort=up", errorExpected: "limit must be at least 1; sort must be one of asc or desc"},
+}
+
+func TestParser_ReadQueryConstraints(t *testing.T) {
+	var testParser Parser
+
+	for _, e := range constraintTests {
+		var q constrainedQuery
+		err := testParser.ReadQuery(httptest.NewRequest(http.MethodGet, "/?"+e.query, nil), &q)
+
+		if e.errorExpected == "" && err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+		}
+		if e.errorExpected != "" && (err == nil || err.Error() != e.errorExpected) {
+			t.Errorf("%s: expected error %q, got %v", e.name, e.errorExpected, err)
+		}
+	}
+}
+
+func TestParser_ReadPath(t *testing.T) {
+	var testParser Parser
+
+	var params struct {
+		ID   UUID `path:"id"`
+		Page int  `path:"page" validate:"min=1"`
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	err := testParser.ReadPath(req, map[string]string{"id": "6ba7b810-9dad-11d1-80b4-00c04fd430c8", "page": "2"}, &params)
+	if err != nil || params.ID.String() != "6ba7b810-9dad-11d1-80b4-00c04fd430c8" || params.Page != 2 {
+		t.Errorf("unexpected path binding: %+v, %v", params, err)
+	}
+
+	err = testParser.ReadPath(req, map[string]string{"id": "x", "page": "0"}, &params)
+	fields := fieldErrors(err)
+	if len(fields) != 2 || fields[0].Field != "id" || fields[1].Message != "must be at least 1" {
+		t.Errorf("expected errors for id and page, got %v", err)
+	}
+}
+
+type shippingOrder struct {
+	Email           string `json:"email" query:"email" validate:"required_without=phone"`
+	Phone           string `json:"phone" query:"phone"`
+	DeliveryMethod  string `json:"delivery_method" query:"delivery_method"`
+	ShippingAddress string `json:"shipping_address" query:"shipping_address" validate:"required_if=delivery_method ship|courier"`
+}
+
+var requirementTests = []struct {
+	name          string
+	json          string
+	query         string
+	errorExpected string
+}{
+	{name: "pickup", json: `{"delivery_method": "pickup", "phone": "555"}`, query: "delivery_method=pickup&phone=555"},
+	{name: "shipped with address", json: `{"delivery_method": "ship", "shipping_address": "1 Main St", "email": "a@example.com"}`, query: "delivery_method=ship&shipping_address=1+Main+St&email=a%40example.com"},
+	{name: "shipped without address", json: `{"delivery_method": "courier", "phone": "555"}`, query: "delivery_method=courier&phone=555", errorExpected: "shipping_address is required when delivery_method is courier"},
+	{name: "null or blank address", json: `{"delivery_method": "ship", "shipping_address": null, "phone": "555"}`, query: "delivery_method=ship&shipping_address=&phone=555", errorExpected: "shipping_address is required when delivery_method is ship"},
+	{name: "no contact", json: `{"delivery_method": "pickup"}`, query: "delivery_method=pickup", errorExpected: "email is required when phone is not given"},
+	{name: "both", json: `{"delivery_method": "ship"}`, query: "delivery_method=ship", errorExpected: "email is required when phone is not given; shipping_address is required when delivery_method is ship"},
+}
+
+func TestParser_ReadJSONRequirements(t *testing.T) {
+	var testParser Parser
+
+	for _, e := range requirementTests {
+		var got shippingOrder
+		err := testParser.ReadJSON(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.json)), &got)
+
+		if e.errorExpected == "" && err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+		}
+		if e.errorExpected != "" && (err == nil || err.Error() != e.errorExpected) {
+			t.Errorf("%s: expected error %q, got %v", e.name, e.errorExpected, err)
+		}
+	}
+}
+
+func TestParser_ReadQueryRequirements(t *testing.T) {
+	var testParser Parser
+
+	for _, e := range requirementTests {
+		var got shippingOrder
+		err := testParser.ReadQuery(httptest.NewRequest(http.MethodGet, "/?"+e.query, nil), &got)
+
+		if e.errorExpected == "" && err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+		}
+		if e.errorExpected != "" && (err == nil || err.Error() != e.errorExpected) {
+			t.Errorf("%s: expected error %q, got %v", e.name, e.errorExpected, err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package ps
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSPolicy configures the cross-origin requests CORS allows.
+type CORSPolicy struct {
+	// Origins lists the allowed origins, such as "https://app.example.com". "*" allows any origin.
+	Origins []string
+	// Methods lists the methods a preflight may ask for (default GET, HEAD and POST).
+	Methods []string
+	// Headers lists the request headers a preflight may ask for. "*" allows any header.
+	Headers []string
+	// ExposedHeaders lists the response headers scripts may read, besides the CORS-safelisted ones.
+	ExposedHeaders []string
+	// Credentials allows requests with cookies or HTTP authentication. It cannot be combined with the "*" origin,
+	// which would let any site make credentialed requests; list the trusted origins instead.
+	Credentials bool
+	// MaxAge is how long browsers may cache a preflight response. If it is 0, they decide.
+	MaxAge time.Duration
+}
+
+// CORS returns middleware that applies policy to cross-origin requests. Preflight requests are answered with 204
+// No Content when the origin, method and headers are allowed, and with a 403 JSON error naming what is not
+// otherwise, without reaching the wrapped handler. Other requests from an allowed origin reach the handler with
+// the CORS headers set; requests from any other origin reach it without them, so browsers hide the response from
+// the calling script.
+//
+// CORS panics if policy allows credentials from any origin.
+func (p *Parser) CORS(policy CORSPolicy) func(http.Handler) http.Handler {
+	if policy.Credentials && containsFold(policy.Origins, "*") {
+		panic(`ps: CORS: Credentials cannot be combined with the "*" origin`)
+	}
+	methods := []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	if len(policy.Methods) > 0 {
+		methods = make([]string, len(policy.Methods))
+		for i, m := range policy.Methods {
+			methods[i] = strings.ToUpper(strings.TrimSpace(m))
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if preflight {
+				AddVary(w, "Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers")
+			} else {
+				AddVary(w, "Origin")
+			}
+
+			allowOrigin, ok := policy.allowOrigin(origin)
+			if !ok {
+				if preflight {
+					_ = p.ErrorJSON(w, fmt.Errorf("origin %s is not allowed", origin), http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("Access-Control-Allow-Origin", allowOrigin)
+			if policy.Credentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if len(policy.ExposedHeaders) > 0 {
+					h.Set("Access-Control-Expose-Headers", strings.Join(policy.ExposedHeaders, ", "))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+			if !containsFold(methods, method) {
+				h.Set("Allow", allowHeader(methods))
+				_ = p.ErrorJSON(w, fmt.Errorf("method %s is not allowed", method), http.StatusForbidden)
+				return
+			}
+			headers := requestedHeaders(r)
+			for _, header := range headers {
+				if !containsFold(policy.Headers, "*") && !containsFold(policy.Headers, header) {
+					_ = p.ErrorJSON(w, fmt.Errorf("header %s is not allowed", header), http.StatusForbidden)
+					return
+				}
+			}
+
+			h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			if len(headers) > 0 {
+				h.Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+			}
+			if policy.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(int(policy.MaxAge/time.Second)))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// allowOrigin reports whether origin is allowed, and the Access-Control-Allow-Origin value to answer it with.
+func (c *CORSPolicy) allowOrigin(origin string) (string, bool) {
+	if containsFold(c.Origins, origin) {
+		return origin, true
+	}
+	if containsFold(c.Origins, "*") {
+		return "*", true
+	}
+	return "", false
+}
+
+// requestedHeaders returns the headers a preflight request asks for.
+func requestedHeaders(r *http.Request) []string {
+	var headers []string
+	for _, value := range r.Header.Values("Access-Control-Request-Headers") {
+		for _, header := range strings.Split(value, ",") {
+			if header = strings.TrimSpace(header); header != "" {
+				headers = append(headers, header)
+			}
+		}
+	}
+	return headers
+}
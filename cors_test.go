@@ -0,0 +1,96 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var corsTests = []struct {
+	name           string
+	policy         CORSPolicy
+	method         string
+	headers        map[string]string
+	statusExpected int
+	allowExpected  string
+}{
+	{name: "same origin", policy: CORSPolicy{Origins: []string{"https://app.example.com"}}, method: http.MethodPost, statusExpected: http.StatusOK},
+	{name: "allowed origin", policy: CORSPolicy{Origins: []string{"https://app.example.com"}}, method: http.MethodPost, headers: map[string]string{"Origin": "https://app.example.com"}, statusExpected: http.StatusOK, allowExpected: "https://app.example.com"},
+	{name: "any origin", policy: CORSPolicy{Origins: []string{"*"}}, method: http.MethodGet, headers: map[string]string{"Origin": "https://app.example.com"}, statusExpected: http.StatusOK, allowExpected: "*"},
+	{name: "other origin", policy: CORSPolicy{Origins: []string{"https://app.example.com"}}, method: http.MethodGet, headers: map[string]string{"Origin": "https://evil.example.com"}, statusExpected: http.StatusOK},
+	{name: "preflight", policy: CORSPolicy{Origins: []string{"https://app.example.com"}, Methods: []string{"put"}, Headers: []string{"Content-Type"}}, method: http.MethodOptions, headers: map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "PUT", "Access-Control-Request-Headers": "content-type"}, statusExpected: http.StatusNoContent, allowExpected: "https://app.example.com"},
+	{name: "preflight other origin", policy: CORSPolicy{Origins: []string{"https://app.example.com"}}, method: http.MethodOptions, headers: map[string]string{"Origin": "https://evil.example.com", "Access-Control-Request-Method": "POST"}, statusExpected: http.StatusForbidden},
+	{name: "preflight method", policy: CORSPolicy{Origins: []string{"*"}}, method: http.MethodOptions, headers: map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "DELETE"}, statusExpected: http.StatusForbidden, allowExpected: "*"},
+	{name: "preflight header", policy: CORSPolicy{Origins: []string{"*"}}, method: http.MethodOptions, headers: map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "POST", "Access-Control-Request-Headers": "X-Secret"}, statusExpected: http.StatusForbidden, allowExpected: "*"},
+	{name: "preflight any header", policy: CORSPolicy{Origins: []string{"*"}, Headers: []string{"*"}}, method: http.MethodOptions, headers: map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "POST", "Access-Control-Request-Headers": "X-Secret"}, statusExpected: http.StatusNoContent, allowExpected: "*"},
+	{name: "plain options", policy: CORSPolicy{Origins: []string{"*"}}, method: http.MethodOptions, headers: map[string]string{"Origin": "https://app.example.com"}, statusExpected: http.StatusOK, allowExpected: "*"},
+}
+
+func TestParser_CORS(t *testing.T) {
+	var testParser Parser
+
+	for _, e := range corsTests {
+		handler := testParser.CORS(e.policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = testParser.WriteJSON(w, http.StatusOK, map[string]bool{"ok": true})
+		}))
+
+		req := httptest.NewRequest(e.method, "/", nil)
+		for k, v := range e.headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.statusExpected {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.statusExpected, rr.Code)
+		}
+		if got := rr.Header().Get("Access-Control-Allow-Origin"); got != e.allowExpected {
+			t.Errorf("%s: expected Access-Control-Allow-Origin %q, got %q", e.name, e.allowExpected, got)
+		}
+		if rr.Code == http.StatusForbidden && rr.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s: expected a JSON error, got Content-Type %q", e.name, rr.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestParser_CORSPreflightHeaders(t *testing.T) {
+	var testParser Parser
+	policy := CORSPolicy{
+		Origins:     []string{"https://app.example.com"},
+		Methods:     []string{http.MethodPut},
+		Headers:     []string{"Content-Type"},
+		Credentials: true,
+		MaxAge:      10 * time.Minute,
+	}
+	handler := testParser.CORS(policy)(http.NotFoundHandler())
+
+	req := httptest.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	for header, expected := range map[string]string{
+		"Access-Control-Allow-Methods":     "PUT",
+		"Access-Control-Allow-Headers":     "Content-Type",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Max-Age":           "600",
+		"Vary":                             "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+	} {
+		if got := rr.Header().Get(header); got != expected {
+			t.Errorf("expected %s %q, got %q", header, expected, got)
+		}
+	}
+}
+
+func TestParser_CORSWildcardCredentials(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected CORS to panic for credentials from any origin")
+		}
+	}()
+	var testParser Parser
+	testParser.CORS(CORSPolicy{Origins: []string{"*"}, Credentials: true})
+}
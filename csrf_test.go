@@ -0,0 +1,69 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var csrfTests = []struct {
+	name           string
+	guard          CSRFGuard
+	method         string
+	headers        map[string]string
+	cookie         string
+	statusExpected int
+}{
+	{name: "safe method", method: http.MethodGet, statusExpected: http.StatusNoContent},
+	{name: "requested with", method: http.MethodPost, headers: map[string]string{"X-Requested-With": "XMLHttpRequest"}, statusExpected: http.StatusNoContent},
+	{name: "token header", method: http.MethodDelete, headers: map[string]string{"X-CSRF-Token": "abc"}, statusExpected: http.StatusNoContent},
+	{name: "custom header", guard: CSRFGuard{HeaderName: "X-Token"}, method: http.MethodPut, headers: map[string]string{"X-Token": "abc"}, statusExpected: http.StatusNoContent},
+	{name: "no header", method: http.MethodPost, statusExpected: http.StatusForbidden},
+	{name: "double submit", guard: CSRFGuard{CookieName: "csrf"}, method: http.MethodPost, headers: map[string]string{"X-CSRF-Token": "abc"}, cookie: "abc", statusExpected: http.StatusNoContent},
+	{name: "double submit mismatch", guard: CSRFGuard{CookieName: "csrf"}, method: http.MethodPost, headers: map[string]string{"X-CSRF-Token": "abc"}, cookie: "xyz", statusExpected: http.StatusForbidden},
+	{name: "double submit no cookie", guard: CSRFGuard{CookieName: "csrf"}, method: http.MethodPatch, headers: map[string]string{"X-CSRF-Token": "abc"}, statusExpected: http.StatusForbidden},
+	{name: "double submit requested with", guard: CSRFGuard{CookieName: "csrf"}, method: http.MethodPost, headers: map[string]string{"X-Requested-With": "XMLHttpRequest"}, cookie: "abc", statusExpected: http.StatusForbidden},
+}
+
+func TestParser_PreventCSRF(t *testing.T) {
+	var testParser Parser
+
+	for _, e := range csrfTests {
+		guard := e.guard
+		handler := testParser.PreventCSRF(&guard)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+		req := httptest.NewRequest(e.method, "/", nil)
+		for k, v := range e.headers {
+			req.Header.Set(k, v)
+		}
+		if e.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "csrf", Value: e.cookie})
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.statusExpected {
+			t.Errorf("%s: expected status %d, got %d: %s", e.name, e.statusExpected, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestCSRFGuard_SetCookie(t *testing.T) {
+	guard := CSRFGuard{CookieName: "csrf"}
+	rr := httptest.NewRecorder()
+	token, err := guard.SetCookie(rr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	for _, c := range rr.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	req.Header.Set("X-CSRF-Token", token)
+	if err := guard.Check(req); err != nil {
+		t.Errorf("expected the issued token to pass, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package ps
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	dateLayout      = "2006-01-02"
+	timeOfDayLayout = "15:04:05"
+)
+
+// Date is a calendar date without a time or time zone, such as a birthday. It is encoded as "2006-01-02".
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// DateOf returns the date of t in t's location.
+func DateOf(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{Year: y, Month: m, Day: d}
+}
+
+// ParseDate parses a date formatted as "2006-01-02".
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return Date{}, fmt.Errorf("invalid date %q (expected YYYY-MM-DD)", s)
+	}
+	return DateOf(t), nil
+}
+
+// String returns the date formatted as "2006-01-02".
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// IsZero reports whether d is the zero Date.
+func (d Date) IsZero() bool {
+	return d == Date{}
+}
+
+// IsValid reports whether d names a real day of the Gregorian calendar.
+func (d Date) IsValid() bool {
+	return DateOf(d.In(time.UTC)) == d
+}
+
+// In returns the time at midnight starting d in loc.
+func (d Date) In(loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, loc)
+}
+
+// Before reports whether d comes before other.
+func (d Date) Before(other Date) bool {
+	return d.In(time.UTC).Before(other.In(time.UTC))
+}
+
+// After reports whether d comes after other.
+func (d Date) After(other Date) bool {
+	return other.Before(d)
+}
+
+// MarshalText implements encoding.TextMarshaler, and through it JSON encoding as a string.
+func (d Date) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, and through it JSON decoding from a string.
+func (d *Date) UnmarshalText(text []byte) error {
+	date, err := ParseDate(string(text))
+	if err != nil {
+		return err
+	}
+	*d = date
+	return nil
+}
+
+func (*Date) expected() string {
+	return "a valid date (YYYY-MM-DD)"
+}
+
+// TimeOfDay is a wall-clock time without a date or time zone, such as an opening hour. It is encoded as
+// "15:04:05".
+type TimeOfDay struct {
+	Hour   int
+	Minute int
+	Second int
+}
+
+// TimeOfDayOf returns the wall-clock time of t in t's location.
+func TimeOfDayOf(t time.Time) TimeOfDay {
+	h, m, s := t.Clock()
+	return TimeOfDay{Hour: h, Minute: m, Second: s}
+}
+
+// ParseTimeOfDay parses a time of day formatted as "15:04:05".
+func ParseTimeOfDay(s string) (TimeOfDay, error) {
+	t, err := time.Parse(timeOfDayLayout, s)
+	if err != nil {
+		return TimeOfDay{}, fmt.Errorf("invalid time of day %q (expected HH:MM:SS)", s)
+	}
+	return TimeOfDayOf(t), nil
+}
+
+// String returns the time of day formatted as "15:04:05".
+func (t TimeOfDay) String() string {
+	return fmt.Sprintf("%02d:%02d:%02d", t.Hour, t.Minute, t.Second)
+}
+
+// IsValid reports whether t is a time between 00:00:00 and 23:59:59.
+func (t TimeOfDay) IsValid() bool {
+	return t.Hour >= 0 && t.Hour < 24 && t.Minute >= 0 && t.Minute < 60 && t.Second >= 0 && t.Second < 60
+}
+
+// On returns the time t on date d in loc.
+func (t TimeOfDay) On(d Date, loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, t.Hour, t.Minute, t.Second, 0, loc)
+}
+
+// Before reports whether t comes before other on the same day.
+func (t TimeOfDay) Before(other TimeOfDay) bool {
+	return t.seconds() < other.seconds()
+}
+
+// After reports whether t comes after other on the same day.
+func (t TimeOfDay) After(other TimeOfDay) bool {
+	return t.seconds() > other.seconds()
+}
+
+// seconds returns the number of seconds since midnight.
+func (t TimeOfDay) seconds() int {
+	return t.Hour*3600 + t.Minute*60 + t.Second
+}
+
+// MarshalText implements encoding.TextMarshaler, and through it JSON encoding as a string.
+func (t TimeOfDay) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, and through it JSON decoding from a string.
+func (t *TimeOfDay) UnmarshalText(text []byte) error {
+	tod, err := ParseTimeOfDay(string(text))
+	if err != nil {
+		return err
+	}
+	*t = tod
+	return nil
+}
+
+func (*TimeOfDay) expected() string {
+	return "a valid time of day (HH:MM:SS)"
+}
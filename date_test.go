@@ -0,0 +1,88 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type dateSample struct {
+	Birthday Date       `json:"birthday"`
+	Opens    TimeOfDay  `json:"opens"`
+	Closes   *TimeOfDay `json:"closes,omitempty"`
+}
+
+var dateTests = []struct {
+	name          string
+	json          string
+	errorExpected bool
+}{
+	{name: "valid", json: `{"birthday":"1990-02-28","opens":"09:00:00","closes":"17:30:00"}`},
+	{name: "impossible date", json: `{"birthday":"1990-02-30"}`, errorExpected: true},
+	{name: "timestamp for date", json: `{"birthday":"1990-02-28T00:00:00Z"}`, errorExpected: true},
+	{name: "number for date", json: `{"birthday":19900228}`, errorExpected: true},
+	{name: "hour out of range", json: `{"opens":"24:00:00"}`, errorExpected: true},
+	{name: "missing seconds", json: `{"opens":"09:00"}`, errorExpected: true},
+}
+
+func TestParser_ReadJSONDate(t *testing.T) {
+	var testParser Parser
+
+	for _, e := range dateTests {
+		var decoded dateSample
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.json))
+		err := testParser.ReadJSON(httptest.NewRecorder(), req, &decoded)
+
+		if e.errorExpected {
+			if err == nil {
+				t.Errorf("%s: error expected, but none received", e.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+			continue
+		}
+
+		if decoded.Birthday != (Date{1990, time.February, 28}) {
+			t.Errorf("%s: wrong date decoded: %v", e.name, decoded.Birthday)
+		}
+		if decoded.Opens != (TimeOfDay{9, 0, 0}) || decoded.Closes == nil || *decoded.Closes != (TimeOfDay{17, 30, 0}) {
+			t.Errorf("%s: wrong times decoded: %v %v", e.name, decoded.Opens, decoded.Closes)
+		}
+
+		rr := httptest.NewRecorder()
+		if err := testParser.WriteJSON(rr, http.StatusOK, decoded); err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+		if rr.Body.String() != e.json {
+			t.Errorf("%s: expected %s, got %s", e.name, e.json, rr.Body.String())
+		}
+	}
+}
+
+func TestDate(t *testing.T) {
+	loc := time.FixedZone("UTC+9", 9*60*60)
+	instant := time.Date(2024, time.March, 1, 23, 30, 0, 0, time.UTC)
+
+	if got := DateOf(instant.In(loc)); got != (Date{2024, time.March, 2}) {
+		t.Errorf("expected the date in the instant's location, got %v", got)
+	}
+	if (Date{2024, time.February, 30}).IsValid() || !(Date{2024, time.February, 29}).IsValid() {
+		t.Error("wrong validity for February dates")
+	}
+	if !(Date{2024, time.March, 1}).Before(Date{2024, time.March, 2}) || (Date{2024, time.March, 1}).After(Date{2024, time.March, 1}) {
+		t.Error("wrong date ordering")
+	}
+
+	opens := TimeOfDay{9, 0, 0}
+	if got := opens.On(Date{2024, time.March, 1}, loc); !got.Equal(time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("wrong instant for time of day: %v", got)
+	}
+	if (TimeOfDay{24, 0, 0}).IsValid() || !opens.Before(TimeOfDay{9, 0, 1}) {
+		t.Error("wrong validity or ordering for times of day")
+	}
+}
@@ -0,0 +1,31 @@
+package ps
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// writeDeadline gives the next write through rc WriteTimeout to finish, if the Parser has one. ResponseWriters
+// that cannot set deadlines, such as httptest.ResponseRecorder, are written without one.
+func (p *Parser) writeDeadline(rc *http.ResponseController) error {
+	if p.WriteTimeout <= 0 {
+		return nil
+	}
+	if err := rc.SetWriteDeadline(time.Now().Add(p.WriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// fullDuplex lets the handler go on reading the request body while the response is written through rc, if the
+// Parser has FullDuplex set and the server supports it.
+func (p *Parser) fullDuplex(rc *http.ResponseController) error {
+	if !p.FullDuplex {
+		return nil
+	}
+	if err := rc.EnableFullDuplex(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
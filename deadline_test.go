@@ -0,0 +1,62 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// deadlineRecorder is a ResponseRecorder that supports the deadline and full-duplex controls of
+// http.ResponseController, recording their use.
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	deadlines  []time.Time
+	fullDuplex bool
+}
+
+func (d *deadlineRecorder) SetWriteDeadline(t time.Time) error {
+	d.deadlines = append(d.deadlines, t)
+	return nil
+}
+
+func (d *deadlineRecorder) EnableFullDuplex() error {
+	d.fullDuplex = true
+	return nil
+}
+
+func TestParser_WriteTimeout(t *testing.T) {
+	testParser := New(WithWriteTimeout(time.Minute), WithFullDuplex(true))
+
+	rr := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+	start := time.Now()
+	if err := testParser.WriteJSON(rr, http.StatusOK, "ok"); err != nil {
+		t.Fatal(err)
+	}
+	if len(rr.deadlines) != 1 || rr.deadlines[0].Before(start.Add(time.Minute)) {
+		t.Errorf("expected one deadline a minute out, got %v", rr.deadlines)
+	}
+
+	rr = &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+	s := testParser.NewStreamWriter(rr, http.StatusOK, NDJSONStream)
+	_ = s.Write(1)
+	_ = s.Write(2)
+	if len(rr.deadlines) != 2 || !rr.fullDuplex {
+		t.Errorf("expected a deadline per value and full duplex, got %v and %v", rr.deadlines, rr.fullDuplex)
+	}
+
+	fixed := start.Add(time.Hour)
+	if err := s.SetWriteDeadline(fixed); err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Write(3)
+	_ = s.Close()
+	if len(rr.deadlines) != 3 || !rr.deadlines[2].Equal(fixed) {
+		t.Errorf("expected the fixed deadline to replace the write timeout, got %v", rr.deadlines)
+	}
+
+	// Writers without deadline support are written without one.
+	if err := testParser.NewStreamWriter(httptest.NewRecorder(), http.StatusOK, ArrayStream).Write(1); err != nil {
+		t.Errorf("expected no error from a plain recorder, got %v", err)
+	}
+}
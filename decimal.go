@@ -0,0 +1,247 @@
+package ps
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an exact decimal number, for amounts that must not pick up floating-point error. It is encoded as a
+// JSON string such as "12.50", keeping the scale it was given, and decoded from either a string or a number.
+//
+// A decimal struct tag limits the values a field accepts, in the manner of SQL's NUMERIC(precision, scale):
+// `decimal:"10,2"` allows at most two decimal places and eight digits before the decimal point.
+//
+// The zero value is 0.
+type Decimal struct {
+	// coef is the unscaled value; nil means zero.
+	coef *big.Int
+	// scale is the number of digits after the decimal point.
+	scale int
+}
+
+// NewDecimal returns unscaled × 10^-scale, so NewDecimal(1250, 2) is 12.50.
+func NewDecimal(unscaled int64, scale int) Decimal {
+	if scale < 0 {
+		return Decimal{coef: new(big.Int).Mul(big.NewInt(unscaled), pow10(-scale))}
+	}
+	return Decimal{coef: big.NewInt(unscaled), scale: scale}
+}
+
+// ParseDecimal parses a decimal number such as "-12.50" or "1.5e3".
+func ParseDecimal(s string) (Decimal, error) {
+	mantissa, exponent, hasExponent := strings.Cut(strings.ToLower(s), "e")
+	whole, fraction, hasPoint := strings.Cut(mantissa, ".")
+
+	digits := strings.TrimLeft(whole, "+-")
+	if len(whole)-len(digits) > 1 || digits == "" || hasPoint && fraction == "" ||
+		!isDigits(digits) || !isDigits(fraction) {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+
+	coef, ok := new(big.Int).SetString(whole+fraction, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	scale := len(fraction)
+
+	if hasExponent {
+		exp, err := strconv.Atoi(exponent)
+		if err != nil || exp > 1000 || exp < -1000 {
+			return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+		}
+		scale -= exp
+		if scale < 0 {
+			coef.Mul(coef, pow10(-scale))
+			scale = 0
+		}
+	}
+
+	return Decimal{coef: coef, scale: scale}, nil
+}
+
+// isDigits reports whether s consists of ASCII digits only.
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// pow10 returns 10^n.
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// unscaled returns the coefficient of d, which must not be modified.
+func (d Decimal) unscaled() *big.Int {
+	if d.coef == nil {
+		return new(big.Int)
+	}
+	return d.coef
+}
+
+// rescale returns the coefficient of d at a scale at least as large as d's.
+func (d Decimal) rescale(scale int) *big.Int {
+	return new(big.Int).Mul(d.unscaled(), pow10(scale-d.scale))
+}
+
+// String returns d with exactly Scale digits after the decimal point.
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(d.unscaled()).String()
+	if d.scale > 0 {
+		if len(digits) <= d.scale {
+			digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-d.scale] + "." + digits[len(digits)-d.scale:]
+	}
+	if d.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// Scale returns the number of digits after the decimal point.
+func (d Decimal) Scale() int {
+	return d.scale
+}
+
+// Precision returns the number of significant digits in d, counting trailing zeros after the decimal point.
+func (d Decimal) Precision() int {
+	return len(new(big.Int).Abs(d.unscaled()).String())
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of d.
+func (d Decimal) Sign() int {
+	return d.unscaled().Sign()
+}
+
+// IsZero reports whether d is zero, at any scale.
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// Cmp compares d and other, returning -1, 0 or +1. Scale does not matter: 1.5 and 1.50 are equal.
+func (d Decimal) Cmp(other Decimal) int {
+	scale := max(d.scale, other.scale)
+	return d.rescale(scale).Cmp(other.rescale(scale))
+}
+
+// Equal reports whether d and other are the same number.
+func (d Decimal) Equal(other Decimal) bool {
+	return d.Cmp(other) == 0
+}
+
+// Neg returns -d.
+func (d Decimal) Neg() Decimal {
+	return Decimal{coef: new(big.Int).Neg(d.unscaled()), scale: d.scale}
+}
+
+// Add returns d + other, at the larger of the two scales.
+func (d Decimal) Add(other Decimal) Decimal {
+	scale := max(d.scale, other.scale)
+	return Decimal{coef: new(big.Int).Add(d.rescale(scale), other.rescale(scale)), scale: scale}
+}
+
+// Sub returns d - other, at the larger of the two scales.
+func (d Decimal) Sub(other Decimal) Decimal {
+	return d.Add(other.Neg())
+}
+
+// Mul returns d × other, at the sum of the two scales.
+func (d Decimal) Mul(other Decimal) Decimal {
+	return Decimal{coef: new(big.Int).Mul(d.unscaled(), other.unscaled()), scale: d.scale + other.scale}
+}
+
+// Round returns d rounded to places digits after the decimal point, rounding halves away from zero. A d with
+// fewer places is padded with zeros, so the result always has scale places.
+func (d Decimal) Round(places int) Decimal {
+	if places < 0 {
+		places = 0
+	}
+	if places >= d.scale {
+		return Decimal{coef: d.rescale(places), scale: places}
+	}
+
+	divisor := pow10(d.scale - places)
+	q, r := new(big.Int).QuoRem(new(big.Int).Abs(d.unscaled()), divisor, new(big.Int))
+	if r.Lsh(r, 1).Cmp(divisor) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if d.Sign() < 0 {
+		q.Neg(q)
+	}
+
+	return Decimal{coef: q, scale: places}
+}
+
+// Float64 returns the nearest float64 to d, for display or approximate arithmetic.
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// MarshalText implements encoding.TextMarshaler, and through it JSON encoding as a string.
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Decimal) UnmarshalText(text []byte) error {
+	dec, err := ParseDecimal(string(text))
+	if err != nil {
+		return err
+	}
+	*d = dec
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting a JSON string or number. Numbers are read from their text,
+// so no precision is lost.
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	if s, err := strconv.Unquote(string(b)); err == nil {
+		b = []byte(s)
+	}
+	return d.UnmarshalText(b)
+}
+
+func (*Decimal) expected() string {
+	return "a decimal number"
+}
+
+// decimalLimits are the precision and scale given in a decimal struct tag.
+type decimalLimits struct {
+	precision int
+	scale     int
+}
+
+// parseDecimalTag parses a decimal struct tag such as "10,2". A tag that does not parse sets no limits.
+func parseDecimalTag(tag string) decimalLimits {
+	p, s, ok := strings.Cut(tag, ",")
+	precision, err1 := strconv.Atoi(p)
+	scale, err2 := strconv.Atoi(s)
+	if !ok || err1 != nil || err2 != nil || precision <= 0 || scale < 0 || scale > precision {
+		return decimalLimits{}
+	}
+	return decimalLimits{precision: precision, scale: scale}
+}
+
+// check reports a FieldError if d does not fit within the limits.
+func (l decimalLimits) check(d Decimal, path string) error {
+	if l.precision == 0 {
+		return nil
+	}
+	if d.Scale() > l.scale {
+		return &FieldError{Field: path, Message: fmt.Sprintf("must have at most %d decimal places", l.scale)}
+	}
+	if d.Precision()-d.Scale() > l.precision-l.scale && !d.IsZero() {
+		return &FieldError{Field: path, Message: fmt.Sprintf("must have at most %d digits before the decimal point", l.precision-l.scale)}
+	}
+	return nil
+}
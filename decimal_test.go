@@ -0,0 +1,134 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var parseDecimalTests = []struct {
+	input         string
+	expected      string
+	errorExpected bool
+}{
+	{input: "12.50", expected: "12.50"},
+	{input: "-0.05", expected: "-0.05"},
+	{input: "+7", expected: "7"},
+	{input: "1.5e3", expected: "1500"},
+	{input: "15e-3", expected: "0.015"},
+	{input: "0.1", expected: "0.1"},
+	{input: "", errorExpected: true},
+	{input: ".5", errorExpected: true},
+	{input: "5.", errorExpected: true},
+	{input: "--5", errorExpected: true},
+	{input: "1,5", errorExpected: true},
+	{input: "NaN", errorExpected: true},
+}
+
+func TestParseDecimal(t *testing.T) {
+	for _, e := range parseDecimalTests {
+		d, err := ParseDecimal(e.input)
+		if e.errorExpected {
+			if err == nil {
+				t.Errorf("%q: error expected, but none received", e.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: error not expected, but one received: %v", e.input, err)
+			continue
+		}
+		if d.String() != e.expected {
+			t.Errorf("%q: expected %s, got %s", e.input, e.expected, d)
+		}
+	}
+}
+
+func TestDecimal_Arithmetic(t *testing.T) {
+	a, _ := ParseDecimal("0.1")
+	b, _ := ParseDecimal("0.2")
+	if sum := a.Add(b); sum.String() != "0.3" {
+		t.Errorf("expected 0.1 + 0.2 = 0.3, got %s", sum)
+	}
+	if diff := a.Sub(NewDecimal(25, 2)); diff.String() != "-0.15" {
+		t.Errorf("expected -0.15, got %s", diff)
+	}
+	if !NewDecimal(150, 2).Equal(NewDecimal(15, 1)) {
+		t.Error("expected 1.50 to equal 1.5")
+	}
+
+	for input, expected := range map[string]string{"2.345": "2.35", "-2.345": "-2.35", "2.344": "2.34", "2": "2.00"} {
+		d, _ := ParseDecimal(input)
+		if got := d.Round(2).String(); got != expected {
+			t.Errorf("expected %s to round to %s, got %s", input, expected, got)
+		}
+	}
+}
+
+func TestMoney(t *testing.T) {
+	price, err := NewMoney("19.99", "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rate, _ := ParseDecimal("0.21")
+	if tax := price.Mul(rate); tax.String() != "4.20 EUR" {
+		t.Errorf("expected 4.20 EUR, got %s", tax)
+	}
+	if _, err := price.Add(Money{Amount: NewDecimal(1, 0), Currency: "USD"}); err == nil {
+		t.Error("expected an error adding different currencies")
+	}
+	if yen := (Money{Amount: NewDecimal(1005, 1), Currency: "JPY"}); yen.Validate() == nil || yen.Round().String() != "101 JPY" {
+		t.Errorf("expected fractional yen to be invalid and round to 101 JPY, got %s", yen.Round())
+	}
+	if _, err := NewMoney("1", "eur"); err == nil {
+		t.Error("expected an error for a lower-case currency code")
+	}
+}
+
+var decimalJSONTests = []struct {
+	name          string
+	json          string
+	expected      string
+	errorExpected string
+}{
+	{name: "string", json: `{"price":"12.50","total":{"amount":"100.00","currency":"EUR"}}`, expected: `{"price":"12.50","total":{"amount":"100.00","currency":"EUR"}}`},
+	{name: "number keeps its digits", json: `{"price":0.30}`, expected: `{"price":"0.30","total":{"amount":"0","currency":""}}`},
+	{name: "too many places", json: `{"price":"1.005"}`, errorExpected: "price must have at most 2 decimal places"},
+	{name: "too large", json: `{"price":"12345678.9"}`, errorExpected: "price must have at most 6 digits before the decimal point"},
+	{name: "not a number", json: `{"price":"ten"}`, errorExpected: "price must be a decimal number"},
+	{name: "nested", json: `{"total":{"amount":true}}`, errorExpected: "total.amount must be a decimal number"},
+}
+
+func TestParser_ReadJSONDecimal(t *testing.T) {
+	var testParser Parser
+
+	for _, e := range decimalJSONTests {
+		var decoded struct {
+			Price Decimal `json:"price" decimal:"8,2"`
+			Total Money   `json:"total"`
+		}
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.json))
+		err := testParser.ReadJSON(httptest.NewRecorder(), req, &decoded)
+
+		if e.errorExpected != "" {
+			if err == nil || err.Error() != e.errorExpected {
+				t.Errorf("%s: expected error %q, got %v", e.name, e.errorExpected, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+			continue
+		}
+
+		rr := httptest.NewRecorder()
+		if err := testParser.WriteJSON(rr, http.StatusOK, decoded); err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+		if rr.Body.String() != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,493 @@
+package ps
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// decodeOptions are the Parser settings that change how request bodies are decoded.
+type decodeOptions struct {
+	timeFormat     string
+	durationFormat string
+	int64AsString  bool
+	decoders       *decoderSet
+	tagName        string
+	rejectReadOnly bool
+	// disallowUnknown is only consulted when keys are renamed from tagName to json names.
+	disallowUnknown bool
+}
+
+// decodeOptions returns the decoding settings of the Parser.
+func (p *Parser) decodeOptions() decodeOptions {
+	o := decodeOptions{
+		timeFormat:     p.TimeFormat,
+		durationFormat: p.DurationFormat,
+		int64AsString:  p.Int64AsString,
+		decoders:       p.decoders,
+		tagName:        p.TagName,
+		rejectReadOnly: p.ReadOnlyFields == RejectReadOnlyFields,
+	}
+	if p.TagName != "" {
+		o.disallowUnknown = !p.AllowUnknownFields
+	}
+	return o
+}
+
+// decodeNeeds caches decodeOptions.needs.
+var decodeNeeds sync.Map
+
+// needs reports whether a body decoded into type t must be prepared first, because a setting or struct tag
+// changes the wire format of something t contains.
+func (o decodeOptions) needs(t reflect.Type) bool {
+	key := typeKey[decodeOptions]{typ: t, opts: o}
+	if needs, ok := decodeNeeds.Load(key); ok {
+		return needs.(bool)
+	}
+
+	needs := o.search(t, make(map[reflect.Type]bool))
+	decodeNeeds.Store(key, needs)
+
+	return needs
+}
+
+// search looks for anything in t whose wire format needs preparing, visiting each type once.
+func (o decodeOptions) search(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	if _, ok := o.decoders.lookup(t); ok {
+		return true
+	}
+	switch t {
+	case timeType:
+		return o.timeFormat != ""
+	case durationType:
+		return o.durationFormat != ""
+	}
+	if reflect.PointerTo(t).Implements(textValueType) {
+		return true
+	}
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Int64, reflect.Uint64:
+		return o.int64AsString
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return o.search(t.Elem(), seen)
+	case reflect.Struct:
+		info := o.fields(t)
+		if info.altTag {
+			return true
+		}
+		for _, f := range info.fields {
+			if f.format != "" || f.required || f.readOnly || len(f.requirements) > 0 || len(f.enum) > 0 || o.search(f.typ, seen) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// fields returns the fields of struct type t, read from the Parser's tag.
+func (o decodeOptions) fields(t reflect.Type) *structInfo {
+	if o.tagName == "" {
+		return structFields(t)
+	}
+	return taggedFields(t, o.tagName)
+}
+
+// prepareBody reads a single JSON value from body, rewrites the parts of it whose wire format differs from what
+// encoding/json expects for type t, and returns the result as JSON for the standard decoder. The rewritten tree is
+// returned too, for assignDecoded.
+func (o decodeOptions) prepareBody(body io.Reader, t reflect.Type) ([]byte, any, error) {
+	dec := json.NewDecoder(body)
+	dec.UseNumber()
+
+	var tree any
+	if err := dec.Decode(&tree); err != nil {
+		return nil, nil, err
+	}
+	if err := checkEOF(dec); err != nil {
+		return nil, nil, err
+	}
+
+	tree, err := o.prepare(tree, t, nil, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prepared, err := json.Marshal(tree)
+	return prepared, tree, err
+}
+
+// prepare rewrites node, which will be decoded into type t, and returns the result. f is the struct field node
+// belongs to, if any, and path names it for error messages.
+func (o decodeOptions) prepare(node any, t reflect.Type, f *field, path string) (any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if fn, ok := o.decoders.lookup(t); ok {
+		if node == nil {
+			return node, nil
+		}
+		return decodeRegistered(fn, node, t, path)
+	}
+
+	format := ""
+	if f != nil {
+		format = f.format
+		if err := checkEnum(node, f.enum, path); err != nil {
+			return nil, err
+		}
+	}
+
+	if t == timeType {
+		if format == "" {
+			format = o.timeFormat
+		}
+		if format == "" || node == nil {
+			return node, nil
+		}
+		return parseTime(node, format, path)
+	}
+	if t == durationType {
+		if format == "" {
+			format = o.durationFormat
+		}
+		if format == "" || node == nil {
+			return node, nil
+		}
+		return parseDuration(node, format, path)
+	}
+	if reflect.PointerTo(t).Implements(textValueType) {
+		if node == nil {
+			return node, nil
+		}
+		v, err := parseTextValue(node, t, path)
+		if err != nil {
+			return nil, err
+		}
+		if d, ok := v.(*Decimal); ok && f != nil {
+			return node, f.decimal.check(*d, path)
+		}
+		return node, nil
+	}
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		return node, nil
+	}
+	if quotesInt(t, format, o.int64AsString) && (f == nil || !f.quoted) {
+		return parseIntString(node, t, path)
+	}
+
+	var err error
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := node.(map[string]any)
+		if !ok {
+			break
+		}
+		if err := o.checkRequired(obj, t, path); err != nil {
+			return nil, err
+		}
+		if err := o.stripReadOnly(obj, t, path); err != nil {
+			return nil, err
+		}
+		if o.tagName != "" {
+			return o.prepareRenamed(obj, t, path)
+		}
+		info := structFields(t)
+		for key, child := range obj {
+			if sf := info.lookup(key); sf != nil {
+				if obj[key], err = o.prepare(child, sf.typ, sf, joinPath(path, sf.name)); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		arr, ok := node.([]any)
+		if !ok {
+			break
+		}
+		for i, child := range arr {
+			if arr[i], err = o.prepare(child, t.Elem(), nil, path); err != nil {
+				return nil, err
+			}
+		}
+
+	case reflect.Map:
+		obj, ok := node.(map[string]any)
+		if !ok {
+			break
+		}
+		for key, child := range obj {
+			if obj[key], err = o.prepare(child, t.Elem(), nil, path); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return node, nil
+}
+
+// checkRequired reports the required fields of struct type t that obj lacks, or holds null for, as a
+// ValidationError, together with those its conditional rules require.
+func (o decodeOptions) checkRequired(obj map[string]any, t reflect.Type, path string) error {
+	var missing []FieldError
+
+	info := o.fields(t)
+	for i := range info.fields {
+		f := &info.fields[i]
+		if !f.required {
+			continue
+		}
+
+		present := false
+		for key, child := range obj {
+			if child != nil && strings.EqualFold(key, f.name) {
+				present = true
+				break
+			}
+		}
+		if !present {
+			missing = append(missing, FieldError{Field: joinPath(path, f.name), Message: "is required"})
+		}
+	}
+	missing = append(missing, checkRequirements(info, path, func(f *field) (string, bool) {
+		for key, child := range obj {
+			if child != nil && strings.EqualFold(key, f.name) {
+				return scalarText(child), true
+			}
+		}
+		return "", false
+	})...)
+
+	if len(missing) > 0 {
+		return &ValidationError{Fields: missing}
+	}
+	return nil
+}
+
+// scalarText returns a string, number or boolean JSON value as text, and "" for other values.
+func scalarText(node any) string {
+	switch node := node.(type) {
+	case string:
+		return node
+	case json.Number:
+		return node.String()
+	case bool:
+		return strconv.FormatBool(node)
+	}
+	return ""
+}
+
+// prepareRenamed prepares obj, which will be decoded into struct type t, and renames its keys from the names in
+// the Parser's tag to the names encoding/json knows the fields by. Keys that name no field are dropped, or
+// rejected if unknown fields are not allowed.
+func (o decodeOptions) prepareRenamed(obj map[string]any, t reflect.Type, path string) (any, error) {
+	info, jsonInfo := o.fields(t), structFields(t)
+
+	out := make(map[string]any, len(obj))
+	for key, child := range obj {
+		sf := info.lookup(key)
+		var jf *field
+		if sf != nil {
+			jf = jsonInfo.byIndex(sf.index)
+		}
+		if jf == nil {
+			if o.disallowUnknown {
+				return nil, fmt.Errorf("json: unknown field %q", key)
+			}
+			continue
+		}
+
+		// The string option may differ between the two tags; encoding/json follows the json one.
+		if sf.quoted && !jf.quoted {
+			if text, ok := child.(string); ok {
+				dec := json.NewDecoder(strings.NewReader(text))
+				dec.UseNumber()
+				if dec.Decode(&child) != nil {
+					return nil, fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal %q into %v", text, sf.typ)
+				}
+			}
+		}
+
+		child, err := o.prepare(child, sf.typ, sf, joinPath(path, sf.name))
+		if err != nil {
+			return nil, err
+		}
+		if !sf.quoted && jf.quoted && child != nil {
+			b, err := json.Marshal(child)
+			if err != nil {
+				return nil, err
+			}
+			child = string(b)
+		}
+		out[jf.name] = child
+	}
+
+	return out, nil
+}
+
+// checkEnum reports a FieldError if node, or an element of it if it is an array, is not one of the values an enum
+// tag allows. Numbers and booleans are compared by their JSON text, and null is always allowed.
+func checkEnum(node any, enum []string, path string) error {
+	if len(enum) == 0 || node == nil {
+		return nil
+	}
+	if arr, ok := node.([]any); ok {
+		for _, elem := range arr {
+			if err := checkEnum(elem, enum, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if !slices.Contains(enum, scalarText(node)) {
+		return &FieldError{Field: path, Message: "must be one of " + joinChoices(enum)}
+	}
+	return nil
+}
+
+// parseTime converts a time sent in format into the RFC 3339 string encoding/json expects.
+func parseTime(node any, format, path string) (any, error) {
+	var t time.Time
+
+	switch format {
+	case TimeRFC3339:
+		return node, nil
+
+	case TimeUnix, TimeUnixMilli:
+		n, ok := node.(json.Number)
+		if !ok {
+			return nil, timeError(format, path)
+		}
+		if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+			if format == TimeUnix {
+				t = time.Unix(i, 0)
+			} else {
+				t = time.UnixMilli(i)
+			}
+			break
+		}
+		f, err := n.Float64()
+		if err != nil || format != TimeUnix {
+			return nil, timeError(format, path)
+		}
+		sec, frac := math.Modf(f)
+		t = time.Unix(int64(sec), int64(frac*1e9))
+
+	default:
+		s, ok := node.(string)
+		if !ok {
+			return nil, timeError(format, path)
+		}
+		var err error
+		if t, err = time.Parse(format, s); err != nil {
+			return nil, timeError(format, path)
+		}
+	}
+
+	return t.UTC().Format(time.RFC3339Nano), nil
+}
+
+// timeError describes a time that was not sent in the expected format.
+func timeError(format, path string) error {
+	var expected string
+	switch format {
+	case TimeUnix:
+		expected = "seconds since the Unix epoch"
+	case TimeUnixMilli:
+		expected = "milliseconds since the Unix epoch"
+	default:
+		expected = "a time formatted as " + strconv.Quote(format)
+	}
+	return fmt.Errorf("body contains invalid time for field %q (expected %s)", path, expected)
+}
+
+// parseDuration converts a duration sent in format into the integer nanoseconds encoding/json expects.
+func parseDuration(node any, format, path string) (any, error) {
+	var d time.Duration
+
+	switch format {
+	case DurationString:
+		s, ok := node.(string)
+		if !ok {
+			return nil, durationError(format, path)
+		}
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return nil, durationError(format, path)
+		}
+
+	case DurationSeconds:
+		n, ok := node.(json.Number)
+		if !ok {
+			return nil, durationError(format, path)
+		}
+		seconds, err := n.Float64()
+		if err != nil || math.Abs(seconds) > math.MaxInt64/float64(time.Second) {
+			return nil, durationError(format, path)
+		}
+		d = time.Duration(math.Round(seconds * float64(time.Second)))
+
+	default:
+		return nil, fmt.Errorf("unknown duration format %q", format)
+	}
+
+	return json.Number(strconv.FormatInt(int64(d), 10)), nil
+}
+
+// durationError describes a duration that was not sent in the expected format.
+func durationError(format, path string) error {
+	expected := `a duration such as "1m30s"`
+	if format == DurationSeconds {
+		expected = "a number of seconds"
+	}
+	return fmt.Errorf("body contains invalid duration for field %q (expected %s)", path, expected)
+}
+
+// parseIntString accepts an integer sent either as a number or as a string, and returns it as a number.
+func parseIntString(node any, t reflect.Type, path string) (any, error) {
+	s, ok := node.(string)
+	if !ok {
+		return node, nil
+	}
+
+	var err error
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		_, err = strconv.ParseInt(s, 10, t.Bits())
+	default:
+		_, err = strconv.ParseUint(s, 10, t.Bits())
+	}
+	if err != nil {
+		return nil, &FieldError{Field: path, Message: "must be an integer"}
+	}
+
+	return json.Number(s), nil
+}
+
+// joinPath appends a field name to a dotted path.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return strings.Join([]string{path, name}, ".")
+}
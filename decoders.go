@@ -0,0 +1,130 @@
+package ps
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// DecodeFunc decodes raw, the JSON sent for one value, into dst, a pointer to the type the function was
+// registered for.
+type DecodeFunc func(raw []byte, dst any) error
+
+// decoderSet holds the registered DecodeFuncs. A set is never changed once built; registering a function
+// replaces the Parser's set with a new one, so that cached decisions keyed on the set stay valid.
+type decoderSet struct {
+	funcs map[reflect.Type]DecodeFunc
+}
+
+// lookup returns the DecodeFunc registered for t.
+func (s *decoderSet) lookup(t reflect.Type) (DecodeFunc, bool) {
+	if s == nil {
+		return nil, false
+	}
+	fn, ok := s.funcs[t]
+	return fn, ok
+}
+
+// RegisterDecoder registers fn to decode values of the same type as sample wherever ReadJSON meets them, taking
+// precedence over any UnmarshalJSON method. This lets third-party types without JSON support be bound without
+// wrapping them. An error from fn is reported as a FieldError for the field being decoded.
+func (p *Parser) RegisterDecoder(sample any, fn DecodeFunc) {
+	p.mustNotBeFrozen("RegisterDecoder")
+
+	set := &decoderSet{funcs: make(map[reflect.Type]DecodeFunc)}
+	if p.decoders != nil {
+		for t, f := range p.decoders.funcs {
+			set.funcs[t] = f
+		}
+	}
+	set.funcs[reflect.TypeOf(sample)] = fn
+
+	p.decoders = set
+}
+
+// decodedValue stands in the prepared body for a value already decoded by a DecodeFunc. encoding/json sees it as
+// null, and assignDecoded stores the value once the rest of the body has been decoded.
+type decodedValue struct {
+	v reflect.Value
+}
+
+// MarshalJSON implements json.Marshaler.
+func (decodedValue) MarshalJSON() ([]byte, error) {
+	return []byte("null"), nil
+}
+
+// decodeRegistered runs the DecodeFunc fn on node, which will be decoded into type t.
+func decodeRegistered(fn DecodeFunc, node any, t reflect.Type, path string) (any, error) {
+	raw, err := json.Marshal(node)
+	if err != nil {
+		return nil, err
+	}
+
+	dst := reflect.New(t)
+	if err := fn(raw, dst.Interface()); err != nil {
+		return nil, &FieldError{Field: path, Message: "is not valid: " + err.Error()}
+	}
+
+	return decodedValue{v: dst.Elem()}, nil
+}
+
+// assignDecoded walks the prepared tree alongside v, which encoding/json has decoded it into, and stores the
+// values decoded by DecodeFuncs in their places.
+func assignDecoded(node any, v reflect.Value) {
+	if d, ok := node.(decodedValue); ok {
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v.Set(d.v)
+		return
+	}
+
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch n := node.(type) {
+	case map[string]any:
+		switch v.Kind() {
+		case reflect.Struct:
+			info := structFields(v.Type())
+			for key, child := range n {
+				if f := info.lookup(key); f != nil {
+					if fv, ok := fieldByIndex(v, f.index); ok {
+						assignDecoded(child, fv)
+					}
+				}
+			}
+
+		case reflect.Map:
+			// Values of maps with string keys are copied, filled in and stored back.
+			if v.Type().Key().Kind() != reflect.String {
+				return
+			}
+			for key, child := range n {
+				k := reflect.ValueOf(key).Convert(v.Type().Key())
+				value := reflect.New(v.Type().Elem()).Elem()
+				if current := v.MapIndex(k); current.IsValid() {
+					value.Set(current)
+				}
+				assignDecoded(child, value)
+				v.SetMapIndex(k, value)
+			}
+		}
+
+	case []any:
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return
+		}
+		for i, child := range n {
+			if i < v.Len() {
+				assignDecoded(child, v.Index(i))
+			}
+		}
+	}
+}
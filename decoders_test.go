@@ -0,0 +1,77 @@
+package ps
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// decoderPoint stands in for a third-party type with unexported fields and no JSON support.
+type decoderPoint struct {
+	x, y int
+}
+
+var decoderTests = []struct {
+	name          string
+	json          string
+	errorExpected string
+}{
+	{name: "all places", json: `{"origin":"1,2","target":"3,4","path":["5,6","7,8"],"named":{"home":"9,10"}}`},
+	{name: "null pointer", json: `{"origin":"1,2","target":null}`},
+	{name: "invalid", json: `{"path":["5,6","x"]}`, errorExpected: `path is not valid: expected "x,y", got "x"`},
+	{name: "wrong type", json: `{"origin":[1,2]}`, errorExpected: "origin is not valid: json: cannot unmarshal array into Go value of type string"},
+}
+
+func TestParser_RegisterDecoder(t *testing.T) {
+	var testParser Parser
+	testParser.RegisterDecoder(decoderPoint{}, func(raw []byte, dst any) error {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return err
+		}
+		p := dst.(*decoderPoint)
+		if _, err := fmt.Sscanf(s, "%d,%d", &p.x, &p.y); err != nil {
+			return fmt.Errorf("expected %q, got %q", "x,y", s)
+		}
+		return nil
+	})
+
+	for _, e := range decoderTests {
+		var decoded struct {
+			Origin decoderPoint            `json:"origin"`
+			Target *decoderPoint           `json:"target"`
+			Path   []decoderPoint          `json:"path"`
+			Named  map[string]decoderPoint `json:"named"`
+		}
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.json))
+		err := testParser.ReadJSON(httptest.NewRecorder(), req, &decoded)
+
+		if e.errorExpected != "" {
+			var fieldError *FieldError
+			if !errors.As(err, &fieldError) || err.Error() != e.errorExpected {
+				t.Errorf("%s: expected error %q, got %v", e.name, e.errorExpected, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+			continue
+		}
+
+		if decoded.Origin != (decoderPoint{1, 2}) {
+			t.Errorf("%s: wrong origin %v", e.name, decoded.Origin)
+		}
+		if strings.Contains(e.json, `"target":"3,4"`) {
+			if decoded.Target == nil || *decoded.Target != (decoderPoint{3, 4}) || len(decoded.Path) != 2 ||
+				decoded.Path[1] != (decoderPoint{7, 8}) || decoded.Named["home"] != (decoderPoint{9, 10}) {
+				t.Errorf("%s: wrong values %+v", e.name, decoded)
+			}
+		} else if decoded.Target != nil {
+			t.Errorf("%s: expected a nil target, got %v", e.name, decoded.Target)
+		}
+	}
+}
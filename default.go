@@ -0,0 +1,22 @@
+package ps
+
+import "net/http"
+
+// DefaultParser is the Parser used by the package-level ReadJSON, WriteJSON and ErrorJSON functions. Configure it
+// once at start-up, before handling requests; it is not safe to change while requests are in flight.
+var DefaultParser = &Parser{}
+
+// ReadJSON reads the JSON body of r into data using DefaultParser.
+func ReadJSON(w http.ResponseWriter, r *http.Request, data any) error {
+	return DefaultParser.ReadJSON(w, r, data)
+}
+
+// WriteJSON writes data as a JSON response using DefaultParser.
+func WriteJSON(w http.ResponseWriter, status int, data any, headers ...http.Header) error {
+	return DefaultParser.WriteJSON(w, status, data, headers...)
+}
+
+// ErrorJSON writes err as a JSON error response using DefaultParser.
+func ErrorJSON(w http.ResponseWriter, err error, status ...int) error {
+	return DefaultParser.ErrorJSON(w, err, status...)
+}
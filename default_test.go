@@ -0,0 +1,44 @@
+package ps
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDefaultParser(t *testing.T) {
+	saved := DefaultParser
+	defer func() { DefaultParser = saved }()
+	DefaultParser = &Parser{MaxJSONSize: 16}
+
+	var decoded struct {
+		Foo string `json:"foo"`
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"foo": "bar"}`))
+	if err := ReadJSON(httptest.NewRecorder(), req, &decoded); err != nil || decoded.Foo != "bar" {
+		t.Errorf("expected the body to be read, got %q (%v)", decoded.Foo, err)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"foo": "a much longer value"}`))
+	if err := ReadJSON(httptest.NewRecorder(), req, &decoded); err == nil {
+		t.Error("expected the default parser's size limit to apply")
+	}
+
+	rr := httptest.NewRecorder()
+	if err := WriteJSON(rr, http.StatusCreated, decoded, http.Header{"X-Test": {"1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusCreated || rr.Header().Get("X-Test") != "1" {
+		t.Errorf("unexpected response: %d %v", rr.Code, rr.Header())
+	}
+
+	rr = httptest.NewRecorder()
+	if err := ErrorJSON(rr, errors.New("nope"), http.StatusTeapot); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusTeapot || !strings.Contains(rr.Body.String(), `"message":"nope"`) {
+		t.Errorf("unexpected error response: %d %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,74 @@
+package ps
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Deprecation describes an endpoint that is being phased out.
+type Deprecation struct {
+	// Since is when the endpoint was deprecated. RFC 9745 only allows a date in the Deprecation header, so it is
+	// left out if Since is zero.
+	Since time.Time
+	// Sunset is when the endpoint will stop responding. It is omitted if zero.
+	Sunset time.Time
+	// Successor is the URL of the endpoint that replaces this one.
+	Successor string
+	// Documentation is the URL of a human-readable deprecation notice.
+	Documentation string
+}
+
+// Deprecate attaches the Deprecation (RFC 9745), Sunset (RFC 8594) and Link headers described by d to the
+// response. It must be called before the response is written. MetaDeprecation notes the same in an envelope.
+func (p *Parser) Deprecate(w http.ResponseWriter, d Deprecation) {
+	h := w.Header()
+
+	if !d.Since.IsZero() {
+		h.Set("Deprecation", fmt.Sprintf("@%d", d.Since.Unix()))
+	}
+
+	if !d.Sunset.IsZero() {
+		h.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+
+	if d.Successor != "" {
+		h.Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, d.Successor))
+	}
+
+	if d.Documentation != "" {
+		h.Add("Link", fmt.Sprintf(`<%s>; rel="deprecation"; type="text/html"`, d.Documentation))
+	}
+}
+
+// Deprecated returns middleware that marks every response of the wrapped handler as deprecated.
+func (p *Parser) Deprecated(d Deprecation) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p.Deprecate(w, d)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// DeprecationMeta is the deprecation notice in the meta section of a JSONResponse, for clients that read the body
+// rather than the headers. Dates are written as WriteJSON writes times.
+type DeprecationMeta struct {
+	Since         *time.Time `json:"since,omitempty"`
+	Sunset        *time.Time `json:"sunset,omitempty"`
+	Successor     string     `json:"successor,omitempty"`
+	Documentation string     `json:"documentation,omitempty"`
+}
+
+// MetaDeprecation notes in the meta section that the endpoint is deprecated as d describes. It does not set the
+// headers; call Deprecate, or use the Deprecated middleware, for those.
+func MetaDeprecation(d Deprecation) EnvelopeOption {
+	meta := DeprecationMeta{Successor: d.Successor, Documentation: d.Documentation}
+	if !d.Since.IsZero() {
+		meta.Since = &d.Since
+	}
+	if !d.Sunset.IsZero() {
+		meta.Sunset = &d.Sunset
+	}
+	return func(r *JSONResponse) { r.meta().Deprecation = &meta }
+}
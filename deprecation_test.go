@@ -0,0 +1,69 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParser_Deprecated(t *testing.T) {
+	var testParser Parser
+
+	since := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC)
+
+	handler := testParser.Deprecated(Deprecation{
+		Since:         since,
+		Sunset:        sunset,
+		Successor:     "https://api.example.com/v2/users",
+		Documentation: "https://example.com/deprecations/users",
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = testParser.WriteJSON(w, http.StatusOK, JSONResponse{Message: "ok"})
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/users", nil))
+
+	if got := rr.Header().Get("Deprecation"); got != "@1704067200" {
+		t.Errorf("wrong Deprecation header: %q", got)
+	}
+
+	if got := rr.Header().Get("Sunset"); got != "Mon, 01 Jul 2024 00:00:00 GMT" {
+		t.Errorf("wrong Sunset header: %q", got)
+	}
+
+	links := rr.Header().Values("Link")
+	if len(links) != 2 || links[0] != `<https://api.example.com/v2/users>; rel="successor-version"` {
+		t.Errorf("wrong Link headers: %q", links)
+	}
+}
+
+func TestParser_DeprecateWithoutDate(t *testing.T) {
+	var testParser Parser
+
+	rr := httptest.NewRecorder()
+	testParser.Deprecate(rr, Deprecation{})
+
+	if rr.Header().Get("Deprecation") != "" || rr.Header().Get("Sunset") != "" || rr.Header().Get("Link") != "" {
+		t.Errorf("unexpected headers: %v", rr.Header())
+	}
+}
+
+func TestMetaDeprecation(t *testing.T) {
+	var testParser Parser
+
+	rr := httptest.NewRecorder()
+	err := testParser.WriteEnvelope(rr, http.StatusOK, nil, MetaDeprecation(Deprecation{
+		Sunset:    time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC),
+		Successor: "/v2/users",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"error":false,"message":"","meta":{"deprecation":{"sunset":"2024-07-01T00:00:00Z","successor":"/v2/users"}}}`
+	if rr.Body.String() != expected {
+		t.Errorf("expected %s, got %s", expected, rr.Body.String())
+	}
+}
@@ -0,0 +1,161 @@
+package ps
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+)
+
+// diagnosticsWindow is how many bytes either side of a failure DecodeDiagnostics quotes from the body.
+const diagnosticsWindow = 32
+
+// DecodeDiagnostics describes where and how ReadJSON failed to decode a body, for logging. It is attached to the
+// error when the Parser's Diagnostics setting is on; see DiagnosticsOf.
+type DecodeDiagnostics struct {
+	// Size is the length of the body in bytes.
+	Size int `json:"size"`
+	// Offset is the byte offset in the body at which decoding failed, or 0 if encoding/json did not report one.
+	Offset int64 `json:"offset"`
+	// Line and Column locate Offset in the body, counting from 1.
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+	// Path is the location of the failure in the JSON document, such as $.items[2].price.
+	Path string `json:"path,omitempty"`
+	// Depth is how many arrays and objects were open at the failure.
+	Depth int `json:"depth"`
+	// Snippet is the part of the body around Offset, starting at byte SnippetStart.
+	Snippet      string `json:"snippet,omitempty"`
+	SnippetStart int64  `json:"snippet_start"`
+	// Partial is the destination as decoding left it, with whatever was decoded before the failure, encoded as
+	// WriteJSON would encode it; writeonly fields, such as passwords, are left out.
+	Partial json.RawMessage `json:"partial,omitempty"`
+}
+
+// DiagnosticsOf returns the diagnostics attached to an error from ReadJSON, or nil if there are none.
+func DiagnosticsOf(err error) *DecodeDiagnostics {
+	var diagnosed *diagnosedError
+	if errors.As(err, &diagnosed) {
+		return diagnosed.diagnostics
+	}
+	return nil
+}
+
+// LogValue implements slog.LogValuer, so that a report can be logged as a group of attributes.
+func (d *DecodeDiagnostics) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("size", d.Size),
+		slog.Int64("offset", d.Offset),
+		slog.String("position", strconv.Itoa(d.Line)+":"+strconv.Itoa(d.Column)),
+		slog.String("path", d.Path),
+		slog.Int("depth", d.Depth),
+		slog.String("snippet", d.Snippet),
+		slog.String("partial", string(d.Partial)),
+	)
+}
+
+// diagnosedError is a decoding error with its diagnostics. It reads exactly as the error it wraps.
+type diagnosedError struct {
+	err         error
+	diagnostics *DecodeDiagnostics
+}
+
+func (e *diagnosedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *diagnosedError) Unwrap() error {
+	return e.err
+}
+
+// diagnose wraps err, which decoding body into data failed with, in a diagnosedError.
+func (p *Parser) diagnose(err error, body []byte, data any) error {
+	d := &DecodeDiagnostics{Size: len(body), Offset: errorOffset(err)}
+
+	if d.Offset > 0 && d.Offset <= int64(len(body)) {
+		before := body[:d.Offset]
+		d.Line = bytes.Count(before, []byte("\n")) + 1
+		d.Column = len(before) - bytes.LastIndexByte(before, '\n')
+		d.Path, d.Depth = jsonPath(body, d.Offset)
+
+		d.SnippetStart = max(d.Offset-diagnosticsWindow, 0)
+		d.Snippet = string(body[d.SnippetStart:min(d.Offset+diagnosticsWindow, int64(len(body)))])
+	}
+
+	if data != nil {
+		// Reports end up in logs, so they are kept on one line even if the Parser indents its output.
+		var partial bytes.Buffer
+		if out, err := p.marshal(data); err == nil && json.Compact(&partial, out) == nil {
+			d.Partial = partial.Bytes()
+		}
+	}
+
+	return &diagnosedError{err: err, diagnostics: d}
+}
+
+// jsonPath reads body's tokens up to offset and returns the path of the value there, and how deeply it is
+// nested. A syntax error before offset stops the walk where it occurs.
+func jsonPath(body []byte, offset int64) (string, int) {
+	// frame is an array or object being read; key records whether an object expects a key next.
+	type frame struct {
+		object bool
+		key    bool
+		name   string
+		index  int
+	}
+	var stack []frame
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+
+		n := len(stack)
+		name, isKey := tok.(string)
+		isKey = isKey && n > 0 && stack[n-1].object && stack[n-1].key
+		if isKey {
+			stack[n-1].name, stack[n-1].key = name, false
+		}
+		if dec.InputOffset() >= offset {
+			break
+		}
+		if isKey {
+			continue
+		}
+
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			stack = append(stack, frame{object: tok == json.Delim('{'), key: true})
+			continue
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:n-1]
+		}
+
+		// A value has ended: the enclosing object expects a key, or the enclosing array its next element.
+		if n := len(stack); n > 0 {
+			if stack[n-1].object {
+				stack[n-1].name, stack[n-1].key = "", true
+			} else {
+				stack[n-1].index++
+			}
+		}
+	}
+
+	path := []byte("$")
+	for _, f := range stack {
+		switch {
+		case f.object && f.name != "":
+			path = append(path, '.')
+			path = append(path, f.name...)
+		case !f.object:
+			path = append(path, '[')
+			path = strconv.AppendInt(path, int64(f.index), 10)
+			path = append(path, ']')
+		}
+	}
+	return string(path), len(stack)
+}
@@ -0,0 +1,93 @@
+package ps
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type diagnosticsSample struct {
+	Name     string `json:"name"`
+	Password string `json:"password,writeonly"`
+	Items    []struct {
+		SKU   string `json:"sku"`
+		Price int    `json:"price"`
+	} `json:"items"`
+}
+
+var diagnosticsTests = []struct {
+	name            string
+	json            string
+	expectedPath    string
+	expectedLine    int
+	expectedColumn  int
+	expectedSnippet string
+	expectedPartial string
+}{
+	{name: "type error", json: `{"name":"order","password":"hunter2","items":[{"sku":"a","price":1},{"sku":"b","price":"2"}]}`,
+		expectedPath: "$.items[1].price", expectedLine: 1, expectedColumn: 91, expectedSnippet: `price":1},{"sku":"b","price":"2"}]}`,
+		expectedPartial: `{"name":"order","items":[{"sku":"a","price":1},{"sku":"b","price":0}]}`},
+	{name: "syntax error", json: "{\n  \"name\": \"order\",\n  \"items\": [}\n}", expectedPath: "$.items[0]", expectedLine: 3,
+		expectedColumn: 14, expectedSnippet: "  \"name\": \"order\",\n  \"items\": [}\n}", expectedPartial: `{"name":"","items":null}`},
+	{name: "duplicate key", json: `{"name":"a","items":[],"name":"b"}`, expectedPath: "$.name", expectedLine: 1, expectedColumn: 30,
+		expectedSnippet: `{"name":"a","items":[],"name":"b"}`, expectedPartial: `{"name":"","items":null}`},
+	{name: "no offset", json: `{"other":1}`, expectedPartial: `{"name":"","items":null}`},
+}
+
+func TestParser_ReadJSONDiagnostics(t *testing.T) {
+	testParser := Parser{Diagnostics: true, RejectDuplicateKeys: true}
+
+	for _, e := range diagnosticsTests {
+		var decoded diagnosticsSample
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.json))
+		err := testParser.ReadJSON(httptest.NewRecorder(), req, &decoded)
+		if err == nil {
+			t.Errorf("%s: error expected, but none received", e.name)
+			continue
+		}
+
+		d := DiagnosticsOf(err)
+		if d == nil {
+			t.Errorf("%s: expected diagnostics for %v", e.name, err)
+			continue
+		}
+		if d.Size != len(e.json) || d.Path != e.expectedPath || d.Line != e.expectedLine || d.Column != e.expectedColumn {
+			t.Errorf("%s: expected %s at %d:%d, got %s at %d:%d (size %d)", e.name, e.expectedPath, e.expectedLine, e.expectedColumn,
+				d.Path, d.Line, d.Column, d.Size)
+		}
+		if d.Snippet != e.expectedSnippet {
+			t.Errorf("%s: expected snippet %q, got %q", e.name, e.expectedSnippet, d.Snippet)
+		}
+		if string(d.Partial) != e.expectedPartial {
+			t.Errorf("%s: expected partial %s, got %s", e.name, e.expectedPartial, d.Partial)
+		}
+	}
+}
+
+func TestParser_ReadJSONWithoutDiagnostics(t *testing.T) {
+	var testParser Parser
+
+	var decoded diagnosticsSample
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":1}`))
+	err := testParser.ReadJSON(httptest.NewRecorder(), req, &decoded)
+	if err == nil || DiagnosticsOf(err) != nil {
+		t.Errorf("expected an error without diagnostics, got %v", err)
+	}
+}
+
+func TestDecodeDiagnostics_LogValue(t *testing.T) {
+	testParser := Parser{Diagnostics: true}
+
+	var decoded diagnosticsSample
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":1}`))
+	err := testParser.ReadJSON(httptest.NewRecorder(), req, &decoded)
+
+	var logged bytes.Buffer
+	slog.New(slog.NewTextHandler(&logged, nil)).Error("bad payload", "diagnostics", DiagnosticsOf(err))
+	if !strings.Contains(logged.String(), "diagnostics.path=$.name") || !strings.Contains(logged.String(), "diagnostics.position=1:10") {
+		t.Errorf("expected the report as attributes, got %q", logged.String())
+	}
+}
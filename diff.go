@@ -0,0 +1,172 @@
+package ps
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// PatchOperation is one operation of a JSON Patch (RFC 6902).
+type PatchOperation struct {
+	// Op is add, remove or replace; Diff produces no others.
+	Op string `json:"op"`
+	// Path is a JSON Pointer (RFC 6901) to the value the operation changes.
+	Path string `json:"path"`
+	// Value is the new value, for add and replace.
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Diff returns a JSON Patch that turns the JSON encoding of before into that of after, for audit logs and change
+// events. Both are encoded as WriteJSON encodes them with the default settings, so fields tagged writeonly never
+// appear in the patch. Objects are compared key by key and arrays element by element, elements being added or
+// removed at the end; a value that changes type is replaced whole. Equal values give an empty, non-nil patch.
+func Diff(before, after any) ([]PatchOperation, error) {
+	a, b, err := diffTrees(before, after)
+	if err != nil {
+		return nil, err
+	}
+
+	ops := []PatchOperation{}
+	if err := diffPatch(&ops, "", a, b); err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+// DiffMerge returns a JSON Merge Patch (RFC 7396) that turns the JSON encoding of before into that of after, encoded
+// as Diff encodes them. Keys that after lacks are set to null, and arrays are replaced whole, as merge patches
+// require; a merge patch cannot set a key to null, so a null in after reads as a removal. Equal values give the
+// empty object.
+func DiffMerge(before, after any) (json.RawMessage, error) {
+	a, b, err := diffTrees(before, after)
+	if err != nil {
+		return nil, err
+	}
+	if reflect.DeepEqual(a, b) {
+		return json.RawMessage("{}"), nil
+	}
+	return json.Marshal(mergePatch(a, b))
+}
+
+// diffTrees encodes before and after, and decodes the results into trees of maps, slices and json.Numbers.
+func diffTrees(before, after any) (a, b any, err error) {
+	if a, err = encodeTree(before); err != nil {
+		return nil, nil, err
+	}
+	if b, err = encodeTree(after); err != nil {
+		return nil, nil, err
+	}
+	return a, b, nil
+}
+
+// encodeTree encodes v with the default settings and decodes the result into a tree.
+func encodeTree(v any) (any, error) {
+	var p Parser
+	out, err := p.marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(out))
+	dec.UseNumber()
+	var tree any
+	err = dec.Decode(&tree)
+	return tree, err
+}
+
+// diffPatch appends to ops the operations that turn a, found at path, into b.
+func diffPatch(ops *[]PatchOperation, path string, a, b any) error {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+		for _, key := range sortedKeys(a) {
+			if _, ok := b[key]; !ok {
+				*ops = append(*ops, PatchOperation{Op: "remove", Path: path + "/" + escapePointer(key)})
+			}
+		}
+		for _, key := range sortedKeys(b) {
+			child := path + "/" + escapePointer(key)
+			if old, ok := a[key]; ok {
+				if err := diffPatch(ops, child, old, b[key]); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := appendOperation(ops, "add", child, b[key]); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case []any:
+		b, ok := b.([]any)
+		if !ok {
+			break
+		}
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if err := diffPatch(ops, path+"/"+strconv.Itoa(i), a[i], b[i]); err != nil {
+				return err
+			}
+		}
+		// Remove from the end, so that the indices of the elements still to go do not shift.
+		for i := len(a) - 1; i >= len(b); i-- {
+			*ops = append(*ops, PatchOperation{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+		}
+		for i := len(a); i < len(b); i++ {
+			if err := appendOperation(ops, "add", path+"/"+strconv.Itoa(i), b[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if reflect.DeepEqual(a, b) {
+		return nil
+	}
+	return appendOperation(ops, "replace", path, b)
+}
+
+// appendOperation appends an operation with value v to ops.
+func appendOperation(ops *[]PatchOperation, op, path string, v any) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	*ops = append(*ops, PatchOperation{Op: op, Path: path, Value: value})
+	return nil
+}
+
+// mergePatch returns the merge patch that turns a into b.
+func mergePatch(a, b any) any {
+	objA, okA := a.(map[string]any)
+	objB, okB := b.(map[string]any)
+	if !okA || !okB {
+		return b
+	}
+
+	patch := map[string]any{}
+	for key := range objA {
+		if _, ok := objB[key]; !ok {
+			patch[key] = nil
+		}
+	}
+	for key, v := range objB {
+		old, ok := objA[key]
+		switch {
+		case !ok:
+			patch[key] = v
+		case !reflect.DeepEqual(old, v):
+			patch[key] = mergePatch(old, v)
+		}
+	}
+	return patch
+}
+
+// escapePointer escapes key for use as a JSON Pointer reference token.
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
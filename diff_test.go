@@ -0,0 +1,72 @@
+package ps
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type diffRecord struct {
+	Name     string            `json:"name"`
+	Password string            `json:"password,writeonly"`
+	Tags     []string          `json:"tags"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Owner    *struct {
+		ID int `json:"id"`
+	} `json:"owner"`
+}
+
+var diffTests = []struct {
+	name          string
+	before, after string
+	patch         string
+	merge         string
+}{
+	{name: "equal", before: `{"name":"a","tags":["x"]}`, after: `{"name":"a","tags":["x"]}`, patch: `[]`, merge: `{}`},
+	{name: "replace", before: `{"name":"a"}`, after: `{"name":"b"}`, patch: `[{"op":"replace","path":"/name","value":"b"}]`, merge: `{"name":"b"}`},
+	{name: "write only", before: `{"password":"a"}`, after: `{"password":"b"}`, patch: `[]`, merge: `{}`},
+	{name: "added and removed keys", before: `{"labels":{"a/b":"1","c":"2"}}`, after: `{"labels":{"c":"2","d~":"3"}}`,
+		patch: `[{"op":"remove","path":"/labels/a~1b"},{"op":"add","path":"/labels/d~0","value":"3"}]`, merge: `{"labels":{"a/b":null,"d~":"3"}}`},
+	{name: "grown array", before: `{"tags":["x"]}`, after: `{"tags":["y","z"]}`,
+		patch: `[{"op":"replace","path":"/tags/0","value":"y"},{"op":"add","path":"/tags/1","value":"z"}]`, merge: `{"tags":["y","z"]}`},
+	{name: "shrunk array", before: `{"tags":["x","y","z"]}`, after: `{"tags":["x"]}`,
+		patch: `[{"op":"remove","path":"/tags/2"},{"op":"remove","path":"/tags/1"}]`, merge: `{"tags":["x"]}`},
+	{name: "to null", before: `{"owner":{"id":1}}`, after: `{}`, patch: `[{"op":"replace","path":"/owner","value":null}]`, merge: `{"owner":null}`},
+	{name: "nested", before: `{"owner":{"id":1}}`, after: `{"owner":{"id":2}}`, patch: `[{"op":"replace","path":"/owner/id","value":2}]`, merge: `{"owner":{"id":2}}`},
+}
+
+func TestDiff(t *testing.T) {
+	for _, e := range diffTests {
+		var before, after diffRecord
+		if err := json.Unmarshal([]byte(e.before), &before); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(e.after), &after); err != nil {
+			t.Fatal(err)
+		}
+
+		ops, err := Diff(before, after)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+		if b, _ := json.Marshal(ops); string(b) != e.patch {
+			t.Errorf("%s: expected patch %s, got %s", e.name, e.patch, b)
+		}
+
+		merge, err := DiffMerge(before, after)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+		if string(merge) != e.merge {
+			t.Errorf("%s: expected merge patch %s, got %s", e.name, e.merge, merge)
+		}
+	}
+}
+
+func TestDiff_RootReplaced(t *testing.T) {
+	ops, err := Diff([]int{1}, map[string]int{"a": 1})
+	if err != nil || len(ops) != 1 || ops[0].Op != "replace" || ops[0].Path != "" || string(ops[0].Value) != `{"a":1}` {
+		t.Errorf("expected the root to be replaced, got %+v, %v", ops, err)
+	}
+}
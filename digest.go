@@ -0,0 +1,120 @@
+package ps
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// DigestPolicy controls whether ReadJSON checks the body of a request against the digest sent with it, in a
+// Content-Digest (RFC 9530), Digest (RFC 3230) or Content-MD5 header.
+type DigestPolicy int
+
+const (
+	// IgnoreDigest does not look at digest headers. This is the default.
+	IgnoreDigest DigestPolicy = iota
+	// VerifyDigest checks the digest headers a request has, and accepts requests without any.
+	VerifyDigest
+	// RequireDigest checks the digest headers, and rejects requests that have none.
+	RequireDigest
+)
+
+// digestAlgorithms are the hashes a digest may use, by their lower-case names in the digest headers.
+var digestAlgorithms = map[string]func() hash.Hash{
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+	"md5":     md5.New,
+}
+
+// IntegrityError is returned when a request body does not match the digest sent with it, or when a required
+// digest is missing.
+type IntegrityError struct {
+	// Header is the digest header that failed, or empty if none was sent.
+	Header string
+	// Reason describes the failure.
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *IntegrityError) Error() string {
+	return "body integrity check failed: " + e.Reason
+}
+
+// checkDigest checks body against the digest headers of r, as the RequestDigest policy asks. Every supported
+// algorithm listed must match, and each header must list at least one.
+func (p *Parser) checkDigest(r *http.Request, body []byte) error {
+	if p.RequestDigest == IgnoreDigest {
+		return nil
+	}
+
+	checked := false
+	for _, header := range []string{"Content-Digest", "Digest", "Content-MD5"} {
+		value := r.Header.Get(header)
+		if value == "" {
+			continue
+		}
+		checked = true
+
+		digests := parseDigests(header, value)
+		if len(digests) == 0 {
+			return &IntegrityError{Header: header, Reason: "the " + header + " header has no supported algorithm"}
+		}
+		for algorithm, want := range digests {
+			h := digestAlgorithms[algorithm]()
+			h.Write(body)
+			if !bytes.Equal(h.Sum(nil), want) {
+				return &IntegrityError{Header: header, Reason: "the body does not match the " + algorithm + " digest in the " + header + " header"}
+			}
+		}
+	}
+
+	if !checked && p.RequestDigest == RequireDigest {
+		return &IntegrityError{Reason: "the request has no Content-Digest, Digest or Content-MD5 header"}
+	}
+	return nil
+}
+
+// parseDigests returns the digests in a digest header that use a supported algorithm, by algorithm. A digest
+// that is not valid base64 is returned empty, so that it fails to match.
+func parseDigests(header, value string) map[string][]byte {
+	if header == "Content-MD5" {
+		sum, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		return map[string][]byte{"md5": sum}
+	}
+
+	digests := map[string][]byte{}
+	for _, item := range strings.Split(value, ",") {
+		algorithm, encoded, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			continue
+		}
+		algorithm = strings.ToLower(strings.TrimSpace(algorithm))
+		if _, supported := digestAlgorithms[algorithm]; !supported {
+			continue
+		}
+		// Content-Digest wraps the base64 in colons, as a structured field byte sequence.
+		encoded = strings.TrimSpace(encoded)
+		if header == "Content-Digest" {
+			encoded = strings.TrimSuffix(strings.TrimPrefix(encoded, ":"), ":")
+		}
+		sum, _ := base64.StdEncoding.DecodeString(encoded)
+		digests[algorithm] = sum
+	}
+	return digests
+}
+
+// setDigest sets the Digest and Content-Digest headers to the SHA-256 digest of body, if the Parser sends them.
+func (p *Parser) setDigest(w http.ResponseWriter, body []byte) {
+	if !p.ResponseDigest {
+		return
+	}
+	sum := sha256.Sum256(body)
+	encoded := base64.StdEncoding.EncodeToString(sum[:])
+	w.Header().Set("Digest", "sha-256="+encoded)
+	w.Header().Set("Content-Digest", "sha-256=:"+encoded+":")
+}
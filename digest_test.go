@@ -0,0 +1,119 @@
+package ps
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const digestBody = `{"amount":100}`
+
+func digestOf(sum []byte) string {
+	return base64.StdEncoding.EncodeToString(sum)
+}
+
+func sha256Sum(s string) []byte {
+	sum := sha256.Sum256([]byte(s))
+	return sum[:]
+}
+
+func md5Sum(s string) []byte {
+	sum := md5.Sum([]byte(s))
+	return sum[:]
+}
+
+var checkDigestTests = []struct {
+	name          string
+	policy        DigestPolicy
+	headers       map[string]string
+	expectedError string
+}{
+	{name: "ignored", policy: IgnoreDigest, headers: map[string]string{"Content-MD5": "bad"}},
+	{name: "content-digest", policy: VerifyDigest, headers: map[string]string{"Content-Digest": "sha-256=:" + digestOf(sha256Sum(digestBody)) + ":, unknown=:eA==:"}},
+	{name: "digest", policy: VerifyDigest, headers: map[string]string{"Digest": "SHA-256=" + digestOf(sha256Sum(digestBody))}},
+	{name: "content-md5", policy: RequireDigest, headers: map[string]string{"Content-MD5": digestOf(md5Sum(digestBody))}},
+	{name: "none", policy: VerifyDigest},
+	{name: "mismatch", policy: VerifyDigest, headers: map[string]string{"Digest": "sha-256=" + digestOf(sha256Sum("other"))},
+		expectedError: "the body does not match the sha-256 digest in the Digest header"},
+	{name: "invalid base64", policy: VerifyDigest, headers: map[string]string{"Content-MD5": "%%%"},
+		expectedError: "the body does not match the md5 digest in the Content-MD5 header"},
+	{name: "unsupported", policy: VerifyDigest, headers: map[string]string{"Content-Digest": "sha-1=:eA==:"},
+		expectedError: "the Content-Digest header has no supported algorithm"},
+	{name: "required", policy: RequireDigest, expectedError: "the request has no Content-Digest, Digest or Content-MD5 header"},
+}
+
+func TestParser_ReadJSONDigest(t *testing.T) {
+	for _, e := range checkDigestTests {
+		testParser := Parser{RequestDigest: e.policy}
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(digestBody))
+		for k, v := range e.headers {
+			req.Header.Set(k, v)
+		}
+
+		var payment struct {
+			Amount int `json:"amount"`
+		}
+		err := testParser.ReadJSON(httptest.NewRecorder(), req, &payment)
+
+		if e.expectedError == "" {
+			if err != nil || payment.Amount != 100 {
+				t.Errorf("%s: expected the body to be read, got %v", e.name, err)
+			}
+			continue
+		}
+		var integrityError *IntegrityError
+		if !errors.As(err, &integrityError) || integrityError.Reason != e.expectedError {
+			t.Errorf("%s: expected an IntegrityError %q, got %v", e.name, e.expectedError, err)
+		}
+	}
+}
+
+func TestParser_ReadJSONDigestBeforeHooks(t *testing.T) {
+	testParser := Parser{RequestDigest: RequireDigest}
+	testParser.BeforeDecode(func(r *http.Request, body []byte) ([]byte, error) {
+		return []byte(`{"amount":1}`), nil
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(digestBody))
+	req.Header.Set("Content-MD5", digestOf(md5Sum(digestBody)))
+	var payment struct {
+		Amount int `json:"amount"`
+	}
+	if err := testParser.ReadJSON(httptest.NewRecorder(), req, &payment); err != nil || payment.Amount != 1 {
+		t.Errorf("expected the digest of the body as sent, got %v", err)
+	}
+}
+
+func TestParser_WriteJSONDigest(t *testing.T) {
+	testParser := Parser{ResponseDigest: true, Pretty: true}
+
+	rr := httptest.NewRecorder()
+	_ = testParser.WriteJSON(rr, http.StatusOK, map[string]int{"amount": 100})
+
+	encoded := digestOf(sha256Sum(rr.Body.String()))
+	if rr.Header().Get("Digest") != "sha-256="+encoded || rr.Header().Get("Content-Digest") != "sha-256=:"+encoded+":" {
+		t.Errorf("expected the digest of %q, got %v", rr.Body.String(), rr.Header())
+	}
+
+	// A response that the server reads back verifies.
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(rr.Body.String()))
+	req.Header.Set("Digest", rr.Header().Get("Digest"))
+	var payment map[string]int
+	if err := (&Parser{RequestDigest: RequireDigest}).ReadJSON(httptest.NewRecorder(), req, &payment); err != nil {
+		t.Errorf("expected the digest to verify, got %v", err)
+	}
+
+	// A truncated response carries the digest of what was actually sent.
+	testParser.MaxResponseSize = 10
+	testParser.OversizedResponse = TruncateOversizedResponse
+	rr = httptest.NewRecorder()
+	_ = testParser.WriteJSON(rr, http.StatusOK, map[string]string{"note": strings.Repeat("x", 20)})
+	if rr.Header().Get("Digest") != "sha-256="+digestOf(sha256Sum(rr.Body.String())) {
+		t.Errorf("expected the digest of the truncation envelope, got %v", rr.Header())
+	}
+}
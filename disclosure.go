@@ -0,0 +1,138 @@
+package ps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"strings"
+)
+
+// maxStackDepth is the number of frames a debug error response lists.
+const maxStackDepth = 32
+
+// Disclosure controls how much ErrorJSON tells the client about an error.
+type Disclosure int
+
+const (
+	// StandardDisclosure sends the error's message as it is. This is the default.
+	StandardDisclosure Disclosure = iota
+	// ProductionDisclosure replaces the message with the generic text of the status code, such as "Bad Request",
+	// so that nothing internal reaches the client, and logs the full error to the Parser's Logger. Field errors,
+	// which describe the client's own input, are still sent.
+	ProductionDisclosure
+	// DebugDisclosure sends the message along with a debug object holding the chain of wrapped errors, the offset
+	// of a decoding error in the body, and the stack of the ErrorJSON call. It is for development only.
+	DebugDisclosure
+)
+
+// ErrorDebug is the extra detail ErrorJSON sends under DebugDisclosure.
+type ErrorDebug struct {
+	// Chain lists the error and everything it wraps, outermost first, each with its type.
+	Chain []string `json:"chain"`
+	// Offset is the byte offset in the request body at which decoding failed, where known.
+	Offset int64 `json:"offset,omitempty"`
+	// Stack lists the calls that led to ErrorJSON, innermost first.
+	Stack []string `json:"stack"`
+}
+
+// debugResponse is the envelope ErrorJSON sends under DebugDisclosure. The debug object lives here rather than
+// on JSONResponse so that it cannot appear, even as null, in other modes.
+type debugResponse struct {
+	JSONResponse
+	Debug *ErrorDebug `json:"debug"`
+}
+
+// disclose applies the Parser's Disclosure to the envelope ErrorJSON is about to send for err, and returns the
+// value to encode.
+func (p *Parser) disclose(payload JSONResponse, err error, status int) any {
+	switch p.Disclosure {
+	case ProductionDisclosure:
+		p.logHidden(err, status)
+		payload.Message = http.StatusText(status)
+		if status >= http.StatusInternalServerError {
+			payload.Fields = nil
+		}
+		return payload
+
+	case DebugDisclosure:
+		return debugResponse{payload, &ErrorDebug{Chain: errorChain(err), Offset: errorOffset(err), Stack: callers(4)}}
+	}
+	return payload
+}
+
+// discloseMessage returns the text to send for err where there is room for nothing but a message, such as a
+// stream trailer: under ProductionDisclosure, the text of status, with err logged as ErrorJSON logs it; otherwise
+// the error's own message.
+func (p *Parser) discloseMessage(err error, status int) string {
+	if p.Disclosure != ProductionDisclosure {
+		return err.Error()
+	}
+	p.logHidden(err, status)
+	return http.StatusText(status)
+}
+
+// logHidden logs an error whose message ProductionDisclosure keeps from the client.
+func (p *Parser) logHidden(err error, status int) {
+	p.logger().LogAttrs(context.Background(), slog.LevelError, "ps: error response",
+		slog.Int("status", status), slog.String("error", err.Error()))
+}
+
+// logger returns the Parser's Logger, or the default one.
+func (p *Parser) logger() *slog.Logger {
+	if p.Logger != nil {
+		return p.Logger
+	}
+	return slog.Default()
+}
+
+// errorChain describes err and every error it wraps, depth first.
+func errorChain(err error) []string {
+	var chain []string
+	var walk func(error)
+	walk = func(err error) {
+		if err == nil {
+			return
+		}
+		chain = append(chain, fmt.Sprintf("%T: %s", err, err))
+		switch e := err.(type) {
+		case interface{ Unwrap() error }:
+			walk(e.Unwrap())
+		case interface{ Unwrap() []error }:
+			for _, inner := range e.Unwrap() {
+				walk(inner)
+			}
+		}
+	}
+	walk(err)
+	return chain
+}
+
+// errorOffset returns the body offset recorded in a decoding error, or 0.
+func errorOffset(err error) int64 {
+	var failure *decodeFailure
+	if errors.As(err, &failure) {
+		return failure.offset
+	}
+	return 0
+}
+
+// callers lists the stack as "function file:line", skipping skip frames as runtime.Callers does.
+func callers(skip int) []string {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(skip, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var stack []string
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			stack = append(stack, fmt.Sprintf("%s %s:%d", frame.Function, frame.File, frame.Line))
+		}
+		if !more {
+			return stack
+		}
+	}
+}
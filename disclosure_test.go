@@ -0,0 +1,140 @@
+package ps
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var disclosureTests = []struct {
+	name            string
+	err             error
+	status          int
+	expectedMessage string
+	expectedFields  int
+}{
+	{name: "client error", err: errors.New("user 42 not found in shard 7"), status: http.StatusBadRequest, expectedMessage: "Bad Request"},
+	{name: "server error", err: fmt.Errorf("query failed: %w", errors.New("connection refused")), status: http.StatusInternalServerError,
+		expectedMessage: "Internal Server Error"},
+	{name: "field errors kept", err: &FieldError{Field: "email", Message: "must not be empty"}, status: http.StatusUnprocessableEntity,
+		expectedMessage: "Unprocessable Entity", expectedFields: 1},
+	{name: "field errors dropped for server errors", err: &FieldError{Field: "email", Message: "must not be empty"},
+		status: http.StatusInternalServerError, expectedMessage: "Internal Server Error"},
+}
+
+func TestParser_ErrorJSONProductionDisclosure(t *testing.T) {
+	for _, e := range disclosureTests {
+		var logged bytes.Buffer
+		testParser := Parser{Disclosure: ProductionDisclosure, Logger: slog.New(slog.NewTextHandler(&logged, nil))}
+
+		rr := httptest.NewRecorder()
+		if err := testParser.ErrorJSON(rr, e.err, e.status); err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+
+		var payload JSONResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &payload); err != nil {
+			t.Errorf("%s: invalid response: %v", e.name, err)
+			continue
+		}
+		if payload.Message != e.expectedMessage || len(payload.Fields) != e.expectedFields {
+			t.Errorf("%s: expected message %q with %d fields, got %s", e.name, e.expectedMessage, e.expectedFields, rr.Body.String())
+		}
+		if strings.Contains(rr.Body.String(), "debug") {
+			t.Errorf("%s: expected no debug object, got %s", e.name, rr.Body.String())
+		}
+		if !strings.Contains(logged.String(), "level=ERROR") || !strings.Contains(logged.String(), fmt.Sprintf("%q", e.err.Error())) {
+			t.Errorf("%s: expected the error to be logged, got %q", e.name, logged.String())
+		}
+	}
+}
+
+func TestParser_ErrorJSONDebugDisclosure(t *testing.T) {
+	testParser := Parser{Disclosure: DebugDisclosure}
+
+	var decoded struct {
+		Name string `json:"name"`
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":1}`))
+	readErr := testParser.ReadJSON(httptest.NewRecorder(), req, &decoded)
+
+	rr := httptest.NewRecorder()
+	if err := testParser.ErrorJSON(rr, fmt.Errorf("create user: %w", readErr)); err != nil {
+		t.Fatal(err)
+	}
+
+	var payload struct {
+		Message string     `json:"message"`
+		Debug   ErrorDebug `json:"debug"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(payload.Message, "create user: body contains incorrect JSON type") {
+		t.Errorf("expected the original message, got %q", payload.Message)
+	}
+	if len(payload.Debug.Chain) != 2 || !strings.HasPrefix(payload.Debug.Chain[1], "*ps.decodeFailure: ") {
+		t.Errorf("expected a chain of two errors, got %q", payload.Debug.Chain)
+	}
+	if payload.Debug.Offset != 9 {
+		t.Errorf("expected offset 9, got %d", payload.Debug.Offset)
+	}
+	if len(payload.Debug.Stack) == 0 || !strings.Contains(payload.Debug.Stack[0], "TestParser_ErrorJSONDebugDisclosure") {
+		t.Errorf("expected the stack to start at the caller of ErrorJSON, got %q", payload.Debug.Stack)
+	}
+}
+
+func TestParser_ProductionDisclosureStreams(t *testing.T) {
+	var logged bytes.Buffer
+	testParser := Parser{Disclosure: ProductionDisclosure, Logger: slog.New(slog.NewTextHandler(&logged, nil))}
+	secret := errors.New("pq: relation orders_v2 does not exist")
+
+	conn := &fakeConn{}
+	if err := testParser.ErrorJSONMessage(conn, secret, http.StatusInternalServerError); err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"error":true,"message":"Internal Server Error"}`; len(conn.sent) != 1 || conn.sent[0] != expected {
+		t.Errorf("expected message %s, got %q", expected, conn.sent)
+	}
+
+	rr := httptest.NewRecorder()
+	_ = testParser.NewFrameWriter(rr, ConnectProtocol).Fail(secret)
+	_, messages := readFrames(t, rr.Body.Bytes())
+	if expected := `{"error":{"code":"unknown","message":"Internal Server Error"}}`; messages[0] != expected {
+		t.Errorf("expected end of stream %s, got %s", expected, messages[0])
+	}
+
+	rr = httptest.NewRecorder()
+	_ = testParser.NewFrameWriter(rr, GRPCWebProtocol).Fail(&RPCError{Code: RPCNotFound, Message: "no order 7"})
+	_, messages = readFrames(t, rr.Body.Bytes())
+	if expected := "grpc-message: Not Found\r\ngrpc-status: 5\r\n"; messages[0] != expected {
+		t.Errorf("expected trailers %q, got %q", expected, messages[0])
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		testParser.NewStreamWriter(w, http.StatusOK, NDJSONStream).Fail(secret)
+	}))
+	defer srv.Close()
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.ReadAll(res.Body)
+	res.Body.Close()
+	if got := res.Trailer.Get(TrailerStatus); got != "failed: Internal Server Error" {
+		t.Errorf("expected a generic stream status, got %q", got)
+	}
+
+	if strings.Contains(conn.sent[0]+rr.Body.String(), "orders_v2") || !strings.Contains(logged.String(), "orders_v2") {
+		t.Errorf("expected the errors to be logged and hidden, logged %q", logged.String())
+	}
+}
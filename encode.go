@@ -0,0 +1,770 @@
+package ps
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Time formats understood by Parser.TimeFormat and the format struct tag. Any other value is treated as a layout
+// for time.Time.Format and time.Parse.
+const (
+	// TimeRFC3339 encodes times as RFC 3339 strings, as encoding/json does.
+	TimeRFC3339 = "rfc3339"
+	// TimeUnix encodes times as whole seconds since the Unix epoch.
+	TimeUnix = "unix"
+	// TimeUnixMilli encodes times as milliseconds since the Unix epoch.
+	TimeUnixMilli = "unixmilli"
+)
+
+// Duration formats understood by Parser.DurationFormat and the format struct tag. By default durations are
+// encoded as integer nanoseconds, as encoding/json does.
+const (
+	// DurationString encodes durations as strings such as "1m30s", accepting anything time.ParseDuration does.
+	DurationString = "string"
+	// DurationSeconds encodes durations as a number of seconds.
+	DurationSeconds = "seconds"
+)
+
+// IntString is the format struct tag value that encodes an integer field as a JSON string.
+const IntString = "string"
+
+// FloatFormat controls how WriteJSON writes floating-point numbers. The zero value writes the shortest decimal that
+// round-trips, never using scientific notation.
+type FloatFormat struct {
+	// Decimals is the maximum number of digits after the decimal point, to which values are rounded; 0 keeps as
+	// many as the value needs.
+	Decimals int
+	// TrailingZeros pads numbers to exactly Decimals digits after the point, so 0.5 is written as 0.50, instead of
+	// trimming the zeros.
+	TrailingZeros bool
+	// Exponent allows scientific notation for very large and very small values, as encoding/json does.
+	Exponent bool
+}
+
+// EmptyFields controls whether WriteJSON leaves out struct fields holding empty values: false, 0, nil pointers and
+// interfaces, and empty strings, slices and maps.
+type EmptyFields int
+
+const (
+	// TagEmptyFields leaves out empty fields whose json tag has the omitempty option. This is the default.
+	TagEmptyFields EmptyFields = iota
+	// KeepEmptyFields writes every field, ignoring omitempty, so absent values appear as explicit nulls.
+	KeepEmptyFields
+	// OmitEmptyFields leaves out every empty field, as if all of them had the omitempty option.
+	OmitEmptyFields
+)
+
+// NonFinite controls what WriteJSON does with NaN and infinite floats, which JSON cannot represent.
+type NonFinite int
+
+const (
+	// RejectNonFinite fails the write with a *NonFiniteError before anything is sent. This is the default.
+	RejectNonFinite NonFinite = iota
+	// NullNonFinite writes non-finite floats as null.
+	NullNonFinite
+	// StringNonFinite writes non-finite floats as the strings "NaN", "+Inf" and "-Inf".
+	StringNonFinite
+)
+
+// NonFiniteError is returned when a value to be encoded contains NaN or an infinity and the Parser rejects them.
+type NonFiniteError struct {
+	// Value is the offending float.
+	Value float64
+}
+
+// Error implements the error interface.
+func (e *NonFiniteError) Error() string {
+	return "cannot encode " + strconv.FormatFloat(e.Value, 'g', -1, 64) + " as JSON"
+}
+
+// maxEncodeDepth is how deeply nested a value may be before it is assumed to contain a cycle.
+const maxEncodeDepth = 1000
+
+var (
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	numberType        = reflect.TypeOf(json.Number(""))
+	durationType      = reflect.TypeOf(time.Duration(0))
+	timeType          = reflect.TypeOf(time.Time{})
+)
+
+// encodeOptions are the Parser settings that change how values are encoded.
+type encodeOptions struct {
+	timeFormat     string
+	durationFormat string
+	int64AsString  bool
+	formatFloats   bool
+	floatFormat    FloatFormat
+	nonFinite      NonFinite
+	tagName        string
+	emptyFields    EmptyFields
+}
+
+// encodeOptions returns the encoding settings of the Parser.
+func (p *Parser) encodeOptions() encodeOptions {
+	o := encodeOptions{
+		timeFormat:     p.TimeFormat,
+		durationFormat: p.DurationFormat,
+		int64AsString:  p.Int64AsString,
+		nonFinite:      p.NonFinite,
+		tagName:        p.TagName,
+		emptyFields:    p.EmptyFields,
+	}
+	if p.FloatFormat != nil {
+		o.formatFloats, o.floatFormat = true, *p.FloatFormat
+	}
+	return o
+}
+
+// typeKey caches a per-type decision that also depends on a set of options.
+type typeKey[O comparable] struct {
+	typ  reflect.Type
+	opts O
+}
+
+// encodeNeeds caches encodeOptions.needs.
+var encodeNeeds sync.Map
+
+// needs reports whether values of type t must go through the encoder, because a setting or struct tag affects
+// them or something they contain, rather than straight to encoding/json. Interfaces always need the encoder,
+// since what they hold is only known at run time.
+func (o encodeOptions) needs(t reflect.Type) bool {
+	key := typeKey[encodeOptions]{typ: t, opts: o}
+	if needs, ok := encodeNeeds.Load(key); ok {
+		return needs.(bool)
+	}
+
+	needs := o.search(t, make(map[reflect.Type]bool))
+	encodeNeeds.Store(key, needs)
+
+	return needs
+}
+
+// search looks for anything in t that needs the encoder, visiting each type once.
+func (o encodeOptions) search(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	switch t {
+	case timeType:
+		return o.timeFormat != ""
+	case durationType:
+		return o.durationFormat != ""
+	}
+	if marshals(t) {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Int64, reflect.Uint64:
+		return o.int64AsString
+	case reflect.Float32, reflect.Float64:
+		return o.formatFloats || o.nonFinite != RejectNonFinite
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return o.search(t.Elem(), seen)
+	case reflect.Struct:
+		info := o.fields(t)
+		if info.altTag || o.emptyFields != TagEmptyFields {
+			return true
+		}
+		for _, f := range info.fields {
+			if f.format != "" || f.writeOnly || o.search(f.typ, seen) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// fields returns the fields of struct type t, read from the Parser's tag.
+func (o encodeOptions) fields(t reflect.Type) *structInfo {
+	if o.tagName == "" {
+		return structFields(t)
+	}
+	return taggedFields(t, o.tagName)
+}
+
+// quotesInt reports whether integers of type t are sent as strings, because of a format tag or because t is 64
+// bits wide and the Parser's Int64AsString setting is on. Durations and types with their own marshaling are left
+// alone.
+func quotesInt(t reflect.Type, format string, int64AsString bool) bool {
+	if t == durationType || marshals(t) {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Int64, reflect.Uint64:
+		return format == IntString || int64AsString
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uintptr:
+		return format == IntString
+	}
+
+	return false
+}
+
+// marshals reports whether encoding/json would use a MarshalJSON or MarshalText method for values of type t.
+func marshals(t reflect.Type) bool {
+	p := reflect.PointerTo(t)
+	return t.Implements(marshalerType) || p.Implements(marshalerType) ||
+		t.Implements(textMarshalerType) || p.Implements(textMarshalerType)
+}
+
+// marshal encodes v using the Parser's settings.
+func (p *Parser) marshal(v any) ([]byte, error) {
+	opts := p.encodeOptions()
+
+	var out []byte
+	var err error
+
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || !opts.needs(rv.Type()) {
+		out, err = json.Marshal(v)
+	} else {
+		e := encoder{opts: opts}
+		err = e.encode(rv, nil)
+		out = e.buf.Bytes()
+	}
+
+	// Report NaN and infinities, wherever they were found, as a NonFiniteError.
+	var unsupported *json.UnsupportedValueError
+	if errors.As(err, &unsupported) {
+		if f, perr := strconv.ParseFloat(unsupported.Str, 64); perr == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			return nil, &NonFiniteError{Value: f}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Canonical {
+		return canonicalize(out)
+	}
+	if p.SortKeys {
+		if out, err = sortKeys(out); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.Pretty {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, out, "", "  "); err != nil {
+			return nil, err
+		}
+		out = buf.Bytes()
+	}
+
+	return out, nil
+}
+
+// encoder writes JSON the way encoding/json does, except where a Parser setting or struct tag asks for something
+// different. Values that nothing applies to are handed to encoding/json whole.
+type encoder struct {
+	opts  encodeOptions
+	buf   bytes.Buffer
+	depth int
+}
+
+// encode writes v. f is the struct field v was read from, if any.
+func (e *encoder) encode(v reflect.Value, f *field) error {
+	if !v.IsValid() {
+		e.buf.WriteString("null")
+		return nil
+	}
+
+	e.depth++
+	defer func() { e.depth-- }()
+	if e.depth > maxEncodeDepth {
+		return &json.UnsupportedValueError{Value: v, Str: "encountered a cycle via " + v.Type().String()}
+	}
+
+	format := ""
+	if f != nil {
+		format = f.format
+	}
+
+	t := v.Type()
+	switch {
+	case t == timeType && (format != "" || e.opts.timeFormat != ""):
+		return e.encodeTime(v.Interface().(time.Time), format)
+	case t == durationType && (format != "" || e.opts.durationFormat != ""):
+		return e.encodeDuration(time.Duration(v.Int()), format)
+	case quotesInt(t, format, e.opts.int64AsString):
+		e.buf.WriteByte('"')
+		if err := e.encodeValue(v); err != nil {
+			return err
+		}
+		e.buf.WriteByte('"')
+		return nil
+	case (e.opts.formatFloats || e.opts.nonFinite != RejectNonFinite) &&
+		(t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64) && !marshals(t) && (f == nil || !f.quoted):
+		return e.encodeFloat(v)
+	case format == "" && !e.opts.needs(t):
+		return e.delegate(v, f)
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			e.buf.WriteString("null")
+			return nil
+		}
+		return e.encode(v.Elem(), nil)
+
+	case reflect.Pointer:
+		if v.IsNil() {
+			e.buf.WriteString("null")
+			return nil
+		}
+		return e.encode(v.Elem(), f)
+
+	case reflect.Struct:
+		return e.encodeStruct(v)
+
+	case reflect.Map:
+		return e.encodeMap(v)
+
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf.WriteString("null")
+			return nil
+		}
+		return e.encodeArray(v)
+
+	case reflect.Array:
+		return e.encodeArray(v)
+	}
+
+	return e.delegate(v, f)
+}
+
+// encodeStruct writes the fields of struct v as an object. Fields tagged writeonly, such as passwords, are
+// accepted by ReadJSON but never written.
+func (e *encoder) encodeStruct(v reflect.Value) error {
+	e.buf.WriteByte('{')
+
+	first := true
+	info := e.opts.fields(v.Type())
+	for i := range info.fields {
+		f := &info.fields[i]
+
+		if f.writeOnly {
+			continue
+		}
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || e.omit(f) && isEmptyValue(fv) {
+			continue
+		}
+
+		if !first {
+			e.buf.WriteByte(',')
+		}
+		first = false
+
+		e.buf.Write(f.encodedName)
+		if err := e.encode(fv, f); err != nil {
+			return err
+		}
+	}
+
+	e.buf.WriteByte('}')
+	return nil
+}
+
+// omit reports whether field f is left out when empty.
+func (e *encoder) omit(f *field) bool {
+	switch e.opts.emptyFields {
+	case KeepEmptyFields:
+		return false
+	case OmitEmptyFields:
+		return true
+	}
+	return f.omitEmpty
+}
+
+// encodeMap writes map v as an object with its keys sorted.
+func (e *encoder) encodeMap(v reflect.Value) error {
+	if v.IsNil() {
+		e.buf.WriteString("null")
+		return nil
+	}
+
+	type entry struct {
+		key   string
+		value reflect.Value
+	}
+
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, ok := mapKey(iter.Key())
+		if !ok {
+			// Let encoding/json report the unsupported key type.
+			return e.delegate(v, nil)
+		}
+		entries = append(entries, entry{key: key, value: iter.Value()})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+	e.buf.WriteByte('{')
+	for i, entry := range entries {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+		e.buf.Write(appendString(e.buf.AvailableBuffer(), entry.key, true))
+		e.buf.WriteByte(':')
+		if err := e.encode(entry.value, nil); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte('}')
+
+	return nil
+}
+
+// mapKey converts a map key to an object key, as encoding/json does.
+func mapKey(k reflect.Value) (string, bool) {
+	if k.Kind() == reflect.String {
+		return k.String(), true
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Pointer && k.IsNil() {
+			return "", true
+		}
+		b, err := tm.MarshalText()
+		return string(b), err == nil
+	}
+
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), true
+	}
+
+	return "", false
+}
+
+// encodeArray writes slice or array v, except for byte slices, which encoding/json writes as base64.
+func (e *encoder) encodeArray(v reflect.Value) error {
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 && !marshals(v.Type().Elem()) {
+		return e.delegate(v, nil)
+	}
+
+	e.buf.WriteByte('[')
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+		if err := e.encode(v.Index(i), nil); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte(']')
+
+	return nil
+}
+
+// encodeTime writes t in format, or in the Parser's time format if format is empty.
+func (e *encoder) encodeTime(t time.Time, format string) error {
+	if format == "" {
+		format = e.opts.timeFormat
+	}
+
+	switch format {
+	case TimeRFC3339:
+		b, err := t.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		e.buf.Write(b)
+	case TimeUnix:
+		e.buf.Write(strconv.AppendInt(e.buf.AvailableBuffer(), t.Unix(), 10))
+	case TimeUnixMilli:
+		e.buf.Write(strconv.AppendInt(e.buf.AvailableBuffer(), t.UnixMilli(), 10))
+	default:
+		e.buf.Write(appendString(e.buf.AvailableBuffer(), t.Format(format), true))
+	}
+
+	return nil
+}
+
+// encodeFloat writes float v using the Parser's float format and non-finite policy.
+func (e *encoder) encodeFloat(v reflect.Value) error {
+	f := v.Float()
+
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		switch e.opts.nonFinite {
+		case NullNonFinite:
+			e.buf.WriteString("null")
+			return nil
+		case StringNonFinite:
+			e.buf.Write(appendString(e.buf.AvailableBuffer(), strconv.FormatFloat(f, 'g', -1, 64), true))
+			return nil
+		}
+	}
+
+	var b []byte
+	var err error
+	if e.opts.formatFloats {
+		b, err = e.opts.floatFormat.append(e.buf.AvailableBuffer(), f, v.Type().Bits())
+	} else {
+		b, err = appendFloat(e.buf.AvailableBuffer(), f, v.Type().Bits())
+	}
+	if err != nil {
+		return &json.UnsupportedValueError{Value: v, Str: err.Error()}
+	}
+	e.buf.Write(b)
+
+	return nil
+}
+
+// encodeDuration writes d in format, or in the Parser's duration format if format is empty.
+func (e *encoder) encodeDuration(d time.Duration, format string) error {
+	if format == "" {
+		format = e.opts.durationFormat
+	}
+
+	switch format {
+	case DurationString:
+		e.buf.Write(appendString(e.buf.AvailableBuffer(), d.String(), true))
+	case DurationSeconds:
+		e.buf.Write(strconv.AppendFloat(e.buf.AvailableBuffer(), d.Seconds(), 'f', -1, 64))
+	default:
+		return fmt.Errorf("unknown duration format %q", format)
+	}
+
+	return nil
+}
+
+// delegate writes v as encoding/json would, honouring the string option of field f.
+func (e *encoder) delegate(v reflect.Value, f *field) error {
+	start := e.buf.Len()
+
+	if err := e.encodeValue(v); err != nil {
+		return err
+	}
+
+	// The string option wraps the encoded scalar in a JSON string.
+	if f != nil && f.quoted && v.Kind() != reflect.Pointer && v.Kind() != reflect.Interface {
+		encoded := string(e.buf.Bytes()[start:])
+		e.buf.Truncate(start)
+		e.buf.Write(appendString(e.buf.AvailableBuffer(), encoded, true))
+	}
+
+	return nil
+}
+
+// encodeValue writes v as encoding/json would. Plain scalars are written directly; anything else goes through
+// json.Marshal.
+func (e *encoder) encodeValue(v reflect.Value) error {
+	t := v.Type()
+	if !marshals(t) && t != numberType {
+		switch v.Kind() {
+		case reflect.Bool:
+			e.buf.WriteString(strconv.FormatBool(v.Bool()))
+			return nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			e.buf.Write(strconv.AppendInt(e.buf.AvailableBuffer(), v.Int(), 10))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			e.buf.Write(strconv.AppendUint(e.buf.AvailableBuffer(), v.Uint(), 10))
+			return nil
+		case reflect.Float32, reflect.Float64:
+			b, err := appendFloat(e.buf.AvailableBuffer(), v.Float(), t.Bits())
+			if err != nil {
+				return &json.UnsupportedValueError{Value: v, Str: err.Error()}
+			}
+			e.buf.Write(b)
+			return nil
+		case reflect.String:
+			e.buf.Write(appendString(e.buf.AvailableBuffer(), v.String(), true))
+			return nil
+		}
+	}
+
+	// Pointer-receiver marshalers are only used for addressable values, as in encoding/json.
+	x := v.Interface()
+	if v.Kind() != reflect.Pointer && v.CanAddr() && marshals(reflect.PointerTo(t)) {
+		x = v.Addr().Interface()
+	}
+
+	b, err := json.Marshal(x)
+	if err != nil {
+		return err
+	}
+	e.buf.Write(b)
+
+	return nil
+}
+
+// appendFloat appends f formatted as encoding/json formats floats of the given bit size. It fails for NaN and
+// infinities, which JSON cannot represent.
+func appendFloat(dst []byte, f float64, bits int) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return dst, &strconvError{strconv.FormatFloat(f, 'g', -1, bits)}
+	}
+
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+
+	dst = strconv.AppendFloat(dst, f, format, -1, bits)
+	if format == 'e' {
+		// Clean up e-09 to e-9.
+		if n := len(dst); n >= 4 && dst[n-4] == 'e' && dst[n-3] == '-' && dst[n-2] == '0' {
+			dst[n-2] = dst[n-1]
+			dst = dst[:n-1]
+		}
+	}
+
+	return dst, nil
+}
+
+// append appends f formatted as ff describes. Like appendFloat, it fails for NaN and infinities.
+func (ff FloatFormat) append(dst []byte, f float64, bits int) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return dst, &strconvError{strconv.FormatFloat(f, 'g', -1, bits)}
+	}
+	if ff.Decimals == 0 && ff.Exponent {
+		return appendFloat(dst, f, bits)
+	}
+
+	start := len(dst)
+	if ff.Decimals == 0 {
+		dst = strconv.AppendFloat(dst, f, 'f', -1, bits)
+	} else if ff.Exponent && f != 0 && (math.Abs(f) < 1e-6 || math.Abs(f) >= 1e21) {
+		return appendFloat(dst, f, bits)
+	} else {
+		dst = strconv.AppendFloat(dst, f, 'f', ff.Decimals, bits)
+		if !ff.TrailingZeros {
+			dst = trimZeros(dst, start)
+		}
+	}
+
+	// Rounding can leave a negative zero.
+	if s := string(dst[start:]); strings.Trim(s, "-0.") == "" && s[0] == '-' {
+		dst = append(dst[:start], dst[start+1:]...)
+	}
+
+	return dst, nil
+}
+
+// trimZeros removes trailing zeros after the decimal point, and the point itself if nothing follows it, from the
+// number that starts at dst[start].
+func trimZeros(dst []byte, start int) []byte {
+	if !bytes.ContainsRune(dst[start:], '.') {
+		return dst
+	}
+	dst = bytes.TrimRight(dst, "0")
+	return bytes.TrimSuffix(dst, []byte("."))
+}
+
+// strconvError carries the text of a value that could not be formatted.
+type strconvError struct {
+	value string
+}
+
+// Error implements the error interface.
+func (e *strconvError) Error() string {
+	return e.value
+}
+
+// appendString appends s as a JSON string, escaping it as encoding/json does. If escapeHTML is set, <, > and &
+// are escaped too.
+func appendString(dst []byte, s string, escapeHTML bool) []byte {
+	const hex = "0123456789abcdef"
+
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && (!escapeHTML || b != '<' && b != '>' && b != '&') {
+				i++
+				continue
+			}
+
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '\\', '"':
+				dst = append(dst, '\\', b)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+
+		// U+2028 and U+2029 are valid JSON but break JavaScript, so encoding/json escapes them.
+		if c == '\u2028' || c == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hex[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+
+	return append(dst, '"')
+}
+
+// isEmptyValue reports whether v is empty in the sense of the omitempty option.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,464 @@
+package ps
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type encodeEmbedded struct {
+	Inner  string `json:"inner"`
+	Shadow string `json:"shadow"`
+}
+
+type encodeConflictA struct{ Dup string }
+
+type encodeConflictB struct{ Dup string }
+
+type encodeTextKey struct{ a, b int }
+
+func (k encodeTextKey) MarshalText() ([]byte, error) {
+	return []byte(strconv.Itoa(k.a) + "-" + strconv.Itoa(k.b)), nil
+}
+
+type encodePointerMarshaler struct{ n int }
+
+func (m *encodePointerMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`"pointer"`), nil
+}
+
+type encodeSample struct {
+	encodeEmbedded
+	*encodeConflictA
+	encodeConflictB
+	Shadow     string                    `json:"shadow"`
+	Name       string                    `json:"name"`
+	HTML       string                    `json:"html"`
+	Omitted    string                    `json:"omitted,omitempty"`
+	Quoted     int64                     `json:"quoted,string"`
+	QuotedStr  string                    `json:"quoted_str,string"`
+	Float      float64                   `json:"float"`
+	Small      float32                   `json:"small"`
+	Bytes      []byte                    `json:"bytes"`
+	NilSlice   []int                     `json:"nil_slice"`
+	Array      [2]bool                   `json:"array"`
+	IntKeys    map[int]string            `json:"int_keys"`
+	TextKeys   map[encodeTextKey]int     `json:"text_keys"`
+	Any        any                       `json:"any"`
+	Number     json.Number               `json:"number"`
+	Raw        json.RawMessage           `json:"raw"`
+	Time       time.Time                 `json:"time"`
+	TimePtr    *time.Time                `json:"time_ptr"`
+	Marshaler  encodePointerMarshaler    `json:"marshaler"`
+	Nested     []map[string]encodeSample `json:"nested,omitempty"`
+	unexported string
+	Skipped    string `json:"-"`
+	Dash       string `json:"-,"`
+}
+
+func TestEncoder_MatchesEncodingJSON(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 30, 0, 0, time.UTC)
+	sample := encodeSample{
+		encodeEmbedded:  encodeEmbedded{Inner: "inner", Shadow: "hidden"},
+		encodeConflictA: &encodeConflictA{Dup: "a"},
+		encodeConflictB: encodeConflictB{Dup: "b"},
+		Shadow:          "shadow",
+		Name:            "Jack \u2028 \xff \u00e9",
+		HTML:            "<b>&</b>\n\t\"",
+		Quoted:          42,
+		QuotedStr:       "x",
+		Float:           1e21,
+		Small:           0.0000001,
+		Bytes:           []byte("hello"),
+		Array:           [2]bool{true, false},
+		IntKeys:         map[int]string{2: "two", 10: "ten"},
+		TextKeys:        map[encodeTextKey]int{{2, 1}: 1, {1, 2}: 2},
+		Any:             map[string]any{"b": 1, "a": []any{"x", nil}},
+		Number:          "12.50",
+		Raw:             json.RawMessage(`{"z":1,"a":2}`),
+		Time:            now,
+		TimePtr:         &now,
+		unexported:      "x",
+		Skipped:         "x",
+		Dash:            "dash",
+	}
+	sample.Nested = []map[string]encodeSample{{"k": {Name: "nested"}}}
+
+	for _, v := range []any{sample, &sample, []any{sample, 1.5, "s", nil}, JSONResponse{Message: "ok", Data: sample}} {
+		expected, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		e := encoder{}
+		if err := e.encode(reflect.ValueOf(v), nil); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := e.buf.String(); got != string(expected) {
+			t.Errorf("encoder output differs from encoding/json:\nexpected %s\ngot      %s", expected, got)
+		}
+	}
+}
+
+func TestEncoder_Errors(t *testing.T) {
+	for _, v := range []any{
+		JSONResponse{Data: make(chan int)},
+		JSONResponse{Data: math.NaN()},
+		JSONResponse{Data: map[[2]int]string{{1, 2}: "x"}},
+	} {
+		if _, err := json.Marshal(v); err == nil {
+			t.Fatalf("expected encoding/json to fail for %#v", v)
+		}
+		e := encoder{}
+		if err := e.encode(reflect.ValueOf(v), nil); err == nil {
+			t.Errorf("expected an error for %#v", v)
+		}
+	}
+}
+
+type timeFormatSample struct {
+	Default time.Time  `json:"default"`
+	Millis  time.Time  `json:"millis" format:"unixmilli"`
+	Date    *time.Time `json:"date,omitempty" format:"2006-01-02"`
+	RFC     time.Time  `json:"rfc" format:"rfc3339"`
+}
+
+var timeFormatTests = []struct {
+	name     string
+	format   string
+	expected string
+}{
+	{name: "default", expected: `{"default":"2024-03-01T12:30:00Z","millis":1709296200000,"date":"2024-03-01","rfc":"2024-03-01T12:30:00Z"}`},
+	{name: "unix", format: TimeUnix, expected: `{"default":1709296200,"millis":1709296200000,"date":"2024-03-01","rfc":"2024-03-01T12:30:00Z"}`},
+	{name: "layout", format: time.Kitchen, expected: `{"default":"12:30PM","millis":1709296200000,"date":"2024-03-01","rfc":"2024-03-01T12:30:00Z"}`},
+}
+
+func TestParser_WriteJSONTimeFormat(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 30, 0, 0, time.UTC)
+
+	for _, e := range timeFormatTests {
+		testParser := Parser{TimeFormat: e.format}
+
+		rr := httptest.NewRecorder()
+		err := testParser.WriteJSON(rr, http.StatusOK, timeFormatSample{Default: now, Millis: now, Date: &now, RFC: now})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+
+		if rr.Body.String() != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, rr.Body.String())
+		}
+	}
+}
+
+var readTimeFormatTests = []struct {
+	name          string
+	format        string
+	json          string
+	errorExpected bool
+}{
+	{name: "default", json: `{"default":"2024-03-01T12:30:00Z","millis":1709296200000,"date":"2024-03-01"}`},
+	{name: "unix", format: TimeUnix, json: `{"default":1709296200,"millis":1709296200000}`},
+	{name: "unix fraction", format: TimeUnix, json: `{"default":1709296200.0}`},
+	{name: "unix as string", format: TimeUnix, json: `{"default":"1709296200"}`, errorExpected: true},
+	{name: "bad millis", json: `{"millis":"2024-03-01T12:30:00Z"}`, errorExpected: true},
+	{name: "bad layout", json: `{"date":"01/03/2024"}`, errorExpected: true},
+	{name: "null", json: `{"date":null}`},
+	{name: "unknown field still rejected", json: `{"millis":1709296200000,"extra":1}`, errorExpected: true},
+	{name: "two values", json: `{"millis":1709296200000}{}`, errorExpected: true},
+}
+
+func TestParser_ReadJSONTimeFormat(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 30, 0, 0, time.UTC)
+
+	for _, e := range readTimeFormatTests {
+		testParser := Parser{TimeFormat: e.format}
+
+		var decoded timeFormatSample
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.json))
+		err := testParser.ReadJSON(httptest.NewRecorder(), req, &decoded)
+
+		if e.errorExpected {
+			if err == nil {
+				t.Errorf("%s: error expected, but none received", e.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+			continue
+		}
+
+		if strings.Contains(e.json, `"default"`) && !decoded.Default.Equal(now) {
+			t.Errorf("%s: expected default time %v, got %v", e.name, now, decoded.Default)
+		}
+		if strings.Contains(e.json, `"millis"`) && !decoded.Millis.Equal(now) {
+			t.Errorf("%s: expected millis time %v, got %v", e.name, now, decoded.Millis)
+		}
+		if strings.Contains(e.json, `"date":"`) && (decoded.Date == nil || decoded.Date.Format("2006-01-02") != "2024-03-01") {
+			t.Errorf("%s: expected date to be decoded, got %v", e.name, decoded.Date)
+		}
+	}
+}
+
+type durationSample struct {
+	Default time.Duration  `json:"default"`
+	Timeout time.Duration  `json:"timeout" format:"string"`
+	Retry   *time.Duration `json:"retry,omitempty" format:"seconds"`
+}
+
+var durationTests = []struct {
+	name          string
+	format        string
+	json          string
+	expected      string
+	errorExpected bool
+}{
+	{name: "default", json: `{"default":30000000000,"timeout":"1m30s","retry":1.5}`, expected: `{"default":30000000000,"timeout":"1m30s","retry":1.5}`},
+	{name: "string", format: DurationString, json: `{"default":"30s","timeout":"90s","retry":1.5}`, expected: `{"default":"30s","timeout":"1m30s","retry":1.5}`},
+	{name: "seconds", format: DurationSeconds, json: `{"default":30,"timeout":"1m30s","retry":1.5}`, expected: `{"default":30,"timeout":"1m30s","retry":1.5}`},
+	{name: "number for string", json: `{"timeout":90}`, errorExpected: true},
+	{name: "bad string", json: `{"timeout":"soon"}`, errorExpected: true},
+	{name: "string for seconds", json: `{"retry":"1.5s"}`, errorExpected: true},
+	{name: "seconds out of range", json: `{"retry":1e300}`, errorExpected: true},
+}
+
+func TestParser_DurationFormat(t *testing.T) {
+	for _, e := range durationTests {
+		testParser := Parser{DurationFormat: e.format}
+
+		var decoded durationSample
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.json))
+		err := testParser.ReadJSON(httptest.NewRecorder(), req, &decoded)
+
+		if e.errorExpected {
+			if err == nil {
+				t.Errorf("%s: error expected, but none received", e.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+			continue
+		}
+
+		if decoded.Default != 30*time.Second || decoded.Timeout != 90*time.Second || decoded.Retry == nil || *decoded.Retry != 1500*time.Millisecond {
+			t.Errorf("%s: wrong values decoded: %+v", e.name, decoded)
+		}
+
+		rr := httptest.NewRecorder()
+		if err := testParser.WriteJSON(rr, http.StatusOK, decoded); err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+		if rr.Body.String() != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, rr.Body.String())
+		}
+	}
+}
+
+type int64Sample struct {
+	ID      int64          `json:"id"`
+	Owner   *uint64        `json:"owner"`
+	Count   int            `json:"count"`
+	Small   int32          `json:"small" format:"string"`
+	Timeout time.Duration  `json:"timeout"`
+	Tags    map[string]int `json:"tags"`
+	Extra   any            `json:"extra"`
+}
+
+var int64Tests = []struct {
+	name          string
+	global        bool
+	json          string
+	expected      string
+	errorExpected bool
+}{
+	{name: "off", json: `{"id":9007199254740993,"owner":1,"count":2,"small":"3","timeout":4,"tags":{"a":5},"extra":6}`, expected: `{"id":9007199254740993,"owner":1,"count":2,"small":"3","timeout":4,"tags":{"a":5},"extra":6}`},
+	{name: "on", global: true, json: `{"id":"9007199254740993","owner":"1","count":2,"small":3,"timeout":4,"tags":{"a":5}}`, expected: `{"id":"9007199254740993","owner":"1","count":2,"small":"3","timeout":4,"tags":{"a":5},"extra":null}`},
+	{name: "numbers accepted", global: true, json: `{"id":9007199254740993,"owner":1}`, expected: `{"id":"9007199254740993","owner":"1","count":0,"small":"0","timeout":0,"tags":null,"extra":null}`},
+	{name: "not an integer", global: true, json: `{"id":"12ab"}`, errorExpected: true},
+	{name: "out of range", json: `{"small":"3000000000"}`, errorExpected: true},
+	{name: "negative unsigned", global: true, json: `{"owner":"-1"}`, errorExpected: true},
+}
+
+func TestParser_Int64AsString(t *testing.T) {
+	for _, e := range int64Tests {
+		testParser := Parser{Int64AsString: e.global}
+
+		var decoded int64Sample
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.json))
+		err := testParser.ReadJSON(httptest.NewRecorder(), req, &decoded)
+
+		if e.errorExpected {
+			if err == nil {
+				t.Errorf("%s: error expected, but none received", e.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+			continue
+		}
+		if decoded.ID != 9007199254740993 {
+			t.Errorf("%s: expected the id to survive intact, got %d", e.name, decoded.ID)
+		}
+
+		rr := httptest.NewRecorder()
+		if err := testParser.WriteJSON(rr, http.StatusOK, decoded); err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+		if rr.Body.String() != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, rr.Body.String())
+		}
+	}
+}
+
+var floatFormatTests = []struct {
+	name     string
+	format   *FloatFormat
+	expected string
+}{
+	{name: "unset", expected: `[0.30000000000000004,1e+21,1e-7,2.5,-0.001,0.5]`},
+	{name: "zero value", format: &FloatFormat{}, expected: `[0.30000000000000004,1000000000000000000000,0.0000001,2.5,-0.001,0.5]`},
+	{name: "two decimals", format: &FloatFormat{Decimals: 2}, expected: `[0.3,1000000000000000000000,0,2.5,0,0.5]`},
+	{name: "trailing zeros", format: &FloatFormat{Decimals: 2, TrailingZeros: true}, expected: `[0.30,1000000000000000000000.00,0.00,2.50,0.00,0.50]`},
+	{name: "exponent", format: &FloatFormat{Decimals: 2, Exponent: true}, expected: `[0.3,1e+21,1e-7,2.5,0,0.5]`},
+}
+
+func TestParser_WriteJSONFloatFormat(t *testing.T) {
+	var small float32 = 0.5
+	tenth, fifth := 0.1, 0.2
+
+	for _, e := range floatFormatTests {
+		testParser := Parser{FloatFormat: e.format}
+
+		rr := httptest.NewRecorder()
+		err := testParser.WriteJSON(rr, http.StatusOK, []any{tenth + fifth, 1e21, 1e-7, 2.5, -0.001, small})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+
+		if rr.Body.String() != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, rr.Body.String())
+		}
+	}
+}
+
+var nonFiniteTests = []struct {
+	name          string
+	policy        NonFinite
+	format        *FloatFormat
+	expected      string
+	errorExpected bool
+}{
+	{name: "reject", policy: RejectNonFinite, errorExpected: true},
+	{name: "reject with float format", policy: RejectNonFinite, format: &FloatFormat{}, errorExpected: true},
+	{name: "null", policy: NullNonFinite, expected: `{"error":false,"message":"","data":{"values":[null,null,null,1.5],"ratio":null}}`},
+	{name: "string", policy: StringNonFinite, expected: `{"error":false,"message":"","data":{"values":["NaN","+Inf","-Inf",1.5],"ratio":"+Inf"}}`},
+}
+
+func TestParser_WriteJSONNonFinite(t *testing.T) {
+	type stats struct {
+		Values []float64 `json:"values"`
+		Ratio  float32   `json:"ratio"`
+	}
+	payload := JSONResponse{Data: stats{
+		Values: []float64{math.NaN(), math.Inf(1), math.Inf(-1), 1.5},
+		Ratio:  float32(math.Inf(1)),
+	}}
+
+	for _, e := range nonFiniteTests {
+		testParser := Parser{NonFinite: e.policy, FloatFormat: e.format}
+
+		rr := httptest.NewRecorder()
+		err := testParser.WriteJSON(rr, http.StatusOK, payload)
+
+		if e.errorExpected {
+			var nonFiniteError *NonFiniteError
+			if !errors.As(err, &nonFiniteError) || !math.IsNaN(nonFiniteError.Value) {
+				t.Errorf("%s: expected a NonFiniteError for NaN, got %v", e.name, err)
+			}
+			if rr.Body.Len() != 0 {
+				t.Errorf("%s: expected nothing to be written, got %s", e.name, rr.Body.String())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+
+		if rr.Body.String() != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, rr.Body.String())
+		}
+	}
+}
+
+var emptyFieldsTests = []struct {
+	name     string
+	policy   EmptyFields
+	expected string
+}{
+	{name: "tag", policy: TagEmptyFields, expected: `{"error":false,"message":"","data":{"name":"","count":0,"note":null}}`},
+	{name: "keep", policy: KeepEmptyFields, expected: `{"error":false,"message":"","data":{"name":"","count":0,"note":null,"tags":null},"fields":null,"meta":null,"links":null,"warnings":null}`},
+	{name: "omit", policy: OmitEmptyFields, expected: `{"data":{}}`},
+}
+
+func TestParser_WriteJSONEmptyFields(t *testing.T) {
+	type record struct {
+		Name  string   `json:"name"`
+		Count int      `json:"count"`
+		Note  *string  `json:"note"`
+		Tags  []string `json:"tags,omitempty"`
+	}
+
+	for _, e := range emptyFieldsTests {
+		testParser := Parser{EmptyFields: e.policy}
+
+		rr := httptest.NewRecorder()
+		if err := testParser.WriteJSON(rr, http.StatusOK, JSONResponse{Data: record{}}); err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+		if rr.Body.String() != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, rr.Body.String())
+		}
+	}
+}
+
+func TestParser_WriteJSONWriteOnly(t *testing.T) {
+	type account struct {
+		Name     string `json:"name"`
+		Password string `json:"password,writeonly"`
+		Token    string `json:",writeonly"`
+	}
+
+	for _, testParser := range []*Parser{{}, {Pretty: true}, {SortKeys: true}, {TagName: "api"}} {
+		rr := httptest.NewRecorder()
+		if err := testParser.WriteJSON(rr, http.StatusOK, []any{account{Name: "ann", Password: "secret", Token: "t"}}); err != nil {
+			t.Fatal(err)
+		}
+		if body := rr.Body.String(); strings.Contains(body, "secret") || strings.Contains(body, "Token") || !strings.Contains(body, "ann") {
+			t.Errorf("expected only the name to be written, got %s", body)
+		}
+	}
+
+	var testParser Parser
+	var got account
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "ann", "password": "secret"}`))
+	if err := testParser.ReadJSON(httptest.NewRecorder(), req, &got); err != nil || got.Password != "secret" {
+		t.Errorf("expected the password to be read, got %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,155 @@
+package ps
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// defaultMaxExpansionRatio is how many times its compressed size a body may expand to by default.
+const defaultMaxExpansionRatio = 100
+
+// ratioGrace is how large a decompressed body may grow before MaxExpansionRatio applies, so that small bodies,
+// which compress unusually well, are not refused.
+const ratioGrace = 64 << 10
+
+// ErrDecompressionLimit is wrapped in the *RequestTooLargeError returned when a compressed body expands beyond
+// MaxDecompressedSize or MaxExpansionRatio.
+var ErrDecompressionLimit = errors.New("compressed body expands too far")
+
+// UnsupportedEncodingError is returned when a request body uses a Content-Encoding the Parser cannot decode, so
+// its bytes are not fed to the JSON decoder as they are. ErrorJSON answers it with 415 Unsupported Media Type.
+type UnsupportedEncodingError struct {
+	// Encoding is the content coding that is not supported.
+	Encoding string
+	// Supported lists the content codings that are.
+	Supported []string
+}
+
+// Error implements the error interface.
+func (e *UnsupportedEncodingError) Error() string {
+	return "the Content-Encoding " + e.Encoding + " is not supported; the body must be sent as " + strings.Join(e.Supported, " or ")
+}
+
+// supportedEncodings returns the content codings a request body may use.
+func (p *Parser) supportedEncodings() []string {
+	if p.Decompress {
+		return []string{"identity", "gzip", "deflate"}
+	}
+	return []string{"identity"}
+}
+
+// contentCodings returns the content codings applied to the body of r, in the order they were applied, without
+// identity.
+func contentCodings(r *http.Request) []string {
+	var codings []string
+	for _, value := range r.Header.Values("Content-Encoding") {
+		for _, coding := range strings.Split(value, ",") {
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if coding == "x-gzip" {
+				coding = "gzip"
+			}
+			if coding != "" && coding != "identity" {
+				codings = append(codings, coding)
+			}
+		}
+	}
+	return codings
+}
+
+// checkEncoding returns an *UnsupportedEncodingError if the body of r uses a content coding the Parser does not
+// support.
+func (p *Parser) checkEncoding(r *http.Request) error {
+	supported := p.supportedEncodings()
+	for _, coding := range contentCodings(r) {
+		if !containsFold(supported, coding) {
+			return &UnsupportedEncodingError{Encoding: coding, Supported: supported}
+		}
+	}
+	return nil
+}
+
+// decompressed returns a reader for the body read from body with the content codings of r undone. The result may
+// expand to at most MaxExpansionRatio times the compressed bytes read, and to at most maxSize bytes if maxSize is
+// positive; past either limit, reading fails with a *RequestTooLargeError wrapping ErrDecompressionLimit. Call
+// checkEncoding first.
+func (p *Parser) decompressed(r *http.Request, body io.Reader, maxSize int64) (io.Reader, error) {
+	codings := contentCodings(r)
+	if len(codings) == 0 {
+		return body, nil
+	}
+
+	in := &countingReader{r: body}
+	var out io.Reader = in
+	// Codings are listed in the order they were applied, so they are undone from the last.
+	for i := len(codings) - 1; i >= 0; i-- {
+		var err error
+		switch codings[i] {
+		case "gzip":
+			out, err = gzip.NewReader(out)
+		case "deflate":
+			out, err = zlib.NewReader(out)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("the body is not valid %s data: %v", codings[i], err)
+		}
+	}
+
+	ratio := p.MaxExpansionRatio
+	if ratio <= 0 {
+		ratio = defaultMaxExpansionRatio
+	}
+	return &expansionLimit{r: out, in: in, maxSize: maxSize, maxRatio: int64(ratio)}, nil
+}
+
+// decompressBuffer undoes the content codings of r on buf, a whole body read under maxBytes. If there are any, it
+// returns a new pooled buffer holding the result, which the caller hands back with releaseBody as well as buf;
+// otherwise it returns buf.
+func (p *Parser) decompressBuffer(r *http.Request, buf *bytes.Buffer, maxBytes int) (*bytes.Buffer, error) {
+	if len(contentCodings(r)) == 0 {
+		return buf, nil
+	}
+	body, err := p.decompressed(r, bytes.NewReader(buf.Bytes()), p.maxDecompressed(maxBytes))
+	if err != nil {
+		return nil, err
+	}
+	return readBody(body, 0)
+}
+
+// maxDecompressed returns the largest size a compressed body may expand to, given the limit on its compressed size.
+func (p *Parser) maxDecompressed(maxBytes int) int64 {
+	if p.MaxDecompressedSize > 0 {
+		return int64(p.MaxDecompressedSize)
+	}
+	return int64(maxBytes)
+}
+
+// expansionLimit reads decompressed bytes from r, failing once they outgrow maxSize or maxRatio times the
+// compressed bytes read through in.
+type expansionLimit struct {
+	r        io.Reader
+	in       *countingReader
+	n        int64
+	maxSize  int64
+	maxRatio int64
+}
+
+// Read implements io.Reader.
+func (l *expansionLimit) Read(b []byte) (int, error) {
+	n, err := l.r.Read(b)
+	l.n += int64(n)
+	if l.maxSize > 0 && l.n > l.maxSize {
+		return 0, &RequestTooLargeError{Limit: l.maxSize,
+			Err: fmt.Errorf("%w: it expands beyond %d bytes", ErrDecompressionLimit, l.maxSize)}
+	}
+	if l.n > ratioGrace && l.n > l.maxRatio*l.in.n {
+		return 0, &RequestTooLargeError{Limit: l.maxRatio * l.in.n,
+			Err: fmt.Errorf("%w: it expands more than %d times", ErrDecompressionLimit, l.maxRatio)}
+	}
+	return n, err
+}
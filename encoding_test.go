@@ -0,0 +1,142 @@
+package ps
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var encodingTests = []struct {
+	name             string
+	encoding         []string
+	encodingExpected string
+}{
+	{name: "none"},
+	{name: "identity", encoding: []string{"identity"}},
+	{name: "gzip", encoding: []string{"gzip"}, encodingExpected: "gzip"},
+	{name: "upper case", encoding: []string{"BR"}, encodingExpected: "br"},
+	{name: "list", encoding: []string{"identity, deflate"}, encodingExpected: "deflate"},
+	{name: "repeated header", encoding: []string{"identity", "zstd"}, encodingExpected: "zstd"},
+}
+
+func TestParser_ReadJSONContentEncoding(t *testing.T) {
+	var testParser Parser
+
+	for _, e := range encodingTests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"foo": "bar"}`))
+		for _, v := range e.encoding {
+			req.Header.Add("Content-Encoding", v)
+		}
+
+		var decodedJSON struct {
+			Foo string `json:"foo"`
+		}
+		err := testParser.ReadJSON(httptest.NewRecorder(), req, &decodedJSON)
+
+		var encodingError *UnsupportedEncodingError
+		if e.encodingExpected == "" && err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+		}
+		if e.encodingExpected != "" && (!errors.As(err, &encodingError) || encodingError.Encoding != e.encodingExpected) {
+			t.Errorf("%s: expected an *UnsupportedEncodingError for %s, got %v", e.name, e.encodingExpected, err)
+		}
+		if err != nil {
+			rr := httptest.NewRecorder()
+			_ = testParser.ErrorJSON(rr, err)
+			if rr.Code != http.StatusUnsupportedMediaType {
+				t.Errorf("%s: expected status 415, got %d", e.name, rr.Code)
+			}
+		}
+	}
+}
+
+// compress returns body compressed with coding.
+func compress(t *testing.T, coding, body string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	if coding == "gzip" {
+		w = gzip.NewWriter(&buf)
+	} else {
+		w = zlib.NewWriter(&buf)
+	}
+	if _, err := io.WriteString(w, body); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+var decompressionTests = []struct {
+	name          string
+	parser        Parser
+	coding        string
+	body          string
+	errorExpected string
+}{
+	{name: "gzip", parser: Parser{Decompress: true}, coding: "gzip", body: `{"foo": "bar"}`},
+	{name: "deflate", parser: Parser{Decompress: true}, coding: "deflate", body: `{"foo": "bar"}`},
+	{name: "digest checked", parser: Parser{Decompress: true, RequestDigest: VerifyDigest}, coding: "gzip", body: `{"foo": "bar"}`},
+	{name: "disabled", coding: "gzip", body: `{"foo": "bar"}`, errorExpected: "the Content-Encoding gzip is not supported"},
+	{name: "over size", parser: Parser{Decompress: true, MaxDecompressedSize: 1 << 10}, coding: "gzip", body: `{"foo": "` + strings.Repeat("a", 4<<10) + `"}`, errorExpected: ErrDecompressionLimit.Error()},
+	{name: "over default size", parser: Parser{Decompress: true, MaxJSONSize: 1 << 10}, coding: "gzip", body: `{"foo": "` + strings.Repeat("a", 4<<10) + `"}`, errorExpected: ErrDecompressionLimit.Error()},
+	{name: "over ratio", parser: Parser{Decompress: true, MaxJSONSize: 1 << 20, MaxDecompressedSize: 1 << 30}, coding: "gzip", body: `{"foo": "` + strings.Repeat("a", 1<<20) + `"}`, errorExpected: ErrDecompressionLimit.Error()},
+	{name: "within ratio", parser: Parser{Decompress: true, MaxExpansionRatio: 2000, MaxDecompressedSize: 2 << 20}, coding: "gzip", body: `{"foo": "` + strings.Repeat("a", 1<<20) + `"}`},
+}
+
+func TestParser_ReadJSONDecompression(t *testing.T) {
+	for i := range decompressionTests {
+		e := &decompressionTests[i]
+		body := compress(t, e.coding, e.body)
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Encoding", e.coding)
+		if e.parser.RequestDigest != IgnoreDigest {
+			// The digest covers the body as sent, still compressed.
+			sum := sha256.Sum256(body)
+			req.Header.Set("Content-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(sum[:])+":")
+		}
+
+		var decodedJSON struct {
+			Foo string `json:"foo"`
+		}
+		err := e.parser.ReadJSON(httptest.NewRecorder(), req, &decodedJSON)
+
+		if e.errorExpected == "" && (err != nil || decodedJSON.Foo == "") {
+			t.Errorf("%s: expected the body to be decoded, got %v", e.name, err)
+		}
+		if e.errorExpected != "" && (err == nil || !strings.HasPrefix(err.Error(), e.errorExpected)) {
+			t.Errorf("%s: expected error %q, got %v", e.name, e.errorExpected, err)
+		}
+		if errors.Is(err, ErrDecompressionLimit) {
+			rr := httptest.NewRecorder()
+			_ = e.parser.ErrorJSON(rr, err)
+			if rr.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("%s: expected status 413, got %d", e.name, rr.Code)
+			}
+		}
+	}
+}
+
+func TestParser_ReadJSONDecompressionInvalid(t *testing.T) {
+	testParser := Parser{Decompress: true}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"foo": "bar"}`))
+	req.Header.Set("Content-Encoding", "gzip")
+
+	var decodedJSON struct {
+		Foo string `json:"foo"`
+	}
+	err := testParser.ReadJSON(httptest.NewRecorder(), req, &decodedJSON)
+	if err == nil || !strings.HasPrefix(err.Error(), "the body is not valid gzip data") {
+		t.Errorf("expected an invalid gzip error, got %v", err)
+	}
+}
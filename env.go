@@ -0,0 +1,322 @@
+package ps
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewFromEnv returns a Parser configured from environment variables, so limits can be tuned per deployment
+// without a code change. Unset variables leave the default. The variables are:
+//
+//	PS_MAX_JSON_SIZE          maximum body size in bytes
+//	PS_ALLOW_UNKNOWN_FIELDS   true or false
+//	PS_ALLOW_EMPTY_BODY       true or false
+//	PS_MAX_DEPTH              maximum nesting of arrays and objects
+//	PS_DECOMPRESS             true or false
+//	PS_MAX_DECOMPRESSED_SIZE  maximum decompressed body size in bytes
+//	PS_MAX_EXPANSION_RATIO    maximum ratio of decompressed to compressed size
+//	PS_REJECT_DUPLICATE_KEYS  true or false
+//	PS_REQUIRE_CONTENT_TYPE   true or false
+//	PS_LENIENT_COERCION       true or false
+//	PS_READ_ONLY_FIELDS       ignore or reject
+//	PS_DIAGNOSTICS            true or false
+//	PS_REQUEST_DIGEST         ignore, verify or require
+//	PS_MAX_RESPONSE_SIZE      maximum response size in bytes
+//	PS_OVERSIZED_RESPONSE     reject, truncate or stream
+//	PS_POLL_TIMEOUT           no-content or envelope
+//	PS_WRITE_TIMEOUT          time limit for writing a response or stream value, such as 30s
+//	PS_FULL_DUPLEX            true or false
+//	PS_RESPONSE_DIGEST        true or false
+//	PS_PRETTY                 true or false
+//	PS_CANONICAL              true or false
+//	PS_SORT_KEYS              true or false
+//	PS_TRUST_RAW_JSON         true or false
+//	PS_TIME_FORMAT            rfc3339, unix, unixmilli or a time layout
+//	PS_DISCLOSURE             standard, production or debug
+//	PS_DURATION_FORMAT        string or seconds
+//	PS_EMPTY_FIELDS           tag, keep or omit
+//	PS_INT64_AS_STRING        true or false
+//	PS_NON_FINITE             reject, null or string
+//	PS_UNEXPECTED_BODY        ignore, reject or strip
+//	PS_LANGUAGES              comma-separated language tags, the default first
+//	PS_TAG_NAME               struct tag read for field names
+//	PS_VERSION_HEADER         header name
+//	PS_API_VERSIONS           comma-separated API versions, the default first
+//	PS_REQUEST_ID_HEADER      header name
+//	PS_WARNINGS               both, body or header
+//
+// Every invalid variable is reported in the returned error, and no Parser is returned. So are combinations that
+// Validate rejects.
+func NewFromEnv() (*Parser, error) {
+	p := &Parser{}
+	var errs []error
+
+	env := func(name string, parse func(string) error) {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if err := parse(strings.TrimSpace(value)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	boolean := func(dst *bool) func(string) error {
+		return func(s string) error {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return fmt.Errorf("invalid boolean %q", s)
+			}
+			*dst = b
+			return nil
+		}
+	}
+
+	env("PS_MAX_JSON_SIZE", func(s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("must be a positive number of bytes, got %q", s)
+		}
+		p.MaxJSONSize = n
+		return nil
+	})
+	env("PS_ALLOW_UNKNOWN_FIELDS", boolean(&p.AllowUnknownFields))
+	env("PS_ALLOW_EMPTY_BODY", boolean(&p.AllowEmptyBody))
+	env("PS_MAX_DEPTH", func(s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("must be a positive number, got %q", s)
+		}
+		p.MaxDepth = n
+		return nil
+	})
+	env("PS_DECOMPRESS", boolean(&p.Decompress))
+	env("PS_MAX_DECOMPRESSED_SIZE", func(s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("must be a positive number of bytes, got %q", s)
+		}
+		p.MaxDecompressedSize = n
+		return nil
+	})
+	env("PS_MAX_EXPANSION_RATIO", func(s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("must be a positive number, got %q", s)
+		}
+		p.MaxExpansionRatio = n
+		return nil
+	})
+	env("PS_REJECT_DUPLICATE_KEYS", boolean(&p.RejectDuplicateKeys))
+	env("PS_REQUIRE_CONTENT_TYPE", boolean(&p.RequireContentType))
+	env("PS_LENIENT_COERCION", boolean(&p.LenientCoercion))
+	env("PS_READ_ONLY_FIELDS", func(s string) error {
+		switch strings.ToLower(s) {
+		case "ignore":
+			p.ReadOnlyFields = IgnoreReadOnlyFields
+		case "reject":
+			p.ReadOnlyFields = RejectReadOnlyFields
+		default:
+			return fmt.Errorf("must be ignore or reject, got %q", s)
+		}
+		return nil
+	})
+	env("PS_DIAGNOSTICS", boolean(&p.Diagnostics))
+	env("PS_REQUEST_DIGEST", func(s string) error {
+		switch strings.ToLower(s) {
+		case "ignore":
+			p.RequestDigest = IgnoreDigest
+		case "verify":
+			p.RequestDigest = VerifyDigest
+		case "require":
+			p.RequestDigest = RequireDigest
+		default:
+			return fmt.Errorf("must be ignore, verify or require, got %q", s)
+		}
+		return nil
+	})
+	env("PS_MAX_RESPONSE_SIZE", func(s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("must be a positive number of bytes, got %q", s)
+		}
+		p.MaxResponseSize = n
+		return nil
+	})
+	env("PS_OVERSIZED_RESPONSE", func(s string) error {
+		switch strings.ToLower(s) {
+		case "reject":
+			p.OversizedResponse = RejectOversizedResponse
+		case "truncate":
+			p.OversizedResponse = TruncateOversizedResponse
+		case "stream":
+			p.OversizedResponse = StreamOversizedResponse
+		default:
+			return fmt.Errorf("must be reject, truncate or stream, got %q", s)
+		}
+		return nil
+	})
+	env("PS_POLL_TIMEOUT", func(s string) error {
+		switch strings.ToLower(s) {
+		case "no-content":
+			p.PollTimeout = NoContentPollTimeout
+		case "envelope":
+			p.PollTimeout = EnvelopePollTimeout
+		default:
+			return fmt.Errorf("must be no-content or envelope, got %q", s)
+		}
+		return nil
+	})
+	env("PS_WRITE_TIMEOUT", func(s string) error {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("must be a positive duration, got %q", s)
+		}
+		p.WriteTimeout = d
+		return nil
+	})
+	env("PS_FULL_DUPLEX", boolean(&p.FullDuplex))
+	env("PS_RESPONSE_DIGEST", boolean(&p.ResponseDigest))
+	env("PS_PRETTY", boolean(&p.Pretty))
+	env("PS_CANONICAL", boolean(&p.Canonical))
+	env("PS_SORT_KEYS", boolean(&p.SortKeys))
+	env("PS_TRUST_RAW_JSON", boolean(&p.TrustRawJSON))
+	env("PS_TIME_FORMAT", func(s string) error {
+		if s == "" {
+			return errors.New("must not be empty")
+		}
+		p.TimeFormat = s
+		return nil
+	})
+	env("PS_DISCLOSURE", func(s string) error {
+		switch strings.ToLower(s) {
+		case "standard":
+			p.Disclosure = StandardDisclosure
+		case "production":
+			p.Disclosure = ProductionDisclosure
+		case "debug":
+			p.Disclosure = DebugDisclosure
+		default:
+			return fmt.Errorf("must be standard, production or debug, got %q", s)
+		}
+		return nil
+	})
+	env("PS_DURATION_FORMAT", func(s string) error {
+		switch s {
+		case DurationString, DurationSeconds:
+			p.DurationFormat = s
+			return nil
+		}
+		return fmt.Errorf("must be %s or %s, got %q", DurationString, DurationSeconds, s)
+	})
+	env("PS_EMPTY_FIELDS", func(s string) error {
+		switch strings.ToLower(s) {
+		case "tag":
+			p.EmptyFields = TagEmptyFields
+		case "keep":
+			p.EmptyFields = KeepEmptyFields
+		case "omit":
+			p.EmptyFields = OmitEmptyFields
+		default:
+			return fmt.Errorf("must be tag, keep or omit, got %q", s)
+		}
+		return nil
+	})
+	env("PS_INT64_AS_STRING", boolean(&p.Int64AsString))
+	env("PS_NON_FINITE", func(s string) error {
+		switch strings.ToLower(s) {
+		case "reject":
+			p.NonFinite = RejectNonFinite
+		case "null":
+			p.NonFinite = NullNonFinite
+		case "string":
+			p.NonFinite = StringNonFinite
+		default:
+			return fmt.Errorf("must be reject, null or string, got %q", s)
+		}
+		return nil
+	})
+	env("PS_UNEXPECTED_BODY", func(s string) error {
+		switch strings.ToLower(s) {
+		case "ignore":
+			p.UnexpectedBody = IgnoreUnexpectedBody
+		case "reject":
+			p.UnexpectedBody = RejectUnexpectedBody
+		case "strip":
+			p.UnexpectedBody = StripUnexpectedBody
+		default:
+			return fmt.Errorf("must be ignore, reject or strip, got %q", s)
+		}
+		return nil
+	})
+	env("PS_LANGUAGES", func(s string) error {
+		p.Languages = nil
+		for _, tag := range strings.Split(s, ",") {
+			tag = strings.TrimSpace(tag)
+			if !validLanguageTag(tag) {
+				return fmt.Errorf("invalid language tag %q", tag)
+			}
+			p.Languages = append(p.Languages, tag)
+		}
+		return nil
+	})
+	env("PS_TAG_NAME", func(s string) error {
+		if s == "" || strings.ContainsAny(s, " \t:\"") {
+			return fmt.Errorf("invalid struct tag name %q", s)
+		}
+		p.TagName = s
+		return nil
+	})
+	env("PS_VERSION_HEADER", func(s string) error {
+		if s == "" || strings.ContainsAny(s, " \t:") {
+			return fmt.Errorf("invalid header name %q", s)
+		}
+		p.VersionHeader = s
+		return nil
+	})
+	env("PS_API_VERSIONS", func(s string) error {
+		p.APIVersions = nil
+		for _, version := range strings.Split(s, ",") {
+			version = strings.TrimSpace(version)
+			if version == "" {
+				return fmt.Errorf("invalid API version list %q", s)
+			}
+			p.APIVersions = append(p.APIVersions, version)
+		}
+		return nil
+	})
+	env("PS_REQUEST_ID_HEADER", func(s string) error {
+		if s == "" || strings.ContainsAny(s, " \t:") {
+			return fmt.Errorf("invalid header name %q", s)
+		}
+		p.RequestIDHeader = s
+		return nil
+	})
+	env("PS_WARNINGS", func(s string) error {
+		switch strings.ToLower(s) {
+		case "both":
+			p.Warnings = HeaderAndBodyWarnings
+		case "body":
+			p.Warnings = BodyWarnings
+		case "header":
+			p.Warnings = HeaderWarnings
+		default:
+			return fmt.Errorf("must be both, body or header, got %q", s)
+		}
+		return nil
+	})
+
+	// Variables that are valid on their own may still contradict each other.
+	if len(errs) == 0 {
+		errs = p.configErrors()
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid parser configuration: %w", errors.Join(errs...))
+	}
+
+	return p, nil
+}
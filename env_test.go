@@ -0,0 +1,51 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewFromEnv(t *testing.T) {
+	t.Setenv("PS_MAX_JSON_SIZE", "2048")
+	t.Setenv("PS_ALLOW_UNKNOWN_FIELDS", "true")
+	t.Setenv("PS_PRETTY", "1")
+	t.Setenv("PS_NON_FINITE", "null")
+	t.Setenv("PS_UNEXPECTED_BODY", "Strip")
+	t.Setenv("PS_READ_ONLY_FIELDS", "reject")
+	t.Setenv("PS_LANGUAGES", "en-GB, fr")
+
+	p, err := NewFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.MaxJSONSize != 2048 || !p.AllowUnknownFields || !p.Pretty || p.NonFinite != NullNonFinite ||
+		p.UnexpectedBody != StripUnexpectedBody || p.ReadOnlyFields != RejectReadOnlyFields || strings.Join(p.Languages, ",") != "en-GB,fr" {
+		t.Errorf("unexpected configuration: %+v", p)
+	}
+
+	rr := httptest.NewRecorder()
+	if err := p.WriteJSON(rr, http.StatusOK, map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Body.String() != "{\n  \"a\": 1\n}" {
+		t.Errorf("expected indented output, got %q", rr.Body.String())
+	}
+}
+
+func TestNewFromEnv_Invalid(t *testing.T) {
+	t.Setenv("PS_MAX_JSON_SIZE", "-1")
+	t.Setenv("PS_PRETTY", "yes please")
+	t.Setenv("PS_DURATION_FORMAT", "minutes")
+
+	p, err := NewFromEnv()
+	if err == nil || p != nil {
+		t.Fatal("expected an error for invalid variables")
+	}
+	for _, name := range []string{"PS_MAX_JSON_SIZE", "PS_PRETTY", "PS_DURATION_FORMAT"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("expected %s to be reported, got %v", name, err)
+		}
+	}
+}
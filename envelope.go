@@ -0,0 +1,116 @@
+package ps
+
+import (
+	"net/http"
+	"time"
+)
+
+// ResponseMeta is the meta section of a JSONResponse: facts about the response rather than its data.
+type ResponseMeta struct {
+	// RequestID identifies the request, for matching the response with server logs.
+	RequestID string `json:"request_id,omitempty"`
+	// DurationMS is how long the server took to handle the request, in milliseconds.
+	DurationMS float64 `json:"duration_ms,omitempty"`
+	// Page describes the page of a paginated collection the data holds.
+	Page *PageMeta `json:"page,omitempty"`
+	// Deprecation, set by MetaDeprecation, tells clients the endpoint is being phased out.
+	Deprecation *DeprecationMeta `json:"deprecation,omitempty"`
+}
+
+// PageMeta describes one page of a paginated collection. Offset-paginated collections set Number and Size;
+// cursor-paginated ones set NextCursor instead.
+type PageMeta struct {
+	Number     int    `json:"number,omitempty"`
+	Size       int    `json:"size,omitempty"`
+	Total      int64  `json:"total,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ResponseLinks is the links section of a JSONResponse.
+type ResponseLinks struct {
+	// Self is the URL of the response itself.
+	Self string `json:"self,omitempty"`
+	// Next and Prev are the URLs of the neighbouring pages of a paginated collection.
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+	// Related holds the URLs of related resources, by relation name.
+	Related map[string]string `json:"related,omitempty"`
+}
+
+// EnvelopeOption fills in part of the envelope WriteEnvelope writes.
+type EnvelopeOption func(*JSONResponse)
+
+// WriteEnvelope writes data in a JSONResponse, with the meta and links sections filled in by opts, so that every
+// service describes requests, timing and pagination the same way:
+//
+//	_ = parser.WriteEnvelope(w, http.StatusOK, orders,
+//		ps.MetaRequestID(r.Header.Get("X-Request-ID")),
+//		ps.MetaPage(ps.PageMeta{Number: 2, Size: 50, Total: 312}),
+//		ps.SelfLink(r.URL.String()),
+//		ps.NextLink("/orders?page=3"))
+//
+// Sections no option fills in are left out.
+func (p *Parser) WriteEnvelope(w http.ResponseWriter, status int, data any, opts ...EnvelopeOption) error {
+	payload := JSONResponse{Data: data}
+	for _, opt := range opts {
+		opt(&payload)
+	}
+	return p.WriteJSON(w, status, payload)
+}
+
+// meta returns the meta section of r, adding one if it has none.
+func (r *JSONResponse) meta() *ResponseMeta {
+	if r.Meta == nil {
+		r.Meta = &ResponseMeta{}
+	}
+	return r.Meta
+}
+
+// links returns the links section of r, adding one if it has none.
+func (r *JSONResponse) links() *ResponseLinks {
+	if r.Links == nil {
+		r.Links = &ResponseLinks{}
+	}
+	return r.Links
+}
+
+// MetaRequestID sets the request ID in the meta section.
+func MetaRequestID(id string) EnvelopeOption {
+	return func(r *JSONResponse) { r.meta().RequestID = id }
+}
+
+// MetaDuration sets the time taken since start, measured when the envelope is written, in the meta section.
+func MetaDuration(start time.Time) EnvelopeOption {
+	return func(r *JSONResponse) { r.meta().DurationMS = float64(time.Since(start).Microseconds()) / 1000 }
+}
+
+// MetaPage sets the page description in the meta section.
+func MetaPage(page PageMeta) EnvelopeOption {
+	return func(r *JSONResponse) { r.meta().Page = &page }
+}
+
+// SelfLink sets the self link.
+func SelfLink(href string) EnvelopeOption {
+	return func(r *JSONResponse) { r.links().Self = href }
+}
+
+// NextLink sets the link to the next page.
+func NextLink(href string) EnvelopeOption {
+	return func(r *JSONResponse) { r.links().Next = href }
+}
+
+// PrevLink sets the link to the previous page.
+func PrevLink(href string) EnvelopeOption {
+	return func(r *JSONResponse) { r.links().Prev = href }
+}
+
+// RelatedLink adds a link to a related resource under rel.
+func RelatedLink(rel, href string) EnvelopeOption {
+	return func(r *JSONResponse) {
+		links := r.links()
+		if links.Related == nil {
+			links.Related = map[string]string{}
+		}
+		links.Related[rel] = href
+	}
+}
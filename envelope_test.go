@@ -0,0 +1,48 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParser_WriteEnvelope(t *testing.T) {
+	var testParser Parser
+
+	rr := httptest.NewRecorder()
+	err := testParser.WriteEnvelope(rr, http.StatusOK, []int{1, 2},
+		MetaRequestID("req-1"),
+		MetaPage(PageMeta{Number: 2, Size: 2, Total: 5}),
+		SelfLink("/items?page=2"),
+		NextLink("/items?page=3"),
+		PrevLink("/items?page=1"),
+		RelatedLink("owner", "/users/7"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"error":false,"message":"","data":[1,2],"meta":{"request_id":"req-1","page":{"number":2,"size":2,"total":5}},` +
+		`"links":{"self":"/items?page=2","next":"/items?page=3","prev":"/items?page=1","related":{"owner":"/users/7"}}}`
+	if rr.Body.String() != expected {
+		t.Errorf("expected %s, got %s", expected, rr.Body.String())
+	}
+}
+
+func TestParser_WriteEnvelopeSections(t *testing.T) {
+	var testParser Parser
+
+	rr := httptest.NewRecorder()
+	if err := testParser.WriteEnvelope(rr, http.StatusOK, "ok"); err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"error":false,"message":"","data":"ok"}`; rr.Body.String() != expected {
+		t.Errorf("expected %s, got %s", expected, rr.Body.String())
+	}
+
+	var payload JSONResponse
+	MetaDuration(time.Now().Add(-1500 * time.Millisecond))(&payload)
+	if payload.Meta == nil || payload.Meta.DurationMS < 1500 || payload.Links != nil {
+		t.Errorf("expected only a duration of at least 1500ms, got %+v", payload)
+	}
+}
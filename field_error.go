@@ -0,0 +1,81 @@
+package ps
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// FieldError reports a value in a request that is not acceptable for the field it was sent for.
+type FieldError struct {
+	// Field is the dotted path of the field, such as "owner.id".
+	Field string `json:"field"`
+	// Message describes what the field must be, such as "must be a valid UUID".
+	Message string `json:"message"`
+}
+
+// Error implements the error interface.
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return "body " + e.Message
+	}
+	return e.Field + " " + e.Message
+}
+
+// ValidationError reports every field of a request that failed a check.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i := range e.Fields {
+		msgs[i] = e.Fields[i].Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// fieldErrors returns the field errors carried by err, which may be a FieldError or a ValidationError.
+func fieldErrors(err error) []FieldError {
+	var validationError *ValidationError
+	if errors.As(err, &validationError) {
+		return validationError.Fields
+	}
+	var fieldError *FieldError
+	if errors.As(err, &fieldError) {
+		return []FieldError{*fieldError}
+	}
+	return nil
+}
+
+// textValue is implemented by the value types of this package that parse themselves from text, so that a bad
+// value can be reported against the field it was sent for.
+type textValue interface {
+	encoding.TextUnmarshaler
+	// expected describes a valid value, such as "a valid UUID".
+	expected() string
+}
+
+var textValueType = reflect.TypeOf((*textValue)(nil)).Elem()
+
+// parseTextValue checks that node, which will be decoded into type t, is text that t accepts, and returns the
+// parsed value. Numbers are offered to t as their text.
+func parseTextValue(node any, t reflect.Type, path string) (textValue, error) {
+	v := reflect.New(t).Interface().(textValue)
+
+	var text string
+	switch n := node.(type) {
+	case string:
+		text = n
+	case json.Number:
+		text = string(n)
+	}
+	if node == nil || v.UnmarshalText([]byte(text)) != nil {
+		return nil, &FieldError{Field: path, Message: "must be " + v.expected()}
+	}
+
+	return v, nil
+}
@@ -0,0 +1,137 @@
+package ps
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type requiredAddress struct {
+	City string `json:"city,required"`
+	Zip  string `json:"zip"`
+}
+
+type requiredSample struct {
+	Name    string           `json:"name,required"`
+	Email   string           `json:"email,omitempty,required"`
+	Age     int              `json:"age"`
+	Address *requiredAddress `json:"address"`
+}
+
+var requiredTests = []struct {
+	name     string
+	json     string
+	expected []FieldError
+}{
+	{name: "all present", json: `{"name":"n","email":"e"}`},
+	{name: "zero values count as present", json: `{"name":"","email":""}`},
+	{name: "case-insensitive", json: `{"NAME":"n","Email":"e"}`},
+	{name: "missing", json: `{"age":1}`, expected: []FieldError{{"name", "is required"}, {"email", "is required"}}},
+	{name: "null", json: `{"name":null,"email":"e"}`, expected: []FieldError{{"name", "is required"}}},
+	{name: "nested", json: `{"name":"n","email":"e","address":{"zip":"1"}}`, expected: []FieldError{{"address.city", "is required"}}},
+	{name: "absent nested struct", json: `{"name":"n","email":"e","address":null}`},
+}
+
+func TestParser_ReadJSONRequired(t *testing.T) {
+	var testParser Parser
+
+	for _, e := range requiredTests {
+		var decoded requiredSample
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.json))
+		err := testParser.ReadJSON(httptest.NewRecorder(), req, &decoded)
+
+		if e.expected == nil {
+			if err != nil {
+				t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+			}
+			continue
+		}
+
+		var validationError *ValidationError
+		if !errors.As(err, &validationError) {
+			t.Errorf("%s: expected a ValidationError, got %v", e.name, err)
+			continue
+		}
+		if len(validationError.Fields) != len(e.expected) {
+			t.Errorf("%s: expected %v, got %v", e.name, e.expected, validationError.Fields)
+			continue
+		}
+		for i := range e.expected {
+			if validationError.Fields[i] != e.expected[i] {
+				t.Errorf("%s: expected %v, got %v", e.name, e.expected, validationError.Fields)
+			}
+		}
+	}
+}
+
+var enumTests = []struct {
+	name          string
+	json          string
+	errorExpected string
+}{
+	{name: "allowed", json: `{"status":"draft","priority":2,"labels":["bug"]}`},
+	{name: "null", json: `{"status":null}`},
+	{name: "absent", json: `{}`},
+	{name: "not allowed", json: `{"status":"deleted"}`, errorExpected: "status must be one of draft, published or archived"},
+	{name: "case matters", json: `{"status":"Draft"}`, errorExpected: "status must be one of draft, published or archived"},
+	{name: "number", json: `{"priority":4}`, errorExpected: "priority must be one of 1, 2 or 3"},
+	{name: "array element", json: `{"labels":["bug","spam"]}`, errorExpected: "labels must be one of bug or feature"},
+	{name: "nested", json: `{"parent":{"status":"gone"}}`, errorExpected: "parent.status must be one of draft, published or archived"},
+}
+
+func TestParser_ReadJSONEnum(t *testing.T) {
+	var testParser Parser
+
+	type post struct {
+		Status   *string  `json:"status" enum:"draft,published,archived"`
+		Priority int      `json:"priority" enum:"1, 2, 3"`
+		Labels   []string `json:"labels" enum:"bug,feature"`
+		Parent   *struct {
+			Status string `json:"status" enum:"draft,published,archived"`
+		} `json:"parent"`
+	}
+	for _, e := range enumTests {
+		var decoded post
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.json))
+		err := testParser.ReadJSON(httptest.NewRecorder(), req, &decoded)
+
+		if e.errorExpected == "" && err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+		}
+		if e.errorExpected != "" && (err == nil || err.Error() != e.errorExpected || len(fieldErrors(err)) != 1) {
+			t.Errorf("%s: expected field error %q, got %v", e.name, e.errorExpected, err)
+		}
+	}
+}
+
+func TestParser_ErrorJSONFields(t *testing.T) {
+	var testParser Parser
+
+	for _, e := range []struct {
+		err      error
+		expected string
+	}{
+		{
+			err:      &ValidationError{Fields: []FieldError{{"name", "is required"}, {"email", "is required"}}},
+			expected: `{"error":true,"message":"name is required; email is required","fields":[{"field":"name","message":"is required"},{"field":"email","message":"is required"}]}`,
+		},
+		{
+			err:      &FieldError{Field: "id", Message: "must be a valid UUID"},
+			expected: `{"error":true,"message":"id must be a valid UUID","fields":[{"field":"id","message":"must be a valid UUID"}]}`,
+		},
+		{
+			err:      errors.New("plain"),
+			expected: `{"error":true,"message":"plain"}`,
+		},
+	} {
+		rr := httptest.NewRecorder()
+		if err := testParser.ErrorJSON(rr, e.err); err != nil {
+			t.Fatal(err)
+		}
+		if rr.Body.String() != e.expected {
+			t.Errorf("expected %s, got %s", e.expected, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package ps
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// field describes one JSON-visible field of a struct, resolved the way encoding/json resolves it, together with
+// the options this package reads from struct tags.
+type field struct {
+	// name is the JSON object key.
+	name string
+	// encodedName is name as a JSON string, followed by a colon.
+	encodedName []byte
+	// tagged reports whether the name came from a struct tag.
+	tagged bool
+	// index is the field's index sequence, for reflect.Value.FieldByIndex.
+	index []int
+	// typ is the declared type of the field.
+	typ reflect.Type
+	// omitEmpty, quoted, required, readOnly and writeOnly record the omitempty, string, required, readonly and
+	// writeonly options of the json tag.
+	omitEmpty bool
+	quoted    bool
+	required  bool
+	readOnly  bool
+	writeOnly bool
+	// format is the value of the format tag.
+	format string
+	// decimal holds the limits of the decimal tag.
+	decimal decimalLimits
+	// normalize is the value of the normalize tag.
+	normalize string
+	// enum lists the values allowed by the enum tag.
+	enum []string
+	// requirements are the conditional required rules of the validate tag.
+	requirements []requirement
+}
+
+// structInfo holds the fields of a struct type, in declaration order.
+type structInfo struct {
+	fields []field
+	byName map[string]*field
+	// altTag reports whether any field, including those of embedded structs, has the tag the fields were read
+	// from, when that is not the json tag.
+	altTag bool
+}
+
+// lookup finds the field for an object key, preferring an exact match and falling back to a case-insensitive
+// one, as encoding/json does.
+func (s *structInfo) lookup(key string) *field {
+	if f, ok := s.byName[key]; ok {
+		return f
+	}
+	for i := range s.fields {
+		if strings.EqualFold(s.fields[i].name, key) {
+			return &s.fields[i]
+		}
+	}
+	return nil
+}
+
+// byIndex finds the field with the given index sequence.
+func (s *structInfo) byIndex(index []int) *field {
+	for i := range s.fields {
+		if slices.Equal(s.fields[i].index, index) {
+			return &s.fields[i]
+		}
+	}
+	return nil
+}
+
+// structKey identifies the fields of a struct type read from one tag.
+type structKey struct {
+	typ reflect.Type
+	tag string
+}
+
+// structCache caches structInfo by structKey.
+var structCache sync.Map
+
+// structFields returns the JSON-visible fields of the struct type t, as encoding/json sees them.
+func structFields(t reflect.Type) *structInfo {
+	return taggedFields(t, "json")
+}
+
+// taggedFields returns the fields of the struct type t, with names and options read from the tag called tagName
+// where a field has one, and from the json tag otherwise.
+func taggedFields(t reflect.Type, tagName string) *structInfo {
+	key := structKey{typ: t, tag: tagName}
+	if info, ok := structCache.Load(key); ok {
+		return info.(*structInfo)
+	}
+
+	fields, altTag := typeFields(t, tagName)
+	info := &structInfo{fields: fields, byName: make(map[string]*field, len(fields)), altTag: altTag}
+	for i := range info.fields {
+		f := &info.fields[i]
+		name, _ := json.Marshal(f.name)
+		f.encodedName = append(name, ':')
+		info.byName[f.name] = f
+	}
+
+	actual, _ := structCache.LoadOrStore(key, info)
+	return actual.(*structInfo)
+}
+
+// typeFields follows the rules of encoding/json: fields of embedded structs are promoted, a shallower field hides
+// deeper ones with the same name, and of several fields at the same depth a tagged one wins, or else all of them
+// are dropped. Names and options come from the tag called tagName, falling back to the json tag; altTag reports
+// whether any field had a tagName tag other than json.
+func typeFields(t reflect.Type, tagName string) (fields []field, altTag bool) {
+
+	current := []field{}
+	next := []field{{typ: t}}
+	count := map[reflect.Type]int{}
+	nextCount := map[reflect.Type]int{}
+	visited := map[reflect.Type]bool{}
+
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, f := range current {
+			if visited[f.typ] {
+				continue
+			}
+			visited[f.typ] = true
+
+			for i := 0; i < f.typ.NumField(); i++ {
+				sf := f.typ.Field(i)
+				if sf.Anonymous {
+					ft := sf.Type
+					if ft.Kind() == reflect.Pointer {
+						ft = ft.Elem()
+					}
+					if !sf.IsExported() && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+
+				tag, ok := sf.Tag.Lookup(tagName)
+				if ok && tagName != "json" {
+					altTag = true
+				} else if !ok {
+					tag = sf.Tag.Get("json")
+				}
+				if tag == "-" {
+					continue
+				}
+				name, opts, _ := strings.Cut(tag, ",")
+				if !validTagName(name) {
+					name = ""
+				}
+
+				index := make([]int, len(f.index)+1)
+				copy(index, f.index)
+				index[len(f.index)] = i
+
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+
+				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+					quoted := false
+					if hasOption(opts, "string") {
+						switch ft.Kind() {
+						case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+							reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+							reflect.Float32, reflect.Float64, reflect.String:
+							quoted = true
+						}
+					}
+
+					fields = append(fields, field{
+						name:      name,
+						tagged:    name != "",
+						index:     index,
+						typ:       sf.Type,
+						omitEmpty: hasOption(opts, "omitempty"),
+						required:  hasOption(opts, "required"),
+						readOnly:  hasOption(opts, "readonly"),
+						writeOnly: hasOption(opts, "writeonly"),
+						quoted:    quoted,
+						format:    sf.Tag.Get("format"),
+						decimal:   parseDecimalTag(sf.Tag.Get("decimal")),
+						normalize: sf.Tag.Get("normalize"),
+						enum:      parseEnumTag(sf.Tag.Get("enum")),
+
+						requirements: parseConstraints(sf.Tag.Get("validate")).requirements,
+					})
+					if fields[len(fields)-1].name == "" {
+						fields[len(fields)-1].name = sf.Name
+					}
+					if count[f.typ] > 1 {
+						// The struct was reached more than once at this depth, so its fields annihilate each other.
+						fields = append(fields, fields[len(fields)-1])
+					}
+					continue
+				}
+
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, field{name: ft.Name(), index: index, typ: ft})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		x, y := fields[i], fields[j]
+		if x.name != y.name {
+			return x.name < y.name
+		}
+		if len(x.index) != len(y.index) {
+			return len(x.index) < len(y.index)
+		}
+		if x.tagged != y.tagged {
+			return x.tagged
+		}
+		return indexLess(x.index, y.index)
+	})
+
+	out := fields[:0]
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].name == fields[i].name {
+			j++
+		}
+		if dominant, ok := dominantField(fields[i:j]); ok {
+			out = append(out, dominant)
+		}
+		i = j
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return indexLess(out[i].index, out[j].index)
+	})
+
+	return out, altTag
+}
+
+// parseEnumTag parses an enum struct tag such as "draft,published,archived" into the values it allows.
+func parseEnumTag(tag string) []string {
+	if tag == "" {
+		return nil
+	}
+	values := strings.Split(tag, ",")
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+	return values
+}
+
+// dominantField picks the field that wins among fields sharing a name, which are sorted by depth and then by
+// whether they are tagged.
+func dominantField(fields []field) (field, bool) {
+	if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) && fields[0].tagged == fields[1].tagged {
+		return field{}, false
+	}
+	return fields[0], true
+}
+
+// indexLess orders index sequences as fields appear in the struct.
+func indexLess(a, b []int) bool {
+	for k, x := range a {
+		if k >= len(b) {
+			return false
+		}
+		if x != b[k] {
+			return x < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// hasOption reports whether the comma-separated tag options include option.
+func hasOption(opts, option string) bool {
+	for opts != "" {
+		var o string
+		o, opts, _ = strings.Cut(opts, ",")
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// validTagName reports whether encoding/json would accept name as a key from a struct tag.
+func validTagName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+	return true
+}
+
+// fieldByIndex returns the field of struct v at index, or false if it sits behind a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
@@ -0,0 +1,70 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type tagNameBase struct {
+	Created string `json:"created_at" api:"createdAt"`
+}
+
+type tagNameSample struct {
+	tagNameBase
+	ID       int64  `json:"id" api:"userId,string"`
+	Name     string `json:"name"`
+	Internal string `json:"internal" api:"-"`
+	Secret   string `json:"-" api:"secret,omitempty"`
+	When     Date   `json:"when" api:"day"`
+}
+
+var tagNameTests = []struct {
+	name          string
+	allowUnknown  bool
+	json          string
+	errorExpected string
+}{
+	{name: "api names", json: `{"userId":"7","name":"n","createdAt":"c","day":"2024-03-01"}`},
+	{name: "case-insensitive", json: `{"USERID":"7","Name":"n","createdat":"c","day":"2024-03-01"}`},
+	{name: "json name rejected", json: `{"id":7}`, errorExpected: `body contains unknown key "id"`},
+	{name: "hidden field rejected", json: `{"internal":"x"}`, errorExpected: `body contains unknown key "internal"`},
+	{name: "json name ignored", allowUnknown: true, json: `{"userId":"7","name":"n","createdAt":"c","day":"2024-03-01","id":8}`},
+	{name: "error path uses api name", json: `{"day":"tomorrow"}`, errorExpected: "day must be a valid date (YYYY-MM-DD)"},
+}
+
+func TestParser_TagName(t *testing.T) {
+	for _, e := range tagNameTests {
+		testParser := Parser{TagName: "api", AllowUnknownFields: e.allowUnknown}
+
+		var decoded tagNameSample
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.json))
+		err := testParser.ReadJSON(httptest.NewRecorder(), req, &decoded)
+
+		if e.errorExpected != "" {
+			if err == nil || err.Error() != e.errorExpected {
+				t.Errorf("%s: expected error %q, got %v", e.name, e.errorExpected, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+			continue
+		}
+		if decoded.ID != 7 || decoded.Name != "n" || decoded.Created != "c" || decoded.When.Day != 1 {
+			t.Errorf("%s: wrong values decoded: %+v", e.name, decoded)
+		}
+	}
+
+	testParser := Parser{TagName: "api"}
+	rr := httptest.NewRecorder()
+	sample := tagNameSample{tagNameBase: tagNameBase{Created: "c"}, ID: 7, Name: "n", Internal: "i", When: Date{2024, 3, 1}}
+	if err := testParser.WriteJSON(rr, http.StatusOK, sample); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"createdAt":"c","userId":"7","name":"n","day":"2024-03-01"}`
+	if rr.Body.String() != expected {
+		t.Errorf("expected %s, got %s", expected, rr.Body.String())
+	}
+}
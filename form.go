@@ -0,0 +1,525 @@
+package ps
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// formMediaType is the media type of a URL-encoded form body.
+const formMediaType = "application/x-www-form-urlencoded"
+
+// maxFormDepth is how many brackets a form or query key may nest.
+const maxFormDepth = 32
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// ReadForm decodes the application/x-www-form-urlencoded body of r into data, a pointer to a struct. Fields are
+// named by their form tag, falling back to their JSON names, and unknown keys are refused unless
+// AllowUnknownFields is set. The body is read under the same size limit, content codings and replay protection as
+// a ReadJSON body, and the AfterDecode hooks run on the result. If the form has been parsed already, so that the
+// body is used up, r.PostForm is decoded instead. The _method field MethodOverride reads is not bound.
+//
+// Keys may use the bracketed style of PHP and Rails forms to reach nested structs, slices and maps:
+// user[name]=Ann sets the Name field of the User struct, items[0][qty]=2 the Qty field of the first element of
+// Items, tags[]=a&tags[]=b appends to Tags, and meta[color]=red sets a key of the Meta map. Slice indices only
+// order the elements, so items[3] after items[0] becomes the second element. Every value that does not fit its
+// field is reported in a *ValidationError.
+func (p *Parser) ReadForm(w http.ResponseWriter, r *http.Request, data any) error {
+	err := p.readForm(w, r, data)
+	p.countDecode(err)
+	p.notifyDecode(r, data, err)
+	return err
+}
+
+// readForm does the work of ReadForm.
+func (p *Parser) readForm(w http.ResponseWriter, r *http.Request, data any) error {
+	if p.ReplayGuard != nil {
+		if err := p.ReplayGuard.Check(r); err != nil {
+			return err
+		}
+	}
+	if mediaType(r.Header.Get("Content-Type")) != formMediaType {
+		return errors.New("the Content-Type header is not " + formMediaType)
+	}
+	if err := p.checkEncoding(r); err != nil {
+		return err
+	}
+
+	// A form parsed already, by r.ParseForm or middleware calling it, has used up the body.
+	if r.PostForm != nil {
+		values := cloneValues(r.PostForm)
+		values.Del(methodOverrideField)
+		return p.bindValues(r, values, "form", data)
+	}
+
+	maxBytes := p.maxPayload(r.Method)
+	if r.ContentLength > int64(maxBytes) {
+		return decodeError(&http.MaxBytesError{Limit: int64(maxBytes)}, maxBytes)
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+	body, err := p.decompressed(r, r.Body, p.maxDecompressed(maxBytes))
+	if err != nil {
+		return err
+	}
+	buf, err := readBody(body, r.ContentLength)
+	if err != nil {
+		return decodeError(err, maxBytes)
+	}
+	defer releaseBody(buf)
+	p.count(bytesRead, int64(buf.Len()))
+
+	values, err := url.ParseQuery(buf.String())
+	if err != nil {
+		return errors.New("body contains a badly-formed form")
+	}
+	values.Del(methodOverrideField)
+	return p.bindValues(r, values, "form", data)
+}
+
+// cloneValues returns a copy of values that can be changed without changing it.
+func cloneValues(values url.Values) url.Values {
+	clone := make(url.Values, len(values))
+	for key, v := range values {
+		clone[key] = v
+	}
+	return clone
+}
+
+// ReadQuery decodes the query parameters of r into data, a pointer to a struct, as ReadForm decodes a form body.
+// Fields are named by their query tag, falling back to their JSON names.
+//
+// Besides the bracketed keys ReadForm accepts, two tag options cover the usual shapes of listing endpoints. With
+// comma, as in `query:"tag,comma"`, ?tag=a,b fills a slice as ?tag=a&tag=b does. With dot, as in
+// `query:"meta,dot"`, ?meta.color=red sets a key of a map, or a field of a struct, as ?meta[color]=red does. The
+// options work in form tags too.
+//
+// A validate tag constrains a field's value: `query:"limit" validate:"min=1,max=100"` bounds a number, or the
+// length of a string; enum=asc|desc lists the allowed values; and regex=^[a-z]+$, which must come last, gives a
+// pattern the value must match. Each rule is checked against each element of a slice, and only when the
+// parameter is present. Violations are reported with the other field errors.
+//
+// Fields whose parameter is absent are left as they are, so a nil pointer field stays nil and tells "not
+// provided" apart from an explicit zero: with `query:"active"` on an *bool, no parameter leaves nil, and
+// ?active=false points it at false.
+func (p *Parser) ReadQuery(r *http.Request, data any) error {
+	values, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		return errors.New("the query string is badly formed")
+	}
+	return p.bindValues(r, values, "query", data)
+}
+
+// ReadPath decodes the path parameters of a request, as a router extracted them, into data, a pointer to a
+// struct, as ReadQuery decodes query parameters. Fields are named by their path tag, falling back to their JSON
+// names.
+//
+//	var params struct {
+//		ID   ps.UUID `path:"id"`
+//		Page int     `path:"page" validate:"min=1"`
+//	}
+//	err := parser.ReadPath(r, map[string]string{"id": chi.URLParam(r, "id"), ...}, &params)
+func (p *Parser) ReadPath(r *http.Request, params map[string]string, data any) error {
+	values := make(url.Values, len(params))
+	for name, value := range params {
+		values.Set(name, value)
+	}
+	return p.bindValues(r, values, "path", data)
+}
+
+// bindValues binds values onto the struct data points to, naming fields by tag, and runs the AfterDecode hooks.
+func (p *Parser) bindValues(r *http.Request, values url.Values, tag string, data any) error {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot bind %s values into %T: it is not a pointer to a struct", tag, data)
+	}
+
+	b := &formBinder{tag: tag, allowUnknown: p.AllowUnknownFields, lenient: p.LenientCoercion, rejectReadOnly: p.ReadOnlyFields == RejectReadOnlyFields}
+	root := &formNode{}
+	for key, vals := range values {
+		path, ok := parseFormKey(key)
+		if !ok {
+			b.fail(key, "is not a valid key")
+			continue
+		}
+		root.insert(path, vals)
+	}
+	b.bind(root, v.Elem(), "")
+
+	if len(b.errs) > 0 {
+		sort.Slice(b.errs, func(i, j int) bool { return b.errs[i].Field < b.errs[j].Field })
+		return &ValidationError{Fields: b.errs}
+	}
+	return p.runAfterDecode(r, data)
+}
+
+// parseFormKey splits a key such as items[0][qty] into its segments. A trailing [] adds to a list, and is dropped.
+func parseFormKey(key string) ([]string, bool) {
+	name, rest, nested := strings.Cut(key, "[")
+	if name == "" {
+		return nil, false
+	}
+	path := []string{name}
+	if !nested {
+		return path, true
+	}
+
+	rest = "[" + rest
+	for rest != "" {
+		if rest[0] != '[' || len(path) > maxFormDepth {
+			return nil, false
+		}
+		end := strings.IndexByte(rest, ']')
+		if end < 0 {
+			return nil, false
+		}
+		segment := rest[1:end]
+		rest = rest[end+1:]
+		if strings.IndexByte(segment, '[') >= 0 {
+			return nil, false
+		}
+		if segment == "" {
+			// Only the last segment may be empty.
+			return path, rest == ""
+		}
+		path = append(path, segment)
+	}
+	return path, true
+}
+
+// formNode holds the values sent for one key, and the nodes for the keys nested in it.
+type formNode struct {
+	values   []string
+	children map[string]*formNode
+}
+
+// insert adds values at path below n.
+func (n *formNode) insert(path []string, values []string) {
+	for _, segment := range path {
+		if n.children == nil {
+			n.children = map[string]*formNode{}
+		}
+		child, ok := n.children[segment]
+		if !ok {
+			child = &formNode{}
+			n.children[segment] = child
+		}
+		n = child
+	}
+	n.values = append(n.values, values...)
+}
+
+// formBinder binds a tree of formNodes onto a Go value, collecting the problems it finds.
+type formBinder struct {
+	tag            string
+	allowUnknown   bool
+	lenient        bool
+	rejectReadOnly bool
+	errs           []FieldError
+}
+
+// fail records a problem with the value at path.
+func (b *formBinder) fail(path, message string) {
+	b.errs = append(b.errs, FieldError{Field: path, Message: message})
+}
+
+// bind sets v, which must be settable, from n.
+func (b *formBinder) bind(n *formNode, v reflect.Value, path string) {
+	t := v.Type()
+	if t.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		b.bind(n, v.Elem(), path)
+		return
+	}
+
+	switch {
+	case reflect.PointerTo(t).Implements(textUnmarshalerType) || t.Kind() != reflect.Struct && t.Kind() != reflect.Slice && t.Kind() != reflect.Map:
+		if len(n.children) > 0 {
+			b.fail(path, "must not have nested keys")
+			return
+		}
+		if len(n.values) > 0 {
+			b.setScalar(v, n.values[0], path)
+		}
+
+	case t.Kind() == reflect.Struct:
+		if len(n.values) > 0 && path != "" {
+			b.fail(path, "must be sent as nested keys")
+			return
+		}
+		info := taggedFields(t, b.tag)
+		b.undot(n, t, info)
+		for key, child := range n.children {
+			f := info.lookup(key)
+			if f == nil {
+				if !b.allowUnknown {
+					b.fail(joinPath(path, key), "is not a known field")
+				}
+				continue
+			}
+			if f.readOnly {
+				if b.rejectReadOnly {
+					b.fail(joinPath(path, f.name), "is read-only")
+				}
+				continue
+			}
+			fv, ok := allocFieldByIndex(v, f.index)
+			if !ok {
+				continue
+			}
+			if b.hasOption(t, f, "comma") {
+				child = splitCommas(child)
+			}
+			fieldPath := joinPath(path, f.name)
+			failed := len(b.errs)
+			b.bind(child, fv, fieldPath)
+			if rules, ok := t.FieldByIndex(f.index).Tag.Lookup("validate"); ok && len(b.errs) == failed {
+				if err := parseConstraints(rules).check(fv, fieldPath); err != nil {
+					b.errs = append(b.errs, *err)
+				}
+			}
+		}
+		b.errs = append(b.errs, checkRequirements(info, path, func(f *field) (string, bool) {
+			for key, child := range n.children {
+				if info.lookup(key) != f {
+					continue
+				}
+				if len(child.values) > 0 {
+					return child.values[0], child.values[0] != ""
+				}
+				return "", len(child.children) > 0
+			}
+			return "", false
+		})...)
+
+	case t.Kind() == reflect.Slice:
+		b.bindSlice(n, v, path)
+
+	case t.Kind() == reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			b.fail(path, "has an unsupported type")
+			return
+		}
+		if len(n.values) > 0 {
+			b.fail(path, "must be sent as nested keys")
+			return
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(t, len(n.children)))
+		}
+		for key, child := range n.children {
+			elem := reflect.New(t.Elem()).Elem()
+			b.bind(child, elem, joinPath(path, key))
+			v.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), elem)
+		}
+	}
+}
+
+// hasOption reports whether the tag of f, a field of the struct type t, has option.
+func (b *formBinder) hasOption(t reflect.Type, f *field, option string) bool {
+	tag, ok := t.FieldByIndex(f.index).Tag.Lookup(b.tag)
+	if !ok {
+		return false
+	}
+	_, opts, _ := strings.Cut(tag, ",")
+	return hasOption(opts, option)
+}
+
+// undot moves the children of n sent as name.key, for fields of the struct type t tagged with the dot option, to
+// the key child of the name child, so that ?meta.color=red binds as meta[color]=red would.
+func (b *formBinder) undot(n *formNode, t reflect.Type, info *structInfo) {
+	for key, child := range n.children {
+		name, rest, dotted := strings.Cut(key, ".")
+		if !dotted || rest == "" || info.lookup(key) != nil {
+			continue
+		}
+		if f := info.lookup(name); f == nil || !b.hasOption(t, f, "dot") {
+			continue
+		}
+		delete(n.children, key)
+		n.insert([]string{name}, nil)
+		n.children[name].insert([]string{rest}, nil)
+		n.children[name].children[rest].merge(child)
+	}
+}
+
+// merge adds the values and children of other to n.
+func (n *formNode) merge(other *formNode) {
+	n.values = append(n.values, other.values...)
+	for key, child := range other.children {
+		if n.children == nil {
+			n.children = map[string]*formNode{}
+		}
+		if existing, ok := n.children[key]; ok {
+			existing.merge(child)
+		} else {
+			n.children[key] = child
+		}
+	}
+}
+
+// splitCommas returns n with each of its values split at commas, for fields tagged with the comma option.
+func splitCommas(n *formNode) *formNode {
+	split := &formNode{children: n.children}
+	for _, value := range n.values {
+		split.values = append(split.values, strings.Split(value, ",")...)
+	}
+	return split
+}
+
+// bindSlice appends to the slice v an element for each value of n, then one for each indexed key, in index order.
+func (b *formBinder) bindSlice(n *formNode, v reflect.Value, path string) {
+	t := v.Type()
+	for _, value := range n.values {
+		elem := reflect.New(t.Elem()).Elem()
+		b.bind(&formNode{values: []string{value}}, elem, path)
+		v.Set(reflect.Append(v, elem))
+	}
+
+	type indexed struct {
+		i   int
+		key string
+	}
+	keys := make([]indexed, 0, len(n.children))
+	for key := range n.children {
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 {
+			b.fail(joinPath(path, key), "must be a list index")
+			continue
+		}
+		keys = append(keys, indexed{i, key})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].i != keys[j].i {
+			return keys[i].i < keys[j].i
+		}
+		return keys[i].key < keys[j].key
+	})
+	for _, k := range keys {
+		elem := reflect.New(t.Elem()).Elem()
+		b.bind(n.children[k.key], elem, joinPath(path, k.key))
+		v.Set(reflect.Append(v, elem))
+	}
+}
+
+// setScalar parses s into v.
+func (b *formBinder) setScalar(v reflect.Value, s string, path string) {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if u.UnmarshalText([]byte(s)) != nil {
+			if tv, ok := u.(textValue); ok {
+				b.fail(path, "must be "+tv.expected())
+			} else {
+				b.fail(path, "is not valid")
+			}
+		}
+		return
+	}
+
+	t := v.Type()
+	if b.lenient && t.Kind() != reflect.String {
+		s = strings.TrimSpace(s)
+		if t.Kind() != reflect.Bool && t != durationType {
+			s = stripThousands(s)
+		}
+	}
+
+	switch {
+	case t == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			b.fail(path, "must be a duration")
+			return
+		}
+		v.SetInt(int64(d))
+	case t.Kind() == reflect.String:
+		v.SetString(s)
+	case t.Kind() == reflect.Bool:
+		x, err := b.parseBool(s)
+		if err != nil {
+			b.fail(path, "must be true or false")
+			return
+		}
+		v.SetBool(x)
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		x, err := strconv.ParseInt(s, 10, t.Bits())
+		if err != nil {
+			b.fail(path, "must be an integer")
+			return
+		}
+		v.SetInt(x)
+	case t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64:
+		x, err := strconv.ParseUint(s, 10, t.Bits())
+		if err != nil {
+			b.fail(path, "must be a non-negative integer")
+			return
+		}
+		v.SetUint(x)
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		x, err := strconv.ParseFloat(s, t.Bits())
+		if err != nil {
+			b.fail(path, "must be a number")
+			return
+		}
+		v.SetFloat(x)
+	default:
+		b.fail(path, "has an unsupported type")
+	}
+}
+
+// parseBool parses s as strconv.ParseBool does, also accepting yes, no, on and off, as checkboxes and other
+// forms send them, if the binder is lenient.
+func (b *formBinder) parseBool(s string) (bool, error) {
+	if b.lenient {
+		switch strings.ToLower(s) {
+		case "yes", "on":
+			return true, nil
+		case "no", "off":
+			return false, nil
+		}
+	}
+	return strconv.ParseBool(s)
+}
+
+// stripThousands removes the commas from a number written with them between groups of three digits, such as
+// 1,234,567.5, and returns anything else as it is.
+func stripThousands(s string) string {
+	digits := strings.TrimLeft(s, "+-")
+	whole, _, _ := strings.Cut(digits, ".")
+	groups := strings.Split(whole, ",")
+	if len(groups) < 2 || len(groups[0]) == 0 || len(groups[0]) > 3 {
+		return s
+	}
+	for _, g := range groups[1:] {
+		if len(g) != 3 {
+			return s
+		}
+	}
+	return strings.ReplaceAll(s, ",", "")
+}
+
+// allocFieldByIndex returns the field of struct v at index, allocating the embedded pointers on the way to it. It
+// returns false if one of them cannot be set.
+func allocFieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
@@ -0,0 +1,288 @@
+package ps
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type formItem struct {
+	SKU string `form:"sku"`
+	Qty int    `form:"qty"`
+}
+
+type formOrder struct {
+	User struct {
+		Name  string `form:"name"`
+		Email string `json:"email"`
+	} `form:"user"`
+	Items   []formItem        `form:"items"`
+	Tags    []string          `form:"tags"`
+	Meta    map[string]string `form:"meta"`
+	Note    *string           `form:"note"`
+	Rush    bool              `form:"rush"`
+	Timeout time.Duration     `form:"timeout"`
+	ID      UUID              `form:"id"`
+}
+
+var readFormTests = []struct {
+	name          string
+	body          string
+	expected      func(*formOrder)
+	errorExpected string
+}{
+	{name: "nested struct", body: "user[name]=Ann&user[email]=ann%40example.com", expected: func(o *formOrder) {
+		o.User.Name, o.User.Email = "Ann", "ann@example.com"
+	}},
+	{name: "indexed structs", body: "items[1][sku]=b&items[0][sku]=a&items[0][qty]=2&items[10][sku]=c", expected: func(o *formOrder) {
+		o.Items = []formItem{{SKU: "a", Qty: 2}, {SKU: "b"}, {SKU: "c"}}
+	}},
+	{name: "appended list", body: "tags[]=x&tags[]=y", expected: func(o *formOrder) { o.Tags = []string{"x", "y"} }},
+	{name: "repeated key", body: "tags=x&tags=y", expected: func(o *formOrder) { o.Tags = []string{"x", "y"} }},
+	{name: "map", body: "meta[color]=red&meta[size]=L", expected: func(o *formOrder) {
+		o.Meta = map[string]string{"color": "red", "size": "L"}
+	}},
+	{name: "scalars", body: "note=hi&rush=true&timeout=1m30s", expected: func(o *formOrder) {
+		note := "hi"
+		o.Note, o.Rush, o.Timeout = &note, true, 90*time.Second
+	}},
+	{name: "bad values", body: "rush=maybe&items[0][qty]=two&id=x&timeout=soon", errorExpected: "id must be a valid UUID; items.0.qty must be an integer; rush must be true or false; timeout must be a duration"},
+	{name: "unknown key", body: "user[age]=3&other=1", errorExpected: "other is not a known field; user.age is not a known field"},
+	{name: "bad index", body: "items[x][sku]=a", errorExpected: "items.x must be a list index"},
+	{name: "bad key", body: "items[0[sku]=a", errorExpected: "items[0[sku] is not a valid key"},
+	{name: "nested scalar", body: "rush[a]=1", errorExpected: "rush must not have nested keys"},
+	{name: "flat struct", body: "user=Ann", errorExpected: "user must be sent as nested keys"},
+}
+
+func TestParser_ReadForm(t *testing.T) {
+	var testParser Parser
+
+	for _, e := range readFormTests {
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(e.body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		var got formOrder
+		err := testParser.ReadForm(httptest.NewRecorder(), req, &got)
+
+		if e.errorExpected != "" {
+			if err == nil || err.Error() != e.errorExpected {
+				t.Errorf("%s: expected error %q, got %v", e.name, e.errorExpected, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+			continue
+		}
+		var expected formOrder
+		e.expected(&expected)
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected %+v, got %+v", e.name, expected, got)
+		}
+	}
+}
+
+func TestParser_ReadFormContentType(t *testing.T) {
+	var testParser Parser
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader("rush=true"))
+	req.Header.Set("Content-Type", "application/json")
+
+	var got formOrder
+	if err := testParser.ReadForm(httptest.NewRecorder(), req, &got); err == nil {
+		t.Error("expected an error for a body that is not a form")
+	}
+}
+
+func TestParser_ReadQuery(t *testing.T) {
+	testParser := Parser{AllowUnknownFields: true}
+
+	var filter struct {
+		Status []string `query:"status"`
+		Range  struct {
+			From int `query:"from"`
+			To   int `query:"to"`
+		} `query:"range"`
+		Page int `json:"page"`
+	}
+	req := httptest.NewRequest(http.MethodGet, "/orders?status[]=open&status[]=held&range[from]=10&range[to]=20&page=3&utm_source=mail", nil)
+	if err := testParser.ReadQuery(req, &filter); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(filter.Status, ",") != "open,held" || filter.Range.From != 10 || filter.Range.To != 20 || filter.Page != 3 {
+		t.Errorf("unexpected query binding: %+v", filter)
+	}
+}
+
+var queryOptionTests = []struct {
+	name          string
+	query         string
+	tags          string
+	meta          string
+	owner         string
+	plain         string
+	errorExpected string
+}{
+	{name: "repeated", query: "tag=a&tag=b", tags: "a,b"},
+	{name: "comma separated", query: "tag=a,b&tag=c", tags: "a,b,c"},
+	{name: "bracketed list", query: "tag[]=a,b", tags: "a,b"},
+	{name: "comma not enabled", query: "plain=a,b", plain: "a,b"},
+	{name: "dotted map", query: "meta.color=red&meta.size=L", meta: "color=red,size=L"},
+	{name: "dotted and bracketed map", query: "meta.color=red&meta[size]=L", meta: "color=red,size=L"},
+	{name: "dotted struct", query: "owner.name=Ann", owner: "Ann"},
+	{name: "dot not enabled", query: "plain.x=1", errorExpected: "plain.x is not a known field"},
+	{name: "dotted unknown field", query: "owner.age=3", errorExpected: "owner.age is not a known field"},
+}
+
+func TestParser_ReadQueryOptions(t *testing.T) {
+	var testParser Parser
+
+	for _, e := range queryOptionTests {
+		var filter struct {
+			Tags  []string          `query:"tag,comma"`
+			Plain []string          `query:"plain"`
+			Meta  map[string]string `query:"meta,dot"`
+			Owner struct {
+				Name string `query:"name"`
+			} `query:"owner,dot"`
+		}
+		err := testParser.ReadQuery(httptest.NewRequest(http.MethodGet, "/?"+e.query, nil), &filter)
+
+		if e.errorExpected != "" {
+			if err == nil || err.Error() != e.errorExpected {
+				t.Errorf("%s: expected error %q, got %v", e.name, e.errorExpected, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+			continue
+		}
+
+		var meta []string
+		for k, v := range filter.Meta {
+			meta = append(meta, k+"="+v)
+		}
+		sort.Strings(meta)
+		if got := strings.Join(filter.Tags, ","); got != e.tags {
+			t.Errorf("%s: expected tags %q, got %q", e.name, e.tags, got)
+		}
+		if got := strings.Join(meta, ","); got != e.meta {
+			t.Errorf("%s: expected meta %q, got %q", e.name, e.meta, got)
+		}
+		if len(filter.Plain) > 1 || strings.Join(filter.Plain, "") != e.plain {
+			t.Errorf("%s: expected plain [%q], got %q", e.name, e.plain, filter.Plain)
+		}
+		if filter.Owner.Name != e.owner {
+			t.Errorf("%s: expected owner %q, got %q", e.name, e.owner, filter.Owner.Name)
+		}
+	}
+}
+
+var lenientCoercionTests = []struct {
+	name          string
+	query         string
+	lenient       bool
+	expected      string
+	errorExpected bool
+}{
+	{name: "strict bool", query: "active=1&count=3&price=2.5", expected: "true 3 2.5"},
+	{name: "strict yes", query: "active=yes", errorExpected: true},
+	{name: "strict whitespace", query: "count=+3+", errorExpected: true},
+	{name: "strict separators", query: "count=1,000", errorExpected: true},
+	{name: "yes", query: "active=yes", lenient: true, expected: "true 0 0"},
+	{name: "on", query: "active=ON", lenient: true, expected: "true 0 0"},
+	{name: "off", query: "active=off", lenient: true, expected: "false 0 0"},
+	{name: "whitespace", query: "active=+no+&count=+3+&price=%092.5", lenient: true, expected: "false 3 2.5"},
+	{name: "separators", query: "count=1,234,567&price=-12,345.75", lenient: true, expected: "false 1234567 -12345.75"},
+	{name: "misplaced separators", query: "count=12,34", lenient: true, errorExpected: true},
+	{name: "still invalid", query: "active=maybe", lenient: true, errorExpected: true},
+}
+
+func TestParser_ReadQueryLenientCoercion(t *testing.T) {
+	for _, e := range lenientCoercionTests {
+		testParser := Parser{LenientCoercion: e.lenient}
+		var filter struct {
+			Active bool    `query:"active"`
+			Count  int     `query:"count"`
+			Price  float64 `query:"price"`
+		}
+		err := testParser.ReadQuery(httptest.NewRequest(http.MethodGet, "/?"+e.query, nil), &filter)
+
+		if e.errorExpected {
+			if err == nil {
+				t.Errorf("%s: error expected, but none received", e.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+			continue
+		}
+		if got := fmt.Sprint(filter.Active, filter.Count, filter.Price); got != e.expected {
+			t.Errorf("%s: expected %q, got %q", e.name, e.expected, got)
+		}
+	}
+}
+
+var optionalQueryTests = []struct {
+	name     string
+	query    string
+	expected string
+}{
+	{name: "absent", query: "", expected: "<nil> <nil> <nil>"},
+	{name: "zero values", query: "active=false&limit=0&owner[name]=", expected: "false 0 {}"},
+	{name: "values", query: "active=true&limit=20&owner[name]=Ann", expected: "true 20 {Ann}"},
+}
+
+func TestParser_ReadQueryOptional(t *testing.T) {
+	var testParser Parser
+
+	for _, e := range optionalQueryTests {
+		var filter struct {
+			Active *bool `query:"active"`
+			Limit  *int  `query:"limit"`
+			Owner  *struct {
+				Name string `query:"name"`
+			} `query:"owner"`
+		}
+		if err := testParser.ReadQuery(httptest.NewRequest(http.MethodGet, "/?"+e.query, nil), &filter); err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+			continue
+		}
+
+		got := []string{"<nil>", "<nil>", "<nil>"}
+		if filter.Active != nil {
+			got[0] = fmt.Sprint(*filter.Active)
+		}
+		if filter.Limit != nil {
+			got[1] = fmt.Sprint(*filter.Limit)
+		}
+		if filter.Owner != nil {
+			got[2] = fmt.Sprint(*filter.Owner)
+		}
+		if strings.Join(got, " ") != e.expected {
+			t.Errorf("%s: expected %q, got %q", e.name, e.expected, strings.Join(got, " "))
+		}
+	}
+}
+
+func TestParser_ReadFormParsed(t *testing.T) {
+	var testParser Parser
+	var got struct {
+		Name string `form:"name"`
+	}
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader("name=Ann"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := req.ParseForm(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := testParser.ReadForm(httptest.NewRecorder(), req, &got); err != nil || got.Name != "Ann" {
+		t.Errorf("expected the parsed form to be bound, got %v %+v", err, got)
+	}
+}
@@ -0,0 +1,403 @@
+package ps
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Flags of the byte that starts each length-prefixed frame.
+const (
+	frameCompressed = 0x01
+	// frameEndStream marks the final frame of a Connect stream, which holds the error and trailing metadata.
+	frameEndStream = 0x02
+	// frameTrailer marks the final frame of a gRPC-web response, which holds the trailers.
+	frameTrailer = 0x80
+)
+
+// frameHeaderSize is the length of a frame's flags byte and big-endian message length.
+const frameHeaderSize = 5
+
+// FrameProtocol is a protocol that sends JSON messages in length-prefixed frames.
+type FrameProtocol int
+
+const (
+	// ConnectProtocol is the Connect streaming protocol, as application/connect+json. A stream ends with a frame
+	// holding a JSON object with the error, if any, and the trailing metadata.
+	ConnectProtocol FrameProtocol = iota
+	// GRPCWebProtocol is gRPC-web, as application/grpc-web+json. A response ends with a frame holding the
+	// trailers, grpc-status among them, as HTTP/1 header lines.
+	GRPCWebProtocol
+)
+
+// contentType returns the media type of the protocol.
+func (f FrameProtocol) contentType() string {
+	if f == GRPCWebProtocol {
+		return "application/grpc-web+json"
+	}
+	return "application/connect+json"
+}
+
+// RPCCode is a status code shared by gRPC and Connect.
+type RPCCode int
+
+// The RPC status codes, numbered as in gRPC.
+const (
+	RPCOK RPCCode = iota
+	RPCCanceled
+	RPCUnknown
+	RPCInvalidArgument
+	RPCDeadlineExceeded
+	RPCNotFound
+	RPCAlreadyExists
+	RPCPermissionDenied
+	RPCResourceExhausted
+	RPCFailedPrecondition
+	RPCAborted
+	RPCOutOfRange
+	RPCUnimplemented
+	RPCInternal
+	RPCUnavailable
+	RPCDataLoss
+	RPCUnauthenticated
+)
+
+var rpcCodeNames = []string{
+	"ok", "canceled", "unknown", "invalid_argument", "deadline_exceeded", "not_found", "already_exists",
+	"permission_denied", "resource_exhausted", "failed_precondition", "aborted", "out_of_range", "unimplemented",
+	"internal", "unavailable", "data_loss", "unauthenticated",
+}
+
+// String returns the name Connect uses for c, such as "invalid_argument".
+func (c RPCCode) String() string {
+	if c < 0 || int(c) >= len(rpcCodeNames) {
+		return "code_" + strconv.Itoa(int(c))
+	}
+	return rpcCodeNames[c]
+}
+
+// rpcHTTPStatuses are the HTTP statuses Connect maps the RPC codes to.
+var rpcHTTPStatuses = []int{
+	http.StatusOK, 499, http.StatusInternalServerError, http.StatusBadRequest, http.StatusGatewayTimeout,
+	http.StatusNotFound, http.StatusConflict, http.StatusForbidden, http.StatusTooManyRequests, http.StatusBadRequest,
+	http.StatusConflict, http.StatusBadRequest, http.StatusNotImplemented, http.StatusInternalServerError,
+	http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusUnauthorized,
+}
+
+// httpStatus returns the HTTP status Connect maps c to, or 500 for a code it does not define.
+func (c RPCCode) httpStatus() int {
+	if c < 0 || int(c) >= len(rpcHTTPStatuses) {
+		return http.StatusInternalServerError
+	}
+	return rpcHTTPStatuses[c]
+}
+
+// RPCError is an error with the RPC status code to end a framed stream with. Errors returned by a FrameReader are
+// RPCErrors, and Fail sends any other error with RPCInvalidArgument if it has field errors and RPCUnknown if not.
+type RPCError struct {
+	Code    RPCCode
+	Message string
+	Err     error
+}
+
+// Error returns the message of the error.
+func (e *RPCError) Error() string {
+	if e.Message == "" && e.Err != nil {
+		return e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the underlying error, if any.
+func (e *RPCError) Unwrap() error {
+	return e.Err
+}
+
+// rpcError returns err as an *RPCError.
+func rpcError(err error) *RPCError {
+	var re *RPCError
+	if errors.As(err, &re) {
+		return &RPCError{Code: re.Code, Message: re.Error(), Err: re.Err}
+	}
+	if len(fieldErrors(err)) > 0 {
+		return &RPCError{Code: RPCInvalidArgument, Message: err.Error(), Err: err}
+	}
+	return &RPCError{Code: RPCUnknown, Message: err.Error(), Err: err}
+}
+
+// FrameReader reads the messages of a Connect streaming or gRPC-web request. Each message is decoded like a
+// ReadJSON body, and may be at most MaxJSONSize bytes long; it is counted in Stats and reported to the OnBind and
+// OnDecodeError callbacks as a read of its own.
+type FrameReader struct {
+	p        *Parser
+	r        *http.Request
+	protocol FrameProtocol
+	maxBytes int
+	header   [frameHeaderSize]byte
+}
+
+// NewFrameReader checks that r is a Connect streaming or gRPC-web JSON request and returns a reader for its
+// messages.
+func (p *Parser) NewFrameReader(r *http.Request) (*FrameReader, error) {
+	if p.ReplayGuard != nil {
+		if err := p.ReplayGuard.Check(r); err != nil {
+			return nil, err
+		}
+	}
+
+	var protocol FrameProtocol
+	switch mt := mediaType(r.Header.Get("Content-Type")); {
+	case strings.EqualFold(mt, ConnectProtocol.contentType()):
+		protocol = ConnectProtocol
+	case strings.EqualFold(mt, GRPCWebProtocol.contentType()):
+		protocol = GRPCWebProtocol
+	default:
+		return nil, fmt.Errorf("the Content-Type header is not %s or %s", ConnectProtocol.contentType(), GRPCWebProtocol.contentType())
+	}
+
+	return &FrameReader{p: p, r: r, protocol: protocol, maxBytes: p.maxPayload(r.Method)}, nil
+}
+
+// Protocol returns the protocol of the request.
+func (f *FrameReader) Protocol() FrameProtocol {
+	return f.protocol
+}
+
+// Read decodes the next message into data. It returns io.EOF when the body ends after a whole frame; any other
+// error is an *RPCError with RPCInvalidArgument.
+func (f *FrameReader) Read(data any) error {
+	err := f.read(data)
+	if err != io.EOF {
+		f.p.countDecode(err)
+		f.p.notifyDecode(f.r, data, err)
+	}
+	return err
+}
+
+// read does the work of Read.
+func (f *FrameReader) read(data any) error {
+	if _, err := io.ReadFull(f.r.Body, f.header[:]); err != nil {
+		if err == io.EOF {
+			return io.EOF
+		}
+		return f.error(errors.New("body ends in the middle of a frame"))
+	}
+
+	flags, size := f.header[0], binary.BigEndian.Uint32(f.header[1:])
+	switch {
+	case flags&frameCompressed != 0:
+		return f.error(errors.New("compressed messages are not supported"))
+	case flags != 0:
+		return f.error(fmt.Errorf("frame has unexpected flags %#x", flags))
+	case int64(size) > int64(f.maxBytes):
+		return f.error(fmt.Errorf("message must not be larger than %d bytes", f.maxBytes))
+	}
+
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(f.r.Body, msg); err != nil {
+		return f.error(errors.New("body ends in the middle of a frame"))
+	}
+	f.p.count(bytesRead, int64(len(msg)))
+
+	if err := f.p.checkStructure(msg); err != nil {
+		return f.error(err)
+	}
+	if err := f.p.decodeBody(f.r, bytes.NewReader(msg), data, f.maxBytes); err != nil {
+		return f.error(err)
+	}
+	return nil
+}
+
+func (f *FrameReader) error(err error) error {
+	return &RPCError{Code: RPCInvalidArgument, Message: err.Error(), Err: err}
+}
+
+// FrameWriter writes a Connect streaming or gRPC-web response, one frame per message, and ends it with a frame
+// holding the status and trailers. Each frame is flushed as soon as it is written. The OnWrite callbacks run when
+// the response is closed.
+type FrameWriter struct {
+	p        *Parser
+	w        http.ResponseWriter
+	rc       *http.ResponseController
+	protocol FrameProtocol
+	trailer  http.Header
+	start    time.Time
+	size     int64
+	err      error
+	closed   bool
+	deadline bool
+}
+
+// NewFrameWriter starts a framed response in the given protocol. Both protocols send status 200 and report
+// failures in the final frame.
+func (p *Parser) NewFrameWriter(w http.ResponseWriter, protocol FrameProtocol) *FrameWriter {
+	rc := http.NewResponseController(w)
+	s := &FrameWriter{p: p, w: w, rc: rc, protocol: protocol, trailer: http.Header{}, start: time.Now()}
+	s.err = p.fullDuplex(rc)
+	w.Header().Set("Content-Type", protocol.contentType())
+	w.WriteHeader(http.StatusOK)
+	return s
+}
+
+// Write encodes v, as WriteJSON would, and sends it as the next message. A message larger than MaxResponseSize
+// is not sent, and a *ResponseTooLargeError is returned. Once a write has failed, every later one returns the same
+// error.
+func (s *FrameWriter) Write(v any) error {
+	if s.closed {
+		return errStreamClosed
+	}
+	if s.err != nil {
+		return s.err
+	}
+
+	out, err := s.p.marshal(v)
+	if err != nil {
+		return err
+	}
+	if s.p.oversized(out) {
+		return &ResponseTooLargeError{Size: len(out), Limit: s.p.MaxResponseSize}
+	}
+	return s.frame(0, out)
+}
+
+// SetWriteDeadline sets a deadline for writing the rest of the response, replacing the Parser's WriteTimeout, which
+// otherwise gives each frame its own. It returns http.ErrNotSupported if the ResponseWriter cannot set deadlines.
+func (s *FrameWriter) SetWriteDeadline(t time.Time) error {
+	s.deadline = true
+	return s.rc.SetWriteDeadline(t)
+}
+
+// SetTrailer adds a trailer to send in the final frame: Connect metadata, or a gRPC-web trailer.
+func (s *FrameWriter) SetTrailer(name, value string) {
+	s.trailer.Add(name, value)
+}
+
+// Close ends the response successfully.
+func (s *FrameWriter) Close() error {
+	return s.finish(nil)
+}
+
+// Fail ends the response with err, sent with the code of an *RPCError or as described there.
+func (s *FrameWriter) Fail(err error) error {
+	return s.finish(rpcError(err))
+}
+
+// finish sends the final frame and reports the response.
+func (s *FrameWriter) finish(re *RPCError) error {
+	if s.closed {
+		return errStreamClosed
+	}
+	s.closed = true
+
+	err := s.p.countWrite(s.end(re))
+	s.p.notifyWrite(s.w, WriteInfo{Status: http.StatusOK, Size: s.size, Duration: time.Since(s.start), Err: err})
+	return err
+}
+
+// end sends the final frame, unless an earlier write failed.
+func (s *FrameWriter) end(re *RPCError) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	if s.protocol == GRPCWebProtocol {
+		return s.frame(frameTrailer, s.grpcTrailer(re))
+	}
+
+	end := struct {
+		Error    *connectError `json:"error,omitempty"`
+		Metadata http.Header   `json:"metadata,omitempty"`
+	}{Metadata: s.trailer}
+	if re != nil {
+		end.Error = &connectError{Code: re.Code.String(), Message: s.message(re)}
+	}
+	out, err := json.Marshal(end)
+	if err != nil {
+		return err
+	}
+	return s.frame(frameEndStream, out)
+}
+
+// message returns the message to send for re, following the Parser's Disclosure policy.
+func (s *FrameWriter) message(re *RPCError) string {
+	return s.p.discloseMessage(re, re.Code.httpStatus())
+}
+
+// connectError is the error object of a Connect end-of-stream message.
+type connectError struct {
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// grpcTrailer formats the gRPC-web trailer frame: the status, the message if any, and the other trailers.
+func (s *FrameWriter) grpcTrailer(re *RPCError) []byte {
+	h := s.trailer.Clone()
+	h.Set("Grpc-Status", "0")
+	if re != nil {
+		h.Set("Grpc-Status", strconv.Itoa(int(re.Code)))
+		if message := s.message(re); message != "" {
+			h.Set("Grpc-Message", percentEncode(message))
+		}
+	}
+
+	// gRPC-web clients expect lower-case names.
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		for _, v := range h[name] {
+			buf.WriteString(strings.ToLower(name) + ": " + v + "\r\n")
+		}
+	}
+	return buf.Bytes()
+}
+
+// frame sends msg in a frame with the given flags and flushes it.
+func (s *FrameWriter) frame(flags byte, msg []byte) error {
+	buf := make([]byte, frameHeaderSize, frameHeaderSize+len(msg))
+	buf[0] = flags
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(msg)))
+	buf = append(buf, msg...)
+
+	if !s.deadline {
+		if err := s.p.writeDeadline(s.rc); err != nil {
+			s.err = err
+			return err
+		}
+	}
+	n, err := s.w.Write(buf)
+	s.size += int64(n)
+	s.p.count(bytesWritten, int64(n))
+	if err == nil {
+		if err = s.rc.Flush(); errors.Is(err, http.ErrNotSupported) {
+			err = nil
+		}
+	}
+	s.err = err
+	return err
+}
+
+// percentEncode encodes the bytes of s outside printable ASCII, and '%', as gRPC requires of grpc-message.
+func percentEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,143 @@
+package ps
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// frames joins messages into a framed body, each with the given flags.
+func frames(flags byte, messages ...string) []byte {
+	var buf bytes.Buffer
+	for _, m := range messages {
+		var header [frameHeaderSize]byte
+		header[0] = flags
+		binary.BigEndian.PutUint32(header[1:], uint32(len(m)))
+		buf.Write(header[:])
+		buf.WriteString(m)
+	}
+	return buf.Bytes()
+}
+
+// readFrames splits a framed body into its flags and messages.
+func readFrames(t *testing.T, body []byte) (flags []byte, messages []string) {
+	for len(body) > 0 {
+		if len(body) < frameHeaderSize {
+			t.Fatalf("truncated frame: %q", body)
+		}
+		size := int(binary.BigEndian.Uint32(body[1:frameHeaderSize]))
+		flags = append(flags, body[0])
+		messages = append(messages, string(body[frameHeaderSize:frameHeaderSize+size]))
+		body = body[frameHeaderSize+size:]
+	}
+	return flags, messages
+}
+
+var frameReaderTests = []struct {
+	name          string
+	body          []byte
+	expectedNames []string
+	expectedError string
+}{
+	{name: "messages", body: frames(0, `{"name":"a"}`, `{"name":"b"}`), expectedNames: []string{"a", "b"}},
+	{name: "empty", body: nil},
+	{name: "unknown key", body: frames(0, `{"name":"a"}`, `{"other":1}`), expectedNames: []string{"a"}, expectedError: `unknown key "other"`},
+	{name: "compressed", body: frames(frameCompressed, `{}`), expectedError: "compressed messages are not supported"},
+	{name: "too large", body: frames(0, `{"name":"`+strings.Repeat("x", 40)+`"}`), expectedError: "message must not be larger than 32 bytes"},
+	{name: "truncated", body: frames(0, `{"name":"a"}`)[:8], expectedError: "body ends in the middle of a frame"},
+}
+
+func TestParser_NewFrameReader(t *testing.T) {
+	testParser := Parser{MaxJSONSize: 32}
+
+	for _, e := range frameReaderTests {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(e.body))
+		req.Header.Set("Content-Type", "application/grpc-web+json")
+		fr, err := testParser.NewFrameReader(req)
+		if err != nil {
+			t.Fatalf("%s: %v", e.name, err)
+		}
+
+		var names []string
+		for {
+			var msg struct {
+				Name string `json:"name"`
+			}
+			if err = fr.Read(&msg); err != nil {
+				break
+			}
+			names = append(names, msg.Name)
+		}
+
+		if strings.Join(names, ",") != strings.Join(e.expectedNames, ",") {
+			t.Errorf("%s: expected %v, got %v", e.name, e.expectedNames, names)
+		}
+		var re *RPCError
+		switch {
+		case e.expectedError == "" && err != io.EOF:
+			t.Errorf("%s: expected io.EOF, got %v", e.name, err)
+		case e.expectedError != "" && (!errors.As(err, &re) || re.Code != RPCInvalidArgument || !strings.Contains(err.Error(), e.expectedError)):
+			t.Errorf("%s: expected an invalid_argument error containing %q, got %v", e.name, e.expectedError, err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Content-Type", "application/json")
+	if _, err := testParser.NewFrameReader(req); err == nil {
+		t.Error("expected an error for a plain JSON request")
+	}
+}
+
+func TestParser_NewFrameWriterConnect(t *testing.T) {
+	var testParser Parser
+	rr := httptest.NewRecorder()
+	fw := testParser.NewFrameWriter(rr, ConnectProtocol)
+	_ = fw.Write(map[string]int{"n": 1})
+	_ = fw.Write(map[string]int{"n": 2})
+	fw.SetTrailer("Request-Id", "r1")
+	if err := fw.Fail(&FieldError{Field: "n", Message: "is too large"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Header().Get("Content-Type") != "application/connect+json" {
+		t.Errorf("unexpected Content-Type %s", rr.Header().Get("Content-Type"))
+	}
+	flags, messages := readFrames(t, rr.Body.Bytes())
+	if string(flags) != "\x00\x00\x02" || messages[0] != `{"n":1}` || messages[1] != `{"n":2}` {
+		t.Errorf("unexpected frames %q %q", flags, messages)
+	}
+	if expected := `{"error":{"code":"invalid_argument","message":"n is too large"},"metadata":{"Request-Id":["r1"]}}`; messages[2] != expected {
+		t.Errorf("expected end of stream %s, got %s", expected, messages[2])
+	}
+
+	if err := fw.Write(1); err != errStreamClosed {
+		t.Errorf("expected a closed stream, got %v", err)
+	}
+}
+
+func TestParser_NewFrameWriterGRPCWeb(t *testing.T) {
+	var testParser Parser
+	rr := httptest.NewRecorder()
+	fw := testParser.NewFrameWriter(rr, GRPCWebProtocol)
+	_ = fw.Write([]int{1})
+	_ = fw.Fail(&RPCError{Code: RPCNotFound, Message: "no order 7 (100%)"})
+
+	flags, messages := readFrames(t, rr.Body.Bytes())
+	if string(flags) != "\x00\x80" || messages[0] != `[1]` {
+		t.Fatalf("unexpected frames %q %q", flags, messages)
+	}
+	if expected := "grpc-message: no order 7 (100%25)\r\ngrpc-status: 5\r\n"; messages[1] != expected {
+		t.Errorf("expected trailers %q, got %q", expected, messages[1])
+	}
+
+	rr = httptest.NewRecorder()
+	_ = testParser.NewFrameWriter(rr, GRPCWebProtocol).Close()
+	if _, messages := readFrames(t, rr.Body.Bytes()); messages[0] != "grpc-status: 0\r\n" {
+		t.Errorf("expected status 0, got %q", messages[0])
+	}
+}
@@ -0,0 +1,28 @@
+package ps
+
+// Freeze marks the configuration of p as final and returns p. Registration methods such as RegisterVersion panic
+// on a frozen Parser, so code that tries to change a Parser after it has started serving requests fails loudly
+// instead of racing with the goroutines using it. Freeze also takes private copies of the maps and slices p was
+// configured with, so that changes to the caller's copies do not leak in.
+//
+// Exported fields cannot be guarded and must not be assigned after Freeze. To derive a different configuration,
+// use Clone or With, which return unfrozen copies.
+func (p *Parser) Freeze() *Parser {
+	if !p.frozen {
+		p.copyShared()
+		p.frozen = true
+	}
+	return p
+}
+
+// Frozen reports whether Freeze has been called on p.
+func (p *Parser) Frozen() bool {
+	return p.frozen
+}
+
+// mustNotBeFrozen panics if p is frozen. method names the registration method being called.
+func (p *Parser) mustNotBeFrozen(method string) {
+	if p.frozen {
+		panic("ps: " + method + " called on a frozen Parser")
+	}
+}
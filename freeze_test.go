@@ -0,0 +1,91 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParser_Freeze(t *testing.T) {
+	languages := []string{"en", "fr"}
+	p := New(WithLanguages(languages...))
+	p.Freeze()
+	languages[0] = "de"
+
+	if !p.Frozen() || p.Languages[0] != "en" {
+		t.Errorf("expected a frozen parser with its own languages, got %v", p.Languages)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected RegisterVersion to panic on a frozen parser")
+		}
+	}()
+
+	derived := p.With(WithMaxJSONSize(10))
+	if derived.Frozen() {
+		t.Error("expected With to return an unfrozen parser")
+	}
+	derived.RegisterVersion("1", JSONResponse{}, func(data any) (any, error) { return data, nil })
+
+	p.RegisterVersion("1", JSONResponse{}, func(data any) (any, error) { return data, nil })
+}
+
+// TestParser_Concurrent exercises a shared, frozen Parser from many goroutines. Run it with -race.
+func TestParser_Concurrent(t *testing.T) {
+	type payload struct {
+		Name    string        `json:"name"`
+		Created time.Time     `json:"created" format:"unix"`
+		Timeout time.Duration `json:"timeout" format:"string"`
+		ID      UUID          `json:"id"`
+		Extra   any           `json:"extra"`
+	}
+
+	p := New(
+		WithLanguages("en", "fr"),
+		WithInt64AsString(true),
+		WithFloatFormat(FloatFormat{Decimals: 2}),
+	)
+	p.RegisterVersion("1", JSONResponse{}, func(data any) (any, error) {
+		resp := data.(JSONResponse)
+		resp.Message = "v1: " + resp.Message
+		return resp, nil
+	})
+	p.Freeze()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			body := `{"name":"n","created":1709296200,"timeout":"1s","id":"6ba7b810-9dad-11d1-80b4-00c04fd430c8","extra":1.5}`
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			req.Header.Set("Accept-Language", "fr")
+			req.Header.Set("API-Version", "1")
+
+			rr := httptest.NewRecorder()
+			var decoded payload
+			if err := p.ReadJSON(rr, req, &decoded); err != nil {
+				t.Errorf("goroutine %d: %v", i, err)
+				return
+			}
+
+			p.NegotiateLanguage(rr, req)
+			p.NegotiateVersion(rr, req)
+			if err := p.WriteJSON(rr, http.StatusOK, JSONResponse{Message: "ok", Data: decoded}); err != nil {
+				t.Errorf("goroutine %d: %v", i, err)
+				return
+			}
+			if !strings.Contains(rr.Body.String(), `"v1: ok"`) || rr.Header().Get("Content-Language") != "fr" {
+				t.Errorf("goroutine %d: unexpected response %v %s", i, rr.Header(), rr.Body.String())
+			}
+
+			_ = p.With(WithMaxJSONSize(i + 1))
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,200 @@
+package ps
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// errHALState is returned when the state of a HAL resource does not encode as a JSON object.
+var errHALState = errors.New("the state of a HAL resource must encode as a JSON object")
+
+// HALLink is a link object of a HAL resource.
+type HALLink struct {
+	Href      string `json:"href"`
+	Templated bool   `json:"templated,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Title     string `json:"title,omitempty"`
+}
+
+// HALResource is a resource to write as application/hal+json: its state, the fields of a struct or map, with
+// _links and _embedded added. Build it with NewHAL and its methods, which can be chained:
+//
+//	res := ps.NewHAL(r, order).
+//		Link("customer", "/customers/"+order.CustomerID).
+//		Embed("items", items...)
+//	p.WriteHAL(w, http.StatusOK, res)
+//
+// Relative hrefs are resolved against the URL of the request, as a browser would resolve them.
+type HALResource struct {
+	base     *url.URL
+	state    any
+	rels     []string
+	links    map[string][]HALLink
+	embeds   []string
+	embedded map[string][]*HALResource
+	many     map[string]bool
+}
+
+// NewHAL returns a resource with the given state, linked to itself by the URL of r.
+func NewHAL(r *http.Request, state any) *HALResource {
+	base := &url.URL{Path: r.URL.Path, RawPath: r.URL.RawPath, RawQuery: r.URL.RawQuery}
+	h := newHAL(base, state)
+	return h.AddLink("self", HALLink{Href: base.String()})
+}
+
+func newHAL(base *url.URL, state any) *HALResource {
+	return &HALResource{base: base, state: state, links: map[string][]HALLink{}, embedded: map[string][]*HALResource{}, many: map[string]bool{}}
+}
+
+// Resource returns a resource with the given state whose self link is href, for embedding in h. Its own relative
+// hrefs are resolved against href.
+func (h *HALResource) Resource(href string, state any) *HALResource {
+	base := h.resolve(href)
+	sub := newHAL(base, state)
+	return sub.AddLink("self", HALLink{Href: base.String()})
+}
+
+// Link adds a link to href under rel. A relation given more than one link is written as an array.
+func (h *HALResource) Link(rel, href string) *HALResource {
+	return h.AddLink(rel, HALLink{Href: href})
+}
+
+// LinkTemplate adds a templated link under rel. href is a URI template, such as "/orders{?status}", and is written
+// as it is.
+func (h *HALResource) LinkTemplate(rel, href string) *HALResource {
+	return h.AddLink(rel, HALLink{Href: href, Templated: true})
+}
+
+// AddLink adds link under rel, resolving its href unless it is templated.
+func (h *HALResource) AddLink(rel string, link HALLink) *HALResource {
+	if !link.Templated {
+		link.Href = h.resolve(link.Href).String()
+	}
+	if _, ok := h.links[rel]; !ok {
+		h.rels = append(h.rels, rel)
+	}
+	h.links[rel] = append(h.links[rel], link)
+	return h
+}
+
+// Embed adds resources under rel in _embedded. One resource is written as an object, and any other number as an
+// array; EmbedList always writes an array.
+func (h *HALResource) Embed(rel string, resources ...*HALResource) *HALResource {
+	if _, ok := h.embedded[rel]; !ok {
+		h.embeds = append(h.embeds, rel)
+	}
+	h.embedded[rel] = append(h.embedded[rel], resources...)
+	return h
+}
+
+// EmbedList adds resources under rel in _embedded, written as an array even if it holds one resource or none.
+func (h *HALResource) EmbedList(rel string, resources ...*HALResource) *HALResource {
+	h.many[rel] = true
+	return h.Embed(rel, resources...)
+}
+
+// resolve resolves href against the resource's URL. An href that is not a valid URL is kept as it is.
+func (h *HALResource) resolve(href string) *url.URL {
+	ref, err := url.Parse(href)
+	if err != nil {
+		return &url.URL{Path: href}
+	}
+	return h.base.ResolveReference(ref)
+}
+
+// encode writes the resource, with its state encoded using p's settings.
+func (h *HALResource) encode(p *Parser, buf *bytes.Buffer) error {
+	var state []byte
+	if h.state != nil {
+		out, err := p.marshal(h.state)
+		if err != nil {
+			return err
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, out); err != nil {
+			return err
+		}
+		state = compact.Bytes()
+		if len(state) < 2 || state[0] != '{' {
+			return errHALState
+		}
+		// Keep the members, without the braces.
+		state = state[1 : len(state)-1]
+	}
+
+	buf.WriteString(`{"_links":{`)
+	for i, rel := range h.rels {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeObjectKey(buf, rel)
+		links := h.links[rel]
+		var v any = links
+		if len(links) == 1 {
+			v = links[0]
+		}
+		out, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(out)
+	}
+	buf.WriteByte('}')
+
+	if len(h.embeds) > 0 {
+		buf.WriteString(`,"_embedded":{`)
+		for i, rel := range h.embeds {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeObjectKey(buf, rel)
+			resources := h.embedded[rel]
+			array := h.many[rel] || len(resources) != 1
+			if array {
+				buf.WriteByte('[')
+			}
+			for j, res := range resources {
+				if j > 0 {
+					buf.WriteByte(',')
+				}
+				if err := res.encode(p, buf); err != nil {
+					return err
+				}
+			}
+			if array {
+				buf.WriteByte(']')
+			}
+		}
+		buf.WriteByte('}')
+	}
+
+	if len(state) > 0 {
+		buf.WriteByte(',')
+		buf.Write(state)
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// writeObjectKey writes name as an object key, followed by its colon.
+func writeObjectKey(buf *bytes.Buffer, name string) {
+	key, _ := json.Marshal(name)
+	buf.Write(key)
+	buf.WriteByte(':')
+}
+
+// WriteHAL writes res as an application/hal+json response. Its state, and the state of the resources it embeds,
+// are encoded as WriteJSON would encode them, and the whole document goes through the same version transforms,
+// hooks and size limit.
+func (p *Parser) WriteHAL(w http.ResponseWriter, status int, res *HALResource, headers ...http.Header) error {
+	var buf bytes.Buffer
+	if err := res.encode(p, &buf); err != nil {
+		p.count(encodeFailures, 1)
+		return err
+	}
+	return p.writeAs(w, "application/hal+json", status, json.RawMessage(buf.Bytes()), headers)
+}
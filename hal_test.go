@@ -0,0 +1,60 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type halOrder struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+}
+
+func TestParser_WriteHAL(t *testing.T) {
+	var testParser Parser
+	req := httptest.NewRequest(http.MethodGet, "/customers/3/orders?page=2", nil)
+
+	res := NewHAL(req, map[string]int{"total": 2}).
+		Link("next", "orders?page=3").
+		Link("customer", "/customers/3").
+		Link("customer", "https://crm.example.com/c/3").
+		LinkTemplate("find", "/orders/{id}")
+	res.EmbedList("orders",
+		res.Resource("/orders/7", halOrder{ID: 7, Status: "paid"}).Link("items", "7/items"),
+	)
+	res.Embed("owner", res.Resource("/owners/1", nil))
+
+	rr := httptest.NewRecorder()
+	if err := testParser.WriteHAL(rr, http.StatusOK, res); err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Header().Get("Content-Type") != "application/hal+json" {
+		t.Errorf("expected application/hal+json, got %s", rr.Header().Get("Content-Type"))
+	}
+	expected := `{"_links":{"self":{"href":"/customers/3/orders?page=2"},"next":{"href":"/customers/3/orders?page=3"},` +
+		`"customer":[{"href":"/customers/3"},{"href":"https://crm.example.com/c/3"}],"find":{"href":"/orders/{id}","templated":true}},` +
+		`"_embedded":{"orders":[{"_links":{"self":{"href":"/orders/7"},"items":{"href":"/orders/7/items"}},"id":7,"status":"paid"}],` +
+		`"owner":{"_links":{"self":{"href":"/owners/1"}}}},"total":2}`
+	if rr.Body.String() != expected {
+		t.Errorf("expected %s, got %s", expected, rr.Body.String())
+	}
+}
+
+func TestParser_WriteHALState(t *testing.T) {
+	testParser := Parser{Pretty: true}
+	req := httptest.NewRequest(http.MethodGet, "/orders/7", nil)
+
+	rr := httptest.NewRecorder()
+	if err := testParser.WriteHAL(rr, http.StatusOK, NewHAL(req, []int{1})); err != errHALState {
+		t.Errorf("expected errHALState, got %v", err)
+	}
+
+	rr = httptest.NewRecorder()
+	_ = testParser.WriteHAL(rr, http.StatusOK, NewHAL(req, halOrder{ID: 7}))
+	expected := "{\n  \"_links\": {\n    \"self\": {\n      \"href\": \"/orders/7\"\n    }\n  },\n  \"id\": 7,\n  \"status\": \"\"\n}"
+	if rr.Body.String() != expected {
+		t.Errorf("expected %s, got %s", expected, rr.Body.String())
+	}
+}
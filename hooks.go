@@ -0,0 +1,166 @@
+package ps
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// BeforeDecodeHook rewrites the raw body of a request before ReadJSON decodes it. body belongs to a pooled buffer
+// and must not be kept after the hook returns.
+type BeforeDecodeHook func(r *http.Request, body []byte) ([]byte, error)
+
+// AfterDecodeHook runs after ReadJSON has decoded a request body, to normalize, check or enrich the result. data
+// is the pointer that was passed to ReadJSON.
+type AfterDecodeHook func(r *http.Request, data any) error
+
+// BeforeEncodeHook changes or replaces the value WriteJSON is about to encode, for cross-cutting additions such as
+// request IDs or timestamps. w gives access to the response headers set so far.
+type BeforeEncodeHook func(w http.ResponseWriter, status int, data any) (any, error)
+
+// AfterEncodeHook rewrites the encoded body of a response before WriteJSON sends it.
+type AfterEncodeHook func(w http.ResponseWriter, status int, body []byte) ([]byte, error)
+
+// BeforeDecode registers hooks to run, in order, on the raw body of every request ReadJSON decodes. Registering
+// any makes ReadJSON read the whole body into memory first; MaxJSONSize still applies.
+func (p *Parser) BeforeDecode(hooks ...BeforeDecodeHook) {
+	p.mustNotBeFrozen("BeforeDecode")
+	p.beforeDecode = append(p.beforeDecode, hooks...)
+}
+
+// AfterDecode registers hooks to run, in order, on every value ReadJSON decodes successfully. The first error a
+// hook returns is returned by ReadJSON.
+func (p *Parser) AfterDecode(hooks ...AfterDecodeHook) {
+	p.mustNotBeFrozen("AfterDecode")
+	p.afterDecode = append(p.afterDecode, hooks...)
+}
+
+// BeforeEncode registers hooks to run, in order, on every value WriteJSON encodes, after any API version
+// transform. The first error a hook returns is returned by WriteJSON, and nothing is written.
+func (p *Parser) BeforeEncode(hooks ...BeforeEncodeHook) {
+	p.mustNotBeFrozen("BeforeEncode")
+	p.beforeEncode = append(p.beforeEncode, hooks...)
+}
+
+// AfterEncode registers hooks to run, in order, on every body WriteJSON encodes. The first error a hook returns is
+// returned by WriteJSON, and nothing is written.
+func (p *Parser) AfterEncode(hooks ...AfterEncodeHook) {
+	p.mustNotBeFrozen("AfterEncode")
+	p.afterEncode = append(p.afterEncode, hooks...)
+}
+
+// runBeforeDecode passes body through the BeforeDecode hooks.
+func (p *Parser) runBeforeDecode(r *http.Request, body []byte) ([]byte, error) {
+	var err error
+	for _, hook := range p.beforeDecode {
+		if body, err = hook(r, body); err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+// runAfterDecode passes data through the AfterDecode hooks, then the validation methods it has.
+func (p *Parser) runAfterDecode(r *http.Request, data any) error {
+	for _, hook := range p.afterDecode {
+		if err := hook(r, data); err != nil {
+			return err
+		}
+	}
+	return validate(r.Context(), data)
+}
+
+// runBeforeEncode passes data through the BeforeEncode hooks.
+func (p *Parser) runBeforeEncode(w http.ResponseWriter, status int, data any) (any, error) {
+	var err error
+	for _, hook := range p.beforeEncode {
+		if data, err = hook(w, status, data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// runAfterEncode passes body through the AfterEncode hooks.
+func (p *Parser) runAfterEncode(w http.ResponseWriter, status int, body []byte) ([]byte, error) {
+	var err error
+	for _, hook := range p.afterEncode {
+		if body, err = hook(w, status, body); err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+// NormalizeStrings is an AfterDecodeHook that cleans up string fields according to their normalize struct tag,
+// a comma-separated list of:
+//
+//	trim   remove leading and trailing white space
+//	lower  convert to lower case
+//	upper  convert to upper case
+//
+// The tag also applies to strings inside slices, arrays, maps and pointers held by the field. For example:
+//
+//	Email string `json:"email" normalize:"trim,lower"`
+func NormalizeStrings(_ *http.Request, data any) error {
+	normalize(reflect.ValueOf(data), "", 0)
+	return nil
+}
+
+// normalize applies the normalize tag ops to the strings in v, and the tags of struct fields to their values.
+func normalize(v reflect.Value, ops string, depth int) {
+	if depth > maxEncodeDepth {
+		return
+	}
+	depth++
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			normalize(v.Elem(), ops, depth)
+		}
+
+	case reflect.String:
+		if ops != "" && v.CanSet() {
+			v.SetString(normalizeString(v.String(), ops))
+		}
+
+	case reflect.Struct:
+		info := structFields(v.Type())
+		for i := range info.fields {
+			if fv, ok := fieldByIndex(v, info.fields[i].index); ok {
+				normalize(fv, info.fields[i].normalize, depth)
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			normalize(v.Index(i), ops, depth)
+		}
+
+	case reflect.Map:
+		// Map values cannot be changed in place, so each one is copied, normalized and stored back.
+		iter := v.MapRange()
+		for iter.Next() {
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(iter.Value())
+			normalize(value, ops, depth)
+			v.SetMapIndex(iter.Key(), value)
+		}
+	}
+}
+
+// normalizeString applies the comma-separated normalize ops to s.
+func normalizeString(s, ops string) string {
+	for _, op := range strings.Split(ops, ",") {
+		switch strings.TrimSpace(op) {
+		case "trim":
+			s = strings.TrimSpace(s)
+		case "lower":
+			s = strings.ToLower(s)
+		case "upper":
+			s = strings.ToUpper(s)
+		}
+	}
+	return s
+}
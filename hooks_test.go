@@ -0,0 +1,92 @@
+package ps
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type normalizeSample struct {
+	Email   string            `json:"email" normalize:"trim,lower"`
+	Code    *string           `json:"code" normalize:"upper"`
+	Tags    []string          `json:"tags" normalize:"trim"`
+	Labels  map[string]string `json:"labels" normalize:"lower"`
+	Raw     string            `json:"raw"`
+	Contact *normalizeSample  `json:"contact"`
+}
+
+func TestParser_DecodeHooks(t *testing.T) {
+	var testParser Parser
+	testParser.BeforeDecode(func(r *http.Request, body []byte) ([]byte, error) {
+		// Strip a byte order mark some clients send.
+		return bytes.TrimPrefix(body, []byte("\xef\xbb\xbf")), nil
+	})
+	testParser.AfterDecode(NormalizeStrings, func(r *http.Request, data any) error {
+		if data.(*normalizeSample).Email == "" {
+			return errors.New("email is required")
+		}
+		return nil
+	})
+
+	body := "\xef\xbb\xbf" + `{"email":"  Jack@Example.COM ","code":"ab","tags":[" a "],"labels":{"k":"V"},"raw":" x ",` +
+		`"contact":{"email":" B@Example.com"}}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	var decoded normalizeSample
+	if err := testParser.ReadJSON(httptest.NewRecorder(), req, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Email != "jack@example.com" || *decoded.Code != "AB" || decoded.Tags[0] != "a" ||
+		decoded.Labels["k"] != "v" || decoded.Raw != " x " || decoded.Contact.Email != "b@example.com" {
+		t.Errorf("unexpected normalized value: %+v", decoded)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"raw":"x"}`))
+	if err := testParser.ReadJSON(httptest.NewRecorder(), req, &normalizeSample{}); err == nil || err.Error() != "email is required" {
+		t.Errorf("expected the after hook's error, got %v", err)
+	}
+
+	testParser.MaxJSONSize = 8
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	if err := testParser.ReadJSON(httptest.NewRecorder(), req, &normalizeSample{}); err == nil || !strings.Contains(err.Error(), "larger than 8 bytes") {
+		t.Errorf("expected the size limit to apply, got %v", err)
+	}
+}
+
+func TestParser_EncodeHooks(t *testing.T) {
+	var testParser Parser
+	testParser.BeforeEncode(func(w http.ResponseWriter, status int, data any) (any, error) {
+		// Stamp the request ID set by earlier middleware onto every response.
+		resp, ok := data.(JSONResponse)
+		if !ok {
+			return data, nil
+		}
+		resp.Data = map[string]any{"request_id": w.Header().Get("X-Request-ID"), "payload": resp.Data}
+		return resp, nil
+	})
+	testParser.AfterEncode(func(w http.ResponseWriter, status int, body []byte) ([]byte, error) {
+		if status >= 500 {
+			return nil, errors.New("refusing to send server errors")
+		}
+		return append([]byte(")]}',\n"), body...), nil
+	})
+
+	rr := httptest.NewRecorder()
+	rr.Header().Set("X-Request-ID", "abc")
+	if err := testParser.WriteJSON(rr, http.StatusOK, JSONResponse{Message: "ok", Data: 1}); err != nil {
+		t.Fatal(err)
+	}
+	expected := ")]}',\n" + `{"error":false,"message":"ok","data":{"payload":1,"request_id":"abc"}}`
+	if rr.Body.String() != expected {
+		t.Errorf("expected %s, got %s", expected, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	if err := testParser.ErrorJSON(rr, errors.New("boom"), http.StatusInternalServerError); err == nil || rr.Body.Len() != 0 {
+		t.Errorf("expected the after hook to stop the write, got %v and %q", err, rr.Body.String())
+	}
+}
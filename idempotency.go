@@ -0,0 +1,160 @@
+package ps
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// IdempotencyStore persists responses by idempotency key. Implementations must be safe for concurrent use.
+type IdempotencyStore interface {
+	// Get returns the response recorded for key, if there is one.
+	Get(ctx context.Context, key string) (StoredResponse, bool, error)
+	// Set records the response for key.
+	Set(ctx context.Context, key string, resp StoredResponse) error
+}
+
+// Idempotent returns middleware that makes requests carrying an Idempotency-Key header safe to retry. The first
+// successful (2xx) response for a key is recorded in store; later requests with the same key and the same body
+// receive the recorded response, with an Idempotent-Replayed header, without reaching the handler.
+//
+// Reusing a key for a different request is answered with 422, and a request arriving while another with the same
+// key is still being handled by this process is answered with 409.
+//
+// Keys are scoped to the request method and path, and to the value scope returns for the request, such as the
+// authenticated subject, so that two clients that happen to pick the same key do not see each other's responses.
+// Any function that keys a RateLimiter, such as RateLimitByTenant, will do. Without a scope, keys are shared by
+// every client.
+func (p *Parser) Idempotent(store IdempotencyStore, scope ...func(r *http.Request) string) func(http.Handler) http.Handler {
+	var inFlight sync.Map
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("Idempotency-Key")
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			prefix := r.Method + " " + r.URL.Path + " "
+			for _, fn := range scope {
+				prefix += strconv.Quote(fn(r)) + " "
+			}
+			key = prefix + key
+
+			fingerprint, err := p.fingerprint(w, r)
+			if err != nil {
+				_ = p.ErrorJSON(w, err)
+				return
+			}
+
+			if _, busy := inFlight.LoadOrStore(key, struct{}{}); busy {
+				_ = p.ErrorJSON(w, errors.New("a request with this Idempotency-Key is already in progress"), http.StatusConflict)
+				return
+			}
+			defer inFlight.Delete(key)
+
+			stored, ok, err := store.Get(r.Context(), key)
+			if err != nil {
+				_ = p.ErrorJSON(w, err, http.StatusInternalServerError)
+				return
+			}
+
+			if ok {
+				if stored.Fingerprint != fingerprint {
+					_ = p.ErrorJSON(w, errors.New("the Idempotency-Key has already been used for a different request"), http.StatusUnprocessableEntity)
+					return
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				_ = stored.replay(w)
+				return
+			}
+
+			cw := &captureWriter{ResponseWriter: w}
+			next.ServeHTTP(cw, r)
+
+			resp := cw.response()
+			if resp.Status >= 200 && resp.Status < 300 {
+				resp.Fingerprint = fingerprint
+				_ = store.Set(r.Context(), key, resp)
+			}
+		})
+	}
+}
+
+// fingerprint hashes the request body, leaving it in place for the handler. The body is read subject to the
+// Parser's size limit; a larger one is reported as a *RequestTooLargeError.
+func (p *Parser) fingerprint(w http.ResponseWriter, r *http.Request) (string, error) {
+	maxBytes := p.maxPayload(r.Method)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(maxBytes)))
+	if err != nil {
+		return "", decodeError(err, maxBytes)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore that keeps responses in memory for a fixed time. It suits a single
+// instance; deployments with several instances need a shared store.
+type MemoryIdempotencyStore struct {
+	ttl       time.Duration
+	mu        sync.Mutex
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+}
+
+// memoryEntry is a stored response with its expiry time.
+type memoryEntry struct {
+	resp    StoredResponse
+	expires time.Time
+}
+
+// NewMemoryIdempotencyStore returns a MemoryIdempotencyStore that forgets responses after ttl.
+func NewMemoryIdempotencyStore(ttl time.Duration) *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{ttl: ttl, entries: make(map[string]memoryEntry)}
+}
+
+// Get returns the response recorded for key, if it has not expired.
+func (s *MemoryIdempotencyStore) Get(_ context.Context, key string) (StoredResponse, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return StoredResponse{}, false, nil
+	}
+	if time.Now().After(entry.expires) {
+		delete(s.entries, key)
+		return StoredResponse{}, false, nil
+	}
+
+	return entry.resp, true, nil
+}
+
+// Set records the response for key. Expired entries are dropped once per ttl, so that each Set does not have to
+// look at every entry.
+func (s *MemoryIdempotencyStore) Set(_ context.Context, key string, resp StoredResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) >= s.ttl {
+		for k, entry := range s.entries {
+			if now.After(entry.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	s.entries[key] = memoryEntry{resp: resp, expires: now.Add(s.ttl)}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package ps
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParser_Idempotent(t *testing.T) {
+	var testParser Parser
+
+	calls := 0
+	handler := testParser.Idempotent(NewMemoryIdempotencyStore(time.Minute))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var payload struct {
+			Amount int `json:"amount"`
+		}
+		if err := testParser.ReadJSON(w, r, &payload); err != nil {
+			_ = testParser.ErrorJSON(w, err)
+			return
+		}
+		_ = testParser.WriteJSON(w, http.StatusCreated, JSONResponse{Message: "charged", Data: calls})
+	}))
+
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/charges", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := send("abc", `{"amount": 100}`)
+	if first.Code != http.StatusCreated || calls != 1 {
+		t.Fatalf("expected first request to be handled, got status %d", first.Code)
+	}
+
+	replayed := send("abc", `{"amount": 100}`)
+	if calls != 1 {
+		t.Error("expected duplicate request not to reach the handler")
+	}
+	if replayed.Code != http.StatusCreated || replayed.Body.String() != first.Body.String() {
+		t.Errorf("expected replayed response %d %s, got %d %s", first.Code, first.Body, replayed.Code, replayed.Body)
+	}
+	if replayed.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("expected Idempotent-Replayed header on replayed response")
+	}
+
+	if rr := send("abc", `{"amount": 200}`); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for reused key, got %d", rr.Code)
+	}
+
+	send("", `{"amount": 100}`)
+	send("", `{"amount": 100}`)
+	if calls != 3 {
+		t.Errorf("expected requests without a key to always be handled, got %d calls", calls)
+	}
+
+	if rr := send("bad", `{"amount": "x"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+	send("bad", `{"amount": "x"}`)
+	if calls != 5 {
+		t.Errorf("expected failed responses not to be recorded, got %d calls", calls)
+	}
+}
+
+func TestMemoryIdempotencyStore_Expiry(t *testing.T) {
+	store := NewMemoryIdempotencyStore(-time.Second)
+
+	_ = store.Set(context.Background(), "key", StoredResponse{Status: http.StatusOK})
+	if _, ok, _ := store.Get(context.Background(), "key"); ok {
+		t.Error("expected expired entry to be dropped")
+	}
+}
+
+func TestParser_IdempotentScope(t *testing.T) {
+	testParser := New(WithMaxJSONSize(16))
+
+	calls := 0
+	handler := testParser.Idempotent(NewMemoryIdempotencyStore(time.Minute), RateLimitByHeader("X-User"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_ = testParser.WriteJSON(w, http.StatusCreated, r.Header.Get("X-User"))
+	}))
+
+	send := func(user, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/charges", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", "abc")
+		req.Header.Set("X-User", user)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	send("ann", `{}`)
+	if rr := send("bob", `{}`); calls != 2 || rr.Body.String() != `"bob"` {
+		t.Errorf("expected another client's key not to be replayed, got %d calls and %s", calls, rr.Body.String())
+	}
+	if rr := send("ann", `{}`); calls != 2 || rr.Body.String() != `"ann"` {
+		t.Errorf("expected the client's own key to be replayed, got %d calls and %s", calls, rr.Body.String())
+	}
+
+	if rr := send("ann", `{"amount": 1000000}`); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for an oversized body, got %d", rr.Code)
+	}
+}
+
+func TestParser_IdempotentRequestID(t *testing.T) {
+	var testParser Parser
+	handler := testParser.RequestID()(testParser.Idempotent(NewMemoryIdempotencyStore(time.Minute))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = testParser.WriteJSON(w, http.StatusCreated, "charged")
+	})))
+
+	for _, id := range []string{"req-1", "req-2"} {
+		req := httptest.NewRequest(http.MethodPost, "/charges", strings.NewReader(`{}`))
+		req.Header.Set("Idempotency-Key", "abc")
+		req.Header.Set("X-Request-ID", id)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if got := rr.Header().Values("X-Request-ID"); rr.Code != http.StatusCreated || len(got) != 1 || got[0] != id {
+			t.Errorf("%s: expected the current request ID, got %d %q", id, rr.Code, got)
+		}
+	}
+}
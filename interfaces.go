@@ -0,0 +1,24 @@
+package ps
+
+import "net/http"
+
+// JSONReader reads JSON request bodies. *Parser implements it, so application code can depend on JSONReader and
+// tests can pass a fake that fails on demand; see the pstest package for one.
+type JSONReader interface {
+	ReadJSON(w http.ResponseWriter, r *http.Request, data any) error
+}
+
+// JSONWriter writes JSON responses and error envelopes. *Parser implements it, so application code can depend on
+// JSONWriter and tests can pass a fake that captures what is written.
+type JSONWriter interface {
+	WriteJSON(w http.ResponseWriter, status int, data any, headers ...http.Header) error
+	ErrorJSON(w http.ResponseWriter, err error, status ...int) error
+}
+
+// JSONReadWriter groups JSONReader and JSONWriter, for handlers that both read and write.
+type JSONReadWriter interface {
+	JSONReader
+	JSONWriter
+}
+
+var _ JSONReadWriter = (*Parser)(nil)
@@ -0,0 +1,560 @@
+package ps
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// JSONAPIMediaType is the media type of JSON:API documents.
+const JSONAPIMediaType = "application/vnd.api+json"
+
+// APIError is an error object of a JSON:API error document.
+type APIError struct {
+	Status string          `json:"status,omitempty"`
+	Title  string          `json:"title,omitempty"`
+	Detail string          `json:"detail,omitempty"`
+	Source *APIErrorSource `json:"source,omitempty"`
+}
+
+// APIErrorSource points to the member of the request document an APIError is about.
+type APIErrorSource struct {
+	// Pointer is a JSON Pointer into the request document, such as "/data/attributes/email".
+	Pointer string `json:"pointer,omitempty"`
+}
+
+// apiResource is a JSON:API resource object.
+type apiResource struct {
+	Type          string                     `json:"type"`
+	ID            string                     `json:"id,omitempty"`
+	Attributes    json.RawMessage            `json:"attributes,omitempty"`
+	Relationships map[string]apiRelationship `json:"relationships,omitempty"`
+}
+
+// apiRelationship is a relationship object, holding the resource linkage of one relationship.
+type apiRelationship struct {
+	Data json.RawMessage `json:"data"`
+}
+
+// apiIdentifier is a resource identifier object.
+type apiIdentifier struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// apiInfo describes how a struct type maps onto a JSON:API resource: its resource type, the field holding its ID,
+// the fields holding its relationships, and its attributes, which are all its other fields.
+type apiInfo struct {
+	typeName string
+	id       field
+	rels     []field
+	attrs    []field
+}
+
+// apiInfo reads the jsonapi struct tags of t, a struct or a pointer to one. The ID field is tagged
+// `jsonapi:"primary,<type>"` and must be a string or an integer; relationship fields are tagged
+// `jsonapi:"relation"` and hold a tagged struct, a pointer to one, or a slice of either.
+func (p *Parser) apiInfo(t reflect.Type) (*apiInfo, error) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("JSON:API resources must be structs, got %s", t)
+	}
+
+	info := &apiInfo{}
+	for _, f := range p.encodeOptions().fields(t).fields {
+		tag := t.FieldByIndex(f.index).Tag.Get("jsonapi")
+		switch kind, typeName, _ := strings.Cut(tag, ","); kind {
+		case "primary":
+			switch f.typ.Kind() {
+			case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			default:
+				return nil, fmt.Errorf("the ID field of %s must be a string or an integer, got %s", t, f.typ)
+			}
+			info.typeName, info.id = typeName, f
+		case "relation":
+			info.rels = append(info.rels, f)
+		default:
+			info.attrs = append(info.attrs, f)
+		}
+	}
+
+	if info.typeName == "" {
+		return nil, fmt.Errorf(`%s has no field tagged jsonapi:"primary,<type>"`, t)
+	}
+	return info, nil
+}
+
+// WriteJSONAPI writes data as a JSON:API document, as application/vnd.api+json. data is a tagged struct, as
+// described for ReadJSONAPI, a pointer to one, a slice of either, or nil. Nil elements of a slice are left out.
+// Attributes are encoded as WriteJSON would encode the struct's fields.
+//
+// The document is compound: related resources are added to included, once each. A related resource that has
+// nothing but its ID, such as a foreign key loaded into an otherwise empty struct, is only linked.
+func (p *Parser) WriteJSONAPI(w http.ResponseWriter, status int, data any, headers ...http.Header) error {
+	doc, err := p.jsonAPIDocument(data)
+	if err != nil {
+		p.count(encodeFailures, 1)
+		return err
+	}
+	return p.writeAs(w, JSONAPIMediaType, status, json.RawMessage(doc), headers)
+}
+
+// jsonAPIDocument encodes data as a JSON:API document.
+func (p *Parser) jsonAPIDocument(data any) ([]byte, error) {
+	e := &apiEncoder{p: p, seen: map[apiIdentifier]bool{}}
+
+	var primary json.RawMessage
+	v := reflect.ValueOf(data)
+	for v.IsValid() && v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	switch {
+	case !v.IsValid() || v.Kind() == reflect.Pointer:
+		primary = json.RawMessage("null")
+
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Array:
+		resources := make([]apiResource, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if isNilResource(v.Index(i)) {
+				continue
+			}
+			res, err := e.resource(v.Index(i), true)
+			if err != nil {
+				return nil, err
+			}
+			resources = append(resources, res)
+		}
+		out, err := json.Marshal(resources)
+		if err != nil {
+			return nil, err
+		}
+		primary = out
+
+	default:
+		res, err := e.resource(v, true)
+		if err != nil {
+			return nil, err
+		}
+		out, err := json.Marshal(res)
+		if err != nil {
+			return nil, err
+		}
+		primary = out
+	}
+
+	if err := e.includeQueued(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		Data     json.RawMessage `json:"data"`
+		Included []apiResource   `json:"included,omitempty"`
+	}{primary, e.included})
+}
+
+// apiEncoder encodes the resources of one document, collecting the related resources to include.
+type apiEncoder struct {
+	p        *Parser
+	seen     map[apiIdentifier]bool
+	queue    []reflect.Value
+	included []apiResource
+}
+
+// resource encodes v, a tagged struct or a pointer to one, and queues its related resources for inclusion.
+// primary marks the resources of the document's data, which are never included.
+func (e *apiEncoder) resource(v reflect.Value, primary bool) (apiResource, error) {
+	if isNilResource(v) {
+		return apiResource{}, errors.New("a JSON:API resource must not be nil")
+	}
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	info, err := e.p.apiInfo(v.Type())
+	if err != nil {
+		return apiResource{}, err
+	}
+
+	res := apiResource{Type: info.typeName, ID: apiID(v.FieldByIndex(info.id.index))}
+	if primary && res.ID != "" {
+		e.seen[apiIdentifier{res.Type, res.ID}] = true
+	}
+
+	// Encode the whole struct with the Parser's settings, then pick out the attributes in declaration order.
+	out, err := e.p.marshal(v.Interface())
+	if err != nil {
+		return apiResource{}, err
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(out, &members); err != nil {
+		return apiResource{}, err
+	}
+	var attrs bytes.Buffer
+	for _, f := range info.attrs {
+		raw, ok := members[f.name]
+		if !ok {
+			continue
+		}
+		if attrs.Len() == 0 {
+			attrs.WriteByte('{')
+		} else {
+			attrs.WriteByte(',')
+		}
+		attrs.Write(f.encodedName)
+		if err := json.Compact(&attrs, raw); err != nil {
+			return apiResource{}, err
+		}
+	}
+	if attrs.Len() > 0 {
+		attrs.WriteByte('}')
+		res.Attributes = attrs.Bytes()
+	}
+
+	for _, f := range info.rels {
+		linkage, err := e.linkage(v.FieldByIndex(f.index))
+		if err != nil {
+			return apiResource{}, fmt.Errorf("relationship %s: %w", f.name, err)
+		}
+		if res.Relationships == nil {
+			res.Relationships = map[string]apiRelationship{}
+		}
+		res.Relationships[f.name] = apiRelationship{Data: linkage}
+	}
+	return res, nil
+}
+
+// isNilResource reports whether v is a nil pointer or interface, or one leading to a nil one.
+func isNilResource(v reflect.Value) bool {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return true
+		}
+		v = v.Elem()
+	}
+	return !v.IsValid()
+}
+
+// linkage returns the resource linkage for the value of a relationship field, queueing the related resources.
+func (e *apiEncoder) linkage(v reflect.Value) (json.RawMessage, error) {
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		ids := make([]apiIdentifier, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			id, ok, err := e.identify(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				ids = append(ids, id)
+			}
+		}
+		return json.Marshal(ids)
+	}
+
+	id, ok, err := e.identify(v)
+	if err != nil || !ok {
+		return json.RawMessage("null"), err
+	}
+	return json.Marshal(id)
+}
+
+// identify returns the identifier of a related resource and queues it, unless it is nil.
+func (e *apiEncoder) identify(v reflect.Value) (apiIdentifier, bool, error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return apiIdentifier{}, false, nil
+		}
+		v = v.Elem()
+	}
+	info, err := e.p.apiInfo(v.Type())
+	if err != nil {
+		return apiIdentifier{}, false, err
+	}
+	e.queue = append(e.queue, v)
+	return apiIdentifier{Type: info.typeName, ID: apiID(v.FieldByIndex(info.id.index))}, true, nil
+}
+
+// includeQueued encodes the queued related resources, and theirs in turn, adding those with more than an ID to
+// included.
+func (e *apiEncoder) includeQueued() error {
+	for len(e.queue) > 0 {
+		v := e.queue[0]
+		e.queue = e.queue[1:]
+
+		res, err := e.resource(v, false)
+		if err != nil {
+			return err
+		}
+		key := apiIdentifier{res.Type, res.ID}
+		if e.seen[key] || (len(res.Attributes) == 0 && len(res.Relationships) == 0) {
+			continue
+		}
+		e.seen[key] = true
+		e.included = append(e.included, res)
+	}
+	return nil
+}
+
+// apiID formats the value of an ID field, which JSON:API always sends as a string. A zero ID is left empty, for
+// resources that the server has not assigned one yet.
+func apiID(v reflect.Value) string {
+	if v.IsZero() {
+		return ""
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	default:
+		return strconv.FormatInt(v.Int(), 10)
+	}
+}
+
+// ErrorJSONAPI takes an error, and optionally a response status code, and sends it as a JSON:API error document.
+// A field error becomes one error object per field, pointing at the attribute it is about. The Disclosure policy
+// applies as it does to ErrorJSON.
+func (p *Parser) ErrorJSONAPI(w http.ResponseWriter, err error, status ...int) error {
+	statusCode := http.StatusBadRequest
+	if len(status) > 0 {
+		statusCode = status[0]
+	}
+
+	var debug *ErrorDebug
+	payload := JSONResponse{Error: true, Message: err.Error(), Fields: fieldErrors(err)}
+	switch disclosed := p.disclose(payload, err, statusCode).(type) {
+	case JSONResponse:
+		payload = disclosed
+	case debugResponse:
+		payload, debug = disclosed.JSONResponse, disclosed.Debug
+	}
+
+	code, title := strconv.Itoa(statusCode), http.StatusText(statusCode)
+	var errs []APIError
+	for _, f := range payload.Fields {
+		errs = append(errs, APIError{Status: code, Title: title, Detail: f.Error(),
+			Source: &APIErrorSource{Pointer: "/data/attributes/" + strings.ReplaceAll(f.Field, ".", "/")}})
+	}
+	if len(errs) == 0 {
+		errs = append(errs, APIError{Status: code, Title: title, Detail: payload.Message})
+	}
+
+	doc := struct {
+		Errors []APIError `json:"errors"`
+		Meta   any        `json:"meta,omitempty"`
+	}{Errors: errs}
+	if debug != nil {
+		doc.Meta = map[string]any{"debug": debug}
+	}
+	return p.writeAs(w, JSONAPIMediaType, statusCode, doc, nil)
+}
+
+// ReadJSONAPI reads a JSON:API document holding a single resource into data, a pointer to a struct. The struct
+// has an ID field tagged `jsonapi:"primary,<type>"`, which names its resource type, and may have relationship
+// fields tagged `jsonapi:"relation"`; every other field is an attribute. Related resources are read as structs
+// holding only their ID.
+//
+// The document must be sent as application/vnd.api+json, and the resource must have the struct's type. The
+// attributes are decoded as ReadJSON would decode the struct, with the same size limit, strictness,
+// AllowedFields and hooks. The BeforeDecode hooks see the whole document, and AllowedFields names members of the
+// struct, as for ReadJSON, rather than paths in the document.
+func (p *Parser) ReadJSONAPI(w http.ResponseWriter, r *http.Request, data any) error {
+	err := p.readJSONAPI(w, r, data)
+	p.countDecode(err)
+	p.notifyDecode(r, data, err)
+	return err
+}
+
+// readJSONAPI does the work of ReadJSONAPI.
+func (p *Parser) readJSONAPI(w http.ResponseWriter, r *http.Request, data any) error {
+	if p.ReplayGuard != nil {
+		if err := p.ReplayGuard.Check(r); err != nil {
+			return err
+		}
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "" && !strings.EqualFold(mediaType(ct), JSONAPIMediaType) {
+		return fmt.Errorf("the Content-Type header is not %s", JSONAPIMediaType)
+	}
+
+	t := reflect.TypeOf(data)
+	if t == nil || t.Kind() != reflect.Pointer {
+		return errors.New("ReadJSONAPI needs a pointer to a struct")
+	}
+	info, err := p.apiInfo(t.Elem())
+	if err != nil {
+		return err
+	}
+
+	buf, maxBytes, err := p.readLimitedBody(w, r)
+	if err != nil {
+		return err
+	}
+	defer releaseBody(buf)
+
+	body, err := p.runBeforeDecode(r, buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	var doc struct {
+		Data *struct {
+			Type          string                     `json:"type"`
+			ID            *string                    `json:"id"`
+			Attributes    map[string]json.RawMessage `json:"attributes"`
+			Relationships map[string]struct {
+				Data json.RawMessage `json:"data"`
+			} `json:"relationships"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return decodeError(err, maxBytes)
+	}
+	if doc.Data == nil {
+		return errors.New("body must contain a resource object in data")
+	}
+	if doc.Data.Type != info.typeName {
+		return fmt.Errorf("resource type must be %q, got %q", info.typeName, doc.Data.Type)
+	}
+
+	// Rebuild the resource as the plain object ReadJSON would expect for the struct, and decode that.
+	object := make(map[string]json.RawMessage, len(doc.Data.Attributes)+len(doc.Data.Relationships)+1)
+	for name, raw := range doc.Data.Attributes {
+		if name == info.id.name || info.rel(name) != nil {
+			return &FieldError{Field: name, Message: "is not an attribute"}
+		}
+		object[name] = raw
+	}
+	if doc.Data.ID != nil {
+		id, err := apiIDJSON(*doc.Data.ID, info.id.typ)
+		if err != nil {
+			return &FieldError{Field: "id", Message: err.Error()}
+		}
+		object[info.id.name] = id
+	}
+	for name, rel := range doc.Data.Relationships {
+		f := info.rel(name)
+		if f == nil {
+			if p.AllowUnknownFields {
+				continue
+			}
+			return &decodeFailure{class: unknownFieldFailure, message: fmt.Sprintf("body contains unknown relationship %q", name)}
+		}
+		linked, err := p.apiLinked(rel.Data, f.typ)
+		if err != nil {
+			return &FieldError{Field: name, Message: err.Error()}
+		}
+		object[name] = linked
+	}
+
+	b, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+	if b, err = p.filterFields(b); err != nil {
+		return err
+	}
+	return p.decodeBody(r, bytes.NewReader(b), data, maxBytes)
+}
+
+// rel returns the relationship field with the given name, or nil.
+func (info *apiInfo) rel(name string) *field {
+	for i := range info.rels {
+		if info.rels[i].name == name {
+			return &info.rels[i]
+		}
+	}
+	return nil
+}
+
+// apiLinked turns resource linkage into the JSON for a relationship field of type t: null, an object holding the
+// related resource's ID, or an array of them.
+func (p *Parser) apiLinked(linkage json.RawMessage, t reflect.Type) (json.RawMessage, error) {
+	elem := t
+	for elem.Kind() == reflect.Pointer || elem.Kind() == reflect.Slice || elem.Kind() == reflect.Array {
+		elem = elem.Elem()
+	}
+	info, err := p.apiInfo(elem)
+	if err != nil {
+		return nil, err
+	}
+
+	link := func(raw json.RawMessage) (json.RawMessage, error) {
+		var id apiIdentifier
+		if err := json.Unmarshal(raw, &id); err != nil || id.ID == "" {
+			return nil, errors.New("must be a resource identifier")
+		}
+		if id.Type != info.typeName {
+			return nil, fmt.Errorf("must link to a resource of type %q", info.typeName)
+		}
+		v, err := apiIDJSON(id.ID, info.id.typ)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]json.RawMessage{info.id.name: v})
+	}
+
+	trimmed := bytes.TrimSpace(linkage)
+	switch {
+	case len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")):
+		return json.RawMessage("null"), nil
+	case trimmed[0] == '[':
+		var raws []json.RawMessage
+		if err := json.Unmarshal(trimmed, &raws); err != nil {
+			return nil, errors.New("must be an array of resource identifiers")
+		}
+		out := make([]json.RawMessage, len(raws))
+		for i, raw := range raws {
+			if out[i], err = link(raw); err != nil {
+				return nil, err
+			}
+		}
+		return json.Marshal(out)
+	default:
+		return link(trimmed)
+	}
+}
+
+// apiIDJSON returns id, sent as a JSON:API string ID, as the JSON for an ID field of type t.
+func apiIDJSON(id string, t reflect.Type) (json.RawMessage, error) {
+	if t.Kind() == reflect.String {
+		return json.Marshal(id)
+	}
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+			return nil, errors.New("must be an integer ID")
+		}
+	}
+	return json.RawMessage(id), nil
+}
+
+// WriteNegotiated writes data as a JSON:API document if the request's Accept header prefers
+// application/vnd.api+json to application/json, and as WriteJSON would otherwise.
+func (p *Parser) WriteNegotiated(w http.ResponseWriter, r *http.Request, status int, data any, headers ...http.Header) error {
+	AddVary(w, "Accept")
+	if wantsJSONAPI(r) {
+		return p.WriteJSONAPI(w, status, data, headers...)
+	}
+	return p.WriteJSON(w, status, data, headers...)
+}
+
+// ErrorNegotiated sends err as a JSON:API error document if the request's Accept header prefers
+// application/vnd.api+json to application/json, and as ErrorJSON would otherwise.
+func (p *Parser) ErrorNegotiated(w http.ResponseWriter, r *http.Request, err error, status ...int) error {
+	AddVary(w, "Accept")
+	if wantsJSONAPI(r) {
+		return p.ErrorJSONAPI(w, err, status...)
+	}
+	return p.ErrorJSON(w, err, status...)
+}
+
+// wantsJSONAPI reports whether r prefers JSON:API responses.
+func wantsJSONAPI(r *http.Request) bool {
+	match, ok := NegotiateMediaType(r.Header.Get("Accept"), []string{"application/json", JSONAPIMediaType})
+	return ok && match == JSONAPIMediaType
+}
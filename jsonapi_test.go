@@ -0,0 +1,191 @@
+package ps
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type apiCustomer struct {
+	ID   string `json:"id" jsonapi:"primary,customers"`
+	Name string `json:"name,omitempty"`
+}
+
+type apiItem struct {
+	ID  int `json:"id" jsonapi:"primary,items"`
+	Qty int `json:"qty"`
+}
+
+type apiOrder struct {
+	ID       int          `json:"id" jsonapi:"primary,orders"`
+	Status   string       `json:"status,required"`
+	Total    int64        `json:"total"`
+	Customer *apiCustomer `json:"customer" jsonapi:"relation"`
+	Items    []apiItem    `json:"items" jsonapi:"relation"`
+}
+
+func TestParser_WriteJSONAPI(t *testing.T) {
+	testParser := Parser{Int64AsString: true}
+	ann := &apiCustomer{ID: "c1", Name: "Ann"}
+	orders := []apiOrder{
+		{ID: 7, Status: "paid", Total: 1200, Customer: ann, Items: []apiItem{{ID: 1, Qty: 2}}},
+		{ID: 8, Status: "open", Customer: ann},
+		{ID: 9, Status: "open", Customer: &apiCustomer{ID: "c2"}},
+	}
+
+	rr := httptest.NewRecorder()
+	if err := testParser.WriteJSONAPI(rr, http.StatusOK, orders); err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Header().Get("Content-Type") != JSONAPIMediaType {
+		t.Errorf("expected %s, got %s", JSONAPIMediaType, rr.Header().Get("Content-Type"))
+	}
+	expected := `{"data":[` +
+		`{"type":"orders","id":"7","attributes":{"status":"paid","total":"1200"},"relationships":{"customer":{"data":{"type":"customers","id":"c1"}},"items":{"data":[{"type":"items","id":"1"}]}}},` +
+		`{"type":"orders","id":"8","attributes":{"status":"open","total":"0"},"relationships":{"customer":{"data":{"type":"customers","id":"c1"}},"items":{"data":[]}}},` +
+		`{"type":"orders","id":"9","attributes":{"status":"open","total":"0"},"relationships":{"customer":{"data":{"type":"customers","id":"c2"}},"items":{"data":[]}}}],` +
+		`"included":[{"type":"customers","id":"c1","attributes":{"name":"Ann"}},{"type":"items","id":"1","attributes":{"qty":2}}]}`
+	if rr.Body.String() != expected {
+		t.Errorf("expected %s, got %s", expected, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	_ = testParser.WriteJSONAPI(rr, http.StatusOK, (*apiOrder)(nil))
+	if rr.Body.String() != `{"data":null}` {
+		t.Errorf("expected null data, got %s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	if err := testParser.WriteJSONAPI(rr, http.StatusOK, []*apiCustomer{ann, nil}); err != nil {
+		t.Fatalf("expected nil elements to be left out, got %v", err)
+	}
+	if expected := `{"data":[{"type":"customers","id":"c1","attributes":{"name":"Ann"}}]}`; rr.Body.String() != expected {
+		t.Errorf("expected %s, got %s", expected, rr.Body.String())
+	}
+
+	if err := testParser.WriteJSONAPI(httptest.NewRecorder(), http.StatusOK, struct{ A int }{}); err == nil {
+		t.Error("expected an error for an untagged struct")
+	}
+}
+
+func TestParser_ReadJSONAPIHooks(t *testing.T) {
+	testParser := New(WithAllowedFields(RejectDisallowedFields, "id", "status", "customer", "items"))
+	var seen string
+	testParser.BeforeDecode(func(_ *http.Request, body []byte) ([]byte, error) {
+		seen = string(body)
+		return body, nil
+	})
+
+	body := `{"data":{"type":"orders","attributes":{"status":"paid","total":5}}}`
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	req.Header.Set("Content-Type", JSONAPIMediaType)
+	err := testParser.ReadJSONAPI(httptest.NewRecorder(), req, &apiOrder{})
+
+	if seen != body {
+		t.Errorf("expected the BeforeDecode hook to see the document, got %q", seen)
+	}
+	if err == nil || !strings.Contains(err.Error(), "total is not an allowed field") {
+		t.Errorf("expected total to be rejected, got %v", err)
+	}
+}
+
+var readJSONAPITests = []struct {
+	name          string
+	body          string
+	contentType   string
+	expectedError string
+}{
+	{name: "resource", body: `{"data":{"type":"orders","id":"7","attributes":{"status":"paid"},"relationships":{"customer":{"data":{"type":"customers","id":"c1"}},"items":{"data":[{"type":"items","id":"1"}]}}}}`},
+	{name: "wrong content type", body: `{}`, contentType: "application/json", expectedError: "the Content-Type header is not application/vnd.api+json"},
+	{name: "no data", body: `{"meta":{}}`, expectedError: "body must contain a resource object in data"},
+	{name: "wrong type", body: `{"data":{"type":"people"}}`, expectedError: `resource type must be "orders", got "people"`},
+	{name: "bad id", body: `{"data":{"type":"orders","id":"x","attributes":{"status":"paid"}}}`, expectedError: "id must be an integer ID"},
+	{name: "unknown attribute", body: `{"data":{"type":"orders","attributes":{"status":"paid","color":"red"}}}`, expectedError: `unknown key "color"`},
+	{name: "relationship as attribute", body: `{"data":{"type":"orders","attributes":{"customer":null}}}`, expectedError: "customer is not an attribute"},
+	{name: "unknown relationship", body: `{"data":{"type":"orders","attributes":{"status":"paid"},"relationships":{"owner":{"data":null}}}}`, expectedError: `unknown relationship "owner"`},
+	{name: "wrong related type", body: `{"data":{"type":"orders","attributes":{"status":"paid"},"relationships":{"customer":{"data":{"type":"items","id":"1"}}}}}`, expectedError: `customer must link to a resource of type "customers"`},
+	{name: "required attribute", body: `{"data":{"type":"orders"}}`, expectedError: "status is required"},
+}
+
+func TestParser_ReadJSONAPI(t *testing.T) {
+	var testParser Parser
+
+	for _, e := range readJSONAPITests {
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(e.body))
+		req.Header.Set("Content-Type", JSONAPIMediaType)
+		if e.contentType != "" {
+			req.Header.Set("Content-Type", e.contentType)
+		}
+
+		var order apiOrder
+		err := testParser.ReadJSONAPI(httptest.NewRecorder(), req, &order)
+
+		if e.expectedError == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", e.name, err)
+			} else if order.ID != 7 || order.Status != "paid" || order.Customer == nil || order.Customer.ID != "c1" ||
+				len(order.Items) != 1 || order.Items[0].ID != 1 {
+				t.Errorf("%s: unexpected order %+v", e.name, order)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), e.expectedError) {
+			t.Errorf("%s: expected an error containing %q, got %v", e.name, e.expectedError, err)
+		}
+	}
+}
+
+func TestParser_ErrorJSONAPI(t *testing.T) {
+	var testParser Parser
+
+	rr := httptest.NewRecorder()
+	_ = testParser.ErrorJSONAPI(rr, &ValidationError{Fields: []FieldError{{Field: "address.city", Message: "is required"}}}, http.StatusUnprocessableEntity)
+	expected := `{"errors":[{"status":"422","title":"Unprocessable Entity","detail":"address.city is required","source":{"pointer":"/data/attributes/address/city"}}]}`
+	if rr.Code != http.StatusUnprocessableEntity || rr.Body.String() != expected {
+		t.Errorf("expected %s, got %d %s", expected, rr.Code, rr.Body.String())
+	}
+
+	testParser.Disclosure = ProductionDisclosure
+	testParser.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	rr = httptest.NewRecorder()
+	_ = testParser.ErrorJSONAPI(rr, errors.New("connection refused"), http.StatusInternalServerError)
+	if expected := `{"errors":[{"status":"500","title":"Internal Server Error","detail":"Internal Server Error"}]}`; rr.Body.String() != expected {
+		t.Errorf("expected %s, got %s", expected, rr.Body.String())
+	}
+}
+
+var negotiatedTests = []struct {
+	accept       string
+	expectedType string
+}{
+	{accept: "", expectedType: "application/json"},
+	{accept: "*/*", expectedType: "application/json"},
+	{accept: JSONAPIMediaType, expectedType: JSONAPIMediaType},
+	{accept: "application/json;q=0.5, application/vnd.api+json", expectedType: JSONAPIMediaType},
+}
+
+func TestParser_WriteNegotiated(t *testing.T) {
+	var testParser Parser
+
+	for _, e := range negotiatedTests {
+		req := httptest.NewRequest(http.MethodGet, "/orders/7", nil)
+		req.Header.Set("Accept", e.accept)
+		rr := httptest.NewRecorder()
+		_ = testParser.WriteNegotiated(rr, req, http.StatusOK, apiOrder{ID: 7})
+
+		if rr.Header().Get("Content-Type") != e.expectedType || rr.Header().Get("Vary") != "Accept" {
+			t.Errorf("%q: expected %s, got %s", e.accept, e.expectedType, rr.Header().Get("Content-Type"))
+		}
+
+		rr = httptest.NewRecorder()
+		_ = testParser.ErrorNegotiated(rr, req, errors.New("no"))
+		if rr.Header().Get("Content-Type") != e.expectedType {
+			t.Errorf("%q: expected an error as %s, got %s", e.accept, e.expectedType, rr.Header().Get("Content-Type"))
+		}
+	}
+}
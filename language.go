@@ -0,0 +1,169 @@
+package ps
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Language is one entry of an Accept-Language header.
+type Language struct {
+	// Tag is the language tag, such as en-GB, or * for any language.
+	Tag string
+	// Q is the relative preference, between 0 and 1.
+	Q float64
+}
+
+// Languages is a list of language preferences, most preferred first.
+type Languages []Language
+
+// ParseAcceptLanguage parses an Accept-Language header into the languages it lists, ordered by decreasing
+// q-value. Entries with the same q-value keep their original order, and malformed entries are dropped. Entries
+// with q=0 are kept at the end so that Match can honour them as exclusions.
+func ParseAcceptLanguage(header string) Languages {
+	var languages Languages
+
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.TrimSpace(tag)
+		if !validLanguageTag(tag) {
+			continue
+		}
+
+		q, ok := parseQ(params)
+		if !ok {
+			continue
+		}
+
+		languages = append(languages, Language{Tag: tag, Q: q})
+	}
+
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].Q > languages[j].Q
+	})
+
+	return languages
+}
+
+// Tags returns the acceptable language tags in order of preference.
+func (l Languages) Tags() []string {
+	tags := make([]string, 0, len(l))
+	for _, language := range l {
+		if language.Q > 0 {
+			tags = append(tags, language.Tag)
+		}
+	}
+	return tags
+}
+
+// Match returns the entry of supported that best satisfies the preferences. Each preferred tag is tried in turn,
+// first using the lookup scheme of RFC 4647 (en-GB-oxendict, then en-GB, then en), and then against any more
+// specific supported tag (en matches en-US). A wildcard matches the first supported language that has not been
+// excluded with q=0. Tags are compared case-insensitively. ok is false if nothing matched.
+func (l Languages) Match(supported []string) (match string, ok bool) {
+	var excluded []string
+	for _, language := range l {
+		if language.Q == 0 {
+			excluded = append(excluded, language.Tag)
+		}
+	}
+
+	for _, language := range l {
+		if language.Q == 0 {
+			continue
+		}
+
+		if language.Tag == "*" {
+			for _, s := range supported {
+				if !containsFold(excluded, s) {
+					return s, true
+				}
+			}
+			continue
+		}
+
+		for tag := language.Tag; tag != ""; tag = truncateLanguageTag(tag) {
+			for _, s := range supported {
+				if strings.EqualFold(s, tag) && !containsFold(excluded, s) {
+					return s, true
+				}
+			}
+		}
+
+		for _, s := range supported {
+			if len(s) > len(language.Tag) && strings.EqualFold(s[:len(language.Tag)+1], language.Tag+"-") && !containsFold(excluded, s) {
+				return s, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// NegotiateLanguage matches the Accept-Language header of r against the Parser's Languages, falling back to the
+// first of them, and sets the Content-Language header that WriteJSON then sends with the response. Accept-Language
+// is added to Vary. It returns the chosen language, or an empty string if no Languages are configured.
+func (p *Parser) NegotiateLanguage(w http.ResponseWriter, r *http.Request) string {
+	if len(p.Languages) == 0 {
+		return ""
+	}
+
+	language, ok := ParseAcceptLanguage(r.Header.Get("Accept-Language")).Match(p.Languages)
+	if !ok {
+		language = p.Languages[0]
+	}
+
+	w.Header().Set("Content-Language", language)
+	AddVary(w, "Accept-Language")
+
+	return language
+}
+
+// truncateLanguageTag removes the last subtag of tag, along with any single-letter subtag left before it.
+func truncateLanguageTag(tag string) string {
+	i := strings.LastIndexByte(tag, '-')
+	if i < 0 {
+		return ""
+	}
+	tag = tag[:i]
+	if i = strings.LastIndexByte(tag, '-'); i >= 0 && len(tag)-i == 2 {
+		tag = tag[:i]
+	}
+	return tag
+}
+
+// validLanguageTag reports whether tag looks like a language range: * or alphanumeric subtags separated by hyphens.
+func validLanguageTag(tag string) bool {
+	if tag == "*" {
+		return true
+	}
+	for _, subtag := range strings.Split(tag, "-") {
+		if subtag == "" || len(subtag) > 8 {
+			return false
+		}
+		for _, c := range subtag {
+			if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// parseQ extracts the q parameter from the parameters of a header element, such as "q=0.8" or
+// "charset=utf-8; q=0.5". It returns 1 if there is no q parameter, and false if the value is not a valid weight.
+func parseQ(params string) (float64, bool) {
+	for _, param := range strings.Split(params, ";") {
+		name, value, _ := strings.Cut(param, "=")
+		if !strings.EqualFold(strings.TrimSpace(name), "q") {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || q < 0 || q > 1 {
+			return 0, false
+		}
+		return q, true
+	}
+	return 1, true
+}
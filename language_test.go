@@ -0,0 +1,92 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+var parseAcceptLanguageTests = []struct {
+	name     string
+	header   string
+	expected []string
+}{
+	{name: "empty", header: "", expected: []string{}},
+	{name: "single", header: "en-US", expected: []string{"en-US"}},
+	{name: "ordered by q", header: "fr;q=0.5, en-GB, de;q=0.8", expected: []string{"en-GB", "de", "fr"}},
+	{name: "stable for equal q", header: "nl, da;q=0.9, sv;q=0.9", expected: []string{"nl", "da", "sv"}},
+	{name: "malformed entries dropped", header: "en;q=2, de;q=x, fr, $$", expected: []string{"fr"}},
+	{name: "excluded", header: "en, fr;q=0", expected: []string{"en"}},
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	for _, e := range parseAcceptLanguageTests {
+		if got := ParseAcceptLanguage(e.header).Tags(); !reflect.DeepEqual(got, e.expected) {
+			t.Errorf("%s: expected %v, got %v", e.name, e.expected, got)
+		}
+	}
+}
+
+var matchLanguageTests = []struct {
+	name      string
+	header    string
+	supported []string
+	expected  string
+	ok        bool
+}{
+	{name: "exact", header: "de, en", supported: []string{"en", "de"}, expected: "de", ok: true},
+	{name: "case insensitive", header: "EN-gb", supported: []string{"en-GB"}, expected: "en-GB", ok: true},
+	{name: "truncated", header: "en-GB-oxendict", supported: []string{"fr", "en"}, expected: "en", ok: true},
+	{name: "more specific supported", header: "en", supported: []string{"fr", "en-US"}, expected: "en-US", ok: true},
+	{name: "preference order wins", header: "pt-BR, fr;q=0.9", supported: []string{"fr", "pt"}, expected: "pt", ok: true},
+	{name: "wildcard", header: "ja, *;q=0.1", supported: []string{"en", "de"}, expected: "en", ok: true},
+	{name: "wildcard with exclusion", header: "*, en;q=0", supported: []string{"en", "de"}, expected: "de", ok: true},
+	{name: "no match", header: "ja", supported: []string{"en", "de"}},
+}
+
+func TestLanguages_Match(t *testing.T) {
+	for _, e := range matchLanguageTests {
+		got, ok := ParseAcceptLanguage(e.header).Match(e.supported)
+		if got != e.expected || ok != e.ok {
+			t.Errorf("%s: expected %q %t, got %q %t", e.name, e.expected, e.ok, got, ok)
+		}
+	}
+}
+
+var negotiateLanguageTests = []struct {
+	name     string
+	header   string
+	expected string
+}{
+	{name: "matched", header: "de-AT, en;q=0.5", expected: "de"},
+	{name: "default", header: "ja", expected: "en"},
+	{name: "missing header", expected: "en"},
+}
+
+func TestParser_NegotiateLanguage(t *testing.T) {
+	testParser := Parser{Languages: []string{"en", "de"}}
+
+	for _, e := range negotiateLanguageTests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if e.header != "" {
+			req.Header.Set("Accept-Language", e.header)
+		}
+
+		rr := httptest.NewRecorder()
+		if got := testParser.NegotiateLanguage(rr, req); got != e.expected {
+			t.Errorf("%s: expected %q, got %q", e.name, e.expected, got)
+		}
+
+		if err := testParser.WriteJSON(rr, http.StatusOK, JSONResponse{}); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := rr.Header().Get("Content-Language"); got != e.expected {
+			t.Errorf("%s: expected Content-Language %q, got %q", e.name, e.expected, got)
+		}
+		if got := rr.Header().Get("Vary"); got != "Accept-Language" {
+			t.Errorf("%s: expected Vary to include Accept-Language, got %q", e.name, got)
+		}
+	}
+}
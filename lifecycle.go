@@ -0,0 +1,74 @@
+package ps
+
+import (
+	"net/http"
+	"time"
+)
+
+// DecodeErrorCallback is told of every call reading a body that fails, with the error it returns: ReadJSON,
+// ReadForm, ReadJSONAPI, ReadCloudEvent, ReadJSONMulti and Decode, and of every message or element that fails to
+// decode in FrameReader.Read, ReadJSONMessage, StreamJSON and StreamJSONChan. r is nil for Decode, which has no
+// request.
+type DecodeErrorCallback func(r *http.Request, err error)
+
+// BindCallback is told of every call reading a body that succeeds. data is the pointer that was passed to it; for
+// ReadJSONMulti, it is told of each document, with the value from factory, and for the streaming readers of each
+// message or element. r is nil for Decode.
+type BindCallback func(r *http.Request, data any)
+
+// WriteCallback is told of every response once it has finished: WriteJSON calls, including those made by
+// ErrorJSON, StreamWriters and FrameWriters when they are closed, and Encode and WriteJSONMessage calls, for which
+// w is nil.
+type WriteCallback func(w http.ResponseWriter, info WriteInfo)
+
+// WriteInfo describes a finished response.
+type WriteInfo struct {
+	// Status is the status code the response was written with, or was to be; 0 for Encode.
+	Status int
+	// Size is the number of body bytes written.
+	Size int64
+	// Duration is how long the call took, encoding included; for a StreamWriter or FrameWriter, the time from its
+	// creation to Close or Fail.
+	Duration time.Duration
+	// Err is the error returned, if any.
+	Err error
+}
+
+// OnDecodeError registers callbacks to run, in order, whenever reading a body fails. Callbacks suit telemetry and
+// alerting: they cannot change the outcome, and run on the request's goroutine, so they must be quick.
+func (p *Parser) OnDecodeError(callbacks ...DecodeErrorCallback) {
+	p.mustNotBeFrozen("OnDecodeError")
+	p.onDecodeError = append(p.onDecodeError, callbacks...)
+}
+
+// OnBind registers callbacks to run, in order, whenever reading a body succeeds, after its hooks and validation.
+func (p *Parser) OnBind(callbacks ...BindCallback) {
+	p.mustNotBeFrozen("OnBind")
+	p.onBind = append(p.onBind, callbacks...)
+}
+
+// OnWrite registers callbacks to run, in order, whenever a response is finished, whether or not it succeeded.
+func (p *Parser) OnWrite(callbacks ...WriteCallback) {
+	p.mustNotBeFrozen("OnWrite")
+	p.onWrite = append(p.onWrite, callbacks...)
+}
+
+// notifyDecode runs the OnDecodeError or OnBind callbacks for the outcome of reading a body.
+func (p *Parser) notifyDecode(r *http.Request, data any, err error) {
+	if err != nil {
+		for _, callback := range p.onDecodeError {
+			callback(r, err)
+		}
+		return
+	}
+	for _, callback := range p.onBind {
+		callback(r, data)
+	}
+}
+
+// notifyWrite runs the OnWrite callbacks for a finished response.
+func (p *Parser) notifyWrite(w http.ResponseWriter, info WriteInfo) {
+	for _, callback := range p.onWrite {
+		callback(w, info)
+	}
+}
@@ -0,0 +1,189 @@
+package ps
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParser_LifecycleCallbacks(t *testing.T) {
+	var testParser Parser
+	var decodeErrors []string
+	var binds []any
+	var writes []WriteInfo
+	testParser.OnDecodeError(func(r *http.Request, err error) { decodeErrors = append(decodeErrors, err.Error()) })
+	testParser.OnBind(func(r *http.Request, data any) { binds = append(binds, data) })
+	testParser.OnWrite(func(w http.ResponseWriter, info WriteInfo) { writes = append(writes, info) })
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var data struct {
+			Name string `json:"name"`
+		}
+		if err := testParser.ReadJSON(w, r, &data); err != nil {
+			_ = testParser.ErrorJSON(w, err)
+			return
+		}
+		_ = testParser.WriteJSON(w, http.StatusCreated, data)
+	})
+
+	for _, body := range []string{`{"name": "Jack"}`, `{"name":`} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	}
+
+	if len(binds) != 1 {
+		t.Errorf("expected one bind, got %d", len(binds))
+	}
+	if len(decodeErrors) != 1 || decodeErrors[0] != "body contains badly-formed JSON" {
+		t.Errorf("expected one decode error, got %q", decodeErrors)
+	}
+	if len(writes) != 2 {
+		t.Fatalf("expected two writes, got %d", len(writes))
+	}
+	if writes[0].Status != http.StatusCreated || writes[0].Size != int64(len(`{"name":"Jack"}`)) || writes[0].Err != nil {
+		t.Errorf("unexpected first write %+v", writes[0])
+	}
+	if writes[1].Status != http.StatusBadRequest || writes[1].Size != int64(len(`{"error":true,"message":"body contains badly-formed JSON"}`)) {
+		t.Errorf("unexpected second write %+v", writes[1])
+	}
+
+	// A failed write is reported too.
+	_ = testParser.WriteJSON(httptest.NewRecorder(), http.StatusOK, make(chan int))
+	if last := writes[len(writes)-1]; last.Err == nil || last.Size != 0 {
+		t.Errorf("expected a failed write with nothing written, got %+v", last)
+	}
+}
+
+func TestParser_LifecycleCallbacksOtherCalls(t *testing.T) {
+	var testParser Parser
+	var decodeErrors []error
+	var binds []any
+	var writes []WriteInfo
+	testParser.OnDecodeError(func(r *http.Request, err error) { decodeErrors = append(decodeErrors, err) })
+	testParser.OnBind(func(r *http.Request, data any) { binds = append(binds, data) })
+	testParser.OnWrite(func(w http.ResponseWriter, info WriteInfo) { writes = append(writes, info) })
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("name=Jack"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var form struct {
+		Name string `json:"name"`
+	}
+	if err := testParser.ReadForm(httptest.NewRecorder(), req, &form); err != nil {
+		t.Fatal(err)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id":1}{"id":2}`))
+	req.Header.Set("Content-Type", "application/json")
+	factory := func() any { return new(map[string]int) }
+	if err := testParser.ReadJSONMulti(httptest.NewRecorder(), req, factory, func(any) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded map[string]int
+	if err := testParser.Decode(context.Background(), strings.NewReader(`{"id":3}`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	_ = testParser.Decode(context.Background(), strings.NewReader(`{"id":`), &decoded)
+
+	if len(binds) != 4 || len(decodeErrors) != 1 {
+		t.Errorf("expected four binds and one decode error, got %v and %v", binds, decodeErrors)
+	}
+
+	var buf bytes.Buffer
+	if err := testParser.Encode(&buf, decoded); err != nil {
+		t.Fatal(err)
+	}
+	stream := testParser.NewStreamWriter(httptest.NewRecorder(), http.StatusOK, NDJSONStream)
+	_ = stream.Write(decoded)
+	_ = stream.Close()
+
+	if len(writes) != 2 {
+		t.Fatalf("expected two writes, got %+v", writes)
+	}
+	if writes[0].Status != 0 || writes[0].Size != int64(buf.Len()) {
+		t.Errorf("unexpected Encode write %+v", writes[0])
+	}
+	if writes[1].Status != http.StatusOK || writes[1].Size != int64(len(`{"id":3}`+"\n")) {
+		t.Errorf("unexpected stream write %+v", writes[1])
+	}
+}
+
+func TestParser_LifecycleCallbacksStreams(t *testing.T) {
+	var testParser Parser
+	var decodeErrors []error
+	var binds []any
+	var writes []WriteInfo
+	testParser.OnDecodeError(func(r *http.Request, err error) { decodeErrors = append(decodeErrors, err) })
+	testParser.OnBind(func(r *http.Request, data any) { binds = append(binds, data) })
+	testParser.OnWrite(func(w http.ResponseWriter, info WriteInfo) { writes = append(writes, info) })
+
+	// Each message or element is a read of its own; the end of the stream is not one.
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(frames(0, `{"id":1}`, `{"id":`)))
+	req.Header.Set("Content-Type", "application/grpc-web+json")
+	fr, err := testParser.NewFrameReader(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]int
+	for fr.Read(&decoded) == nil {
+	}
+
+	conn := &fakeConn{incoming: [][]byte{[]byte(`{"id":2}`)}}
+	for testParser.ReadJSONMessage(conn, req, &decoded) == nil {
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{\"id\":3}\n{\"id\":4}"))
+	values, errc := StreamJSONChan[map[string]int](context.Background(), &testParser, req, nil)
+	for range values {
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	// An error from handle is the caller's, not a decode failure.
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id":5}`))
+	factory := func() any { return new(map[string]int) }
+	handleErr := errors.New("out of stock")
+	if err := testParser.ReadJSONMulti(httptest.NewRecorder(), req, factory, func(any) error { return handleErr }); err != handleErr {
+		t.Fatalf("expected the handle error, got %v", err)
+	}
+
+	if len(binds) != 5 || len(decodeErrors) != 1 {
+		t.Errorf("expected five binds and one decode error, got %v and %v", binds, decodeErrors)
+	}
+
+	rr := httptest.NewRecorder()
+	fw := testParser.NewFrameWriter(rr, ConnectProtocol)
+	_ = fw.Write(decoded)
+	_ = fw.Fail(errors.New("database went away"))
+	if err := testParser.WriteJSONMessage(conn, decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(writes) != 2 {
+		t.Fatalf("expected two writes, got %+v", writes)
+	}
+	if writes[0].Status != http.StatusOK || writes[0].Size != int64(rr.Body.Len()) || writes[0].Err != nil {
+		t.Errorf("unexpected frame write %+v", writes[0])
+	}
+	if writes[1].Status != 0 || writes[1].Size != int64(len(conn.sent[0])) {
+		t.Errorf("unexpected message write %+v", writes[1])
+	}
+
+	stats := testParser.Stats()
+	if stats.Decodes != 5 || stats.DecodeFailures != 1 || stats.Encodes != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestParser_OnWriteFrozen(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected OnWrite to panic on a frozen Parser")
+		}
+	}()
+	New().Freeze().OnWrite(func(http.ResponseWriter, WriteInfo) {})
+}
@@ -0,0 +1,59 @@
+package ps
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// longPollWriteGrace is how long past the poll timeout the response may take to write.
+const longPollWriteGrace = 10 * time.Second
+
+// PollTimeout controls what LongPoll writes when no data arrives before the timeout.
+type PollTimeout int
+
+const (
+	// NoContentPollTimeout writes 204 No Content. This is the default.
+	NoContentPollTimeout PollTimeout = iota
+	// EnvelopePollTimeout writes a JSONResponse with status 200 and the message "no data before timeout", for
+	// clients that expect every response to have a body.
+	EnvelopePollTimeout
+)
+
+// LongPoll holds r open until wait returns or timeout passes, then writes the result. wait is given a context that
+// is cancelled at the timeout or when the client goes away, and should return ctx.Err() when it is done.
+//
+// Data returned by wait is written with WriteJSON and status 200. At the timeout, the response is decided by
+// PollTimeout. If the client disconnects, nothing is written and the request context's error is returned. Any
+// other error from wait is returned unwritten, for the caller to pass to ErrorJSON.
+//
+// The connection's write deadline is extended to cover the wait, so a server WriteTimeout shorter than timeout
+// does not cut the poll short.
+func (p *Parser) LongPoll(w http.ResponseWriter, r *http.Request, timeout time.Duration, wait func(ctx context.Context) (any, error)) error {
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Now().Add(timeout + longPollWriteGrace)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	data, err := wait(ctx)
+	switch {
+	case r.Context().Err() != nil:
+		return r.Context().Err()
+
+	case errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil:
+		if p.PollTimeout == EnvelopePollTimeout {
+			return p.WriteJSON(w, http.StatusOK, JSONResponse{Message: "no data before timeout"})
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+
+	case err != nil:
+		return err
+	}
+
+	return p.WriteJSON(w, http.StatusOK, data)
+}
@@ -0,0 +1,68 @@
+package ps
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var longPollTests = []struct {
+	name           string
+	policy         PollTimeout
+	data           bool
+	expectedStatus int
+	expectedBody   string
+}{
+	{name: "data", data: true, expectedStatus: http.StatusOK, expectedBody: `{"id":1}`},
+	{name: "timeout", expectedStatus: http.StatusNoContent},
+	{name: "timeout envelope", policy: EnvelopePollTimeout, expectedStatus: http.StatusOK,
+		expectedBody: `{"error":false,"message":"no data before timeout"}`},
+}
+
+func TestParser_LongPoll(t *testing.T) {
+	for _, e := range longPollTests {
+		testParser := Parser{PollTimeout: e.policy}
+		events := make(chan any, 1)
+		if e.data {
+			events <- map[string]int{"id": 1}
+		}
+
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/poll", nil)
+		err := testParser.LongPoll(rr, req, 10*time.Millisecond, func(ctx context.Context) (any, error) {
+			select {
+			case v := <-events:
+				return v, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		})
+
+		if err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+		}
+		if rr.Code != e.expectedStatus || rr.Body.String() != e.expectedBody {
+			t.Errorf("%s: expected %d %s, got %d %s", e.name, e.expectedStatus, e.expectedBody, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestParser_LongPollDisconnect(t *testing.T) {
+	var testParser Parser
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/poll", nil).WithContext(ctx)
+	rr := httptest.NewRecorder()
+
+	go cancel()
+	err := testParser.LongPoll(rr, req, time.Minute, func(ctx context.Context) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	if !errors.Is(err, context.Canceled) || rr.Body.Len() != 0 || rr.Flushed {
+		t.Errorf("expected nothing written and context.Canceled, got %v and %q", err, rr.Body.String())
+	}
+}
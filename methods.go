@@ -0,0 +1,126 @@
+package ps
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// methodOverrideField is the form field MethodOverride reads the method from.
+const methodOverrideField = "_method"
+
+// Options answers an OPTIONS request for a route that accepts methods. The Allow header lists methods, plus HEAD
+// when GET is allowed and OPTIONS itself. If description is not nil it is written as the JSON body of a 200
+// response; otherwise the response is 204 No Content.
+func (p *Parser) Options(w http.ResponseWriter, methods []string, description any) error {
+	w.Header().Set("Allow", allowHeader(methods))
+
+	if description == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
+	return p.WriteJSON(w, http.StatusOK, description)
+}
+
+// MethodNotAllowed sends a 405 JSON error response with an Allow header listing methods.
+func (p *Parser) MethodNotAllowed(w http.ResponseWriter, r *http.Request, methods ...string) error {
+	w.Header().Set("Allow", allowHeader(methods))
+	return p.ErrorJSON(w, fmt.Errorf("method %s is not allowed", r.Method), http.StatusMethodNotAllowed)
+}
+
+// AllowMethods returns middleware that only lets requests using one of methods reach the wrapped handler.
+// OPTIONS requests are answered by Options with the given description, and any other method receives a JSON
+// 405 response.
+func (p *Parser) AllowMethods(methods []string, description any) func(http.Handler) http.Handler {
+	allowed := strings.Split(allowHeader(methods), ", ")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Method == http.MethodOptions:
+				_ = p.Options(w, methods, description)
+			case containsFold(allowed, r.Method):
+				next.ServeHTTP(w, r)
+			default:
+				_ = p.MethodNotAllowed(w, r, methods...)
+			}
+		})
+	}
+}
+
+// allowHeader builds the value of an Allow header for methods, adding HEAD when GET is present and OPTIONS.
+func allowHeader(methods []string) string {
+	var allowed []string
+	add := func(m string) {
+		if !containsFold(allowed, m) {
+			allowed = append(allowed, m)
+		}
+	}
+
+	for _, m := range methods {
+		m = strings.ToUpper(strings.TrimSpace(m))
+		add(m)
+		if m == http.MethodGet {
+			add(http.MethodHead)
+		}
+	}
+	add(http.MethodOptions)
+
+	return strings.Join(allowed, ", ")
+}
+
+// overridableMethods are the methods a POST request may be rewritten to by MethodOverride.
+var overridableMethods = []string{http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// MethodOverride returns middleware that lets POST requests tunnel another method through the
+// X-HTTP-Method-Override header, or the _method field of a URL-encoded form, for clients stuck behind proxies
+// that only allow GET and POST. Only PUT, PATCH and DELETE may be requested; any other value is rejected with a
+// 400 JSON error. A form body is read under the Parser's size limit and put back for the handler.
+func (p *Parser) MethodOverride() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			method := r.Header.Get("X-HTTP-Method-Override")
+			if method == "" && mediaType(r.Header.Get("Content-Type")) == formMediaType && r.Body != nil {
+				maxBytes := p.maxPayload(r.Method)
+				body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(maxBytes)))
+				if err != nil {
+					_ = p.ErrorJSON(w, decodeError(err, maxBytes))
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+				// A body that is not a form, such as a compressed one, is left for the handler to reject.
+				if values, err := url.ParseQuery(string(body)); err == nil {
+					method = values.Get(methodOverrideField)
+				}
+			}
+
+			if method != "" {
+				method = strings.ToUpper(strings.TrimSpace(method))
+				if !containsFold(overridableMethods, method) {
+					_ = p.ErrorJSON(w, fmt.Errorf("method override %q is not allowed", method))
+					return
+				}
+				r.Method = method
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// mediaType returns the lower-cased media type of a Content-Type header, without parameters.
+func mediaType(contentType string) string {
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
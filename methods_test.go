@@ -0,0 +1,119 @@
+package ps
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var allowMethodsTests = []struct {
+	name        string
+	method      string
+	description any
+	status      int
+}{
+	{name: "allowed", method: http.MethodPost, status: http.StatusCreated},
+	{name: "implied head", method: http.MethodHead, status: http.StatusCreated},
+	{name: "options", method: http.MethodOptions, status: http.StatusNoContent},
+	{name: "options with description", method: http.MethodOptions, description: map[string]string{"summary": "Create a user"}, status: http.StatusOK},
+	{name: "not allowed", method: http.MethodDelete, status: http.StatusMethodNotAllowed},
+}
+
+func TestParser_AllowMethods(t *testing.T) {
+	var testParser Parser
+
+	for _, e := range allowMethodsTests {
+		handler := testParser.AllowMethods([]string{"get", http.MethodPost}, e.description)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		}))
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(e.method, "/users", nil))
+
+		if rr.Code != e.status {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.status, rr.Code)
+		}
+
+		if e.status == http.StatusCreated {
+			continue
+		}
+
+		if got := rr.Header().Get("Allow"); got != "GET, HEAD, POST, OPTIONS" {
+			t.Errorf("%s: wrong Allow header: %q", e.name, got)
+		}
+
+		if e.status == http.StatusMethodNotAllowed {
+			var payload JSONResponse
+			if err := json.NewDecoder(rr.Body).Decode(&payload); err != nil || !payload.Error {
+				t.Errorf("%s: expected a JSON error response, got %q", e.name, rr.Body.String())
+			}
+		}
+	}
+}
+
+var methodOverrideTests = []struct {
+	name     string
+	method   string
+	header   string
+	form     string
+	expected string
+	status   int
+}{
+	{name: "no override", method: http.MethodPost, expected: http.MethodPost, status: http.StatusOK},
+	{name: "header", method: http.MethodPost, header: "patch", expected: http.MethodPatch, status: http.StatusOK},
+	{name: "form field", method: http.MethodPost, form: "_method=DELETE", expected: http.MethodDelete, status: http.StatusOK},
+	{name: "only post is rewritten", method: http.MethodGet, header: "DELETE", expected: http.MethodGet, status: http.StatusOK},
+	{name: "disallowed method", method: http.MethodPost, header: "CONNECT", status: http.StatusBadRequest},
+}
+
+func TestParser_MethodOverride(t *testing.T) {
+	var testParser Parser
+
+	for _, e := range methodOverrideTests {
+		var got string
+		handler := testParser.MethodOverride()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.Method
+		}))
+
+		req := httptest.NewRequest(e.method, "/users/1", strings.NewReader(e.form))
+		if e.header != "" {
+			req.Header.Set("X-HTTP-Method-Override", e.header)
+		}
+		if e.form != "" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.status {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.status, rr.Code)
+		}
+		if got != e.expected {
+			t.Errorf("%s: expected method %q, got %q", e.name, e.expected, got)
+		}
+	}
+}
+
+func TestParser_MethodOverrideReadForm(t *testing.T) {
+	var testParser Parser
+	var got struct {
+		Name string `form:"name"`
+	}
+	var method string
+	var readErr error
+	handler := testParser.MethodOverride()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		readErr = testParser.ReadForm(w, r, &got)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/users/1", strings.NewReader("_method=PUT&name=Ann"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if method != http.MethodPut || readErr != nil || got.Name != "Ann" {
+		t.Errorf("expected PUT with the form bound, got %s %v %+v", method, readErr, got)
+	}
+}
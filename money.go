@@ -0,0 +1,96 @@
+package ps
+
+import (
+	"fmt"
+)
+
+// currencyScales lists the ISO 4217 currencies whose minor unit is not a hundredth.
+var currencyScales = map[string]int{
+	"BHD": 3, "BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "IQD": 3, "ISK": 0, "JOD": 3, "JPY": 0, "KMF": 0,
+	"KRW": 0, "KWD": 3, "LYD": 3, "OMR": 3, "PYG": 0, "RWF": 0, "TND": 3, "UGX": 0, "UYI": 0, "VND": 0,
+	"VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+}
+
+// CurrencyScale returns the number of decimal places in the minor unit of an ISO 4217 currency code: 2 for most
+// currencies, 0 for JPY, 3 for KWD.
+func CurrencyScale(currency string) int {
+	if scale, ok := currencyScales[currency]; ok {
+		return scale
+	}
+	return 2
+}
+
+// Money is an amount in a currency. It is encoded as {"amount":"12.50","currency":"EUR"}.
+type Money struct {
+	Amount   Decimal `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+// NewMoney parses amount and returns it in currency, which must be a three-letter ISO 4217 code.
+func NewMoney(amount, currency string) (Money, error) {
+	if !validCurrency(currency) {
+		return Money{}, fmt.Errorf("invalid currency code %q", currency)
+	}
+	d, err := ParseDecimal(amount)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: d, Currency: currency}, nil
+}
+
+// validCurrency reports whether code looks like an ISO 4217 code: three upper-case ASCII letters.
+func validCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for i := 0; i < len(code); i++ {
+		if code[i] < 'A' || code[i] > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the amount followed by the currency code, such as "12.50 EUR".
+func (m Money) String() string {
+	return m.Amount.String() + " " + m.Currency
+}
+
+// Round returns m rounded to the minor unit of its currency.
+func (m Money) Round() Money {
+	return Money{Amount: m.Amount.Round(CurrencyScale(m.Currency)), Currency: m.Currency}
+}
+
+// Add returns m + other. It fails if the currencies differ.
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("cannot add %s to %s", other.Currency, m.Currency)
+	}
+	return Money{Amount: m.Amount.Add(other.Amount), Currency: m.Currency}, nil
+}
+
+// Sub returns m - other. It fails if the currencies differ.
+func (m Money) Sub(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("cannot subtract %s from %s", other.Currency, m.Currency)
+	}
+	return Money{Amount: m.Amount.Sub(other.Amount), Currency: m.Currency}, nil
+}
+
+// Mul returns m multiplied by factor, such as a quantity or a tax rate, rounded to the minor unit of its
+// currency.
+func (m Money) Mul(factor Decimal) Money {
+	return Money{Amount: m.Amount.Mul(factor), Currency: m.Currency}.Round()
+}
+
+// Validate checks that the currency is a well-formed code and that the amount has no more decimal places than the
+// currency's minor unit.
+func (m Money) Validate() error {
+	if !validCurrency(m.Currency) {
+		return &FieldError{Field: "currency", Message: "must be a three-letter ISO 4217 code"}
+	}
+	if scale := CurrencyScale(m.Currency); m.Amount.Scale() > scale {
+		return &FieldError{Field: "amount", Message: fmt.Sprintf("must have at most %d decimal places for %s", scale, m.Currency)}
+	}
+	return nil
+}
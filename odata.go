@@ -0,0 +1,545 @@
+package ps
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxFilterDepth bounds the nesting of a $filter expression, so that a hostile query cannot exhaust the stack.
+const maxFilterDepth = 32
+
+// ODataOptions says which OData query options a request may use.
+type ODataOptions struct {
+	// Fields lists the fields that $filter, $select and $orderby may name. Paths into nested values are written
+	// with slashes, as OData writes them, such as "Address/City".
+	Fields []string
+	// MaxTop is the largest $top accepted, and the $top used when a request sends none; 0 leaves $top unlimited.
+	MaxTop int
+}
+
+// ODataQuery holds the OData query options of a request.
+type ODataQuery struct {
+	// Filter is the parsed $filter expression, or nil.
+	Filter FilterExpr
+	// Select lists the fields of $select, in the order given; it is empty if all fields are wanted.
+	Select []string
+	// OrderBy lists the sort keys of $orderby, most significant first.
+	OrderBy []OrderBy
+	// Top is the number of results to return, or -1 for no limit.
+	Top int
+	// Skip is the number of results to skip.
+	Skip int
+	// Count reports whether $count=true asked for the total number of results.
+	Count bool
+}
+
+// OrderBy is a sort key of $orderby.
+type OrderBy struct {
+	Field string
+	Desc  bool
+}
+
+// FilterExpr is a node of a parsed $filter expression: a *LogicalExpr, *NotExpr, *CompareExpr, *CallExpr,
+// *FieldExpr or *LiteralExpr.
+type FilterExpr interface {
+	fmt.Stringer
+	filterExpr()
+}
+
+// LogicalExpr joins two conditions with "and" or "or".
+type LogicalExpr struct {
+	Op          string
+	Left, Right FilterExpr
+}
+
+// NotExpr negates a condition.
+type NotExpr struct {
+	Expr FilterExpr
+}
+
+// CompareExpr compares two values with one of "eq", "ne", "gt", "ge", "lt" and "le".
+type CompareExpr struct {
+	Op          string
+	Left, Right FilterExpr
+}
+
+// CallExpr calls one of the functions listed in filterFuncs, such as contains or tolower.
+type CallExpr struct {
+	Func string
+	Args []FilterExpr
+}
+
+// FieldExpr names a field, one of the allowed Fields.
+type FieldExpr struct {
+	Name string
+}
+
+// LiteralExpr is a constant: a string, int64, float64, bool, Date, time.Time, or nil for null.
+type LiteralExpr struct {
+	Value any
+}
+
+func (*LogicalExpr) filterExpr() {}
+func (*NotExpr) filterExpr()     {}
+func (*CompareExpr) filterExpr() {}
+func (*CallExpr) filterExpr()    {}
+func (*FieldExpr) filterExpr()   {}
+func (*LiteralExpr) filterExpr() {}
+
+// String returns the expression in OData syntax, parenthesized.
+func (e *LogicalExpr) String() string {
+	return "(" + e.Left.String() + " " + e.Op + " " + e.Right.String() + ")"
+}
+
+// String returns the expression in OData syntax.
+func (e *NotExpr) String() string {
+	return "not " + e.Expr.String()
+}
+
+// String returns the expression in OData syntax, parenthesized.
+func (e *CompareExpr) String() string {
+	return "(" + e.Left.String() + " " + e.Op + " " + e.Right.String() + ")"
+}
+
+// String returns the call in OData syntax.
+func (e *CallExpr) String() string {
+	args := make([]string, len(e.Args))
+	for i, a := range e.Args {
+		args[i] = a.String()
+	}
+	return e.Func + "(" + strings.Join(args, ",") + ")"
+}
+
+// String returns the name of the field.
+func (e *FieldExpr) String() string {
+	return e.Name
+}
+
+// String returns the value in OData syntax.
+func (e *LiteralExpr) String() string {
+	switch v := e.Value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// filterFuncs are the functions a $filter may call, with their number of arguments.
+var filterFuncs = map[string]int{
+	"contains": 2, "startswith": 2, "endswith": 2,
+	"tolower": 1, "toupper": 1, "trim": 1, "length": 1,
+	"year": 1, "month": 1, "day": 1,
+}
+
+// ParseOData parses the OData query options in query: $filter, $select, $orderby, $top, $skip and $count. Other
+// options that start with "$" are refused, and parameters without a "$" are left to the caller. Every problem is
+// reported, in a *ValidationError whose field errors name the option.
+func ParseOData(query url.Values, opts ODataOptions) (*ODataQuery, error) {
+	q := &ODataQuery{Top: -1}
+	if opts.MaxTop > 0 {
+		q.Top = opts.MaxTop
+	}
+
+	allowed := make(map[string]bool, len(opts.Fields))
+	for _, f := range opts.Fields {
+		allowed[f] = true
+	}
+
+	var errs []FieldError
+	fail := func(option, format string, args ...any) {
+		errs = append(errs, FieldError{Field: option, Message: fmt.Sprintf(format, args...)})
+	}
+
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !strings.HasPrefix(name, "$") {
+			continue
+		}
+		values := query[name]
+		if len(values) > 1 {
+			fail(name, "must not be given more than once")
+			continue
+		}
+		value := strings.TrimSpace(values[0])
+
+		switch name {
+		case "$filter":
+			expr, err := parseFilter(value, allowed)
+			if err != nil {
+				fail(name, "%s", err)
+				continue
+			}
+			q.Filter = expr
+
+		case "$select":
+			for _, f := range splitList(value) {
+				if !allowed[f] {
+					fail(name, "cannot select %q", f)
+					continue
+				}
+				q.Select = append(q.Select, f)
+			}
+
+		case "$orderby":
+			for _, item := range splitList(value) {
+				f, dir, _ := strings.Cut(item, " ")
+				key := OrderBy{Field: f}
+				switch strings.TrimSpace(dir) {
+				case "", "asc":
+				case "desc":
+					key.Desc = true
+				default:
+					fail(name, "must order %q by asc or desc, got %q", f, strings.TrimSpace(dir))
+					continue
+				}
+				if !allowed[f] {
+					fail(name, "cannot order by %q", f)
+					continue
+				}
+				q.OrderBy = append(q.OrderBy, key)
+			}
+
+		case "$top":
+			n, err := strconv.Atoi(value)
+			switch {
+			case err != nil || n < 0:
+				fail(name, "must be a non-negative integer")
+			case opts.MaxTop > 0 && n > opts.MaxTop:
+				fail(name, "must be at most %d", opts.MaxTop)
+			default:
+				q.Top = n
+			}
+
+		case "$skip":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				fail(name, "must be a non-negative integer")
+				continue
+			}
+			q.Skip = n
+
+		case "$count":
+			switch value {
+			case "true":
+				q.Count = true
+			case "false":
+			default:
+				fail(name, "must be true or false")
+			}
+
+		default:
+			fail(name, "is not supported")
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, &ValidationError{Fields: errs}
+	}
+	return q, nil
+}
+
+// splitList splits a comma-separated option value, dropping the spaces around each item.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// filterToken is a token of a $filter expression. kind is one of '(', ')', ',', 's' for a string literal, 'w' for
+// a word and 'n' for a number or date.
+type filterToken struct {
+	kind byte
+	text string
+	pos  int
+}
+
+// lexFilter splits a $filter expression into tokens.
+func lexFilter(s string) ([]filterToken, error) {
+	var tokens []filterToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+
+		case c == '(' || c == ')' || c == ',':
+			tokens = append(tokens, filterToken{kind: c, text: string(c), pos: i})
+			i++
+
+		case c == '\'':
+			// Quotes inside a string are doubled.
+			var b strings.Builder
+			j := i + 1
+			for {
+				if j >= len(s) {
+					return nil, fmt.Errorf("has an unterminated string at %d", i+1)
+				}
+				if s[j] == '\'' {
+					if j+1 < len(s) && s[j+1] == '\'' {
+						b.WriteByte('\'')
+						j += 2
+						continue
+					}
+					break
+				}
+				b.WriteByte(s[j])
+				j++
+			}
+			tokens = append(tokens, filterToken{kind: 's', text: b.String(), pos: i})
+			i = j + 1
+
+		case isDigit(c) || (c == '-' && i+1 < len(s) && isDigit(s[i+1])):
+			j := i + 1
+			for j < len(s) && (isDigit(s[j]) || strings.IndexByte(".-+:TZeE", s[j]) >= 0) {
+				j++
+			}
+			tokens = append(tokens, filterToken{kind: 'n', text: s[i:j], pos: i})
+			i = j
+
+		case isWordByte(c):
+			j := i + 1
+			for j < len(s) && (isWordByte(s[j]) || isDigit(s[j]) || s[j] == '/') {
+				j++
+			}
+			tokens = append(tokens, filterToken{kind: 'w', text: s[i:j], pos: i})
+			i = j
+
+		default:
+			return nil, fmt.Errorf("has an unexpected %q at %d", c, i+1)
+		}
+	}
+	return tokens, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// filterParser parses a $filter expression by recursive descent. From loosest to tightest, the operators bind
+// as: or, and, not, then the comparisons.
+type filterParser struct {
+	tokens  []filterToken
+	pos     int
+	depth   int
+	allowed map[string]bool
+}
+
+// parseFilter parses s, checking that it only names allowed fields.
+func parseFilter(s string, allowed map[string]bool) (FilterExpr, error) {
+	if s == "" {
+		return nil, errors.New("must not be empty")
+	}
+	tokens, err := lexFilter(s)
+	if err != nil {
+		return nil, err
+	}
+
+	fp := &filterParser{tokens: tokens, allowed: allowed}
+	expr, err := fp.or()
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := fp.peek(); ok {
+		return nil, fmt.Errorf("has an unexpected %q at %d", t.text, t.pos+1)
+	}
+	return expr, nil
+}
+
+func (fp *filterParser) peek() (filterToken, bool) {
+	if fp.pos >= len(fp.tokens) {
+		return filterToken{}, false
+	}
+	return fp.tokens[fp.pos], true
+}
+
+// keyword reports whether the next token is the word w, and consumes it if so.
+func (fp *filterParser) keyword(w string) bool {
+	if t, ok := fp.peek(); ok && t.kind == 'w' && t.text == w {
+		fp.pos++
+		return true
+	}
+	return false
+}
+
+// expect consumes the next token, which must be of the given kind.
+func (fp *filterParser) expect(kind byte) error {
+	t, ok := fp.peek()
+	if !ok {
+		return fmt.Errorf("ends where %q was expected", kind)
+	}
+	if t.kind != kind {
+		return fmt.Errorf("has %q at %d where %q was expected", t.text, t.pos+1, kind)
+	}
+	fp.pos++
+	return nil
+}
+
+func (fp *filterParser) or() (FilterExpr, error) {
+	left, err := fp.and()
+	for err == nil && fp.keyword("or") {
+		var right FilterExpr
+		if right, err = fp.and(); err == nil {
+			left = &LogicalExpr{Op: "or", Left: left, Right: right}
+		}
+	}
+	return left, err
+}
+
+func (fp *filterParser) and() (FilterExpr, error) {
+	left, err := fp.not()
+	for err == nil && fp.keyword("and") {
+		var right FilterExpr
+		if right, err = fp.not(); err == nil {
+			left = &LogicalExpr{Op: "and", Left: left, Right: right}
+		}
+	}
+	return left, err
+}
+
+func (fp *filterParser) not() (FilterExpr, error) {
+	if fp.keyword("not") {
+		if fp.depth++; fp.depth > maxFilterDepth {
+			return nil, fmt.Errorf("must not nest more than %d levels deep", maxFilterDepth)
+		}
+		defer func() { fp.depth-- }()
+		expr, err := fp.not()
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{Expr: expr}, nil
+	}
+	return fp.comparison()
+}
+
+func (fp *filterParser) comparison() (FilterExpr, error) {
+	left, err := fp.operand()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"eq", "ne", "gt", "ge", "lt", "le"} {
+		if fp.keyword(op) {
+			right, err := fp.operand()
+			if err != nil {
+				return nil, err
+			}
+			return &CompareExpr{Op: op, Left: left, Right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+// operand parses a parenthesized expression, a function call, a field or a literal.
+func (fp *filterParser) operand() (FilterExpr, error) {
+	t, ok := fp.peek()
+	if !ok {
+		return nil, errors.New("ends where a value was expected")
+	}
+	fp.pos++
+
+	switch t.kind {
+	case '(':
+		if fp.depth++; fp.depth > maxFilterDepth {
+			return nil, fmt.Errorf("must not nest more than %d levels deep", maxFilterDepth)
+		}
+		defer func() { fp.depth-- }()
+		expr, err := fp.or()
+		if err != nil {
+			return nil, err
+		}
+		return expr, fp.expect(')')
+
+	case 's':
+		return &LiteralExpr{Value: t.text}, nil
+
+	case 'n':
+		v, err := parseFilterNumber(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("has an invalid number or date %q at %d", t.text, t.pos+1)
+		}
+		return &LiteralExpr{Value: v}, nil
+
+	case 'w':
+		switch t.text {
+		case "true":
+			return &LiteralExpr{Value: true}, nil
+		case "false":
+			return &LiteralExpr{Value: false}, nil
+		case "null":
+			return &LiteralExpr{Value: nil}, nil
+		}
+		if next, ok := fp.peek(); ok && next.kind == '(' {
+			return fp.call(t)
+		}
+		if !fp.allowed[t.text] {
+			return nil, fmt.Errorf("cannot filter on %q", t.text)
+		}
+		return &FieldExpr{Name: t.text}, nil
+	}
+	return nil, fmt.Errorf("has an unexpected %q at %d", t.text, t.pos+1)
+}
+
+// call parses the arguments of a call to the function named by t.
+func (fp *filterParser) call(t filterToken) (FilterExpr, error) {
+	arity, ok := filterFuncs[t.text]
+	if !ok {
+		return nil, fmt.Errorf("calls unknown function %q", t.text)
+	}
+	fp.pos++ // the '('
+
+	call := &CallExpr{Func: t.text}
+	for {
+		arg, err := fp.or()
+		if err != nil {
+			return nil, err
+		}
+		call.Args = append(call.Args, arg)
+		if next, ok := fp.peek(); !ok || next.kind != ',' {
+			break
+		}
+		fp.pos++
+	}
+	if err := fp.expect(')'); err != nil {
+		return nil, err
+	}
+	if len(call.Args) != arity {
+		return nil, fmt.Errorf("calls %s with %d arguments instead of %d", t.text, len(call.Args), arity)
+	}
+	return call, nil
+}
+
+// parseFilterNumber parses an unquoted literal: an integer, a decimal, a date or a date and time.
+func parseFilterNumber(s string) (any, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, nil
+	}
+	if d, err := ParseDate(s); err == nil {
+		return d, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
@@ -0,0 +1,119 @@
+package ps
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testODataOptions = ODataOptions{Fields: []string{"Name", "Price", "Created", "Address/City"}, MaxTop: 100}
+
+var parseFilterTests = []struct {
+	name          string
+	filter        string
+	expected      string
+	expectedError string
+}{
+	{name: "comparison", filter: "Price gt 10", expected: "(Price gt 10)"},
+	{name: "precedence", filter: "Price lt 5 or Price gt 10 and Name eq 'a'", expected: "((Price lt 5) or ((Price gt 10) and (Name eq 'a')))"},
+	{name: "parentheses", filter: "(Price lt 5 or Price gt 10) and not (Name eq null)", expected: "(((Price lt 5) or (Price gt 10)) and not (Name eq null))"},
+	{name: "functions", filter: "contains(tolower(Name),'o''brien') and length(Address/City) ge 3", expected: "(contains(tolower(Name),'o''brien') and (length(Address/City) ge 3))"},
+	{name: "literals", filter: "Price eq -1.5 or Created ge 2024-01-31 or Created lt 2024-02-01T10:00:00Z", expected: "(((Price eq -1.5) or (Created ge 2024-01-31)) or (Created lt 2024-02-01T10:00:00Z))"},
+	{name: "field not allowed", filter: "Cost gt 10", expectedError: `cannot filter on "Cost"`},
+	{name: "unknown function", filter: "substringof('a',Name)", expectedError: `calls unknown function "substringof"`},
+	{name: "arity", filter: "contains(Name)", expectedError: "calls contains with 1 arguments instead of 2"},
+	{name: "unterminated string", filter: "Name eq 'a", expectedError: "has an unterminated string at 9"},
+	{name: "unbalanced", filter: "(Price gt 1", expectedError: "ends where ')' was expected"},
+	{name: "trailing", filter: "Price gt 1 Price", expectedError: `has an unexpected "Price" at 12`},
+	{name: "bad number", filter: "Price gt 1.2.3", expectedError: `has an invalid number or date "1.2.3" at 10`},
+	{name: "too deep", filter: strings.Repeat("(", 40) + "Price gt 1" + strings.Repeat(")", 40), expectedError: "must not nest more than 32 levels deep"},
+}
+
+func TestParseODataFilter(t *testing.T) {
+	for _, e := range parseFilterTests {
+		q, err := ParseOData(url.Values{"$filter": {e.filter}}, testODataOptions)
+
+		if e.expectedError != "" {
+			if err == nil || !strings.Contains(err.Error(), e.expectedError) {
+				t.Errorf("%s: expected an error containing %q, got %v", e.name, e.expectedError, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", e.name, err)
+			continue
+		}
+		if q.Filter.String() != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, q.Filter)
+		}
+	}
+}
+
+func TestParseODataLiterals(t *testing.T) {
+	q, err := ParseOData(url.Values{"$filter": {"Created ge 2024-01-31 and Created lt 2024-02-01T10:00:00Z and Price eq 7"}}, testODataOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	and := q.Filter.(*LogicalExpr)
+	date := and.Left.(*LogicalExpr).Left.(*CompareExpr).Right.(*LiteralExpr).Value
+	instant := and.Left.(*LogicalExpr).Right.(*CompareExpr).Right.(*LiteralExpr).Value
+	price := and.Right.(*CompareExpr).Right.(*LiteralExpr).Value
+	if date != (Date{2024, time.January, 31}) {
+		t.Errorf("expected a Date, got %#v", date)
+	}
+	if ts, ok := instant.(time.Time); !ok || !ts.Equal(time.Date(2024, 2, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected a time.Time, got %#v", instant)
+	}
+	if price != int64(7) {
+		t.Errorf("expected int64 7, got %#v", price)
+	}
+}
+
+func TestParseOData(t *testing.T) {
+	q, err := ParseOData(url.Values{
+		"$select":  {"Name, Price"},
+		"$orderby": {"Price desc,Name"},
+		"$top":     {"20"},
+		"$skip":    {"40"},
+		"$count":   {"true"},
+		"page":     {"ignored"},
+	}, testODataOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(q.Select, ",") != "Name,Price" || len(q.OrderBy) != 2 || q.OrderBy[0] != (OrderBy{"Price", true}) ||
+		q.OrderBy[1] != (OrderBy{"Name", false}) || q.Top != 20 || q.Skip != 40 || !q.Count || q.Filter != nil {
+		t.Errorf("unexpected query %+v", q)
+	}
+
+	if q, _ := ParseOData(url.Values{}, ODataOptions{}); q.Top != -1 {
+		t.Errorf("expected no limit, got %d", q.Top)
+	}
+	if q, _ := ParseOData(url.Values{}, testODataOptions); q.Top != 100 {
+		t.Errorf("expected the default limit, got %d", q.Top)
+	}
+}
+
+func TestParseODataErrors(t *testing.T) {
+	_, err := ParseOData(url.Values{
+		"$select":  {"Name,Secret"},
+		"$orderby": {"Price sideways"},
+		"$top":     {"500"},
+		"$skip":    {"-1"},
+		"$expand":  {"Owner"},
+		"$count":   {"yes"},
+	}, testODataOptions)
+
+	var validationError *ValidationError
+	if !errors.As(err, &validationError) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	expected := `$count must be true or false; $expand is not supported; $orderby must order "Price" by asc or desc, got "sideways"; ` +
+		`$select cannot select "Secret"; $skip must be a non-negative integer; $top must be at most 100`
+	if err.Error() != expected {
+		t.Errorf("expected %s, got %s", expected, err)
+	}
+}
@@ -0,0 +1,582 @@
+package ps
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// OpenAPI is an OpenAPI 3.1 document. It covers the parts this package generates and checks: operations with
+// their parameters and JSON bodies, and component schemas.
+type OpenAPI struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       OpenAPIInfo          `json:"info"`
+	Paths      map[string]*PathItem `json:"paths,omitempty"`
+	Components OpenAPIComponents    `json:"components"`
+}
+
+// OpenAPIInfo is the info object of an OpenAPI document.
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenAPIComponents holds the reusable schemas of an OpenAPI document, by name.
+type OpenAPIComponents struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// PathItem holds the operations of one path, by method, and what they share.
+type PathItem struct {
+	Summary     string `json:"summary,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	Get     *Operation `json:"get,omitempty"`
+	Put     *Operation `json:"put,omitempty"`
+	Post    *Operation `json:"post,omitempty"`
+	Delete  *Operation `json:"delete,omitempty"`
+	Options *Operation `json:"options,omitempty"`
+	Head    *Operation `json:"head,omitempty"`
+	Patch   *Operation `json:"patch,omitempty"`
+	Trace   *Operation `json:"trace,omitempty"`
+
+	Servers []OpenAPIServer `json:"servers,omitempty"`
+	// Parameters apply to every operation of the path, unless the operation has its own with the same name and
+	// location.
+	Parameters []Parameter `json:"parameters,omitempty"`
+}
+
+// OpenAPIServer is a server an API, or one of its paths, is served from.
+type OpenAPIServer struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+// pathMethods are the methods a PathItem has operations for, in the order Methods lists them.
+var pathMethods = []string{http.MethodDelete, http.MethodGet, http.MethodHead, http.MethodOptions,
+	http.MethodPatch, http.MethodPost, http.MethodPut, http.MethodTrace}
+
+// Operation returns the operation for method, in any case, or nil if there is none.
+func (item *PathItem) Operation(method string) *Operation {
+	if slot := item.slot(method); slot != nil {
+		return *slot
+	}
+	return nil
+}
+
+// Methods returns the upper-case methods the path has operations for, sorted.
+func (item *PathItem) Methods() []string {
+	var methods []string
+	for _, m := range pathMethods {
+		if item.Operation(m) != nil {
+			methods = append(methods, m)
+		}
+	}
+	return methods
+}
+
+// slot returns the field holding the operation for method, or nil for a method OpenAPI does not describe.
+func (item *PathItem) slot(method string) **Operation {
+	switch strings.ToUpper(method) {
+	case http.MethodGet:
+		return &item.Get
+	case http.MethodPut:
+		return &item.Put
+	case http.MethodPost:
+		return &item.Post
+	case http.MethodDelete:
+		return &item.Delete
+	case http.MethodOptions:
+		return &item.Options
+	case http.MethodHead:
+		return &item.Head
+	case http.MethodPatch:
+		return &item.Patch
+	case http.MethodTrace:
+		return &item.Trace
+	}
+	return nil
+}
+
+// parameters returns the parameters of op, an operation of the path: its own, and those of the path it does not
+// override.
+func (item *PathItem) parameters(op *Operation) []Parameter {
+	if len(item.Parameters) == 0 {
+		return op.Parameters
+	}
+	params := slices.Clone(op.Parameters)
+	for _, shared := range item.Parameters {
+		if !slices.ContainsFunc(op.Parameters, func(own Parameter) bool {
+			return own.Name == shared.Name && own.In == shared.In
+		}) {
+			params = append(params, shared)
+		}
+	}
+	return params
+}
+
+// Operation describes what one method of one path takes and returns.
+type Operation struct {
+	OperationID string               `json:"operationId,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter is a path, query or header parameter of an operation.
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema,omitempty"`
+}
+
+// RequestBody describes the body an operation accepts, by media type.
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes one response of an operation, by media type.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType gives the schema of a body in one media type.
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Schema is a JSON Schema, as used by OpenAPI 3.1. The zero value accepts anything. A schema given as false in a
+// document is read as {"not": {}}, which accepts nothing.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 SchemaType         `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	ContentEncoding      string             `json:"contentEncoding,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	Not                  *Schema            `json:"not,omitempty"`
+	ReadOnly             bool               `json:"readOnly,omitempty"`
+	WriteOnly            bool               `json:"writeOnly,omitempty"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting the boolean schemas true and false as well as objects.
+func (s *Schema) UnmarshalJSON(b []byte) error {
+	switch string(bytes.TrimSpace(b)) {
+	case "true":
+		*s = Schema{}
+		return nil
+	case "false":
+		*s = Schema{Not: &Schema{}}
+		return nil
+	}
+
+	type schema Schema
+	return json.Unmarshal(b, (*schema)(s))
+}
+
+// SchemaType is the type keyword of a Schema: one JSON type, or several, such as ["string", "null"].
+type SchemaType []string
+
+// MarshalJSON implements json.Marshaler, writing a single type as a string.
+func (t SchemaType) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting a string or an array of strings.
+func (t *SchemaType) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*t = SchemaType{s}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(t))
+}
+
+// componentsPrefix starts the $ref of a component schema.
+const componentsPrefix = "#/components/schemas/"
+
+var (
+	dateType      = reflect.TypeOf(Date{})
+	timeOfDayType = reflect.TypeOf(TimeOfDay{})
+	uuidType      = reflect.TypeOf(UUID{})
+	decimalType   = reflect.TypeOf(Decimal{})
+	rawType       = reflect.TypeOf(json.RawMessage(nil))
+	errorType     = reflect.TypeOf(JSONResponse{})
+)
+
+// APISpec builds an OpenAPI document from the Go types a Parser reads and writes, so that the document cannot
+// drift from them. Schemas follow the Parser's settings: field names come from its TagName, times and durations
+// take its formats, objects are closed unless it allows unknown fields, and the required, format and decimal struct
+// tags are honored.
+//
+// Build the document at start-up; an APISpec is not safe to change while it is being served.
+type APISpec struct {
+	parser *Parser
+	doc    OpenAPI
+	names  map[reflect.Type]string
+}
+
+// NewAPISpec returns an empty APISpec for an API called title, at version.
+func (p *Parser) NewAPISpec(title, version string) *APISpec {
+	return &APISpec{
+		parser: p,
+		doc: OpenAPI{
+			OpenAPI:    "3.1.0",
+			Info:       OpenAPIInfo{Title: title, Version: version},
+			Paths:      make(map[string]*PathItem),
+			Components: OpenAPIComponents{Schemas: make(map[string]*Schema)},
+		},
+		names: make(map[reflect.Type]string),
+	}
+}
+
+// Register adds the struct types of values, and the named struct types they use, to the component schemas.
+func (s *APISpec) Register(values ...any) {
+	for _, v := range values {
+		s.SchemaOf(v)
+	}
+}
+
+// SchemaOf returns the schema of the type of v, adding the named struct types it uses to the component schemas.
+// Pointers are followed, so v can be a nil pointer of the type to describe.
+func (s *APISpec) SchemaOf(v any) *Schema {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return &Schema{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return s.schema(t, nil)
+}
+
+// Operation adds the operation method on the path matched by pattern and returns it, for the caller to add a
+// summary or parameters. The pattern is in the form http.ServeMux uses, such as /users/{id}, without the method,
+// and each wildcard becomes a path parameter. A method OpenAPI has no field for, such as CONNECT, is left out of
+// the document.
+// request, if not nil, is the body the operation reads, accepted in the media types the Parser's Methods allow.
+// response, if not nil, is what it writes with a 200 status; otherwise it answers 204. Errors are described by the
+// envelope ErrorJSON sends.
+func (s *APISpec) Operation(method, pattern string, request, response any) *Operation {
+	pattern = strings.TrimSuffix(pattern, "{$}")
+	op := &Operation{Parameters: pathParameters(pattern), Responses: make(map[string]*Response)}
+
+	if request != nil {
+		policy := s.parser.Methods[method]
+		types := policy.ContentTypes
+		if len(types) == 0 {
+			types = []string{"application/json"}
+		}
+
+		schema := s.SchemaOf(request)
+		op.RequestBody = &RequestBody{Required: policy.Body != BodyOptional, Content: make(map[string]MediaType)}
+		for _, mt := range types {
+			op.RequestBody.Content[mt] = MediaType{Schema: schema}
+		}
+	}
+
+	if response != nil {
+		op.Responses["200"] = &Response{
+			Description: http.StatusText(http.StatusOK),
+			Content:     map[string]MediaType{"application/json": {Schema: s.SchemaOf(response)}},
+		}
+	} else {
+		op.Responses["204"] = &Response{Description: http.StatusText(http.StatusNoContent)}
+	}
+	op.Responses["default"] = &Response{
+		Description: "Error",
+		Content:     map[string]MediaType{"application/json": {Schema: s.schema(errorType, nil)}},
+	}
+
+	path := strings.ReplaceAll(pattern, "...}", "}")
+	item := s.doc.Paths[path]
+	if item == nil {
+		item = &PathItem{}
+		s.doc.Paths[path] = item
+	}
+	if slot := item.slot(method); slot != nil {
+		*slot = op
+	}
+
+	return op
+}
+
+// Document returns the document built so far.
+func (s *APISpec) Document() *OpenAPI {
+	return &s.doc
+}
+
+// ServeHTTP writes the document as JSON with the Parser's WriteJSON. See APIDocs for serving it with a
+// documentation page.
+func (s *APISpec) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.parser.serveSpec(w, r, &s.doc)
+}
+
+// pathParameters returns a required string parameter for each wildcard in a ServeMux pattern.
+func pathParameters(pattern string) []Parameter {
+	var params []Parameter
+	for {
+		_, rest, ok := strings.Cut(pattern, "{")
+		if !ok {
+			return params
+		}
+		var name string
+		name, pattern, _ = strings.Cut(rest, "}")
+		params = append(params, Parameter{
+			Name:     strings.TrimSuffix(name, "..."),
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: SchemaType{"string"}},
+		})
+	}
+}
+
+// schema returns the schema of type t, for a value held in field f if f is not nil.
+func (s *APISpec) schema(t reflect.Type, f *field) *Schema {
+	var format string
+	if f != nil {
+		format = f.format
+	}
+
+	switch t {
+	case timeType:
+		if format == "" {
+			format = s.parser.TimeFormat
+		}
+		switch format {
+		case "", TimeRFC3339:
+			return &Schema{Type: SchemaType{"string"}, Format: "date-time"}
+		case TimeUnix, TimeUnixMilli:
+			return &Schema{Type: SchemaType{"integer"}, Format: "int64"}
+		}
+		return &Schema{Type: SchemaType{"string"}}
+
+	case durationType:
+		if format == "" {
+			format = s.parser.DurationFormat
+		}
+		switch format {
+		case DurationString:
+			return &Schema{Type: SchemaType{"string"}}
+		case DurationSeconds:
+			return &Schema{Type: SchemaType{"number"}}
+		}
+		return &Schema{Type: SchemaType{"integer"}, Format: "int64"}
+
+	case dateType:
+		return &Schema{Type: SchemaType{"string"}, Format: "date"}
+	case timeOfDayType:
+		return &Schema{Type: SchemaType{"string"}, Pattern: `^([01][0-9]|2[0-3]):[0-5][0-9]:[0-5][0-9]$`}
+	case uuidType:
+		return &Schema{Type: SchemaType{"string"}, Format: "uuid"}
+	case decimalType:
+		return decimalSchema(f)
+	case numberType:
+		return &Schema{Type: SchemaType{"number"}}
+	case rawType:
+		return &Schema{}
+	}
+
+	if marshals(t) {
+		if p := reflect.PointerTo(t); !t.Implements(marshalerType) && !p.Implements(marshalerType) {
+			return &Schema{Type: SchemaType{"string"}}
+		}
+		return &Schema{}
+	}
+
+	quoted := f != nil && f.quoted
+	switch t.Kind() {
+	case reflect.Bool:
+		if quoted {
+			return &Schema{Type: SchemaType{"string"}, Enum: []any{"true", "false"}}
+		}
+		return &Schema{Type: SchemaType{"boolean"}}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if quoted || quotesInt(t, format, s.parser.Int64AsString) {
+			return &Schema{Type: SchemaType{"string"}, Pattern: `^-?[0-9]+$`}
+		}
+		schema := &Schema{Type: SchemaType{"integer"}, Format: "int64"}
+		if t.Bits() <= 32 {
+			schema.Format = "int32"
+		}
+		if t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uintptr {
+			zero := 0.0
+			schema.Minimum = &zero
+		}
+		return schema
+
+	case reflect.Float32, reflect.Float64:
+		schema := &Schema{Type: SchemaType{"number"}, Format: "double"}
+		if t.Kind() == reflect.Float32 {
+			schema.Format = "float"
+		}
+		switch {
+		case quoted:
+			schema.Type = SchemaType{"string"}
+		case s.parser.NonFinite == NullNonFinite:
+			schema.Type = append(schema.Type, "null")
+		case s.parser.NonFinite == StringNonFinite:
+			schema.Type = append(schema.Type, "string")
+		}
+		return schema
+
+	case reflect.String:
+		return &Schema{Type: SchemaType{"string"}}
+
+	case reflect.Pointer:
+		return nullable(s.schema(t.Elem(), f))
+
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 && !marshals(reflect.PointerTo(t.Elem())) {
+			return &Schema{Type: SchemaType{"string"}, ContentEncoding: "base64"}
+		}
+		return &Schema{Type: SchemaType{"array"}, Items: s.schema(t.Elem(), nil)}
+
+	case reflect.Array:
+		n := t.Len()
+		return &Schema{Type: SchemaType{"array"}, Items: s.schema(t.Elem(), nil), MinItems: &n, MaxItems: &n}
+
+	case reflect.Map:
+		return &Schema{Type: SchemaType{"object"}, AdditionalProperties: s.schema(t.Elem(), nil)}
+
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return s.component(t)
+	}
+
+	// Interfaces can hold anything; channels and functions cannot be encoded at all.
+	return &Schema{}
+}
+
+// component returns a reference to the component schema of the named struct type t, adding it if need be.
+func (s *APISpec) component(t reflect.Type) *Schema {
+	name, ok := s.names[t]
+	if !ok {
+		name = s.componentName(t)
+		s.names[t] = name
+		// Reserve the name first, so that a type which refers to itself finds it.
+		s.doc.Components.Schemas[name] = &Schema{}
+		s.doc.Components.Schemas[name] = s.object(t)
+	}
+	return &Schema{Ref: componentsPrefix + name}
+}
+
+// componentName picks an unused component name for t: its type name, qualified by its package if another type
+// has that name, and numbered as a last resort. Characters OpenAPI does not allow in names become underscores.
+func (s *APISpec) componentName(t reflect.Type) string {
+	clean := func(name string) string {
+		return strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+				return r
+			}
+			return '_'
+		}, name)
+	}
+
+	taken := func(name string) bool {
+		_, ok := s.doc.Components.Schemas[name]
+		return ok
+	}
+
+	name := clean(t.Name())
+	if !taken(name) {
+		return name
+	}
+	name = clean(path.Base(t.PkgPath()) + "." + t.Name())
+	for i := 2; taken(name); i++ {
+		name = fmt.Sprintf("%s%d", strings.TrimRight(name, "0123456789"), i)
+	}
+	return name
+}
+
+// object returns the schema of struct type t, with a property for each field encoding/json would read.
+func (s *APISpec) object(t reflect.Type) *Schema {
+	info := s.parser.encodeOptions().fields(t)
+
+	schema := &Schema{Type: SchemaType{"object"}, Properties: make(map[string]*Schema, len(info.fields))}
+	for i := range info.fields {
+		f := &info.fields[i]
+		prop := s.schema(f.typ, f)
+		if len(f.enum) > 0 {
+			withEnum(prop, f.enum)
+		}
+		prop.ReadOnly, prop.WriteOnly = f.readOnly, f.writeOnly
+		schema.Properties[f.name] = prop
+		if f.required {
+			schema.Required = append(schema.Required, f.name)
+		}
+	}
+	if !s.parser.AllowUnknownFields {
+		schema.AdditionalProperties = &Schema{Not: &Schema{}}
+	}
+
+	return schema
+}
+
+// withEnum limits a string schema, or the string items of an array schema, to the values of an enum tag.
+func withEnum(schema *Schema, enum []string) {
+	if schema.Items != nil {
+		schema = schema.Items
+	}
+	if schema.Ref != "" || !slices.Contains(schema.Type, "string") {
+		return
+	}
+	schema.Enum = make([]any, len(enum))
+	for i, v := range enum {
+		schema.Enum[i] = v
+	}
+}
+
+// nullable returns schema extended to accept null.
+func nullable(schema *Schema) *Schema {
+	switch {
+	case schema.Ref != "":
+		return &Schema{AnyOf: []*Schema{schema, {Type: SchemaType{"null"}}}}
+	case len(schema.Type) > 0:
+		schema.Type = append(schema.Type, "null")
+	}
+	return schema
+}
+
+// decimalSchema returns the schema of a Decimal held in field f, whose decimal tag limits its digits. Decimals are
+// written as strings but read from numbers too.
+func decimalSchema(f *field) *Schema {
+	schema := &Schema{Type: SchemaType{"string", "number"}, Pattern: `^-?[0-9]+(\.[0-9]+)?$`}
+	if f != nil && f.decimal.precision > 0 {
+		whole, places := f.decimal.precision-f.decimal.scale, f.decimal.scale
+		schema.Pattern = fmt.Sprintf(`^-?[0-9]{1,%d}`, max(whole, 1))
+		if places > 0 {
+			schema.Pattern += fmt.Sprintf(`(\.[0-9]{1,%d})?`, places)
+		}
+		schema.Pattern += "$"
+	}
+	return schema
+}
@@ -0,0 +1,186 @@
+package ps
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type specOwner struct {
+	ID   UUID   `json:"id"`
+	Name string `json:"name,required"`
+}
+
+type specOrder struct {
+	ID        int64          `json:"id" format:"string"`
+	Owner     *specOwner     `json:"owner"`
+	Total     Decimal        `json:"total" decimal:"10,2"`
+	Placed    time.Time      `json:"placed" format:"unix"`
+	Due       Date           `json:"due"`
+	Tags      []string       `json:"tags,omitempty"`
+	Meta      map[string]int `json:"meta"`
+	Parent    *specOrder     `json:"parent,omitempty"`
+	Count     uint8          `json:"count"`
+	Extra     any            `json:"extra"`
+	internal  bool
+	Cancelled bool `json:"-"`
+}
+
+var specSchemaTests = []struct {
+	name     string
+	parser   Parser
+	value    any
+	expected string
+}{
+	{name: "string", value: "", expected: `{"type":"string"}`},
+	{name: "pointer", value: new(*float64), expected: `{"type":"number","format":"double"}`},
+	{name: "slice of pointers", value: []*int32{}, expected: `{"type":"array","items":{"type":["integer","null"],"format":"int32"}}`},
+	{name: "bytes", value: []byte{}, expected: `{"type":"string","contentEncoding":"base64"}`},
+	{name: "array", value: [2]bool{}, expected: `{"type":"array","items":{"type":"boolean"},"minItems":2,"maxItems":2}`},
+	{name: "int64 as string", parser: Parser{Int64AsString: true}, value: int64(0), expected: `{"type":"string","pattern":"^-?[0-9]+$"}`},
+	{name: "duration", value: time.Duration(0), expected: `{"type":"integer","format":"int64"}`},
+	{name: "duration string", parser: Parser{DurationFormat: DurationString}, value: time.Duration(0), expected: `{"type":"string"}`},
+	{name: "time", value: time.Time{}, expected: `{"type":"string","format":"date-time"}`},
+	{name: "null non-finite", parser: Parser{NonFinite: NullNonFinite}, value: float32(0), expected: `{"type":["number","null"],"format":"float"}`},
+	{name: "anonymous struct", parser: Parser{AllowUnknownFields: true}, value: struct {
+		A string `json:"a,required"`
+		B int    `api:"b"`
+	}{}, expected: `{"type":"object","properties":{"B":{"type":"integer","format":"int64"},"a":{"type":"string"}},"required":["a"]}`},
+	{name: "tag name", parser: Parser{TagName: "api", AllowUnknownFields: true}, value: struct {
+		B int `api:"b"`
+	}{}, expected: `{"type":"object","properties":{"b":{"type":"integer","format":"int64"}}}`},
+	{name: "enum", parser: Parser{AllowUnknownFields: true}, value: struct {
+		Status string   `json:"status" enum:"draft,published"`
+		Tags   []string `json:"tags" enum:"a,b"`
+	}{}, expected: `{"type":"object","properties":{"status":{"type":"string","enum":["draft","published"]},"tags":{"type":"array","items":{"type":"string","enum":["a","b"]}}}}`},
+	{name: "read and write only", parser: Parser{AllowUnknownFields: true}, value: struct {
+		ID       string `json:"id,readonly"`
+		Password string `json:"password,writeonly"`
+	}{}, expected: `{"type":"object","properties":{"id":{"type":"string","readOnly":true},"password":{"type":"string","writeOnly":true}}}`},
+	{name: "named struct", value: specOwner{}, expected: `{"$ref":"#/components/schemas/specOwner"}`},
+}
+
+func TestAPISpec_SchemaOf(t *testing.T) {
+	for i := range specSchemaTests {
+		e := &specSchemaTests[i]
+		testParser := &e.parser
+		b, err := json.Marshal(testParser.NewAPISpec("test", "1").SchemaOf(e.value))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+		if string(b) != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, b)
+		}
+	}
+}
+
+func TestAPISpec_Register(t *testing.T) {
+	var testParser Parser
+	spec := testParser.NewAPISpec("Orders", "1.0.0")
+	spec.Register(&specOrder{})
+
+	schemas := spec.Document().Components.Schemas
+	if len(schemas) != 2 {
+		t.Fatalf("expected schemas for specOrder and specOwner, got %v", schemas)
+	}
+
+	expected := map[string]string{
+		"id":     `{"type":"string","pattern":"^-?[0-9]+$"}`,
+		"owner":  `{"anyOf":[{"$ref":"#/components/schemas/specOwner"},{"type":"null"}]}`,
+		"total":  `{"type":["string","number"],"pattern":"^-?[0-9]{1,8}(\\.[0-9]{1,2})?$"}`,
+		"placed": `{"type":"integer","format":"int64"}`,
+		"due":    `{"type":"string","format":"date"}`,
+		"tags":   `{"type":"array","items":{"type":"string"}}`,
+		"meta":   `{"type":"object","additionalProperties":{"type":"integer","format":"int64"}}`,
+		"parent": `{"anyOf":[{"$ref":"#/components/schemas/specOrder"},{"type":"null"}]}`,
+		"count":  `{"type":"integer","format":"int32","minimum":0}`,
+		"extra":  `{}`,
+	}
+	order := schemas["specOrder"]
+	if len(order.Properties) != len(expected) {
+		t.Errorf("expected %d properties, got %d", len(expected), len(order.Properties))
+	}
+	for name, want := range expected {
+		b, _ := json.Marshal(order.Properties[name])
+		if string(b) != want {
+			t.Errorf("%s: expected %s, got %s", name, want, b)
+		}
+	}
+
+	b, _ := json.Marshal(schemas["specOwner"])
+	if want := `{"type":"object","properties":{"id":{"type":"string","format":"uuid"},"name":{"type":"string"}},"required":["name"],"additionalProperties":{"not":{}}}`; string(b) != want {
+		t.Errorf("expected %s, got %s", want, b)
+	}
+}
+
+func TestAPISpec_Operation(t *testing.T) {
+	testParser := Parser{Methods: map[string]MethodPolicy{
+		http.MethodPatch: {ContentTypes: []string{"application/merge-patch+json"}, Body: BodyOptional},
+	}}
+	spec := testParser.NewAPISpec("Orders", "1.0.0")
+	spec.Operation(http.MethodPatch, "/owners/{id}", specOwner{}, specOwner{}).Summary = "Update an owner"
+	spec.Operation(http.MethodDelete, "/files/{path...}", nil, nil)
+
+	patch := spec.Document().Paths["/owners/{id}"].Patch
+	if patch == nil || patch.Summary != "Update an owner" {
+		t.Fatalf("expected the patch operation, got %v", spec.Document().Paths)
+	}
+	if len(patch.Parameters) != 1 || patch.Parameters[0].Name != "id" || patch.Parameters[0].In != "path" {
+		t.Errorf("expected an id path parameter, got %v", patch.Parameters)
+	}
+	if body := patch.RequestBody; body == nil || body.Required || body.Content["application/merge-patch+json"].Schema == nil {
+		t.Errorf("expected an optional merge patch body, got %+v", body)
+	}
+	if patch.Responses["200"] == nil || patch.Responses["default"] == nil {
+		t.Errorf("expected 200 and default responses, got %v", patch.Responses)
+	}
+	if spec.Document().Components.Schemas["JSONResponse"] == nil || spec.Document().Components.Schemas["FieldError"] == nil {
+		t.Errorf("expected the error envelope schemas, got %v", spec.Document().Components.Schemas)
+	}
+
+	del := spec.Document().Paths["/files/{path}"].Delete
+	if del == nil || del.RequestBody != nil || del.Responses["204"] == nil || del.Parameters[0].Name != "path" {
+		t.Errorf("expected a bodyless delete with a path parameter, got %+v", del)
+	}
+}
+
+func TestAPISpec_ServeHTTP(t *testing.T) {
+	testParser := Parser{EmptyFields: KeepEmptyFields}
+	spec := testParser.NewAPISpec("Orders", "1.0.0")
+	spec.Operation(http.MethodGet, "/owners/{id}", nil, specOwner{})
+
+	rr := httptest.NewRecorder()
+	spec.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("expected a JSON response, got %d %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if !strings.HasPrefix(rr.Body.String(), `{"openapi":"3.1.0","info":{"title":"Orders","version":"1.0.0"}`) {
+		t.Errorf("unexpected document: %s", rr.Body.String())
+	}
+	if strings.Contains(rr.Body.String(), `"$ref":""`) {
+		t.Errorf("expected empty keywords to be left out: %s", rr.Body.String())
+	}
+
+	var doc OpenAPI
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if schema := doc.Components.Schemas["specOwner"]; schema == nil || schema.AdditionalProperties == nil || schema.AdditionalProperties.Not == nil {
+		t.Errorf("expected the document to read back, got %+v", schema)
+	}
+}
+
+func TestSchema_UnmarshalJSON(t *testing.T) {
+	var schema Schema
+	if err := json.Unmarshal([]byte(`{"type":["string","null"],"additionalProperties":false,"items":true}`), &schema); err != nil {
+		t.Fatal(err)
+	}
+	if len(schema.Type) != 2 || schema.AdditionalProperties.Not == nil || schema.Items == nil || schema.Items.Not != nil {
+		t.Errorf("unexpected schema: %+v", schema)
+	}
+}
@@ -0,0 +1,521 @@
+package ps
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRefHops is how many $ref links a schema may follow before reaching a schema with content.
+const maxRefHops = 32
+
+// ValidateOpenAPI returns middleware that checks each request against the operation doc describes for it before
+// the wrapped handler runs: the required path, query, header and cookie parameters must be present and match their
+// schemas, and the body must be sent in a media type the operation lists and, if JSON, match that type's schema.
+// Violations are answered with ErrorJSON: 400 with a field error per problem, 413 for a body over the Parser's
+// limit, 415 for a media type the operation does not take, and 405 for a path the document describes but not
+// for the request's method. Requests for paths the document does not describe pass through unchecked.
+//
+// The body is read to check it and then put back, so the handler can still read it with ReadJSON.
+func (p *Parser) ValidateOpenAPI(doc *OpenAPI) func(http.Handler) http.Handler {
+	v := &specValidator{doc: doc}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			item, op, params, allowed := v.match(r)
+			if op == nil {
+				if allowed != nil {
+					_ = p.MethodNotAllowed(w, r, allowed...)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if status, err := v.check(p, w, r, item, op, params); err != nil {
+				_ = p.ErrorJSON(w, err, status)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// specValidator checks requests against an OpenAPI document.
+type specValidator struct {
+	doc *OpenAPI
+	// patterns caches the compiled pattern keywords, or nil for those that do not compile.
+	patterns sync.Map
+}
+
+// match finds the operation for r and the path it belongs to, and returns them with the values of the path's
+// parameters. Of the paths that match, the one with the most literal segments wins; between those with as many,
+// the one whose first literal segment comes earliest, and then the first in sorted order. If the path is
+// described but not for r's method, it returns the methods that are.
+func (v *specValidator) match(r *http.Request) (*PathItem, *Operation, map[string]string, []string) {
+	segments := strings.Split(r.URL.Path, "/")
+
+	var best *PathItem
+	var bestTemplate string
+	var bestLiterals []bool
+	var params map[string]string
+	bestScore := -1
+	for template, item := range v.doc.Paths {
+		parts := strings.Split(template, "/")
+		if item == nil || len(parts) != len(segments) {
+			continue
+		}
+
+		score, values, literals := 0, make(map[string]string), make([]bool, len(parts))
+		for i, part := range parts {
+			if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+				values[part[1:len(part)-1]] = segments[i]
+				continue
+			}
+			if part != segments[i] {
+				score = -1
+				break
+			}
+			score++
+			literals[i] = true
+		}
+		if score < 0 || score < bestScore {
+			continue
+		}
+		if score == bestScore {
+			if c := compareLiterals(literals, bestLiterals); c < 0 || (c == 0 && template > bestTemplate) {
+				continue
+			}
+		}
+		best, bestTemplate, bestLiterals, params, bestScore = item, template, literals, values, score
+	}
+	if best == nil {
+		return nil, nil, nil, nil
+	}
+
+	if op := best.Operation(r.Method); op != nil {
+		return best, op, params, nil
+	}
+	if op := best.Get; op != nil && r.Method == http.MethodHead {
+		return best, op, params, nil
+	}
+	return nil, nil, nil, best.Methods()
+}
+
+// compareLiterals compares the literal segments of two path templates of the same length, marked true in a and b:
+// it is positive if a has a literal where b first has a parameter, negative if b does, and 0 if neither does.
+func compareLiterals(a, b []bool) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] {
+				return 1
+			}
+			return -1
+		}
+	}
+	return 0
+}
+
+// check validates the parameters and body of r against op, an operation of item, returning the status to answer
+// with if they fail.
+func (v *specValidator) check(p *Parser, w http.ResponseWriter, r *http.Request, item *PathItem, op *Operation, pathValues map[string]string) (int, error) {
+	var errs []FieldError
+
+	query := r.URL.Query()
+	for _, param := range item.parameters(op) {
+		var values []string
+		switch param.In {
+		case "path":
+			if value, ok := pathValues[param.Name]; ok {
+				values = []string{value}
+			}
+		case "query":
+			values = query[param.Name]
+		case "header":
+			values = r.Header.Values(param.Name)
+		case "cookie":
+			if c, err := r.Cookie(param.Name); err == nil {
+				values = []string{c.Value}
+			}
+		}
+
+		switch {
+		case len(values) == 0:
+			if param.Required {
+				errs = append(errs, FieldError{Field: param.Name, Message: "is required"})
+			}
+		case param.Schema != nil:
+			errs = v.validate(param.Schema, v.parameter(param.Schema, values), param.Name, errs)
+		}
+	}
+	if len(errs) > 0 {
+		return http.StatusBadRequest, &ValidationError{Fields: errs}
+	}
+
+	if op.RequestBody == nil {
+		return 0, nil
+	}
+	if !hasBody(r) {
+		if op.RequestBody.Required {
+			return http.StatusBadRequest, errors.New("body must not be empty")
+		}
+		return 0, nil
+	}
+
+	contentType := mediaType(r.Header.Get("Content-Type"))
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	media, ok := op.RequestBody.Content[contentType]
+	if !ok {
+		media, ok = op.RequestBody.Content["*/*"]
+	}
+	if !ok {
+		types := make([]string, 0, len(op.RequestBody.Content))
+		for t := range op.RequestBody.Content {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		return http.StatusUnsupportedMediaType, fmt.Errorf("the Content-Type header is not %s", strings.Join(types, " or "))
+	}
+	if media.Schema == nil || contentType != "application/json" && !strings.HasSuffix(contentType, "+json") {
+		return 0, nil
+	}
+
+	maxBytes := p.maxPayload(r.Method)
+	b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(maxBytes)))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return http.StatusRequestEntityTooLarge, decodeError(err, maxBytes)
+		}
+		return http.StatusBadRequest, decodeError(err, maxBytes)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(b))
+
+	var body any
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&body); err != nil {
+		return http.StatusBadRequest, decodeError(err, maxBytes)
+	}
+	if err := checkEOF(dec); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if errs := v.validate(media.Schema, body, "", nil); len(errs) > 0 {
+		return http.StatusBadRequest, &ValidationError{Fields: errs}
+	}
+	return 0, nil
+}
+
+// parameter converts the text of a parameter to the JSON value its schema expects, so that it can be validated:
+// a number, a boolean, or for an array schema, an array of the values.
+func (v *specValidator) parameter(schema *Schema, values []string) any {
+	s := v.resolve(schema)
+	if s.Type.has("array") {
+		items := make([]any, len(values))
+		for i, value := range values {
+			items[i] = v.parameter(s.Items, []string{value})
+		}
+		return items
+	}
+
+	value := values[0]
+	switch {
+	case s.Type.has("integer") || s.Type.has("number"):
+		if n, ok := jsonNumber(value); ok {
+			return n
+		}
+	case s.Type.has("boolean"):
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// jsonNumber returns s as a json.Number if it is written the way JSON writes numbers.
+func jsonNumber(s string) (json.Number, bool) {
+	var n any
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	if dec.Decode(&n) != nil || checkEOF(dec) != nil {
+		return "", false
+	}
+	number, ok := n.(json.Number)
+	return number, ok
+}
+
+// resolve follows the $ref links of schema to a schema with content. A link that leads nowhere resolves to a
+// schema that accepts anything.
+func (v *specValidator) resolve(schema *Schema) *Schema {
+	for i := 0; schema != nil && schema.Ref != ""; i++ {
+		name, ok := strings.CutPrefix(schema.Ref, componentsPrefix)
+		if !ok || i == maxRefHops {
+			return &Schema{}
+		}
+		schema = v.doc.Components.Schemas[name]
+	}
+	if schema == nil {
+		return &Schema{}
+	}
+	return schema
+}
+
+// validate checks value against schema, appending a FieldError for each problem to errs.
+func (v *specValidator) validate(schema *Schema, value any, path string, errs []FieldError) []FieldError {
+	s := v.resolve(schema)
+	fail := func(format string, args ...any) []FieldError {
+		return append(errs, FieldError{Field: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.Not != nil && len(v.validate(s.Not, value, path, nil)) == 0 {
+		return fail("is not allowed")
+	}
+	if len(s.Type) > 0 && !s.Type.matches(value) {
+		return fail("must be %s", s.Type)
+	}
+	if len(s.AnyOf) > 0 {
+		// If no alternative matches, report the problems with the first one of the value's type, which is most
+		// likely the one meant, or else with the first one.
+		var report []FieldError
+		reportTyped := false
+		for _, alternative := range s.AnyOf {
+			altErrs := v.validate(alternative, value, path, nil)
+			if len(altErrs) == 0 {
+				report = nil
+				break
+			}
+			typed := v.resolve(alternative).Type.matches(value)
+			if report == nil || typed && !reportTyped {
+				report, reportTyped = altErrs, typed
+			}
+		}
+		if report != nil {
+			return append(errs, report...)
+		}
+	}
+	if len(s.Enum) > 0 && !containsJSON(s.Enum, value) {
+		choices := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			b, _ := json.Marshal(e)
+			choices[i] = string(b)
+		}
+		return fail("must be one of %s", strings.Join(choices, ", "))
+	}
+
+	switch value := value.(type) {
+	case string:
+		if n := len([]rune(value)); s.MinLength != nil && n < *s.MinLength {
+			return fail("must be at least %d characters long", *s.MinLength)
+		} else if s.MaxLength != nil && n > *s.MaxLength {
+			return fail("must be at most %d characters long", *s.MaxLength)
+		}
+		if s.Pattern != "" {
+			if re := v.pattern(s.Pattern); re != nil && !re.MatchString(value) {
+				return fail("must match %s", s.Pattern)
+			}
+		}
+		if message := checkFormat(s.Format, value); message != "" {
+			return fail("%s", message)
+		}
+
+	case json.Number:
+		f, _ := value.Float64()
+		if s.Minimum != nil && f < *s.Minimum {
+			return fail("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return fail("must be at most %v", *s.Maximum)
+		}
+
+	case []any:
+		if s.MinItems != nil && len(value) < *s.MinItems {
+			return fail("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(value) > *s.MaxItems {
+			return fail("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range value {
+				errs = v.validate(s.Items, item, joinPath(path, strconv.Itoa(i)), errs)
+			}
+		}
+
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := value[name]; !ok {
+				errs = append(errs, FieldError{Field: joinPath(path, name), Message: "is required"})
+			}
+		}
+
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			property, ok := s.Properties[key]
+			if !ok {
+				property = s.AdditionalProperties
+			}
+			if property != nil {
+				errs = v.validate(property, value[key], joinPath(path, key), errs)
+			}
+		}
+	}
+
+	return errs
+}
+
+// pattern returns the compiled form of a pattern keyword, or nil if it does not compile.
+func (v *specValidator) pattern(pattern string) *regexp.Regexp {
+	if re, ok := v.patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, _ := regexp.Compile(pattern)
+	v.patterns.Store(pattern, re)
+	return re
+}
+
+// checkFormat describes what value must be to have the format keyword format, or returns "" if it has it. Formats
+// this package does not know are not checked.
+func checkFormat(format, value string) string {
+	var err error
+	var expected string
+	switch format {
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, value)
+		expected = "must be an RFC 3339 date-time"
+	case "date":
+		var d Date
+		err, expected = d.UnmarshalText([]byte(value)), "must be "+d.expected()
+	case "uuid":
+		var u UUID
+		err, expected = u.UnmarshalText([]byte(value)), "must be "+u.expected()
+	}
+	if err != nil {
+		return expected
+	}
+	return ""
+}
+
+// has reports whether t includes the JSON type name.
+func (t SchemaType) has(name string) bool {
+	for _, n := range t {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether value, as decoded with UseNumber, is of one of the types in t.
+func (t SchemaType) matches(value any) bool {
+	for _, name := range t {
+		switch v := value.(type) {
+		case nil:
+			if name == "null" {
+				return true
+			}
+		case bool:
+			if name == "boolean" {
+				return true
+			}
+		case string:
+			if name == "string" {
+				return true
+			}
+		case json.Number:
+			if name == "number" {
+				return true
+			}
+			if f, err := v.Float64(); name == "integer" && err == nil && f == math.Trunc(f) {
+				return true
+			}
+		case []any:
+			if name == "array" {
+				return true
+			}
+		case map[string]any:
+			if name == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// String describes the types in t, such as "a string or null".
+func (t SchemaType) String() string {
+	names := make([]string, len(t))
+	for i, name := range t {
+		switch name {
+		case "null":
+			names[i] = name
+		case "integer", "object", "array":
+			names[i] = "an " + name
+		default:
+			names[i] = "a " + name
+		}
+	}
+	if len(names) > 1 {
+		return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+	}
+	return strings.Join(names, "")
+}
+
+// containsJSON reports whether values holds a value equal to value as JSON, so that numbers compare by value
+// whether they were decoded as float64 or json.Number.
+func containsJSON(values []any, value any) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(normalizeJSON(v), normalizeJSON(value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeJSON converts the json.Numbers in a decoded JSON value to float64.
+func normalizeJSON(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = normalizeJSON(item)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			out[key] = normalizeJSON(item)
+		}
+		return out
+	}
+
+	// Enums built in Go may hold numbers of any type.
+	switch v := reflect.ValueOf(value); {
+	case v.CanInt():
+		return float64(v.Int())
+	case v.CanUint():
+		return float64(v.Uint())
+	case v.CanFloat():
+		return v.Float()
+	}
+	return value
+}
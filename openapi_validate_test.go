@@ -0,0 +1,214 @@
+package ps
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// validateSpec is a hand-written document, as a service would load from a file.
+const validateSpec = `{
+  "openapi": "3.1.0",
+  "info": {"title": "Pets", "version": "1"},
+  "paths": {
+    "/pets": {
+      "get": {
+        "parameters": [
+          {"name": "limit", "in": "query", "schema": {"type": "integer", "minimum": 1, "maximum": 100}},
+          {"name": "tag", "in": "query", "schema": {"type": "array", "items": {"type": "string", "enum": ["cat", "dog"]}}},
+          {"name": "X-Tenant", "in": "header", "required": true, "schema": {"type": "string", "format": "uuid"}}
+        ],
+        "responses": {"200": {"description": "OK"}}
+      },
+      "post": {
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Pet"}}}
+        },
+        "responses": {"201": {"description": "Created"}}
+      }
+    },
+    "/pets/{id}": {
+      "summary": "One pet",
+      "servers": [{"url": "https://pets.example.com"}],
+      "parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "integer"}}],
+      "delete": {
+        "responses": {"204": {"description": "No Content"}}
+      },
+      "patch": {
+        "parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}],
+        "responses": {"200": {"description": "OK"}}
+      }
+    },
+    "/shelters/{id}": {
+      "get": {"responses": {"200": {"description": "OK"}}}
+    },
+    "/{region}/pets": {
+      "post": {"responses": {"201": {"description": "Created"}}}
+    },
+    "/pets/mine": {
+      "get": {"responses": {"200": {"description": "OK"}}}
+    }
+  },
+  "components": {
+    "schemas": {
+      "Pet": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string", "minLength": 1, "maxLength": 10},
+          "born": {"type": "string", "format": "date"},
+          "owner": {"anyOf": [{"$ref": "#/components/schemas/Owner"}, {"type": "null"}]},
+          "tags": {"type": "array", "items": {"type": "string", "pattern": "^[a-z]+$"}, "maxItems": 2}
+        },
+        "required": ["name"],
+        "additionalProperties": false
+      },
+      "Owner": {
+        "type": "object",
+        "properties": {"email": {"type": "string"}},
+        "required": ["email"]
+      }
+    }
+  }
+}`
+
+const validateTenant = "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+
+var validateOpenAPITests = []struct {
+	name           string
+	method         string
+	target         string
+	json           string
+	contentType    string
+	tenant         string
+	expectedStatus int
+	expectedBody   string
+}{
+	{name: "valid query", method: http.MethodGet, target: "/pets?limit=10&tag=cat&tag=dog", tenant: validateTenant, expectedStatus: http.StatusOK},
+	{name: "missing header", method: http.MethodGet, target: "/pets", expectedStatus: http.StatusBadRequest,
+		expectedBody: `{"error":true,"message":"X-Tenant is required","fields":[{"field":"X-Tenant","message":"is required"}]}`},
+	{name: "bad query", method: http.MethodGet, target: "/pets?limit=0&tag=cow", tenant: "nope", expectedStatus: http.StatusBadRequest,
+		expectedBody: `{"error":true,"message":"limit must be at least 1; tag.0 must be one of \"cat\", \"dog\"; X-Tenant must be a valid UUID",` +
+			`"fields":[{"field":"limit","message":"must be at least 1"},{"field":"tag.0","message":"must be one of \"cat\", \"dog\""},` +
+			`{"field":"X-Tenant","message":"must be a valid UUID"}]}`},
+	{name: "integer query", method: http.MethodGet, target: "/pets?limit=1.5", tenant: validateTenant, expectedStatus: http.StatusBadRequest,
+		expectedBody: `{"error":true,"message":"limit must be an integer","fields":[{"field":"limit","message":"must be an integer"}]}`},
+	{name: "valid body", method: http.MethodPost, target: "/pets", json: `{"name":"Rex","born":"2020-01-31","owner":null,"tags":["good"]}`,
+		expectedStatus: http.StatusOK},
+	{name: "invalid body", method: http.MethodPost, target: "/pets", json: `{"name":"","born":"2020-02-31","owner":{},"tags":["A","b","c"],"age":3}`,
+		expectedStatus: http.StatusBadRequest,
+		expectedBody: `{"error":true,"message":"age is not allowed; born must be a valid date (YYYY-MM-DD); name must be at least 1 characters long; ` +
+			`owner.email is required; tags must have at most 2 items","fields":[{"field":"age","message":"is not allowed"},` +
+			`{"field":"born","message":"must be a valid date (YYYY-MM-DD)"},{"field":"name","message":"must be at least 1 characters long"},` +
+			`{"field":"owner.email","message":"is required"},{"field":"tags","message":"must have at most 2 items"}]}`},
+	{name: "pattern", method: http.MethodPost, target: "/pets", json: `{"name":"Rex","tags":["A"]}`, expectedStatus: http.StatusBadRequest,
+		expectedBody: `{"error":true,"message":"tags.0 must match ^[a-z]+$","fields":[{"field":"tags.0","message":"must match ^[a-z]+$"}]}`},
+	{name: "wrong type", method: http.MethodPost, target: "/pets", json: `["Rex"]`, expectedStatus: http.StatusBadRequest,
+		expectedBody: `{"error":true,"message":"body must be an object","fields":[{"field":"","message":"must be an object"}]}`},
+	{name: "empty body", method: http.MethodPost, target: "/pets", expectedStatus: http.StatusBadRequest,
+		expectedBody: `{"error":true,"message":"body must not be empty"}`},
+	{name: "bad json", method: http.MethodPost, target: "/pets", json: `{"name":`, expectedStatus: http.StatusBadRequest,
+		expectedBody: `{"error":true,"message":"body contains badly-formed JSON"}`},
+	{name: "media type", method: http.MethodPost, target: "/pets", json: `name=Rex`, contentType: "application/x-www-form-urlencoded",
+		expectedStatus: http.StatusUnsupportedMediaType, expectedBody: `{"error":true,"message":"the Content-Type header is not application/json"}`},
+	{name: "too large", method: http.MethodPost, target: "/pets", json: `{"name":"` + strings.Repeat("x", 200) + `"}`,
+		expectedStatus: http.StatusRequestEntityTooLarge, expectedBody: `{"error":true,"message":"body must not be larger than 100 bytes"}`},
+	{name: "path parameter", method: http.MethodDelete, target: "/pets/abc", expectedStatus: http.StatusBadRequest,
+		expectedBody: `{"error":true,"message":"id must be an integer","fields":[{"field":"id","message":"must be an integer"}]}`},
+	{name: "operation parameter overrides the path's", method: http.MethodPatch, target: "/pets/abc", expectedStatus: http.StatusOK},
+	{name: "earlier literal wins a tie", method: http.MethodGet, target: "/shelters/pets", expectedStatus: http.StatusOK},
+	{name: "literal path wins", method: http.MethodGet, target: "/pets/mine", expectedStatus: http.StatusOK},
+	{name: "method not described", method: http.MethodPut, target: "/pets/1", expectedStatus: http.StatusMethodNotAllowed,
+		expectedBody: `{"error":true,"message":"method PUT is not allowed"}`},
+	{name: "path not described", method: http.MethodGet, target: "/owners", expectedStatus: http.StatusOK},
+}
+
+func TestParser_ValidateOpenAPI(t *testing.T) {
+	var doc OpenAPI
+	if err := json.Unmarshal([]byte(validateSpec), &doc); err != nil {
+		t.Fatal(err)
+	}
+	testParser := Parser{MaxJSONSize: 100}
+
+	for _, e := range validateOpenAPITests {
+		var received string
+		handler := testParser.ValidateOpenAPI(&doc)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			received = string(b)
+		}))
+
+		var body io.Reader
+		if e.json != "" {
+			body = strings.NewReader(e.json)
+		}
+		req := httptest.NewRequest(e.method, e.target, body)
+		if e.contentType != "" {
+			req.Header.Set("Content-Type", e.contentType)
+		}
+		if e.tenant != "" {
+			req.Header.Set("X-Tenant", e.tenant)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatus {
+			t.Errorf("%s: expected status %d, got %d: %s", e.name, e.expectedStatus, rr.Code, rr.Body.String())
+			continue
+		}
+		if e.expectedStatus == http.StatusOK && received != e.json {
+			t.Errorf("%s: expected the handler to read %q, got %q", e.name, e.json, received)
+		}
+		if e.expectedBody != "" && rr.Body.String() != e.expectedBody {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expectedBody, rr.Body.String())
+		}
+	}
+}
+
+func TestSpecValidator_Match(t *testing.T) {
+	var doc OpenAPI
+	if err := json.Unmarshal([]byte(validateSpec), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if item := doc.Paths["/pets/{id}"]; item.Summary != "One pet" || len(item.Servers) != 1 || len(item.Parameters) != 1 {
+		t.Errorf("expected the path-level fields to be read, got %+v", item)
+	}
+
+	// Both /shelters/{id} and /{region}/pets match with one literal segment; the choice must not depend on map
+	// order.
+	v := &specValidator{doc: &doc}
+	for i := 0; i < 20; i++ {
+		item, op, params, _ := v.match(httptest.NewRequest(http.MethodGet, "/shelters/pets", nil))
+		if item != doc.Paths["/shelters/{id}"] || op == nil || params["id"] != "pets" {
+			t.Fatalf("expected /shelters/{id}, got %+v %v", item, params)
+		}
+	}
+}
+
+func TestParser_ValidateOpenAPIGenerated(t *testing.T) {
+	var testParser Parser
+	spec := testParser.NewAPISpec("Orders", "1.0.0")
+	spec.Operation(http.MethodPost, "/owners", specOwner{}, specOwner{})
+
+	handler := testParser.ValidateOpenAPI(spec.Document())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var owner specOwner
+		if err := testParser.ReadJSON(w, r, &owner); err != nil {
+			_ = testParser.ErrorJSON(w, err)
+			return
+		}
+		_ = testParser.WriteJSON(w, http.StatusOK, owner)
+	}))
+
+	for body, expected := range map[string]string{
+		`{"name":"Ann"}`:                    `{"id":"00000000-0000-0000-0000-000000000000","name":"Ann"}`,
+		`{"id":"x","name":"Ann","other":1}`: `{"error":true,"message":"id must be a valid UUID; other is not allowed","fields":[{"field":"id","message":"must be a valid UUID"},{"field":"other","message":"is not allowed"}]}`,
+	} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/owners", strings.NewReader(body)))
+		if rr.Body.String() != expected {
+			t.Errorf("%s: expected %s, got %s", body, expected, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package ps
+
+import (
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+)
+
+// Option changes one setting of a Parser. Options are passed to New and With.
+type Option func(*Parser)
+
+// New returns a Parser with opts applied to the default configuration.
+func New(opts ...Option) *Parser {
+	p := &Parser{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Clone returns a copy of p that can be changed without affecting p, even if p is frozen. Maps and slices are
+// copied; the ReplayGuard, which holds shared state, is not.
+func (p *Parser) Clone() *Parser {
+	// The counters and cached responses are written atomically and belong to p alone, so the settings are copied
+	// one by one rather than with the whole struct; TestParser_CloneCopiesEverySetting catches any left out.
+	c := &Parser{
+		MaxJSONSize:         p.MaxJSONSize,
+		AllowUnknownFields:  p.AllowUnknownFields,
+		APIVersions:         p.APIVersions,
+		AllowEmptyBody:      p.AllowEmptyBody,
+		AllowedFields:       p.AllowedFields,
+		Canonical:           p.Canonical,
+		Decompress:          p.Decompress,
+		Diagnostics:         p.Diagnostics,
+		DisallowedFields:    p.DisallowedFields,
+		Disclosure:          p.Disclosure,
+		DurationFormat:      p.DurationFormat,
+		EmptyFields:         p.EmptyFields,
+		FloatFormat:         p.FloatFormat,
+		FullDuplex:          p.FullDuplex,
+		Int64AsString:       p.Int64AsString,
+		Languages:           p.Languages,
+		LenientCoercion:     p.LenientCoercion,
+		Logger:              p.Logger,
+		MaxDecompressedSize: p.MaxDecompressedSize,
+		MaxDepth:            p.MaxDepth,
+		MaxExpansionRatio:   p.MaxExpansionRatio,
+		MaxResponseSize:     p.MaxResponseSize,
+		Methods:             p.Methods,
+		NonFinite:           p.NonFinite,
+		OversizedResponse:   p.OversizedResponse,
+		PollTimeout:         p.PollTimeout,
+		Pretty:              p.Pretty,
+		ReadOnlyFields:      p.ReadOnlyFields,
+		RejectDuplicateKeys: p.RejectDuplicateKeys,
+		ReplayGuard:         p.ReplayGuard,
+		RequestDigest:       p.RequestDigest,
+		RequestIDHeader:     p.RequestIDHeader,
+		RequireContentType:  p.RequireContentType,
+		ResponseDigest:      p.ResponseDigest,
+		SortKeys:            p.SortKeys,
+		TagName:             p.TagName,
+		TimeFormat:          p.TimeFormat,
+		TrustRawJSON:        p.TrustRawJSON,
+		UnexpectedBody:      p.UnexpectedBody,
+		VersionHeader:       p.VersionHeader,
+		Warnings:            p.Warnings,
+		WriteTimeout:        p.WriteTimeout,
+		afterDecode:         p.afterDecode,
+		afterEncode:         p.afterEncode,
+		beforeDecode:        p.beforeDecode,
+		beforeEncode:        p.beforeEncode,
+		canned:              p.canned,
+		decoders:            p.decoders,
+		onBind:              p.onBind,
+		onDecodeError:       p.onDecodeError,
+		onWrite:             p.onWrite,
+		versions:            p.versions,
+	}
+	c.copyShared()
+	return c
+}
+
+// copyShared replaces the maps, slices and pointers in p's configuration with copies, so that changes made
+// through other references to them no longer reach p.
+func (p *Parser) copyShared() {
+	p.APIVersions = slices.Clone(p.APIVersions)
+	p.AllowedFields = slices.Clone(p.AllowedFields)
+	p.Languages = slices.Clone(p.Languages)
+	p.Methods = maps.Clone(p.Methods)
+	p.canned = maps.Clone(p.canned)
+	p.versions = maps.Clone(p.versions)
+	// Clipped slices are reallocated by the next append, so registering a hook on one copy leaves the other alone.
+	p.afterDecode = slices.Clip(p.afterDecode)
+	p.afterEncode = slices.Clip(p.afterEncode)
+	p.beforeDecode = slices.Clip(p.beforeDecode)
+	p.beforeEncode = slices.Clip(p.beforeEncode)
+	p.onBind = slices.Clip(p.onBind)
+	p.onDecodeError = slices.Clip(p.onDecodeError)
+	p.onWrite = slices.Clip(p.onWrite)
+	if p.FloatFormat != nil {
+		ff := *p.FloatFormat
+		p.FloatFormat = &ff
+	}
+}
+
+// With returns a copy of p with opts applied, leaving p unchanged. It suits routes that differ from the rest of
+// the service in one setting:
+//
+//	uploads := parser.With(ps.WithMaxJSONSize(50 << 20))
+func (p *Parser) With(opts ...Option) *Parser {
+	c := p.Clone()
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithMaxJSONSize sets MaxJSONSize.
+func WithMaxJSONSize(n int) Option {
+	return func(p *Parser) { p.MaxJSONSize = n }
+}
+
+// WithAllowUnknownFields sets AllowUnknownFields.
+func WithAllowUnknownFields(allow bool) Option {
+	return func(p *Parser) { p.AllowUnknownFields = allow }
+}
+
+// WithAPIVersions sets APIVersions.
+func WithAPIVersions(versions ...string) Option {
+	return func(p *Parser) { p.APIVersions = versions }
+}
+
+// WithAllowEmptyBody sets AllowEmptyBody.
+func WithAllowEmptyBody(allow bool) Option {
+	return func(p *Parser) { p.AllowEmptyBody = allow }
+}
+
+// WithAllowedFields sets AllowedFields and DisallowedFields:
+//
+//	signup := parser.With(ps.WithAllowedFields(ps.RejectDisallowedFields, "name", "email", "address.city"))
+func WithAllowedFields(policy DisallowedFields, fields ...string) Option {
+	return func(p *Parser) { p.AllowedFields, p.DisallowedFields = fields, policy }
+}
+
+// WithCanonical sets Canonical.
+func WithCanonical(canonical bool) Option {
+	return func(p *Parser) { p.Canonical = canonical }
+}
+
+// WithDecompression sets Decompress, MaxDecompressedSize and MaxExpansionRatio; zero limits keep their defaults.
+func WithDecompression(maxSize, maxRatio int) Option {
+	return func(p *Parser) { p.Decompress, p.MaxDecompressedSize, p.MaxExpansionRatio = true, maxSize, maxRatio }
+}
+
+// WithDiagnostics sets Diagnostics.
+func WithDiagnostics(diagnostics bool) Option {
+	return func(p *Parser) { p.Diagnostics = diagnostics }
+}
+
+// WithDisclosure sets Disclosure.
+func WithDisclosure(disclosure Disclosure) Option {
+	return func(p *Parser) { p.Disclosure = disclosure }
+}
+
+// WithDurationFormat sets DurationFormat.
+func WithDurationFormat(format string) Option {
+	return func(p *Parser) { p.DurationFormat = format }
+}
+
+// WithEmptyFields sets EmptyFields.
+func WithEmptyFields(policy EmptyFields) Option {
+	return func(p *Parser) { p.EmptyFields = policy }
+}
+
+// WithFloatFormat sets FloatFormat.
+func WithFloatFormat(format FloatFormat) Option {
+	return func(p *Parser) { p.FloatFormat = &format }
+}
+
+// WithFullDuplex sets FullDuplex.
+func WithFullDuplex(fullDuplex bool) Option {
+	return func(p *Parser) { p.FullDuplex = fullDuplex }
+}
+
+// WithInt64AsString sets Int64AsString.
+func WithInt64AsString(enabled bool) Option {
+	return func(p *Parser) { p.Int64AsString = enabled }
+}
+
+// WithLanguages sets Languages.
+func WithLanguages(languages ...string) Option {
+	return func(p *Parser) { p.Languages = languages }
+}
+
+// WithLenientCoercion sets LenientCoercion.
+func WithLenientCoercion(lenient bool) Option {
+	return func(p *Parser) { p.LenientCoercion = lenient }
+}
+
+// WithLogger sets Logger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(p *Parser) { p.Logger = logger }
+}
+
+// WithMaxDepth sets MaxDepth.
+func WithMaxDepth(depth int) Option {
+	return func(p *Parser) { p.MaxDepth = depth }
+}
+
+// WithMaxResponseSize sets MaxResponseSize and OversizedResponse.
+func WithMaxResponseSize(n int, policy OversizedResponse) Option {
+	return func(p *Parser) { p.MaxResponseSize, p.OversizedResponse = n, policy }
+}
+
+// WithMethodPolicy sets the policy for one HTTP method, keeping those of other methods.
+func WithMethodPolicy(method string, policy MethodPolicy) Option {
+	return func(p *Parser) {
+		if p.Methods == nil {
+			p.Methods = make(map[string]MethodPolicy)
+		}
+		p.Methods[method] = policy
+	}
+}
+
+// WithNonFinite sets NonFinite.
+func WithNonFinite(policy NonFinite) Option {
+	return func(p *Parser) { p.NonFinite = policy }
+}
+
+// WithPollTimeout sets PollTimeout.
+func WithPollTimeout(policy PollTimeout) Option {
+	return func(p *Parser) { p.PollTimeout = policy }
+}
+
+// WithPretty sets Pretty.
+func WithPretty(pretty bool) Option {
+	return func(p *Parser) { p.Pretty = pretty }
+}
+
+// WithReadOnlyFields sets ReadOnlyFields.
+func WithReadOnlyFields(policy ReadOnlyFields) Option {
+	return func(p *Parser) { p.ReadOnlyFields = policy }
+}
+
+// WithRejectDuplicateKeys sets RejectDuplicateKeys.
+func WithRejectDuplicateKeys(reject bool) Option {
+	return func(p *Parser) { p.RejectDuplicateKeys = reject }
+}
+
+// WithReplayGuard sets ReplayGuard.
+func WithReplayGuard(guard *ReplayGuard) Option {
+	return func(p *Parser) { p.ReplayGuard = guard }
+}
+
+// WithRequestDigest sets RequestDigest.
+func WithRequestDigest(policy DigestPolicy) Option {
+	return func(p *Parser) { p.RequestDigest = policy }
+}
+
+// WithRequestIDHeader sets RequestIDHeader.
+func WithRequestIDHeader(header string) Option {
+	return func(p *Parser) { p.RequestIDHeader = header }
+}
+
+// WithRequireContentType sets RequireContentType.
+func WithRequireContentType(require bool) Option {
+	return func(p *Parser) { p.RequireContentType = require }
+}
+
+// WithResponseDigest sets ResponseDigest.
+func WithResponseDigest(digest bool) Option {
+	return func(p *Parser) { p.ResponseDigest = digest }
+}
+
+// WithSortKeys sets SortKeys.
+func WithSortKeys(sortKeys bool) Option {
+	return func(p *Parser) { p.SortKeys = sortKeys }
+}
+
+// WithTagName sets TagName.
+func WithTagName(name string) Option {
+	return func(p *Parser) { p.TagName = name }
+}
+
+// WithTimeFormat sets TimeFormat.
+func WithTimeFormat(format string) Option {
+	return func(p *Parser) { p.TimeFormat = format }
+}
+
+// WithTrustRawJSON sets TrustRawJSON.
+func WithTrustRawJSON(trust bool) Option {
+	return func(p *Parser) { p.TrustRawJSON = trust }
+}
+
+// WithUnexpectedBody sets UnexpectedBody.
+func WithUnexpectedBody(policy UnexpectedBody) Option {
+	return func(p *Parser) { p.UnexpectedBody = policy }
+}
+
+// WithVersionHeader sets VersionHeader.
+func WithVersionHeader(header string) Option {
+	return func(p *Parser) { p.VersionHeader = header }
+}
+
+// WithWarnings sets Warnings.
+func WithWarnings(policy Warnings) Option {
+	return func(p *Parser) { p.Warnings = policy }
+}
+
+// WithWriteTimeout sets WriteTimeout.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(p *Parser) { p.WriteTimeout = d }
+}
@@ -0,0 +1,121 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParser_With(t *testing.T) {
+	base := New(
+		WithMaxJSONSize(1024),
+		WithLanguages("en", "fr"),
+		WithMethodPolicy(http.MethodPost, MethodPolicy{MaxJSONSize: 2048}),
+		WithFloatFormat(FloatFormat{Decimals: 2}),
+	)
+	base.RegisterVersion("1", JSONResponse{}, func(data any) (any, error) { return data, nil })
+
+	derived := base.With(
+		WithMaxJSONSize(1<<20),
+		WithMethodPolicy(http.MethodPut, MethodPolicy{Body: BodyOptional}),
+	)
+	derived.Languages[0] = "de"
+	derived.FloatFormat.Decimals = 4
+	derived.RegisterVersion("2", JSONResponse{}, func(data any) (any, error) { return data, nil })
+
+	if derived.MaxJSONSize != 1<<20 || len(derived.Methods) != 2 || len(derived.versions) != 2 {
+		t.Errorf("options not applied to the derived parser: %+v", derived)
+	}
+	if derived.Methods[http.MethodPost].MaxJSONSize != 2048 {
+		t.Error("expected the derived parser to keep the base method policies")
+	}
+
+	if base.MaxJSONSize != 1024 || len(base.Methods) != 1 || len(base.versions) != 1 {
+		t.Errorf("base parser changed by With: %+v", base)
+	}
+	if base.Languages[0] != "en" || base.FloatFormat.Decimals != 2 {
+		t.Error("base parser shares state with its clone")
+	}
+}
+
+// TestParser_CloneCopiesEverySetting fails when a field is added to Parser without being copied by Clone.
+func TestParser_CloneCopiesEverySetting(t *testing.T) {
+	p := &Parser{}
+	v := reflect.ValueOf(p).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if !f.CanSet() {
+			continue
+		}
+		switch f.Kind() {
+		case reflect.Bool:
+			f.SetBool(true)
+		case reflect.Int, reflect.Int64:
+			f.SetInt(1)
+		case reflect.Float64:
+			f.SetFloat(1)
+		case reflect.String:
+			f.SetString("x")
+		case reflect.Slice:
+			f.Set(reflect.MakeSlice(f.Type(), 1, 1))
+		case reflect.Map:
+			f.Set(reflect.MakeMap(f.Type()))
+		case reflect.Pointer:
+			f.Set(reflect.New(f.Type().Elem()))
+		default:
+			t.Fatalf("set %s in this test", v.Type().Field(i).Name)
+		}
+	}
+	p.AfterDecode(NormalizeStrings)
+	p.AfterEncode(func(_ http.ResponseWriter, _ int, body []byte) ([]byte, error) { return body, nil })
+	p.BeforeDecode(func(_ *http.Request, body []byte) ([]byte, error) { return body, nil })
+	p.BeforeEncode(func(_ http.ResponseWriter, _ int, data any) (any, error) { return data, nil })
+	p.OnBind(func(*http.Request, any) {})
+	p.OnDecodeError(func(*http.Request, error) {})
+	p.OnWrite(func(http.ResponseWriter, WriteInfo) {})
+	p.RegisterCannedError(errUpstreamDown)
+	p.RegisterDecoder(time.Time{}, func([]byte, any) error { return nil })
+	p.RegisterVersion("1", JSONResponse{}, func(data any) (any, error) { return data, nil })
+	p.Freeze()
+	p.count(decodes, 1)
+	p.cannedCache()
+
+	c := reflect.ValueOf(p.Clone()).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		switch name {
+		case "frozen", "stats", "cannedBodies":
+			if !c.Field(i).IsZero() {
+				t.Errorf("expected the clone to start without %s", name)
+			}
+			continue
+		}
+		if v.Field(i).IsZero() {
+			t.Errorf("%s is not set in this test", name)
+		}
+		if c.Field(i).IsZero() {
+			t.Errorf("%s is not copied by Clone", name)
+		}
+	}
+}
+
+// TestParser_CloneConcurrent clones a Parser while it is counting. Run it with -race.
+func TestParser_CloneConcurrent(t *testing.T) {
+	var testParser Parser
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_ = testParser.WriteJSON(httptest.NewRecorder(), http.StatusOK, "ok")
+		}()
+		go func() {
+			defer wg.Done()
+			_ = testParser.Clone()
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,176 @@
+package ps
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// ApplyPartial sets the fields of the struct existing points to that raw, a JSON object, has keys for, leaving
+// the others as they are, and returns the dotted paths of the fields whose values changed, sorted. It follows
+// JSON Merge Patch (RFC 7396): null resets a field to its zero value, an object is merged into a nested struct,
+// which is allocated if it is nil, and any other value replaces the field. Map entries are set, or deleted for
+// null, one by one. Fields tagged readonly are left alone, as ReadJSON leaves them by default.
+//
+// Nothing is changed unless all of raw applies: unknown keys and bad values are reported together as a
+// *ValidationError.
+func ApplyPartial(existing any, raw json.RawMessage) ([]string, error) {
+	v := reflect.ValueOf(existing)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, &json.InvalidUnmarshalError{Type: reflect.TypeOf(existing)}
+	}
+
+	var a partialApplier
+	a.object(v.Elem(), raw, "")
+	if len(a.errs) > 0 {
+		sort.Slice(a.errs, func(i, j int) bool { return a.errs[i].Field < a.errs[j].Field })
+		return nil, &ValidationError{Fields: a.errs}
+	}
+
+	for _, set := range a.sets {
+		set()
+	}
+	sort.Strings(a.changed)
+	return a.changed, nil
+}
+
+// partialApplier plans the changes a partial update makes, so that they can be made only if all of it applies.
+type partialApplier struct {
+	sets    []func()
+	changed []string
+	errs    []FieldError
+}
+
+// fail records a problem with the value at path.
+func (a *partialApplier) fail(path, message string) {
+	a.errs = append(a.errs, FieldError{Field: path, Message: message})
+}
+
+// set plans setting v to x, and records path as changed.
+func (a *partialApplier) set(v, x reflect.Value, path string) {
+	a.sets = append(a.sets, func() { v.Set(x) })
+	a.changed = append(a.changed, path)
+}
+
+// object plans merging raw, which must be a JSON object, into struct v, found at path.
+func (a *partialApplier) object(v reflect.Value, raw json.RawMessage, path string) {
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(raw, &obj) != nil || obj == nil {
+		a.fail(path, "must be an object")
+		return
+	}
+
+	info := structFields(v.Type())
+	for _, key := range sortedKeys(obj) {
+		f := info.lookup(key)
+		if f == nil {
+			a.fail(joinPath(path, key), "is not a known field")
+			continue
+		}
+		if f.readOnly {
+			continue
+		}
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok {
+			a.fail(joinPath(path, f.name), "cannot be set")
+			continue
+		}
+		a.value(fv, obj[key], joinPath(path, f.name))
+	}
+}
+
+// value plans applying raw to v, found at path.
+func (a *partialApplier) value(v reflect.Value, raw json.RawMessage, path string) {
+	raw = bytes.TrimSpace(raw)
+	isObject := len(raw) > 0 && raw[0] == '{'
+	t := v.Type()
+
+	switch {
+	case string(raw) == "null":
+		if !v.IsZero() {
+			a.set(v, reflect.Zero(t), path)
+		}
+
+	case isObject && t.Kind() == reflect.Struct && !marshals(t):
+		a.object(v, raw, path)
+
+	case isObject && t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Struct && !marshals(t.Elem()):
+		if !v.IsNil() {
+			a.object(v.Elem(), raw, path)
+			return
+		}
+		// The new struct is not reachable until the pointer is set, so its fields can be planned in place.
+		fresh := reflect.New(t.Elem())
+		a.object(fresh.Elem(), raw, path)
+		a.sets = append(a.sets, func() { v.Set(fresh) })
+
+	case isObject && t.Kind() == reflect.Map && t.Key().Kind() == reflect.String && !marshals(t):
+		a.entries(v, raw, path)
+
+	default:
+		x := reflect.New(t)
+		if err := json.Unmarshal(raw, x.Interface()); err != nil {
+			if tv, ok := x.Interface().(textValue); ok {
+				a.fail(path, "must be "+tv.expected())
+			} else {
+				a.fail(path, "is not valid")
+			}
+			return
+		}
+		if !reflect.DeepEqual(v.Interface(), x.Elem().Interface()) {
+			a.set(v, x.Elem(), path)
+		}
+	}
+}
+
+// entries plans setting the entries of map v, found at path, from the JSON object raw.
+func (a *partialApplier) entries(v reflect.Value, raw json.RawMessage, path string) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		a.fail(path, "must be an object")
+		return
+	}
+
+	t := v.Type()
+	m, added := v, false
+	if v.IsNil() {
+		m = reflect.MakeMapWithSize(t, len(obj))
+	}
+	for _, key := range sortedKeys(obj) {
+		k := reflect.ValueOf(key).Convert(t.Key())
+		old := m.MapIndex(k)
+		entryPath := joinPath(path, key)
+
+		if string(bytes.TrimSpace(obj[key])) == "null" {
+			if old.IsValid() {
+				a.sets = append(a.sets, func() { m.SetMapIndex(k, reflect.Value{}) })
+				a.changed = append(a.changed, entryPath)
+			}
+			continue
+		}
+		x := reflect.New(t.Elem())
+		if err := json.Unmarshal(obj[key], x.Interface()); err != nil {
+			a.fail(entryPath, "is not valid")
+			continue
+		}
+		if !old.IsValid() || !reflect.DeepEqual(old.Interface(), x.Elem().Interface()) {
+			a.sets = append(a.sets, func() { m.SetMapIndex(k, x.Elem()) })
+			a.changed = append(a.changed, entryPath)
+			added = true
+		}
+	}
+	if v.IsNil() && added {
+		a.sets = append(a.sets, func() { v.Set(m) })
+	}
+}
+
+// sortedKeys returns the keys of obj in sorted order, so that problems and changes are found in a stable order.
+func sortedKeys[V any](obj map[string]V) []string {
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
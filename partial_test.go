@@ -0,0 +1,91 @@
+package ps
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type partialAddress struct {
+	City    string `json:"city"`
+	Country string `json:"country"`
+}
+
+type partialAccount struct {
+	ID      string            `json:"id,readonly"`
+	Name    string            `json:"name"`
+	Age     *int              `json:"age"`
+	Tags    []string          `json:"tags"`
+	Home    partialAddress    `json:"home"`
+	Work    *partialAddress   `json:"work"`
+	Labels  map[string]string `json:"labels"`
+	Account UUID              `json:"account"`
+}
+
+var applyPartialTests = []struct {
+	name          string
+	json          string
+	changed       string
+	expected      func(*partialAccount)
+	errorExpected string
+}{
+	{name: "scalar", json: `{"name": "Bo"}`, changed: "name", expected: func(a *partialAccount) { a.Name = "Bo" }},
+	{name: "unchanged", json: `{"name": "Ann", "tags": ["a"]}`, changed: ""},
+	{name: "null", json: `{"age": null, "tags": null}`, changed: "age,tags", expected: func(a *partialAccount) { a.Age, a.Tags = nil, nil }},
+	{name: "replaced slice", json: `{"tags": ["b", "c"]}`, changed: "tags", expected: func(a *partialAccount) { a.Tags = []string{"b", "c"} }},
+	{name: "nested merge", json: `{"home": {"city": "Bergen"}}`, changed: "home.city", expected: func(a *partialAccount) { a.Home.City = "Bergen" }},
+	{name: "nil pointer", json: `{"work": {"city": "Oslo"}}`, changed: "work.city", expected: func(a *partialAccount) { a.Work = &partialAddress{City: "Oslo"} }},
+	{name: "map entries", json: `{"labels": {"size": null, "color": "blue", "fit": "slim"}}`, changed: "labels.color,labels.fit,labels.size", expected: func(a *partialAccount) {
+		a.Labels = map[string]string{"color": "blue", "fit": "slim"}
+	}},
+	{name: "read only", json: `{"id": "other"}`, changed: ""},
+	{name: "text value", json: `{"account": "6ba7b810-9dad-11d1-80b4-00c04fd430c8"}`, changed: "account", expected: func(a *partialAccount) {
+		a.Account, _ = ParseUUID("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	}},
+	{name: "all or nothing", json: `{"name": "Bo", "age": "old", "nick": "b", "account": "x", "home": 3}`, errorExpected: "account must be a valid UUID; age is not valid; home is not valid; nick is not a known field"},
+	{name: "not an object", json: `[1]`, errorExpected: "body must be an object"},
+}
+
+func TestApplyPartial(t *testing.T) {
+	newAccount := func() *partialAccount {
+		age := 30
+		return &partialAccount{ID: "a1", Name: "Ann", Age: &age, Tags: []string{"a"}, Home: partialAddress{City: "Oslo", Country: "NO"},
+			Labels: map[string]string{"color": "red", "size": "L"}}
+	}
+
+	for _, e := range applyPartialTests {
+		got := newAccount()
+		changed, err := ApplyPartial(got, json.RawMessage(e.json))
+
+		expected := newAccount()
+		if e.errorExpected != "" {
+			if err == nil || err.Error() != e.errorExpected {
+				t.Errorf("%s: expected error %q, got %v", e.name, e.errorExpected, err)
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("%s: expected no changes, got %+v", e.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+			continue
+		}
+		if e.expected != nil {
+			e.expected(expected)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected %+v, got %+v", e.name, expected, got)
+		}
+		if strings.Join(changed, ",") != e.changed {
+			t.Errorf("%s: expected changes %q, got %q", e.name, e.changed, changed)
+		}
+	}
+}
+
+func TestApplyPartial_NotAPointer(t *testing.T) {
+	if _, err := ApplyPartial(partialAccount{}, json.RawMessage(`{}`)); err == nil {
+		t.Error("expected an error for a struct passed by value")
+	}
+}
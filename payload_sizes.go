@@ -0,0 +1,188 @@
+package ps
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultSizeBuckets are the bucket bounds a PayloadSizes uses when it has none: powers of four from 256 bytes to
+// 64 MiB.
+var DefaultSizeBuckets = []int64{256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20, 64 << 20}
+
+// PayloadSizes records the sizes of request and response bodies in histograms, one pair per route, so that
+// capacity planning can see how large bodies get before one breaks something. Routes are labelled by the caller,
+// with Measure. It is safe for concurrent use.
+type PayloadSizes struct {
+	// Buckets are the upper bounds of the histogram buckets in bytes, in increasing order (default
+	// DefaultSizeBuckets). Sizes above the last bound fall in an overflow bucket. Set them before first use.
+	Buckets []int64
+
+	routes sync.Map // route label -> *routeHistograms
+}
+
+// routeHistograms holds the histograms of one route.
+type routeHistograms struct {
+	requests, responses histogram
+}
+
+// histogram counts sizes into buckets atomically; counts has one more element than the bounds, for overflow.
+type histogram struct {
+	counts []int64
+	count  int64
+	sum    int64
+}
+
+// RouteSizes is a snapshot of the histograms of one route.
+type RouteSizes struct {
+	Requests  SizeHistogram `json:"requests"`
+	Responses SizeHistogram `json:"responses"`
+}
+
+// SizeHistogram is a snapshot of one histogram. Counts[i] is the number of bodies no larger than Bounds[i] and
+// larger than the bound before it; the last count, one past the bounds, is of bodies larger than every bound.
+type SizeHistogram struct {
+	Bounds []int64 `json:"bounds"`
+	Counts []int64 `json:"counts"`
+	Count  int64   `json:"count"`
+	Sum    int64   `json:"sum"`
+}
+
+// Quantile returns the upper bound of the bucket holding the q quantile, so Quantile(0.99) is the size that 99% of
+// bodies are no larger than, to the resolution of the buckets. It returns 0 if nothing has been observed, and -1
+// if the quantile falls in the overflow bucket.
+func (h SizeHistogram) Quantile(q float64) int64 {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := max(int64(math.Ceil(q*float64(h.Count))), 1)
+	var seen int64
+	for i, n := range h.Counts {
+		seen += n
+		if seen >= rank {
+			if i == len(h.Bounds) {
+				return -1
+			}
+			return h.Bounds[i]
+		}
+	}
+	return -1
+}
+
+// Observe records a request body of request bytes and a response body of response bytes on route.
+func (s *PayloadSizes) Observe(route string, request, response int64) {
+	h := s.route(route)
+	bounds := s.bounds()
+	h.requests.observe(bounds, request)
+	h.responses.observe(bounds, response)
+}
+
+// Snapshot returns the histograms of every route observed so far, for exporting or serving with WriteJSON.
+// Counts are read atomically but not all at once, so a snapshot taken under load may be a request or two out of
+// step between histograms.
+func (s *PayloadSizes) Snapshot() map[string]RouteSizes {
+	bounds := s.bounds()
+	snapshot := make(map[string]RouteSizes)
+	s.routes.Range(func(key, value any) bool {
+		h := value.(*routeHistograms)
+		snapshot[key.(string)] = RouteSizes{
+			Requests:  h.requests.snapshot(bounds),
+			Responses: h.responses.snapshot(bounds),
+		}
+		return true
+	})
+	return snapshot
+}
+
+// Measure returns middleware that records the size of every request body read and response body written under
+// the label route, such as "POST /users". The sizes are those on the wire: a compressed body counts as sent.
+func (s *PayloadSizes) Measure(route string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body := &countingBody{ReadCloser: r.Body}
+			if r.Body != nil {
+				r.Body = body
+			}
+			sw := &sizeWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+			s.Observe(route, body.n, sw.n)
+		})
+	}
+}
+
+// bounds returns the configured bucket bounds, or the default ones.
+func (s *PayloadSizes) bounds() []int64 {
+	if len(s.Buckets) > 0 {
+		return s.Buckets
+	}
+	return DefaultSizeBuckets
+}
+
+// route returns the histograms of route, creating them on first use.
+func (s *PayloadSizes) route(route string) *routeHistograms {
+	if h, ok := s.routes.Load(route); ok {
+		return h.(*routeHistograms)
+	}
+	n := len(s.bounds()) + 1
+	h, _ := s.routes.LoadOrStore(route, &routeHistograms{
+		requests:  histogram{counts: make([]int64, n)},
+		responses: histogram{counts: make([]int64, n)},
+	})
+	return h.(*routeHistograms)
+}
+
+// observe counts a size.
+func (h *histogram) observe(bounds []int64, size int64) {
+	i := sort.Search(len(bounds), func(i int) bool { return bounds[i] >= size })
+	atomic.AddInt64(&h.counts[i], 1)
+	atomic.AddInt64(&h.count, 1)
+	atomic.AddInt64(&h.sum, size)
+}
+
+// snapshot copies the histogram.
+func (h *histogram) snapshot(bounds []int64) SizeHistogram {
+	counts := make([]int64, len(h.counts))
+	for i := range counts {
+		counts[i] = atomic.LoadInt64(&h.counts[i])
+	}
+	return SizeHistogram{
+		Bounds: bounds,
+		Counts: counts,
+		Count:  atomic.LoadInt64(&h.count),
+		Sum:    atomic.LoadInt64(&h.sum),
+	}
+}
+
+// countingBody counts the bytes read from a request body.
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+// Read implements io.Reader.
+func (c *countingBody) Read(b []byte) (int, error) {
+	n, err := c.ReadCloser.Read(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// sizeWriter counts the bytes written to a response.
+type sizeWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+// Write counts b before passing it on.
+func (s *sizeWriter) Write(b []byte) (int, error) {
+	n, err := s.ResponseWriter.Write(b)
+	s.n += int64(n)
+	return n, err
+}
+
+// Unwrap returns the wrapped ResponseWriter, for http.ResponseController.
+func (s *sizeWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
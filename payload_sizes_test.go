@@ -0,0 +1,63 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPayloadSizes_Measure(t *testing.T) {
+	var testParser Parser
+	sizes := &PayloadSizes{Buckets: []int64{10, 100}}
+	handler := sizes.Measure("POST /users")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var data map[string]string
+		if err := testParser.ReadJSON(w, r, &data); err != nil {
+			_ = testParser.ErrorJSON(w, err)
+			return
+		}
+		_ = testParser.WriteJSON(w, http.StatusCreated, data)
+	}))
+
+	for _, body := range []string{`{}`, `{"name": "Jack"}`, `{"name": "` + strings.Repeat("x", 200) + `"}`} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body)))
+	}
+
+	got, ok := sizes.Snapshot()["POST /users"]
+	if !ok {
+		t.Fatal("expected the route in the snapshot")
+	}
+	expected := SizeHistogram{Bounds: []int64{10, 100}, Counts: []int64{1, 1, 1}, Count: 3, Sum: 2 + 16 + 212}
+	if !reflect.DeepEqual(got.Requests, expected) {
+		t.Errorf("expected request sizes %+v, got %+v", expected, got.Requests)
+	}
+	expected.Sum = 2 + 15 + 211
+	if !reflect.DeepEqual(got.Responses, expected) {
+		t.Errorf("expected response sizes %+v, got %+v", expected, got.Responses)
+	}
+}
+
+var quantileTests = []struct {
+	name     string
+	counts   []int64
+	q        float64
+	expected int64
+}{
+	{name: "empty", counts: []int64{0, 0, 0}, q: 0.99, expected: 0},
+	{name: "median", counts: []int64{5, 4, 1}, q: 0.5, expected: 10},
+	{name: "p99", counts: []int64{90, 10, 0}, q: 0.99, expected: 100},
+	{name: "overflow", counts: []int64{1, 0, 1}, q: 0.99, expected: -1},
+}
+
+func TestSizeHistogram_Quantile(t *testing.T) {
+	for _, e := range quantileTests {
+		h := SizeHistogram{Bounds: []int64{10, 100}, Counts: e.counts}
+		for _, n := range e.counts {
+			h.Count += n
+		}
+		if got := h.Quantile(e.q); got != e.expected {
+			t.Errorf("%s: expected %d, got %d", e.name, e.expected, got)
+		}
+	}
+}
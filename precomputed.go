@@ -0,0 +1,39 @@
+package ps
+
+import "bytes"
+
+// PrecomputedJSON is a payload encoded once, ahead of time, for static responses such as enum lists, health checks
+// and common errors. WriteJSON sends its bytes as they are, without encoding them again, and it can be nested in
+// other payloads, such as the Data of a JSONResponse, as it implements json.Marshaler.
+type PrecomputedJSON struct {
+	body []byte
+}
+
+// MarshalOnce encodes v with the Parser's settings and returns it ready to be written any number of times.
+func (p *Parser) MarshalOnce(v any) (*PrecomputedJSON, error) {
+	out, err := p.marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return &PrecomputedJSON{body: out}, nil
+}
+
+// MustMarshalOnce is like MarshalOnce but panics if v cannot be encoded. It suits payloads built in package-level
+// variables.
+func (p *Parser) MustMarshalOnce(v any) *PrecomputedJSON {
+	pre, err := p.MarshalOnce(v)
+	if err != nil {
+		panic("ps: MarshalOnce: " + err.Error())
+	}
+	return pre
+}
+
+// MarshalJSON implements json.Marshaler.
+func (pre *PrecomputedJSON) MarshalJSON() ([]byte, error) {
+	return bytes.Clone(pre.body), nil
+}
+
+// Bytes returns a copy of the encoded payload.
+func (pre *PrecomputedJSON) Bytes() []byte {
+	return bytes.Clone(pre.body)
+}
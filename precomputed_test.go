@@ -0,0 +1,69 @@
+package ps
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParser_MarshalOnce(t *testing.T) {
+	testParser := Parser{SortKeys: true}
+	statuses := testParser.MustMarshalOnce(map[string]any{"values": []string{"open", "closed"}, "default": "open"})
+
+	// A hook that rewrites the body in place must not reach the shared payload.
+	testParser.AfterEncode(func(w http.ResponseWriter, status int, body []byte) ([]byte, error) {
+		for i := range body {
+			if body[i] == 'o' {
+				body[i] = '0'
+			}
+		}
+		return body, nil
+	})
+
+	expected := `{"default":"0pen","values":["0pen","cl0sed"]}`
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		if err := testParser.WriteJSON(rr, http.StatusOK, statuses); err != nil {
+			t.Fatal(err)
+		}
+		if rr.Body.String() != expected {
+			t.Errorf("expected %s, got %s", expected, rr.Body.String())
+		}
+	}
+	if string(statuses.Bytes()) != `{"default":"open","values":["open","closed"]}` {
+		t.Errorf("precomputed payload was modified: %s", statuses.Bytes())
+	}
+
+	var plain Parser
+	rr := httptest.NewRecorder()
+	if err := plain.WriteJSON(rr, http.StatusOK, JSONResponse{Data: statuses}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"error":false,"message":"","data":{"default":"open","values":["open","closed"]}}`; rr.Body.String() != expected {
+		t.Errorf("expected %s, got %s", expected, rr.Body.String())
+	}
+
+	if _, err := plain.MarshalOnce(math.NaN()); err == nil {
+		t.Error("expected an error for a payload that cannot be encoded")
+	}
+}
+
+func BenchmarkParser_WriteJSONPrecomputed(b *testing.B) {
+	var testParser Parser
+	payload := map[string]any{"values": []string{"open", "closed", "archived"}, "default": "open"}
+	pre := testParser.MustMarshalOnce(payload)
+
+	b.Run("marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			testParser.WriteJSON(httptest.NewRecorder(), http.StatusOK, payload)
+		}
+	})
+	b.Run("precomputed", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			testParser.WriteJSON(httptest.NewRecorder(), http.StatusOK, pre)
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package ps
+
+// Limits applied by Strict.
+const (
+	strictMaxJSONSize     = 1 << 20
+	strictMaxDepth        = 32
+	strictMaxResponseSize = 16 << 20
+)
+
+// Strict returns a Parser with hardened defaults, followed by opts: bodies of at most 1 MiB, nested at most 32
+// levels deep, with no unknown fields or duplicate keys, and always sent as application/json, and responses over
+// 16 MiB are refused. Its UnexpectedBody policy rejects GET, HEAD and DELETE requests that carry a body, but like
+// any UnexpectedBody policy it only takes effect where CheckBody runs, so mount CheckBodies in front of the
+// handlers. It suits services that accept input from untrusted clients.
+func Strict(opts ...Option) *Parser {
+	return New(append([]Option{
+		WithMaxJSONSize(strictMaxJSONSize),
+		WithMaxDepth(strictMaxDepth),
+		WithRejectDuplicateKeys(true),
+		WithRequireContentType(true),
+		WithUnexpectedBody(RejectUnexpectedBody),
+		WithMaxResponseSize(strictMaxResponseSize, RejectOversizedResponse),
+	}, opts...)...)
+}
+
+// Lenient returns a Parser that accepts what it reasonably can, followed by opts: unknown fields are ignored, and
+// NaN and infinite floats are written as null rather than failing the response. Where CheckBodies is mounted,
+// bodies sent on GET, HEAD and DELETE requests are discarded. It suits internal services and migrations from
+// looser decoders.
+func Lenient(opts ...Option) *Parser {
+	return New(append([]Option{
+		WithAllowUnknownFields(true),
+		WithUnexpectedBody(StripUnexpectedBody),
+		WithNonFinite(NullNonFinite),
+	}, opts...)...)
+}
@@ -0,0 +1,93 @@
+package ps
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var strictTests = []struct {
+	name          string
+	json          string
+	contentType   string
+	errorExpected string
+}{
+	{name: "valid", json: `{"name":"a","tags":[{"name":"b"}]}`, contentType: "application/json"},
+	{name: "no content type", json: `{"name":"a"}`, errorExpected: "the Content-Type header is missing"},
+	{name: "duplicate key", json: `{"name":"a","name":"b"}`, contentType: "application/json", errorExpected: `body contains duplicate key "name"`},
+	{name: "duplicate nested key", json: `{"tags":[{"name":"a"},{"name":"b","name":"c"}]}`, contentType: "application/json",
+		errorExpected: `body contains duplicate key "name"`},
+	{name: "same key in sibling objects", json: `{"tags":[{"name":"a"},{"name":"b"}],"name":"c"}`, contentType: "application/json"},
+	{name: "too deep", json: `{"tags":` + strings.Repeat("[", 40) + strings.Repeat("]", 40) + `}`, contentType: "application/json",
+		errorExpected: "body must not be nested more than 32 levels deep"},
+	{name: "unknown field", json: `{"other":1}`, contentType: "application/json", errorExpected: `body contains unknown key "other"`},
+	{name: "syntax error", json: `{"name":"a",}`, contentType: "application/json", errorExpected: "body contains badly-formed JSON (at character 13)"},
+}
+
+func TestStrict(t *testing.T) {
+	type tag struct {
+		Name string `json:"name"`
+	}
+
+	for _, e := range strictTests {
+		testParser := Strict()
+		var decoded struct {
+			Name string `json:"name"`
+			Tags []tag  `json:"tags"`
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.json))
+		if e.contentType != "" {
+			req.Header.Set("Content-Type", e.contentType)
+		}
+		err := testParser.ReadJSON(httptest.NewRecorder(), req, &decoded)
+
+		if e.errorExpected != "" {
+			if err == nil || err.Error() != e.errorExpected {
+				t.Errorf("%s: expected error %q, got %v", e.name, e.errorExpected, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+		}
+	}
+}
+
+func TestLenient(t *testing.T) {
+	testParser := Lenient()
+
+	var decoded struct {
+		Name string `json:"name"`
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"a","extra":1}`))
+	if err := testParser.ReadJSON(httptest.NewRecorder(), req, &decoded); err != nil || decoded.Name != "a" {
+		t.Errorf("expected unknown fields to be ignored, got %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	if err := testParser.WriteJSON(rr, http.StatusOK, []float64{1, math.NaN()}); err != nil || rr.Body.String() != "[1,null]" {
+		t.Errorf("expected NaN to be written as null, got %s and %v", rr.Body.String(), err)
+	}
+}
+
+func TestStrictCheckBodies(t *testing.T) {
+	// The UnexpectedBody policy only applies where CheckBodies is mounted.
+	handler := Strict().CheckBodies()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", strings.NewReader(`{"name":"a"}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected a GET with a body to be rejected, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("expected a GET without a body to pass, got %d", rr.Code)
+	}
+}
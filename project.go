@@ -0,0 +1,190 @@
+package ps
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Project returns the parts of v, a struct or a map with string keys, named by fields, as a map that encodes to
+// a partial representation of v. Fields are named by their JSON keys, with dotted paths such as "owner.name"
+// reaching into nested structs and maps; a path through a slice selects from each of its elements. The values
+// selected are kept as they are, so that the map encodes as v would, and naming a field selects all of it.
+//
+// A path that names no field, or a field tagged writeonly, is reported as a *FieldError, whether or not v holds
+// anything at that path, so that the same list of fields always succeeds or always fails for a type.
+func Project(v any, fields []string) (map[string]any, error) {
+	proj, err := newProjection(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct && (rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String) {
+		return nil, fmt.Errorf("cannot project %v, which is not a struct or a map with string keys", reflect.TypeOf(v))
+	}
+	if err := proj.check(rv.Type(), ""); err != nil {
+		return nil, err
+	}
+
+	out, err := proj.apply(rv, "")
+	if err != nil {
+		return nil, err
+	}
+	return out.(map[string]any), nil
+}
+
+// projection is a set of dotted paths, grouped by their first segment. A nil projection selects all of a value.
+type projection map[string]projection
+
+// newProjection groups paths into a projection. A path selecting a whole field absorbs those within it.
+func newProjection(paths []string) (projection, error) {
+	proj := projection{}
+	for _, path := range paths {
+		segments := strings.Split(path, ".")
+		node := proj
+		for i, segment := range segments {
+			if segment == "" {
+				return nil, &FieldError{Field: path, Message: "is not a valid field path"}
+			}
+			child, ok := node[segment]
+			if ok && child == nil {
+				break
+			}
+			if i == len(segments)-1 {
+				node[segment] = nil
+				break
+			}
+			if !ok {
+				child = projection{}
+				node[segment] = child
+			}
+			node = child
+		}
+	}
+	return proj, nil
+}
+
+// names returns the first segments of the paths in p, sorted.
+func (p projection) names() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// check reports the first path in p that values of type t, found at path, do not have. Interfaces are checked
+// against the values they hold, when p is applied.
+func (p projection) check(t reflect.Type, path string) error {
+	if p == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t.Kind() == reflect.Interface:
+		return nil
+	case marshals(t):
+	case t.Kind() == reflect.Struct:
+		info := structFields(t)
+		for _, name := range p.names() {
+			f := info.lookup(name)
+			if f == nil || f.writeOnly {
+				return &FieldError{Field: joinPath(path, name), Message: "is not a known field"}
+			}
+			if err := p[name].check(f.typ, joinPath(path, f.name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String:
+		for _, name := range p.names() {
+			if err := p[name].check(t.Elem(), joinPath(path, name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return p.check(t.Elem(), path)
+	}
+
+	return &FieldError{Field: path, Message: "has no fields to select"}
+}
+
+// apply returns the parts of v, found at path, that p selects. The type of v must have passed check.
+func (p projection) apply(v reflect.Value, path string) (any, error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		if v.Kind() == reflect.Interface {
+			if err := p.check(v.Elem().Type(), path); err != nil {
+				return nil, err
+			}
+		}
+		v = v.Elem()
+	}
+	if p == nil {
+		return v.Interface(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		info := structFields(v.Type())
+		out := make(map[string]any, len(p))
+		for name, sub := range p {
+			f := info.lookup(name)
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok {
+				out[f.name] = nil
+				continue
+			}
+			var err error
+			if out[f.name], err = sub.apply(fv, joinPath(path, f.name)); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		out := make(map[string]any, len(p))
+		for name, sub := range p {
+			mv := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+			if !mv.IsValid() {
+				continue
+			}
+			var err error
+			if out[name], err = sub.apply(mv, joinPath(path, name)); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+
+	// Only slices and arrays are left.
+	if v.Kind() == reflect.Slice && v.IsNil() {
+		return nil, nil
+	}
+	out := make([]any, v.Len())
+	for i := range out {
+		var err error
+		if out[i], err = p.apply(v.Index(i), path); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
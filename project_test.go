@@ -0,0 +1,87 @@
+package ps
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type projectedOwner struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type projectedDoc struct {
+	ID       int               `json:"id"`
+	Title    string            `json:"title,omitempty"`
+	Secret   string            `json:"secret,writeonly"`
+	Created  time.Time         `json:"created"`
+	Owner    *projectedOwner   `json:"owner"`
+	Editors  []projectedOwner  `json:"editors"`
+	Labels   map[string]string `json:"labels"`
+	Extra    any               `json:"extra"`
+	Untagged bool
+}
+
+var projectTests = []struct {
+	name          string
+	fields        []string
+	expected      string
+	errorExpected string
+}{
+	{name: "top level", fields: []string{"id", "title", "Untagged"}, expected: `{"Untagged":true,"id":7,"title":""}`},
+	{name: "nested", fields: []string{"owner.name", "created"}, expected: `{"created":"2024-05-01T00:00:00Z","owner":{"name":"Ann"}}`},
+	{name: "whole wins", fields: []string{"owner.name", "owner"}, expected: `{"owner":{"name":"Ann","email":"ann@example.com"}}`},
+	{name: "through slice", fields: []string{"editors.email"}, expected: `{"editors":[{"email":"bo@example.com"},{"email":"cy@example.com"}]}`},
+	{name: "map keys", fields: []string{"labels.color", "labels.missing"}, expected: `{"labels":{"color":"red"}}`},
+	{name: "interface", fields: []string{"extra.name"}, expected: `{"extra":{"name":"Di"}}`},
+	{name: "none", fields: nil, expected: `{}`},
+	{name: "unknown", fields: []string{"owner.age"}, errorExpected: "owner.age is not a known field"},
+	{name: "write only", fields: []string{"secret"}, errorExpected: "secret is not a known field"},
+	{name: "scalar", fields: []string{"id.x"}, errorExpected: "id has no fields to select"},
+	{name: "marshaler", fields: []string{"created.year"}, errorExpected: "created has no fields to select"},
+	{name: "interface value", fields: []string{"extra.age"}, errorExpected: "extra.age is not a known field"},
+	{name: "empty segment", fields: []string{"owner..name"}, errorExpected: "owner..name is not a valid field path"},
+}
+
+func TestProject(t *testing.T) {
+	doc := &projectedDoc{
+		ID:       7,
+		Secret:   "s",
+		Created:  time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		Owner:    &projectedOwner{Name: "Ann", Email: "ann@example.com"},
+		Editors:  []projectedOwner{{Name: "Bo", Email: "bo@example.com"}, {Name: "Cy", Email: "cy@example.com"}},
+		Labels:   map[string]string{"color": "red", "size": "L"},
+		Extra:    projectedOwner{Name: "Di"},
+		Untagged: true,
+	}
+
+	for _, e := range projectTests {
+		got, err := Project(doc, e.fields)
+
+		if e.errorExpected != "" {
+			if err == nil || err.Error() != e.errorExpected {
+				t.Errorf("%s: expected error %q, got %v", e.name, e.errorExpected, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+			continue
+		}
+		b, _ := json.Marshal(got)
+		if string(b) != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, b)
+		}
+	}
+}
+
+func TestProject_NotAStruct(t *testing.T) {
+	if _, err := Project([]int{1}, []string{"a"}); err == nil {
+		t.Error("expected an error projecting a slice")
+	}
+	var doc *projectedDoc
+	if got, err := Project(doc, []string{"id"}); got != nil || err != nil {
+		t.Errorf("expected nil for a nil pointer, got %v, %v", got, err)
+	}
+}
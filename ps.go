@@ -1,12 +1,18 @@
 package ps
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // defaultMaxPayload is the default max payload size (10 mb)
@@ -14,42 +20,331 @@ const defaultMaxPayload = 10485760
 
 // Parser is the type for this package. Create a variable of this type, and you have access
 // to all the exported methods with the receiver type *Parser.
+//
+// A Parser may be used by any number of goroutines at once, provided its configuration is not changed while it is
+// in use. Call Freeze once it is set up to have later registrations panic rather than race.
 type Parser struct {
 	// MaxJSONSize is the size of JSON file we'll process
 	MaxJSONSize int
 	// AllowUnknownFields is a toggle if set to true, allow unknown fields in JSON
 	AllowUnknownFields bool
+	// APIVersions lists the API versions SelectVersion and Versioned accept, the first being the default
+	APIVersions []string
+	// AllowEmptyBody makes ReadJSON treat a completely empty body as the zero value of its destination, for
+	// endpoints whose body is optional, rather than an error
+	AllowEmptyBody bool
+	// AllowedFields, if set, lists the only keys ReadJSON binds, whether or not AllowUnknownFields is set, with
+	// dotted paths such as address.city for nested ones; a listed key allows everything below it. It guards
+	// against mass assignment on routes that share request types, and is usually set per route with With
+	AllowedFields []string
+	// Canonical makes WriteJSON produce byte-stable output in the RFC 8785 canonical form, for responses that are
+	// hashed or signed; it takes precedence over Pretty
+	Canonical bool
+	// Decompress makes ReadJSON accept gzip and deflate bodies, within MaxDecompressedSize and MaxExpansionRatio;
+	// other content codings are always refused
+	Decompress bool
+	// Diagnostics makes ReadJSON attach a DecodeDiagnostics report to its errors, for the handler to log; it keeps
+	// the whole body in memory
+	Diagnostics bool
+	// DisallowedFields controls whether ReadJSON drops the keys AllowedFields leaves out (the default) or rejects
+	// bodies that have them
+	DisallowedFields DisallowedFields
+	// Disclosure controls whether ErrorJSON sends error messages as they are (the default), replaces them with
+	// generic ones for production, or adds debugging detail
+	Disclosure Disclosure
+	// DurationFormat is the wire format of time.Duration values: integer nanoseconds (the default), DurationString or
+	// DurationSeconds; a format struct tag overrides it for one field
+	DurationFormat string
+	// EmptyFields controls whether WriteJSON follows omitempty tags (the default), writes every field or omits
+	// every empty one
+	EmptyFields EmptyFields
+	// FloatFormat, if set, controls the decimals and notation WriteJSON uses for floats
+	FloatFormat *FloatFormat
+	// FullDuplex lets handlers go on reading the request body after a StreamWriter or FrameWriter has started the
+	// response, for bidirectional streams over HTTP/1.1, which Go's server otherwise refuses
+	FullDuplex bool
+	// Int64AsString sends int64 and uint64 values as JSON strings, which JavaScript clients can hold without losing
+	// precision; ReadJSON accepts either form. The format:"string" struct tag does the same for one field
+	Int64AsString bool
+	// Languages lists the languages responses are available in, the first being the default
+	Languages []string
+	// LenientCoercion makes ReadForm and ReadQuery accept yes, no, on and off for booleans, and surrounding
+	// whitespace and thousands separators in numbers, as HTML checkboxes and third-party forms send them
+	LenientCoercion bool
+	// Logger receives the errors that ErrorJSON hides from clients under ProductionDisclosure (default
+	// slog.Default())
+	Logger *slog.Logger
+	// MaxDecompressedSize, if positive, is the largest a compressed body may expand to (default the body size
+	// limit; a streamed body is only limited by its elements)
+	MaxDecompressedSize int
+	// MaxDepth, if positive, is how deeply ReadJSON lets arrays and objects nest
+	MaxDepth int
+	// MaxExpansionRatio is how many times its compressed size a body may expand to once past 64 KiB (default 100)
+	MaxExpansionRatio int
+	// MaxResponseSize, if positive, is the largest response body WriteJSON will send as usual; OversizedResponse
+	// says what happens to larger ones
+	MaxResponseSize int
+	// Methods overrides the size limit, content types and body requirement for individual HTTP methods
+	Methods map[string]MethodPolicy
+	// NonFinite controls whether WriteJSON rejects NaN and infinite floats (the default) or writes them as null or
+	// strings
+	NonFinite NonFinite
+	// OversizedResponse controls whether WriteJSON rejects (the default), truncates or streams responses larger than
+	// MaxResponseSize
+	OversizedResponse OversizedResponse
+	// PollTimeout controls whether LongPoll answers a poll that times out with 204 No Content (the default) or an
+	// envelope
+	PollTimeout PollTimeout
+	// Pretty indents the JSON written by WriteJSON, for debugging
+	Pretty bool
+	// ReadOnlyFields controls whether ReadJSON and ReadForm ignore fields tagged readonly that a request sends (the
+	// default) or reject the request
+	ReadOnlyFields ReadOnlyFields
+	// RejectDuplicateKeys makes ReadJSON reject objects that repeat a key, which encoding/json would otherwise
+	// resolve silently in favour of the last one
+	RejectDuplicateKeys bool
+	// ReplayGuard, if set, is checked by ReadJSON before the body is decoded
+	ReplayGuard *ReplayGuard
+	// RequestDigest controls whether ReadJSON ignores digest headers (the default), checks the body against those
+	// a request has, or requires one; the body is held in memory to check it
+	RequestDigest DigestPolicy
+	// RequestIDHeader is the header the RequestID middleware reads and echoes, and WebhookSender sends, request IDs
+	// in (default X-Request-ID)
+	RequestIDHeader string
+	// RequireContentType makes ReadJSON reject bodies sent without a Content-Type header
+	RequireContentType bool
+	// ResponseDigest makes WriteJSON send the SHA-256 digest of each body it writes, in Digest and Content-Digest
+	// headers
+	ResponseDigest bool
+	// SortKeys makes WriteJSON write the keys of every object in sorted order, including struct fields and JSON
+	// from json.RawMessage values and custom marshalers; Go maps are always written with sorted keys
+	SortKeys bool
+	// TagName is the struct tag read for field names and options, falling back to the json tag for fields that
+	// do not have it (default json)
+	TagName string
+	// TimeFormat is the wire format of time.Time values: TimeRFC3339 (the default), TimeUnix, TimeUnixMilli or a
+	// layout for time.Parse; a format struct tag overrides it for one field
+	TimeFormat string
+	// TrustRawJSON makes WriteJSON send json.RawMessage, []byte and RawJSONSource payloads without checking that
+	// they are valid JSON, and stream RawJSONSource ones straight to the client when nothing needs the whole body
+	TrustRawJSON bool
+	// UnexpectedBody controls how CheckBody treats GET, HEAD and DELETE requests that carry a body
+	UnexpectedBody UnexpectedBody
+	// VersionHeader is the header used to negotiate the API version (default API-Version)
+	VersionHeader string
+	// Warnings controls whether WriteJSON sends the warnings added with AddWarning in Warning headers and
+	// JSONResponse envelopes (the default), or only in one of them
+	Warnings Warnings
+	// WriteTimeout, if positive, is how long WriteJSON has to write a response, and a StreamWriter or FrameWriter
+	// each value, before the connection is closed; it overrides the server's WriteTimeout for the rest of the
+	// response, so that long streams can outlive it
+	WriteTimeout time.Duration
+
+	afterDecode   []AfterDecodeHook
+	afterEncode   []AfterEncodeHook
+	beforeDecode  []BeforeDecodeHook
+	beforeEncode  []BeforeEncodeHook
+	canned        map[string]bool
+	cannedBodies  atomic.Pointer[sync.Map]
+	decoders      *decoderSet
+	frozen        bool
+	onBind        []BindCallback
+	onDecodeError []DecodeErrorCallback
+	onWrite       []WriteCallback
+	stats         atomic.Pointer[counters]
+	versions      map[versionKey]VersionTransform
 }
 
-// JSONResponse is the type used for sending JSON around.
+// JSONResponse is the type used for sending JSON around. Meta and Links are usually filled in by the options
+// passed to WriteEnvelope, and Warnings by AddWarning.
 type JSONResponse struct {
-	Error   bool   `json:"error"`
-	Message string `json:"message"`
-	Data    any    `json:"data,omitempty"`
+	Error    bool           `json:"error"`
+	Message  string         `json:"message"`
+	Data     any            `json:"data,omitempty"`
+	Fields   []FieldError   `json:"fields,omitempty"`
+	Meta     *ResponseMeta  `json:"meta,omitempty"`
+	Links    *ResponseLinks `json:"links,omitempty"`
+	Warnings []string       `json:"warnings,omitempty"`
 }
 
 // ReadJSON tries to read the body of a request and converts it from JSON to a variable. The third parameter, data,
 // is expected to be a pointer, so that we can read data into it.
 func (p *Parser) ReadJSON(w http.ResponseWriter, r *http.Request, data any) error {
-	// Check content-type header; it should be application/json. If it's not specified,
-	// try to decode the body anyway.
-	if r.Header.Get("Content-Type") != "" {
-		contentType := r.Header.Get("Content-Type")
-		if strings.ToLower(contentType) != "application/json" {
-			return errors.New("the Content-Type header is not application/json")
+	err := p.readJSON(w, r, data)
+	p.countDecode(err)
+	p.notifyDecode(r, data, err)
+	return err
+}
+
+// readJSON does the work of ReadJSON.
+func (p *Parser) readJSON(w http.ResponseWriter, r *http.Request, data any) error {
+	// Reject replayed requests before doing any work on them.
+	if p.ReplayGuard != nil {
+		if err := p.ReplayGuard.Check(r); err != nil {
+			return err
 		}
 	}
 
-	// Set a sensible default for the maximum payload size.
-	maxBytes := defaultMaxPayload
+	policy := p.Methods[r.Method]
 
-	// If MaxJSONSize is set, use that value instead of default.
-	if p.MaxJSONSize != 0 {
-		maxBytes = p.MaxJSONSize
+	// Check content-type header; it should be application/json, or one of the types allowed for this method.
+	// If it's not specified, try to decode the body anyway, unless the Parser requires one.
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" && p.RequireContentType && hasBody(r) {
+		return errors.New("the Content-Type header is missing")
+	}
+	if contentType != "" {
+		allowed := policy.ContentTypes
+		if len(allowed) == 0 {
+			allowed = []string{"application/json"}
+		}
+		if !containsFold(allowed, mediaType(contentType)) {
+			return fmt.Errorf("the Content-Type header is not %s", strings.Join(allowed, " or "))
+		}
+	}
+
+	// Compressed bytes would only confuse the decoder; say what is wrong with them instead.
+	if err := p.checkEncoding(r); err != nil {
+		return err
+	}
+
+	// Some methods may be sent without a body, or must be.
+	switch policy.Body {
+	case BodyOptional:
+		if !hasBody(r) {
+			return nil
+		}
+	case BodyForbidden:
+		if hasBody(r) {
+			return fmt.Errorf("%s %w", r.Method, ErrUnexpectedBody)
+		}
+		return nil
+	}
+	if p.AllowEmptyBody && !hasBody(r) {
+		return p.decodeEmpty(r, data)
+	}
+
+	// Time the decoding, in case the ServerTiming middleware is collecting metrics.
+	defer TimingsFrom(r.Context()).Start("decode")()
+
+	// Refuse a body that announces itself as too large without reading any of it. MaxBytesReader catches the rest
+	// as they arrive, and has the server close the connection rather than drain them.
+	maxBytes := p.maxPayload(r.Method)
+	if r.ContentLength > int64(maxBytes) {
+		return decodeError(&http.MaxBytesError{Limit: int64(maxBytes)}, maxBytes)
 	}
 	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
 
-	dec := json.NewDecoder(r.Body)
+	counted := &countingReader{r: r.Body}
+	defer func() { p.count(bytesRead, counted.n) }()
+	in := decodeInput{body: counted, maxBytes: maxBytes, size: r.ContentLength,
+		after: func(data any) error { return p.runAfterDecode(r, data) }}
+
+	if len(p.beforeDecode) > 0 || p.RequestDigest != IgnoreDigest || p.needsWholeBody() {
+		// The digest covers the body as it was sent, before it is decompressed and any hook rewrites it.
+		var plain *bytes.Buffer
+		defer func() {
+			if plain != nil {
+				releaseBody(plain)
+			}
+		}()
+		in.prepare = func(b []byte) ([]byte, error) {
+			if err := p.checkDigest(r, b); err != nil {
+				return nil, err
+			}
+			if len(contentCodings(r)) > 0 {
+				var err error
+				if plain, err = p.decompressBuffer(r, bytes.NewBuffer(b), maxBytes); err != nil {
+					return nil, err
+				}
+				b = plain.Bytes()
+			}
+			return p.runBeforeDecode(r, b)
+		}
+		return p.decodeJSON(in, data)
+	}
+
+	body, err := p.decompressed(r, in.body, p.maxDecompressed(maxBytes))
+	if err != nil {
+		return err
+	}
+	in.body = body
+	return p.decodeJSON(in, data)
+}
+
+// decodeEmpty sets data, for an empty body, to the zero value of the type it points to and runs the AfterDecode
+// hooks.
+func (p *Parser) decodeEmpty(r *http.Request, data any) error {
+	if err := setZero(data); err != nil {
+		return err
+	}
+	return p.runAfterDecode(r, data)
+}
+
+// errorStatus returns the status to send err with: the first of status if there is one, else 413 for a
+// *RequestTooLargeError, 415 for an *UnsupportedEncodingError and 400 for anything else.
+func errorStatus(err error, status []int) int {
+	if len(status) > 0 {
+		return status[0]
+	}
+	var tooLarge *RequestTooLargeError
+	var unsupported *UnsupportedEncodingError
+	switch {
+	case errors.As(err, &tooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.As(err, &unsupported):
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusBadRequest
+}
+
+// setZero sets the value data points to to its zero value.
+func setZero(data any) error {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return decodeError(&json.InvalidUnmarshalError{Type: reflect.TypeOf(data)}, 0)
+	}
+	v.Elem().SetZero()
+	return nil
+}
+
+// maxPayload returns the largest body accepted for method: the method's own limit if it has one, else MaxJSONSize,
+// else a sensible default.
+func (p *Parser) maxPayload(method string) int {
+	if limit := p.Methods[method].MaxJSONSize; limit != 0 {
+		return limit
+	}
+	if p.MaxJSONSize != 0 {
+		return p.MaxJSONSize
+	}
+	return defaultMaxPayload
+}
+
+// decodeBody decodes the single JSON value in body into data and runs the AfterDecode hooks. maxBytes is the
+// limit body was read under, for error messages.
+func (p *Parser) decodeBody(r *http.Request, body io.Reader, data any, maxBytes int) error {
+	if err := p.decodeValue(body, data, maxBytes); err != nil {
+		return err
+	}
+	return p.runAfterDecode(r, data)
+}
+
+// decodeValue decodes the single JSON value in body into data, applying the Parser's rules for unknown,
+// required and read-only fields. maxBytes is the limit body was read under, for error messages.
+func (p *Parser) decodeValue(body io.Reader, data any, maxBytes int) error {
+	// Some destinations need the body rewritten before encoding/json sees it, e.g. to convert time formats.
+	var tree any
+	if t := reflect.TypeOf(data); t != nil && t.Kind() == reflect.Pointer {
+		if o := p.decodeOptions(); o.needs(t.Elem()) {
+			prepared, prepTree, err := o.prepareBody(body, t.Elem())
+			if err != nil {
+				return decodeError(err, maxBytes)
+			}
+			body, tree = bytes.NewReader(prepared), prepTree
+		}
+	}
+
+	dec := json.NewDecoder(body)
 
 	// Should we allow unknown fields?
 	if !p.AllowUnknownFields {
@@ -60,49 +355,163 @@ func (p *Parser) ReadJSON(w http.ResponseWriter, r *http.Request, data any) erro
 	// response.
 	err := dec.Decode(data)
 	if err != nil {
-		var syntaxError *json.SyntaxError
-		var unmarshalTypeError *json.UnmarshalTypeError
-		var invalidUnmarshalError *json.InvalidUnmarshalError
-
-		switch {
-		case errors.As(err, &syntaxError):
-			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+		return decodeError(err, maxBytes)
+	}
 
-		case errors.Is(err, io.ErrUnexpectedEOF):
-			return errors.New("body contains badly-formed JSON")
+	// A prepared body was re-encoded from a single value, so only a raw one needs checking for more.
+	if tree == nil {
+		if err := checkEOF(dec); err != nil {
+			return err
+		}
+	}
 
-		case errors.As(err, &unmarshalTypeError):
-			return fmt.Errorf("body contains incorrect JSON type for field %q at offset %d", unmarshalTypeError.Field, unmarshalTypeError.Offset)
+	// Values of types with a registered DecodeFunc were decoded while preparing the body; store them now.
+	if p.decoders != nil && tree != nil {
+		assignDecoded(tree, reflect.ValueOf(data))
+	}
 
-		case errors.Is(err, io.EOF):
-			return errors.New("body must not be empty")
+	return nil
+}
 
-		case strings.HasPrefix(err.Error(), "json: unknown field "):
-			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
-			return fmt.Errorf("body contains unknown key %s", fieldName)
+// errMultipleValues is returned when a body holds more than one JSON value.
+var errMultipleValues = errors.New("body must only contain a single JSON value")
 
-		case err.Error() == "http: request body too large":
-			return fmt.Errorf("body must not be larger than %d bytes", maxBytes)
+// checkEOF reports errMultipleValues if dec has anything but whitespace left. Reading a single token, rather
+// than decoding whatever follows, keeps the common case free of allocations.
+func checkEOF(dec *json.Decoder) error {
+	if _, err := dec.Token(); err != io.EOF {
+		return errMultipleValues
+	}
+	return nil
+}
 
-		case errors.As(err, &invalidUnmarshalError):
-			return fmt.Errorf("error unmarshalling json: %s", err.Error())
+// RequestTooLargeError is returned when a request body is larger than it may be, whether the limit is the
+// Parser's own, one set by an http.MaxBytesReader further out, or one on how far a compressed body may expand.
+// ErrorJSON answers it with 413 Request Entity Too Large, unless given another status.
+type RequestTooLargeError struct {
+	// Limit is the largest the body may be, in bytes.
+	Limit int64
+	// Err is the cause, if a limit other than the body's size was reached, such as ErrDecompressionLimit.
+	Err error
+}
 
-		default:
-			return err
-		}
+// Error implements the error interface.
+func (e *RequestTooLargeError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
 	}
+	return fmt.Sprintf("body must not be larger than %d bytes", e.Limit)
+}
 
-	err = dec.Decode(&struct{}{})
-	if err != io.EOF {
-		return errors.New("body must only contain a single JSON value")
-	}
+// Unwrap returns the cause, if any.
+func (e *RequestTooLargeError) Unwrap() error {
+	return e.Err
+}
 
-	return nil
+// decodeError translates an error from encoding/json, or from reading a body limited to maxBytes, into a
+// human-readable one.
+func decodeError(err error, maxBytes int) error {
+	var maxBytesError *http.MaxBytesError
+	var syntaxError *json.SyntaxError
+	var unmarshalTypeError *json.UnmarshalTypeError
+	var invalidUnmarshalError *json.InvalidUnmarshalError
+
+	switch {
+	case errors.As(err, &syntaxError):
+		return &decodeFailure{class: syntaxFailure, message: fmt.Sprintf("body contains badly-formed JSON (at character %d)", syntaxError.Offset), offset: syntaxError.Offset}
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &decodeFailure{class: syntaxFailure, message: "body contains badly-formed JSON"}
+
+	case errors.As(err, &unmarshalTypeError):
+		return &decodeFailure{class: typeFailure, message: fmt.Sprintf("body contains incorrect JSON type for field %q at offset %d", unmarshalTypeError.Field, unmarshalTypeError.Offset), offset: unmarshalTypeError.Offset}
+
+	case errors.Is(err, io.EOF):
+		return &decodeFailure{class: emptyFailure, message: "body must not be empty"}
+
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		return &decodeFailure{class: unknownFieldFailure, message: "body contains unknown key " + fieldName}
+
+	case errors.As(err, &maxBytesError):
+		// The limit that tripped may be tighter than the Parser's, if the server set one of its own.
+		return &RequestTooLargeError{Limit: maxBytesError.Limit}
+
+	case err.Error() == "http: request body too large":
+		return &RequestTooLargeError{Limit: int64(maxBytes)}
+
+	case errors.As(err, &invalidUnmarshalError):
+		return &decodeFailure{class: otherFailure, message: "error unmarshalling json: " + err.Error()}
+
+	default:
+		return err
+	}
 }
 
 // WriteJSON takes a response status code and arbitrary data and writes a JSON response to the client.
+//
+// Payloads that are already JSON, a json.RawMessage, a []byte or a RawJSONSource, are sent as they are, after
+// checking that they are valid unless TrustRawJSON is set; they are only reformatted if Pretty, SortKeys or
+// Canonical is set. A nil one is sent as null. Bytes in a []byte payload are therefore no longer sent as a base64
+// string; wrap them in a struct to have them encoded.
 func (p *Parser) WriteJSON(w http.ResponseWriter, status int, data any, headers ...http.Header) error {
-	out, err := json.Marshal(data)
+	return p.writeAs(w, "application/json", status, data, headers)
+}
+
+// writeAs writes a JSON response with the given Content-Type, counting it in the Parser's stats.
+func (p *Parser) writeAs(w http.ResponseWriter, contentType string, status int, data any, headers []http.Header) error {
+	if len(p.onWrite) == 0 {
+		return p.countWrite(p.writeJSON(w, contentType, status, data, headers))
+	}
+
+	// Count what is written, for the OnWrite callbacks.
+	sw := &sizeWriter{ResponseWriter: w}
+	start := time.Now()
+	err := p.countWrite(p.writeJSON(sw, contentType, status, data, headers))
+	p.notifyWrite(w, WriteInfo{Status: status, Size: sw.n, Duration: time.Since(start), Err: err})
+	return err
+}
+
+// countWrite records the outcome of a WriteJSON call, and returns its error.
+func (p *Parser) countWrite(err error) error {
+	if err != nil {
+		p.count(encodeFailures, 1)
+	} else {
+		p.count(encodes, 1)
+	}
+	return err
+}
+
+// writeJSON does the work of WriteJSON.
+func (p *Parser) writeJSON(w http.ResponseWriter, contentType string, status int, data any, headers []http.Header) error {
+	// Put the warnings the handler attached where the client will look for them.
+	data = p.withWarnings(w, data)
+
+	// If the client negotiated an API version, reshape the payload for it. Once any transform is registered the
+	// body depends on the version header, so caches must key on it.
+	if len(p.versions) > 0 {
+		AddVary(w, p.versionHeader())
+	}
+	data, err := p.transformVersion(w.Header().Get(p.versionHeader()), data)
+	if err != nil {
+		return err
+	}
+
+	data, err = p.runBeforeEncode(w, status, data)
+	if err != nil {
+		return err
+	}
+
+	// Time the encoding, in case the ServerTiming middleware is collecting metrics.
+	timings := timingsOf(w)
+	stop := timings.Start("encode")
+	out, src, err := p.encodeValue(data)
+	if err != nil {
+		return err
+	}
+	stop()
+
+	out, err = p.runAfterEncode(w, status, out)
 	if err != nil {
 		return err
 	}
@@ -114,10 +523,35 @@ func (p *Parser) WriteJSON(w http.ResponseWriter, status int, data any, headers
 		}
 	}
 
+	// Report the metrics gathered during the request.
+	if timings != nil {
+		w.Header().Set("Server-Timing", timings.header())
+	}
+
+	if p.oversized(out) {
+		return p.writeOversized(w, contentType, status, out)
+	}
+
+	p.setDigest(w, out)
+
+	if p.WriteTimeout > 0 {
+		if err := p.writeDeadline(http.NewResponseController(w)); err != nil {
+			return err
+		}
+	}
+
 	// Set the content type and send response.
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(status)
-	_, err = w.Write(out)
+	var n int64
+	if src != nil {
+		n, err = src.WriteTo(w)
+	} else {
+		var written int
+		written, err = w.Write(out)
+		n = int64(written)
+	}
+	p.count(bytesWritten, n)
 	if err != nil {
 		return err
 	}
@@ -125,20 +559,43 @@ func (p *Parser) WriteJSON(w http.ResponseWriter, status int, data any, headers
 	return nil
 }
 
+// encodeValue encodes data with the Parser's settings. A payload that is already JSON is used as it is, and a
+// RawJSONSource may be returned as a source to stream instead.
+func (p *Parser) encodeValue(data any) ([]byte, io.WriterTo, error) {
+	if pre, ok := data.(*PrecomputedJSON); ok {
+		// Precomputed payloads are shared, so hooks must not be able to change them.
+		if len(p.afterEncode) > 0 {
+			return bytes.Clone(pre.body), nil, nil
+		}
+		return pre.body, nil, nil
+	}
+	if raw, src, ok := rawJSON(data); ok {
+		// Pre-serialized payloads are sent as they are, rather than decoded and encoded again.
+		return p.rawBody(raw, src)
+	}
+	out, err := p.marshal(data)
+	return out, nil, err
+}
+
 // ErrorJSON takes an error, and optionally a response status code, and generates and sends
-// a JSON error response.
+// a JSON error response. Without a status code, a *RequestTooLargeError is sent with 413, an
+// *UnsupportedEncodingError with 415 and anything else with 400.
 func (p *Parser) ErrorJSON(w http.ResponseWriter, err error, status ...int) error {
-	statusCode := http.StatusBadRequest
+	statusCode := errorStatus(err, status)
 
-	// If a custom response code is specified, use that instead of bad request.
-	if len(status) > 0 {
-		statusCode = status[0]
+	// The commonest errors are encoded once and their bytes reused.
+	if pre, cerr := p.cannedError(w, err, statusCode); cerr != nil || pre != nil {
+		if cerr != nil {
+			return cerr
+		}
+		return p.WriteJSON(w, statusCode, pre)
 	}
 
 	// Build the JSON payload.
 	var payload JSONResponse
 	payload.Error = true
 	payload.Message = err.Error()
+	payload.Fields = fieldErrors(err)
 
-	return p.WriteJSON(w, statusCode, payload)
+	return p.WriteJSON(w, statusCode, p.disclose(payload, err, statusCode))
 }
@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 var jsonTests = []struct {
@@ -167,3 +170,99 @@ func TestParser_ErrorJSON(t *testing.T) {
 		t.Errorf("wrong status code returned; expected 503, but got %d", rr.Code)
 	}
 }
+
+func TestParser_ReadJSONMaxBytesReader(t *testing.T) {
+	var testParser Parser
+
+	// The server's own limit is tighter than the Parser's, and the body does not announce its length.
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Body = http.MaxBytesReader(rr, io.NopCloser(strings.NewReader(`{"foo": "a long enough value"}`)), 16)
+	req.ContentLength = -1
+
+	var decoded map[string]string
+	err := testParser.ReadJSON(rr, req, &decoded)
+
+	var tooLarge *RequestTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 16 {
+		t.Fatalf("expected a *RequestTooLargeError with a limit of 16, got %v", err)
+	}
+	if testParser.Stats().TooLarge != 1 {
+		t.Errorf("expected the failure to be counted as too large, got %+v", testParser.Stats())
+	}
+
+	_ = testParser.ErrorJSON(rr, err)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413, got %d", rr.Code)
+	}
+	if expected := `{"error":true,"message":"body must not be larger than 16 bytes"}`; rr.Body.String() != expected {
+		t.Errorf("expected %s, got %s", expected, rr.Body.String())
+	}
+}
+
+// resettableBody is a request body that can be rewound, so benchmarks can reuse one request.
+type resettableBody struct {
+	bytes.Reader
+	data []byte
+}
+
+func (b *resettableBody) Close() error { return nil }
+
+func (b *resettableBody) reset() { b.Reset(b.data) }
+
+func BenchmarkParser_ReadJSON(b *testing.B) {
+	var testParser Parser
+	body := &resettableBody{data: []byte(`{"foo":"bar","count":3,"tags":["a","b"]}`)}
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	var decoded struct {
+		Foo   string   `json:"foo"`
+		Count int      `json:"count"`
+		Tags  []string `json:"tags"`
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		body.reset()
+		req.Body = body
+		if err := testParser.ReadJSON(rr, req, &decoded); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParser_ReadJSONPrepared(b *testing.B) {
+	testParser := Parser{Int64AsString: true, TimeFormat: TimeUnix}
+	body := &resettableBody{data: []byte(`{"foo":"bar","at":1704164645,"id":"9007199254740993"}`)}
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	rr := httptest.NewRecorder()
+
+	var decoded struct {
+		Foo string    `json:"foo"`
+		At  time.Time `json:"at"`
+		ID  int64     `json:"id"`
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		body.reset()
+		req.Body = body
+		if err := testParser.ReadJSON(rr, req, &decoded); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParser_WriteJSON(b *testing.B) {
+	var testParser Parser
+	payload := JSONResponse{Message: "ok", Data: map[string]any{"foo": "bar", "count": 3}}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := testParser.WriteJSON(httptest.NewRecorder(), http.StatusOK, payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
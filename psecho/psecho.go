@@ -0,0 +1,63 @@
+// Package psecho adapts ps to the echo web framework. echo's Context satisfies the Context interface declared
+// here, which names only the methods psecho calls, so the package does not depend on echo:
+//
+//	func createOrder(c echo.Context) error {
+//		var order Order
+//		if err := psecho.Bind(p, c, &order); err != nil {
+//			return p.ErrorJSON(c.Response(), err)
+//		}
+//		return p.WriteJSON(c.Response(), http.StatusCreated, order)
+//	}
+//
+// echo's Response is an http.ResponseWriter, so responses need no adapter: WriteJSON, WriteEnvelope,
+// WriteNegotiated and ErrorJSON take c.Response() as it is.
+package psecho
+
+import (
+	"net/http"
+
+	ps "github.com/brizaldi/go-parse"
+)
+
+// Context is the part of echo's Context that psecho uses.
+type Context interface {
+	Request() *http.Request
+	ParamNames() []string
+	ParamValues() []string
+}
+
+// Bind decodes the JSON body of c's request into data with a Parser's ReadJSON, so it gets the parser's size
+// limits, content type checks and field errors. A nil Parser uses the zero Parser.
+//
+// ReadJSON is given no ResponseWriter: echo's Response method returns echo's own *Response type, which Context
+// cannot name without importing echo. ReadJSON only uses the writer to ask the server to close the connection
+// after an oversized body, which is refused all the same. Handlers still pass c.Response() when writing.
+func Bind(p *ps.Parser, c Context, data any) error {
+	return parser(p).ReadJSON(nil, c.Request(), data)
+}
+
+// BindPath decodes the path parameters of c's route into data with a Parser's ReadPath. A nil Parser uses the
+// zero Parser.
+func BindPath(p *ps.Parser, c Context, data any) error {
+	names, values := c.ParamNames(), c.ParamValues()
+	params := make(map[string]string, len(names))
+	for i, name := range names {
+		if i < len(values) {
+			params[name] = values[i]
+		}
+	}
+	return parser(p).ReadPath(c.Request(), params, data)
+}
+
+// BindQuery decodes the query parameters of c's request into data with a Parser's ReadQuery. A nil Parser uses
+// the zero Parser.
+func BindQuery(p *ps.Parser, c Context, data any) error {
+	return parser(p).ReadQuery(c.Request(), data)
+}
+
+func parser(p *ps.Parser) *ps.Parser {
+	if p == nil {
+		return &ps.Parser{}
+	}
+	return p
+}
@@ -0,0 +1,58 @@
+package psecho
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	ps "github.com/brizaldi/go-parse"
+)
+
+// echoContext stands in for echo's Context, which holds the route parameters the router matched.
+type echoContext struct {
+	req    *http.Request
+	names  []string
+	values []string
+}
+
+func (c *echoContext) Request() *http.Request { return c.req }
+func (c *echoContext) ParamNames() []string   { return c.names }
+func (c *echoContext) ParamValues() []string  { return c.values }
+
+func TestBind(t *testing.T) {
+	testParser := ps.Parser{MaxJSONSize: 32}
+	var order struct {
+		ID int `json:"id"`
+	}
+
+	c := &echoContext{req: httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id":7}`))}
+	if err := Bind(&testParser, c, &order); err != nil || order.ID != 7 {
+		t.Errorf("expected id 7, got %d: %v", order.ID, err)
+	}
+
+	c = &echoContext{req: httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id":7,"name":"a long name for the limit"}`))}
+	if err := Bind(&testParser, c, &order); err == nil || !strings.Contains(err.Error(), "must not be larger than 32 bytes") {
+		t.Errorf("expected the size limit, got %v", err)
+	}
+}
+
+func TestBindPathAndQuery(t *testing.T) {
+	var params struct {
+		ID   int    `path:"id"`
+		Sort string `query:"sort" validate:"enum=asc|desc"`
+	}
+
+	c := &echoContext{req: httptest.NewRequest(http.MethodGet, "/orders/7?sort=asc", nil), names: []string{"id"}, values: []string{"7"}}
+	if err := BindPath(nil, c, &params); err != nil || params.ID != 7 {
+		t.Errorf("expected id 7, got %d: %v", params.ID, err)
+	}
+	if err := BindQuery(nil, c, &params); err != nil || params.Sort != "asc" {
+		t.Errorf("expected sort asc, got %q: %v", params.Sort, err)
+	}
+
+	c.values = []string{"seven"}
+	if err := BindPath(nil, c, &params); err == nil || !strings.Contains(err.Error(), "id") {
+		t.Errorf("expected a field error for id, got %v", err)
+	}
+}
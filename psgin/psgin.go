@@ -0,0 +1,90 @@
+// Package psgin adapts ps to the gin web framework. Its types implement gin's binding.Binding and render.Render
+// interfaces, which use only net/http types, so the package does not depend on gin:
+//
+//	var order Order
+//	if err := c.ShouldBindWith(&order, psgin.Binding{Parser: p}); err != nil {
+//		c.Render(http.StatusBadRequest, psgin.Error{Parser: p, Err: err})
+//		return
+//	}
+//	c.Render(http.StatusCreated, psgin.JSON{Parser: p, Data: order})
+package psgin
+
+import (
+	"net/http"
+
+	ps "github.com/brizaldi/go-parse"
+)
+
+// Binding binds a request body with a Parser's ReadJSON, so it gets the parser's size limits, content type
+// checks and field errors. A nil Parser uses the zero Parser.
+type Binding struct {
+	Parser *ps.Parser
+}
+
+// Name returns the name gin reports for the binding.
+func (b Binding) Name() string {
+	return "json"
+}
+
+// Bind decodes the JSON body of r into obj.
+func (b Binding) Bind(r *http.Request, obj any) error {
+	// gin does not hand the response writer to a binding; ReadJSON only uses it to ask the server to close the
+	// connection after an oversized body.
+	return parser(b.Parser).ReadJSON(nil, r, obj)
+}
+
+// JSON renders Data with a Parser's WriteJSON, so it gets the parser's encoding settings, version negotiation
+// and response hooks. A nil Parser uses the zero Parser.
+type JSON struct {
+	Parser *ps.Parser
+	Data   any
+}
+
+// Render writes Data with the status code passed to gin's Context.Render.
+func (j JSON) Render(w http.ResponseWriter) error {
+	code, ok := status(w)
+	if !ok {
+		code = http.StatusOK
+	}
+	return parser(j.Parser).WriteJSON(w, code, j.Data)
+}
+
+// WriteContentType sets the Content-Type of a JSON response.
+func (j JSON) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+}
+
+// Error renders Err with a Parser's ErrorJSON, as the error envelope of ps. A nil Parser uses the zero Parser.
+type Error struct {
+	Parser *ps.Parser
+	Err    error
+}
+
+// Render writes the error envelope with the status code passed to gin's Context.Render, or the status ErrorJSON
+// picks for Err if w does not report one.
+func (e Error) Render(w http.ResponseWriter) error {
+	if code, ok := status(w); ok {
+		return parser(e.Parser).ErrorJSON(w, e.Err, code)
+	}
+	return parser(e.Parser).ErrorJSON(w, e.Err)
+}
+
+// WriteContentType sets the Content-Type of a JSON response.
+func (e Error) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+}
+
+// status returns the status code gin has set on w ahead of rendering, if w reports one.
+func status(w http.ResponseWriter) (int, bool) {
+	if s, ok := w.(interface{ Status() int }); ok && s.Status() > 0 {
+		return s.Status(), true
+	}
+	return 0, false
+}
+
+func parser(p *ps.Parser) *ps.Parser {
+	if p == nil {
+		return &ps.Parser{}
+	}
+	return p
+}
@@ -0,0 +1,119 @@
+package psgin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	ps "github.com/brizaldi/go-parse"
+)
+
+// The interfaces gin's Context.ShouldBindWith and Context.Render take, copied from gin's binding and render
+// packages.
+type (
+	ginBinding interface {
+		Name() string
+		Bind(*http.Request, any) error
+	}
+	ginRender interface {
+		Render(http.ResponseWriter) error
+		WriteContentType(w http.ResponseWriter)
+	}
+)
+
+var (
+	_ ginBinding = Binding{}
+	_ ginRender  = JSON{}
+	_ ginRender  = Error{}
+)
+
+// ginWriter holds back the status code until the first write and reports it, as gin's ResponseWriter does.
+type ginWriter struct {
+	*httptest.ResponseRecorder
+	status  int
+	written bool
+}
+
+func (w *ginWriter) WriteHeader(code int) {
+	if !w.written {
+		w.status = code
+	}
+}
+
+func (w *ginWriter) Write(b []byte) (int, error) {
+	if !w.written {
+		w.written = true
+		w.ResponseRecorder.WriteHeader(w.status)
+	}
+	return w.ResponseRecorder.Write(b)
+}
+
+func (w *ginWriter) Status() int {
+	return w.status
+}
+
+// render does what gin's Context.Render does with r.
+func render(code int, r ginRender) (*httptest.ResponseRecorder, error) {
+	w := &ginWriter{ResponseRecorder: httptest.NewRecorder(), status: http.StatusOK}
+	w.WriteHeader(code)
+	r.WriteContentType(w)
+	return w.ResponseRecorder, r.Render(w)
+}
+
+func TestBinding(t *testing.T) {
+	testParser := ps.Parser{MaxJSONSize: 32}
+	var order struct {
+		ID int `json:"id"`
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id":7}`))
+	if err := (Binding{Parser: &testParser}).Bind(req, &order); err != nil || order.ID != 7 {
+		t.Errorf("expected id 7, got %d: %v", order.ID, err)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id":7,"name":"a long name for the limit"}`))
+	if err := (Binding{Parser: &testParser}).Bind(req, &order); err == nil || !strings.Contains(err.Error(), "must not be larger than 32 bytes") {
+		t.Errorf("expected the size limit, got %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"other":1}`))
+	if err := (Binding{}).Bind(req, &order); err == nil || !strings.Contains(err.Error(), `unknown key "other"`) {
+		t.Errorf("expected an unknown key error, got %v", err)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	testParser := ps.Parser{Pretty: true}
+
+	rr, err := render(http.StatusCreated, JSON{Parser: &testParser, Data: map[string]int{"id": 7}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusCreated || rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("expected a 201 JSON response, got %d %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if rr.Body.String() != "{\n  \"id\": 7\n}" {
+		t.Errorf("expected an indented body, got %q", rr.Body.String())
+	}
+}
+
+func TestError(t *testing.T) {
+	rr, err := render(http.StatusUnprocessableEntity, Error{Err: &ps.FieldError{Field: "email", Message: "is required"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), `"message":"email is required"`) || !strings.Contains(rr.Body.String(), `"field":"email"`) {
+		t.Errorf("expected the error envelope, got %s", rr.Body.String())
+	}
+
+	// Without a status to pick up, ErrorJSON chooses one.
+	rr = httptest.NewRecorder()
+	_ = Error{Err: &ps.FieldError{Field: "email", Message: "is required"}}.Render(rr)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rr.Code)
+	}
+}
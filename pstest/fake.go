@@ -0,0 +1,75 @@
+package pstest
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	ps "github.com/brizaldi/go-parse"
+)
+
+// FakeParser is a ps.JSONReadWriter for testing code that depends on the interfaces rather than on a Parser. Its
+// ReadJSON decodes a canned body or fails with a canned error, and its WriteJSON and ErrorJSON record what they
+// are given before writing it with a default Parser, so the response looks as it would in production.
+//
+// A FakeParser is safe for concurrent use.
+type FakeParser struct {
+	// Body is the JSON that ReadJSON decodes into its data argument.
+	Body string
+	// ReadErr, if not nil, is returned by ReadJSON instead, to simulate a decoding or validation failure.
+	ReadErr error
+
+	mu     sync.Mutex
+	writes []Written
+}
+
+// Written records a call to WriteJSON or ErrorJSON.
+type Written struct {
+	// Status is the status code of the response.
+	Status int
+	// Data is the payload given to WriteJSON, or nil for ErrorJSON.
+	Data any
+	// Err is the error given to ErrorJSON, or nil for WriteJSON.
+	Err error
+}
+
+var _ ps.JSONReadWriter = (*FakeParser)(nil)
+
+// ReadJSON implements ps.JSONReader.
+func (f *FakeParser) ReadJSON(w http.ResponseWriter, r *http.Request, data any) error {
+	if f.ReadErr != nil {
+		return f.ReadErr
+	}
+	return json.Unmarshal([]byte(f.Body), data)
+}
+
+// WriteJSON implements ps.JSONWriter.
+func (f *FakeParser) WriteJSON(w http.ResponseWriter, status int, data any, headers ...http.Header) error {
+	f.record(Written{Status: status, Data: data})
+	var p ps.Parser
+	return p.WriteJSON(w, status, data, headers...)
+}
+
+// ErrorJSON implements ps.JSONWriter.
+func (f *FakeParser) ErrorJSON(w http.ResponseWriter, err error, status ...int) error {
+	statusCode := http.StatusBadRequest
+	if len(status) > 0 {
+		statusCode = status[0]
+	}
+	f.record(Written{Status: statusCode, Err: err})
+	var p ps.Parser
+	return p.ErrorJSON(w, err, statusCode)
+}
+
+// Writes returns the calls to WriteJSON and ErrorJSON so far, in order.
+func (f *FakeParser) Writes() []Written {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Written(nil), f.writes...)
+}
+
+func (f *FakeParser) record(written Written) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.writes = append(f.writes, written)
+}
@@ -0,0 +1,45 @@
+package pstest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	ps "github.com/brizaldi/go-parse"
+)
+
+// createOrder is a handler written against the interfaces, as application code would be.
+func createOrder(rw ps.JSONReadWriter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var order struct {
+			Item string `json:"item"`
+		}
+		if err := rw.ReadJSON(w, r, &order); err != nil {
+			_ = rw.ErrorJSON(w, err)
+			return
+		}
+		_ = rw.WriteJSON(w, http.StatusCreated, map[string]string{"item": order.Item})
+	}
+}
+
+func TestFakeParser(t *testing.T) {
+	fake := &FakeParser{Body: `{"item":"book"}`}
+	rr := httptest.NewRecorder()
+	createOrder(fake)(rr, NewJSONRequest(http.MethodPost, "/orders", nil))
+
+	AssertStatus(t, rr, http.StatusCreated)
+	AssertJSONEq(t, `{"item":"book"}`, rr.Body.String())
+	if writes := fake.Writes(); len(writes) != 1 || writes[0].Status != http.StatusCreated || writes[0].Err != nil {
+		t.Errorf("expected one write, got %+v", writes)
+	}
+
+	fake = &FakeParser{ReadErr: errors.New("body must not be empty")}
+	rr = httptest.NewRecorder()
+	createOrder(fake)(rr, NewJSONRequest(http.MethodPost, "/orders", nil))
+
+	AssertErrorEnvelope(t, rr, http.StatusBadRequest, "body must not be empty")
+	if writes := fake.Writes(); len(writes) != 1 || writes[0].Err != fake.ReadErr || writes[0].Data != nil {
+		t.Errorf("expected the error to be recorded, got %+v", writes)
+	}
+}
@@ -0,0 +1,175 @@
+// Package pstest provides helpers for testing handlers that use ps: building JSON requests and checking the
+// responses written with ps.
+package pstest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	ps "github.com/brizaldi/go-parse"
+)
+
+// DecodeResponse decodes the JSON body of rr into a T, failing the test if the body is not JSON or does not fit a T.
+// Unknown keys are allowed, so a test can decode only the part of a response it checks.
+func DecodeResponse[T any](t testing.TB, rr *httptest.ResponseRecorder) T {
+	t.Helper()
+
+	var v T
+	if err := json.Unmarshal(rr.Body.Bytes(), &v); err != nil {
+		t.Fatalf("cannot decode the response into %T: %v\nbody: %s", v, err, rr.Body.String())
+	}
+	return v
+}
+
+// AssertStatus checks that rr has status code want, showing the body if it does not.
+func AssertStatus(t testing.TB, rr *httptest.ResponseRecorder, want int) {
+	t.Helper()
+
+	if rr.Code != want {
+		t.Errorf("expected status %d, got %d\nbody: %s", want, rr.Code, rr.Body.String())
+	}
+}
+
+// AssertErrorEnvelope checks that rr is a JSON error response, as ErrorJSON writes, with status code status and,
+// unless message is empty, that message. It returns the envelope, for checking its field errors.
+func AssertErrorEnvelope(t testing.TB, rr *httptest.ResponseRecorder, status int, message string) ps.JSONResponse {
+	t.Helper()
+
+	AssertStatus(t, rr, status)
+	if mt, _, _ := mime.ParseMediaType(rr.Header().Get("Content-Type")); mt != "application/json" {
+		t.Errorf("expected a JSON response, got Content-Type %q", rr.Header().Get("Content-Type"))
+	}
+
+	envelope := DecodeResponse[ps.JSONResponse](t, rr)
+	if !envelope.Error {
+		t.Errorf("expected an error envelope, got %s", rr.Body.String())
+	}
+	if message != "" && envelope.Message != message {
+		t.Errorf("expected the error message %q, got %q", message, envelope.Message)
+	}
+	return envelope
+}
+
+// AssertJSONEq checks that want and got hold the same JSON value, regardless of key order and formatting. They
+// may be strings, byte slices, or values to encode, such as a map or a struct. A mismatch is reported with the
+// first place the values differ and both values in full.
+func AssertJSONEq(t testing.TB, want, got any) {
+	t.Helper()
+
+	w, err := normalize(want)
+	if err != nil {
+		t.Fatalf("want is not valid JSON: %v", err)
+	}
+	g, err := normalize(got)
+	if err != nil {
+		t.Errorf("got is not valid JSON: %v", err)
+		return
+	}
+
+	if path, ok := firstDifference(w, g, "$"); !ok {
+		wb, _ := json.MarshalIndent(w, "", "  ")
+		gb, _ := json.MarshalIndent(g, "", "  ")
+		t.Errorf("JSON differs at %s\nwant: %s\ngot:  %s", path, wb, gb)
+	}
+}
+
+// normalize decodes v, or the encoding of v if it is not already JSON text, into plain Go values.
+func normalize(v any) (any, error) {
+	var b []byte
+	switch v := v.(type) {
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	case json.RawMessage:
+		b = v
+	default:
+		var err error
+		if b, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+
+	var out any
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("more than one JSON value")
+	}
+	return out, nil
+}
+
+// firstDifference compares want and got and, if they differ, returns the path of the first difference.
+func firstDifference(want, got any, path string) (string, bool) {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			return path, false
+		}
+		keys := make([]string, 0, len(w)+len(g))
+		for k := range w {
+			keys = append(keys, k)
+		}
+		for k := range g {
+			if _, ok := w[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			wv, inWant := w[k]
+			gv, inGot := g[k]
+			if inWant != inGot {
+				return path + "." + k, false
+			}
+			if p, ok := firstDifference(wv, gv, path+"."+k); !ok {
+				return p, false
+			}
+		}
+		return "", true
+
+	case []any:
+		g, ok := got.([]any)
+		if !ok {
+			return path, false
+		}
+		for i := 0; i < len(w) && i < len(g); i++ {
+			if p, ok := firstDifference(w[i], g[i], path+"["+strconv.Itoa(i)+"]"); !ok {
+				return p, false
+			}
+		}
+		if len(w) != len(g) {
+			return path, false
+		}
+		return "", true
+
+	case json.Number:
+		g, ok := got.(json.Number)
+		if !ok {
+			return path, false
+		}
+		// Compare numbers by value, so that 1, 1.0 and 1e0 are equal.
+		wf, werr := w.Float64()
+		gf, gerr := g.Float64()
+		if w != g && (werr != nil || gerr != nil || wf != gf) {
+			return path, false
+		}
+		return "", true
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		return path, false
+	}
+	return "", true
+}
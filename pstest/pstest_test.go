@@ -0,0 +1,118 @@
+package pstest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	ps "github.com/brizaldi/go-parse"
+)
+
+// recordingT records the failures of a helper instead of failing the test that runs it.
+type recordingT struct {
+	testing.TB
+	failures []string
+	fatal    bool
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...any) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func (t *recordingT) Fatalf(format string, args ...any) {
+	t.Errorf(format, args...)
+	t.fatal = true
+	// Stop the helper as testing.T.FailNow would, without ending the goroutine of the real test.
+	panic(t)
+}
+
+// run calls fn with a fresh recordingT and returns it once fn has returned or failed fatally.
+func run(fn func(t *recordingT)) (rt *recordingT) {
+	rt = &recordingT{}
+	defer func() {
+		if r := recover(); r != nil && r != rt {
+			panic(r)
+		}
+	}()
+	fn(rt)
+	return rt
+}
+
+var assertJSONEqTests = []struct {
+	name             string
+	want             any
+	got              any
+	expectedFailure  string
+	expectedFailures int
+}{
+	{name: "key order", want: `{"a":1,"b":[1,2]}`, got: []byte(`{ "b": [1, 2], "a": 1.0 }`)},
+	{name: "struct", want: struct {
+		A int `json:"a"`
+	}{1}, got: `{"a":1}`},
+	{name: "value", want: `{"a":{"b":[1,2]}}`, got: `{"a":{"b":[1,3]}}`, expectedFailure: "JSON differs at $.a.b[1]", expectedFailures: 1},
+	{name: "missing key", want: `{"a":1,"b":2}`, got: `{"a":1}`, expectedFailure: "JSON differs at $.b", expectedFailures: 1},
+	{name: "extra key", want: `{"a":1}`, got: `{"a":1,"c":null}`, expectedFailure: "JSON differs at $.c", expectedFailures: 1},
+	{name: "length", want: `[1]`, got: `[1,2]`, expectedFailure: "JSON differs at $\n", expectedFailures: 1},
+	{name: "type", want: `{"a":"1"}`, got: `{"a":1}`, expectedFailure: "JSON differs at $.a", expectedFailures: 1},
+	{name: "invalid", want: `{}`, got: `{`, expectedFailure: "got is not valid JSON", expectedFailures: 1},
+}
+
+func TestAssertJSONEq(t *testing.T) {
+	for _, e := range assertJSONEqTests {
+		rt := run(func(rt *recordingT) { AssertJSONEq(rt, e.want, e.got) })
+
+		if len(rt.failures) != e.expectedFailures {
+			t.Errorf("%s: expected %d failures, got %q", e.name, e.expectedFailures, rt.failures)
+			continue
+		}
+		if e.expectedFailure != "" && !strings.Contains(rt.failures[0], e.expectedFailure) {
+			t.Errorf("%s: expected a failure containing %q, got %q", e.name, e.expectedFailure, rt.failures[0])
+		}
+	}
+}
+
+func TestDecodeResponse(t *testing.T) {
+	var testParser ps.Parser
+	rr := httptest.NewRecorder()
+	_ = testParser.WriteJSON(rr, http.StatusOK, map[string]any{"name": "Ann", "age": 30})
+
+	type person struct {
+		Name string `json:"name"`
+	}
+	if p := DecodeResponse[person](t, rr); p.Name != "Ann" {
+		t.Errorf("expected Ann, got %q", p.Name)
+	}
+
+	rt := run(func(rt *recordingT) { DecodeResponse[[]person](rt, rr) })
+	if !rt.fatal || !strings.Contains(rt.failures[0], "cannot decode the response into []pstest.person") {
+		t.Errorf("expected a fatal failure, got %q", rt.failures)
+	}
+}
+
+func TestAssertErrorEnvelope(t *testing.T) {
+	var testParser ps.Parser
+	rr := httptest.NewRecorder()
+	_ = testParser.ErrorJSON(rr, &ps.FieldError{Field: "email", Message: "is required"}, http.StatusUnprocessableEntity)
+
+	envelope := AssertErrorEnvelope(t, rr, http.StatusUnprocessableEntity, "email is required")
+	if len(envelope.Fields) != 1 || envelope.Fields[0].Field != "email" {
+		t.Errorf("expected the field error, got %v", envelope.Fields)
+	}
+
+	rt := run(func(rt *recordingT) { AssertErrorEnvelope(rt, rr, http.StatusBadRequest, "other") })
+	if len(rt.failures) != 2 || !strings.HasPrefix(rt.failures[0], "expected status 400, got 422") ||
+		rt.failures[1] != `expected the error message "other", got "email is required"` {
+		t.Errorf("expected status and message failures, got %q", rt.failures)
+	}
+
+	rr = httptest.NewRecorder()
+	_ = testParser.WriteJSON(rr, http.StatusBadRequest, ps.JSONResponse{Message: "no"})
+	rt = run(func(rt *recordingT) { AssertErrorEnvelope(rt, rr, http.StatusBadRequest, "") })
+	if len(rt.failures) != 1 || !strings.HasPrefix(rt.failures[0], "expected an error envelope") {
+		t.Errorf("expected an envelope failure, got %q", rt.failures)
+	}
+}
@@ -0,0 +1,84 @@
+package pstest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+)
+
+// RequestBuilder builds a request with a JSON body for a handler test. Each With method changes the request and
+// returns the builder, so calls can be chained:
+//
+//	req := pstest.BuildJSONRequest(http.MethodPost, "/orders", order).
+//		WithBearer(token).
+//		WithQuery("dry_run", "true").
+//		Request()
+type RequestBuilder struct {
+	req *http.Request
+}
+
+// NewJSONRequest returns an incoming server request for target, as httptest.NewRequest does, with payload as its
+// JSON body. See BuildJSONRequest for the payloads it takes.
+func NewJSONRequest(method, target string, payload any) *http.Request {
+	return BuildJSONRequest(method, target, payload).Request()
+}
+
+// BuildJSONRequest starts building an incoming server request for target with payload as its JSON body. A
+// string, byte slice, json.RawMessage or io.Reader payload is sent as it is, so tests can send malformed JSON;
+// any other payload is encoded with encoding/json. A nil payload sends no body. Content-Type is set to
+// application/json whenever there is a body.
+//
+// It panics if payload cannot be encoded, as httptest.NewRequest panics on a bad target.
+func BuildJSONRequest(method, target string, payload any) *RequestBuilder {
+	var body io.Reader
+	switch p := payload.(type) {
+	case nil:
+	case string:
+		body = bytes.NewReader([]byte(p))
+	case []byte:
+		body = bytes.NewReader(p)
+	case json.RawMessage:
+		body = bytes.NewReader(p)
+	case io.Reader:
+		body = p
+	default:
+		b, err := json.Marshal(p)
+		if err != nil {
+			panic("pstest: cannot encode the request payload: " + err.Error())
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req := httptest.NewRequest(method, target, body)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return &RequestBuilder{req: req}
+}
+
+// WithHeader sets the header key to value, replacing any values it had.
+func (b *RequestBuilder) WithHeader(key, value string) *RequestBuilder {
+	b.req.Header.Set(key, value)
+	return b
+}
+
+// WithQuery adds value to the query parameter key, keeping any values it already had.
+func (b *RequestBuilder) WithQuery(key, value string) *RequestBuilder {
+	q := b.req.URL.Query()
+	q.Add(key, value)
+	b.req.URL.RawQuery = q.Encode()
+	b.req.RequestURI = b.req.URL.RequestURI()
+	return b
+}
+
+// WithBearer sets the Authorization header to a bearer token.
+func (b *RequestBuilder) WithBearer(token string) *RequestBuilder {
+	return b.WithHeader("Authorization", "Bearer "+token)
+}
+
+// Request returns the request built.
+func (b *RequestBuilder) Request() *http.Request {
+	return b.req
+}
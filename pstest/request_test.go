@@ -0,0 +1,69 @@
+package pstest
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+var newJSONRequestTests = []struct {
+	name         string
+	payload      any
+	expectedBody string
+	expectedType string
+}{
+	{name: "struct", payload: struct {
+		Name string `json:"name"`
+	}{"Ann"}, expectedBody: `{"name":"Ann"}`, expectedType: "application/json"},
+	{name: "map", payload: map[string]int{"b": 2, "a": 1}, expectedBody: `{"a":1,"b":2}`, expectedType: "application/json"},
+	{name: "string sent as it is", payload: `{"name":`, expectedBody: `{"name":`, expectedType: "application/json"},
+	{name: "bytes", payload: []byte(`[1]`), expectedBody: `[1]`, expectedType: "application/json"},
+	{name: "reader", payload: strings.NewReader(`{}`), expectedBody: `{}`, expectedType: "application/json"},
+	{name: "nil", payload: nil},
+}
+
+func TestNewJSONRequest(t *testing.T) {
+	for _, e := range newJSONRequestTests {
+		req := NewJSONRequest(http.MethodPost, "/orders", e.payload)
+
+		b, _ := io.ReadAll(req.Body)
+		if string(b) != e.expectedBody {
+			t.Errorf("%s: expected body %s, got %s", e.name, e.expectedBody, b)
+		}
+		if req.Header.Get("Content-Type") != e.expectedType {
+			t.Errorf("%s: expected Content-Type %q, got %q", e.name, e.expectedType, req.Header.Get("Content-Type"))
+		}
+	}
+}
+
+func TestNewJSONRequestPanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.HasPrefix(r.(string), "pstest: cannot encode the request payload") {
+			t.Errorf("expected a panic, got %v", r)
+		}
+	}()
+	NewJSONRequest(http.MethodPost, "/", make(chan int))
+}
+
+func TestRequestBuilder(t *testing.T) {
+	req := BuildJSONRequest(http.MethodGet, "/orders?status=open", nil).
+		WithQuery("status", "paid").
+		WithQuery("page", "2").
+		WithHeader("Accept-Language", "nl").
+		WithBearer("secret").
+		Request()
+
+	if got := req.URL.Query()["status"]; len(got) != 2 || got[0] != "open" || got[1] != "paid" {
+		t.Errorf("expected both status values, got %v", got)
+	}
+	if req.URL.Query().Get("page") != "2" || req.RequestURI != "/orders?page=2&status=open&status=paid" {
+		t.Errorf("unexpected query: %s", req.RequestURI)
+	}
+	if req.Header.Get("Authorization") != "Bearer secret" || req.Header.Get("Accept-Language") != "nl" {
+		t.Errorf("unexpected headers: %v", req.Header)
+	}
+	if req.Header.Get("Content-Type") != "" || req.Body != http.NoBody {
+		t.Errorf("expected no body, got %v", req.Header)
+	}
+}
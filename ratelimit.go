@@ -0,0 +1,172 @@
+package ps
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitError is returned when a client has used up its requests for the moment.
+type RateLimitError struct {
+	// Limit is the number of requests the client may make in a burst.
+	Limit int
+	// RetryAfter is how long until the client may make another request.
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface.
+func (e *RateLimitError) Error() string {
+	return "rate limit exceeded; retry in " + strconv.Itoa(retrySeconds(e.RetryAfter)) + " seconds"
+}
+
+// RateLimiter is a token-bucket rate limiter with a bucket per client. Each bucket holds up to Burst tokens and
+// refills at Rate tokens a second; a request takes a token, and is refused when there is none. Buckets are kept in
+// memory, so a RateLimiter suits a single instance. Its zero value is not usable: set Rate and Burst, which must
+// be positive; Allow and RateLimit panic otherwise.
+type RateLimiter struct {
+	// Rate is how many requests a second each client may make, on average.
+	Rate float64
+	// Burst is how many requests a client may make at once.
+	Burst int
+	// Key returns the client a request counts against (default RateLimitByIP). Requests it returns "" for are not
+	// limited.
+	Key func(r *http.Request) string
+	// Now returns the current time. If it is nil, time.Now is used.
+	Now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// tokenBucket is the state of one client's bucket.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimitByIP keys requests by the client's IP address, taken from RemoteAddr. Behind a proxy, use
+// RateLimitByHeader with the header the proxy sets instead.
+func RateLimitByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// RateLimitByHeader keys requests by the value of a header, such as an API key.
+func RateLimitByHeader(name string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		return strings.TrimSpace(r.Header.Get(name))
+	}
+}
+
+// RateLimitByTenant keys requests by the tenant RequireTenant stored in their context, so it must run inside
+// RequireTenant.
+func RateLimitByTenant(r *http.Request) string {
+	return TenantFrom(r.Context())
+}
+
+// Allow takes a token from the bucket of key. It returns a *RateLimitError if there is none, along with the number
+// of tokens left and how long until the bucket is full again.
+func (l *RateLimiter) Allow(key string) (remaining int, reset time.Duration, err error) {
+	l.mustBeConfigured("Allow")
+	now := time.Now
+	if l.Now != nil {
+		now = l.Now
+	}
+	t := now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(t)
+
+	b := l.buckets[key]
+	if b == nil {
+		b = &tokenBucket{tokens: float64(l.Burst), last: t}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.Burst), b.tokens+t.Sub(b.last).Seconds()*l.Rate)
+	b.last = t
+
+	if b.tokens < 1 {
+		wait := l.refill(1 - b.tokens)
+		return 0, l.refill(float64(l.Burst) - b.tokens), &RateLimitError{Limit: l.Burst, RetryAfter: wait}
+	}
+	b.tokens--
+	return int(b.tokens), l.refill(float64(l.Burst) - b.tokens), nil
+}
+
+// mustBeConfigured panics if l cannot limit anything, as a Rate of 0 never refills a bucket and a Burst of 0 never
+// fills one.
+func (l *RateLimiter) mustBeConfigured(method string) {
+	if l.Rate <= 0 || l.Burst <= 0 {
+		panic("ps: " + method + " called on a RateLimiter without a positive Rate and Burst")
+	}
+}
+
+// refill returns how long it takes to gain tokens.
+func (l *RateLimiter) refill(tokens float64) time.Duration {
+	return time.Duration(tokens / l.Rate * float64(time.Second))
+}
+
+// sweep drops the buckets that have filled up again, which are no different from new ones, once per time it takes
+// to fill a bucket.
+func (l *RateLimiter) sweep(t time.Time) {
+	if l.buckets == nil {
+		l.buckets = make(map[string]*tokenBucket)
+	}
+	full := l.refill(float64(l.Burst))
+	if t.Sub(l.lastSweep) < full {
+		return
+	}
+	for key, b := range l.buckets {
+		if t.Sub(b.last) >= full {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = t
+}
+
+// retrySeconds rounds d up to whole seconds, for the Retry-After and RateLimit-Reset headers.
+func retrySeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// RateLimit returns middleware that limits requests with limiter. Every limited response carries RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers; a request over the limit is answered with a 429 JSON error and
+// a Retry-After header, without reaching the handler.
+func (p *Parser) RateLimit(limiter *RateLimiter) func(http.Handler) http.Handler {
+	limiter.mustBeConfigured("RateLimit")
+	key := limiter.Key
+	if key == nil {
+		key = RateLimitByIP
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client := key(r)
+			if client == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			remaining, reset, err := limiter.Allow(client)
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(limiter.Burst))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(retrySeconds(reset)))
+			if err != nil {
+				w.Header().Set("Retry-After", strconv.Itoa(retrySeconds(err.(*RateLimitError).RetryAfter)))
+				_ = p.ErrorJSON(w, err, http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var rateLimitTests = []struct {
+	name              string
+	apiKey            string
+	advance           time.Duration
+	statusExpected    int
+	remainingExpected string
+	retryExpected     string
+}{
+	{name: "first", apiKey: "a", statusExpected: http.StatusOK, remainingExpected: "1"},
+	{name: "second", apiKey: "a", statusExpected: http.StatusOK, remainingExpected: "0"},
+	{name: "over the limit", apiKey: "a", statusExpected: http.StatusTooManyRequests, remainingExpected: "0", retryExpected: "2"},
+	{name: "other client", apiKey: "b", statusExpected: http.StatusOK, remainingExpected: "1"},
+	{name: "refilled", apiKey: "a", advance: 2 * time.Second, statusExpected: http.StatusOK, remainingExpected: "0"},
+	{name: "no key", statusExpected: http.StatusOK},
+}
+
+func TestParser_RateLimit(t *testing.T) {
+	var testParser Parser
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := &RateLimiter{
+		Rate:  0.5,
+		Burst: 2,
+		Key:   RateLimitByHeader("X-API-Key"),
+		Now:   func() time.Time { return now },
+	}
+	handler := testParser.RateLimit(limiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, e := range rateLimitTests {
+		now = now.Add(e.advance)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if e.apiKey != "" {
+			req.Header.Set("X-API-Key", e.apiKey)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.statusExpected {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.statusExpected, rr.Code)
+		}
+		if got := rr.Header().Get("RateLimit-Remaining"); got != e.remainingExpected {
+			t.Errorf("%s: expected RateLimit-Remaining %q, got %q", e.name, e.remainingExpected, got)
+		}
+		if got := rr.Header().Get("Retry-After"); got != e.retryExpected {
+			t.Errorf("%s: expected Retry-After %q, got %q", e.name, e.retryExpected, got)
+		}
+		if e.statusExpected == http.StatusTooManyRequests && rr.Body.String() != `{"error":true,"message":"rate limit exceeded; retry in 2 seconds"}` {
+			t.Errorf("%s: unexpected body %s", e.name, rr.Body.String())
+		}
+	}
+}
+
+func TestRateLimitByIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.7:51234"
+	if got := RateLimitByIP(req); got != "203.0.113.7" {
+		t.Errorf("expected 203.0.113.7, got %q", got)
+	}
+}
+
+func TestParser_RateLimitZeroRate(t *testing.T) {
+	for _, limiter := range []*RateLimiter{{Burst: 2}, {Rate: 1}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected RateLimit to panic for Rate %v and Burst %d", limiter.Rate, limiter.Burst)
+				}
+			}()
+			var testParser Parser
+			testParser.RateLimit(limiter)
+		}()
+	}
+}
@@ -0,0 +1,78 @@
+package ps
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// errInvalidRawJSON is returned by WriteJSON for a pre-serialized payload that is not JSON.
+var errInvalidRawJSON = errors.New("payload is not valid JSON")
+
+// null is the JSON a nil pre-serialized payload is sent as, as encoding/json encodes it.
+var null = []byte("null")
+
+// RawJSONSource wraps a source of bytes that are already JSON, such as a *bytes.Buffer or an *os.File, for
+// WriteJSON to send as they are, streaming them when it can. Other io.WriterTo values are encoded as usual, as a
+// type may write itself in some other format.
+type RawJSONSource struct {
+	io.WriterTo
+}
+
+// rawJSON reports whether data is already JSON, as WriteJSON takes json.RawMessage, []byte and RawJSONSource
+// payloads, and returns its bytes or its source. A nil payload is null.
+func rawJSON(data any) ([]byte, io.WriterTo, bool) {
+	switch v := data.(type) {
+	case json.RawMessage:
+		if v == nil {
+			return null, nil, true
+		}
+		return v, nil, true
+	case []byte:
+		if v == nil {
+			return null, nil, true
+		}
+		return v, nil, true
+	case RawJSONSource:
+		if v.WriterTo == nil {
+			return null, nil, true
+		}
+		return nil, v.WriterTo, true
+	}
+	return nil, nil, false
+}
+
+// rawBody prepares a payload that is already JSON for writing, checking that it is unless the Parser trusts raw
+// JSON. If the Parser reformats its output, with Pretty, SortKeys or Canonical, the payload is reformatted too.
+// A RawJSONSource is returned as it is when it can be streamed, which needs a Parser that trusts raw JSON and
+// has no use for the whole body; otherwise it is read into memory.
+func (p *Parser) rawBody(raw []byte, src io.WriterTo) ([]byte, io.WriterTo, error) {
+	reformats := p.Pretty || p.SortKeys || p.Canonical
+	needsBody := reformats || len(p.afterEncode) > 0 || p.ResponseDigest || p.MaxResponseSize > 0
+	if src != nil {
+		if p.TrustRawJSON && !needsBody {
+			return nil, src, nil
+		}
+		var buf bytes.Buffer
+		if _, err := src.WriteTo(&buf); err != nil {
+			return nil, nil, err
+		}
+		raw = buf.Bytes()
+	}
+
+	if reformats {
+		// Reformatting checks the JSON as it goes, and leaves the caller's bytes alone.
+		out, err := p.marshal(json.RawMessage(raw))
+		return out, nil, err
+	}
+	if src == nil && len(p.afterEncode) > 0 {
+		// The caller owns the bytes, so hooks must not be able to change them.
+		raw = bytes.Clone(raw)
+	}
+
+	if !p.TrustRawJSON && !json.Valid(raw) {
+		return nil, nil, errInvalidRawJSON
+	}
+	return raw, nil, nil
+}
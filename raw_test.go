@@ -0,0 +1,79 @@
+package ps
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var rawTests = []struct {
+	name          string
+	parser        *Parser
+	payload       any
+	bodyExpected  string
+	errorExpected bool
+}{
+	{name: "raw message", parser: New(), payload: json.RawMessage(`{"b": 1, "a": [1, 2]}`), bodyExpected: `{"b": 1, "a": [1, 2]}`},
+	{name: "bytes", parser: New(), payload: []byte(`[1,2]`), bodyExpected: `[1,2]`},
+	{name: "nil raw message", parser: New(), payload: json.RawMessage(nil), bodyExpected: `null`},
+	{name: "nil bytes", parser: New(), payload: []byte(nil), bodyExpected: `null`},
+	{name: "source", parser: New(), payload: RawJSONSource{WriterTo: bytes.NewBufferString(`{"ok":true}`)}, bodyExpected: `{"ok":true}`},
+	{name: "nil source", parser: New(), payload: RawJSONSource{}, bodyExpected: `null`},
+	{name: "writer to", parser: New(), payload: writerToPayload{ID: 7}, bodyExpected: `{"id":7}`},
+	{name: "invalid", parser: New(), payload: []byte(`{"ok":`), errorExpected: true},
+	{name: "invalid source", parser: New(), payload: RawJSONSource{WriterTo: bytes.NewBufferString(`nope`)}, errorExpected: true},
+	{name: "trusted", parser: New(WithTrustRawJSON(true)), payload: RawJSONSource{WriterTo: bytes.NewBufferString(`{"ok":`)}, bodyExpected: `{"ok":`},
+	{name: "sorted", parser: New(WithSortKeys(true)), payload: json.RawMessage(`{"b": 1, "a": 2}`), bodyExpected: `{"a":2,"b":1}`},
+	{name: "precomputed", parser: New(), payload: New().MustMarshalOnce(map[string]int{"a": 1}), bodyExpected: `{"a":1}`},
+}
+
+// writerToPayload is a struct that can write itself in another format, and is encoded as JSON all the same.
+type writerToPayload struct {
+	ID int `json:"id"`
+}
+
+func (writerToPayload) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, "id=7")
+	return int64(n), err
+}
+
+func TestParser_WriteJSONRaw(t *testing.T) {
+	for _, e := range rawTests {
+		rr := httptest.NewRecorder()
+		err := e.parser.WriteJSON(rr, http.StatusOK, e.payload)
+
+		if e.errorExpected {
+			if err == nil {
+				t.Errorf("%s: error expected, but none received", e.name)
+			}
+			if rr.Body.Len() != 0 {
+				t.Errorf("%s: expected nothing to be written, got %s", e.name, rr.Body.String())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+		}
+		if rr.Body.String() != e.bodyExpected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.bodyExpected, rr.Body.String())
+		}
+	}
+}
+
+func BenchmarkParser_WriteJSONRaw(b *testing.B) {
+	var testParser Parser
+	payload := json.RawMessage(bytes.Repeat([]byte(`{"id":1,"name":"Jack Smith","tags":["a","b"]},`), 100))
+	payload = append(append(json.RawMessage("["), payload[:len(payload)-1]...), ']')
+	rr := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		rr.Body.Reset()
+		if err := testParser.WriteJSON(rr, http.StatusOK, payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
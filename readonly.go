@@ -0,0 +1,48 @@
+package ps
+
+import (
+	"reflect"
+	"strings"
+)
+
+// ReadOnlyFields controls what ReadJSON and ReadForm do with fields marked readonly in the tag they read, such as
+// `json:"id,readonly"`, when a request sends them. Such fields are managed by the server: clients that echo a
+// whole resource back must not be able to change them.
+type ReadOnlyFields int
+
+const (
+	// IgnoreReadOnlyFields leaves the fields as they were before decoding, as though they had not been sent. This
+	// is the default.
+	IgnoreReadOnlyFields ReadOnlyFields = iota
+	// RejectReadOnlyFields fails the request with a ValidationError naming each of them.
+	RejectReadOnlyFields
+)
+
+// stripReadOnly removes the keys of obj that name readonly fields of struct type t, or reports them as a
+// ValidationError if readonly fields are rejected.
+func (o decodeOptions) stripReadOnly(obj map[string]any, t reflect.Type, path string) error {
+	var sent []FieldError
+
+	info := o.fields(t)
+	for i := range info.fields {
+		f := &info.fields[i]
+		if !f.readOnly {
+			continue
+		}
+		for key := range obj {
+			if !strings.EqualFold(key, f.name) {
+				continue
+			}
+			if o.rejectReadOnly {
+				sent = append(sent, FieldError{Field: joinPath(path, f.name), Message: "is read-only"})
+				break
+			}
+			delete(obj, key)
+		}
+	}
+
+	if len(sent) > 0 {
+		return &ValidationError{Fields: sent}
+	}
+	return nil
+}
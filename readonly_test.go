@@ -0,0 +1,72 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type managedResource struct {
+	ID        string    `json:"id,readonly" form:"id,readonly"`
+	OwnerID   string    `json:"owner_id,readonly" form:"owner_id,readonly"`
+	CreatedAt time.Time `json:"created_at,readonly" form:"created_at,readonly"`
+	Title     string    `json:"title" form:"title"`
+}
+
+var readOnlyTests = []struct {
+	name          string
+	json          string
+	policy        ReadOnlyFields
+	errorExpected string
+}{
+	{name: "ignored", json: `{"id": "other", "owner_id": "mallory", "created_at": "2000-01-01T00:00:00Z", "title": "New"}`},
+	{name: "ignored case variant", json: `{"ID": "other", "title": "New"}`},
+	{name: "not sent", json: `{"title": "New"}`, policy: RejectReadOnlyFields},
+	{name: "rejected", json: `{"owner_id": "mallory", "id": "other", "title": "New"}`, policy: RejectReadOnlyFields, errorExpected: "id is read-only; owner_id is read-only"},
+}
+
+func TestParser_ReadJSONReadOnly(t *testing.T) {
+	created := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, e := range readOnlyTests {
+		testParser := Parser{ReadOnlyFields: e.policy}
+		got := managedResource{ID: "r1", OwnerID: "ann", CreatedAt: created, Title: "Old"}
+		err := testParser.ReadJSON(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/", strings.NewReader(e.json)), &got)
+
+		if e.errorExpected != "" {
+			if err == nil || err.Error() != e.errorExpected {
+				t.Errorf("%s: expected error %q, got %v", e.name, e.errorExpected, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+			continue
+		}
+		if got.ID != "r1" || got.OwnerID != "ann" || !got.CreatedAt.Equal(created) || got.Title != "New" {
+			t.Errorf("%s: server-managed fields changed: %+v", e.name, got)
+		}
+	}
+}
+
+func TestParser_ReadFormReadOnly(t *testing.T) {
+	for _, policy := range []ReadOnlyFields{IgnoreReadOnlyFields, RejectReadOnlyFields} {
+		testParser := Parser{ReadOnlyFields: policy}
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("id=other&title=New"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		got := managedResource{ID: "r1"}
+		err := testParser.ReadForm(httptest.NewRecorder(), req, &got)
+		if policy == RejectReadOnlyFields {
+			if err == nil || err.Error() != "id is read-only" {
+				t.Errorf("expected id to be rejected, got %v", err)
+			}
+			continue
+		}
+		if err != nil || got.ID != "r1" || got.Title != "New" {
+			t.Errorf("expected id to be ignored, got %+v, %v", got, err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package ps
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultMaxSkew is the default window a request timestamp may fall in around the current time.
+const defaultMaxSkew = 5 * time.Minute
+
+// ReplayError is returned when a request fails replay protection.
+type ReplayError struct {
+	// Reason describes why the request was rejected.
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *ReplayError) Error() string {
+	return "request rejected: " + e.Reason
+}
+
+// NonceStore remembers the nonces that have been used. Implementations must be safe for concurrent use.
+type NonceStore interface {
+	// Seen records nonce as used until expires, and reports whether it had already been recorded. The check and
+	// the record must happen atomically. now is the current time by the ReplayGuard's clock, against which
+	// expiry is measured.
+	Seen(ctx context.Context, nonce string, now, expires time.Time) (bool, error)
+}
+
+// ReplayGuard validates the timestamp and nonce headers that signed requests carry, so that a captured request
+// cannot be sent again. Set it as Parser.ReplayGuard to have ReadJSON check requests before decoding them.
+type ReplayGuard struct {
+	// TimestampHeader holds the time the request was sent, in Unix seconds or RFC 3339 (default X-Timestamp).
+	TimestampHeader string
+	// NonceHeader holds a value unique to each request (default X-Nonce).
+	NonceHeader string
+	// MaxSkew is how far the timestamp may be from the current time, in either direction (default 5 minutes).
+	MaxSkew time.Duration
+	// Nonces remembers used nonces. If it is nil, only the timestamp is checked.
+	Nonces NonceStore
+	// Now returns the current time. If it is nil, time.Now is used.
+	Now func() time.Time
+}
+
+// Check returns a *ReplayError if r is missing its timestamp or nonce, if the timestamp is outside the allowed
+// window, or if the nonce has been used before.
+func (g *ReplayGuard) Check(r *http.Request) error {
+	timestampHeader := g.TimestampHeader
+	if timestampHeader == "" {
+		timestampHeader = "X-Timestamp"
+	}
+	nonceHeader := g.NonceHeader
+	if nonceHeader == "" {
+		nonceHeader = "X-Nonce"
+	}
+	maxSkew := g.MaxSkew
+	if maxSkew == 0 {
+		maxSkew = defaultMaxSkew
+	}
+	clock := time.Now
+	if g.Now != nil {
+		clock = g.Now
+	}
+	now := clock()
+
+	value := r.Header.Get(timestampHeader)
+	if value == "" {
+		return &ReplayError{Reason: "missing " + timestampHeader + " header"}
+	}
+
+	sent, err := parseTimestamp(value)
+	if err != nil {
+		return &ReplayError{Reason: "invalid " + timestampHeader + " header"}
+	}
+
+	if skew := now.Sub(sent); skew > maxSkew || skew < -maxSkew {
+		return &ReplayError{Reason: "timestamp is outside the allowed window"}
+	}
+
+	if g.Nonces == nil {
+		return nil
+	}
+
+	nonce := r.Header.Get(nonceHeader)
+	if nonce == "" {
+		return &ReplayError{Reason: "missing " + nonceHeader + " header"}
+	}
+
+	// Once the timestamp is out of the window the request is rejected anyway, so the nonce need not be kept longer.
+	seen, err := g.Nonces.Seen(r.Context(), nonce, now, sent.Add(maxSkew))
+	if err != nil {
+		return err
+	}
+	if seen {
+		return &ReplayError{Reason: "nonce has already been used"}
+	}
+
+	return nil
+}
+
+// parseTimestamp accepts Unix seconds or an RFC 3339 time.
+func parseTimestamp(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// MemoryNonceStore is a NonceStore that keeps nonces in memory. It suits a single instance; deployments with
+// several instances need a shared store.
+type MemoryNonceStore struct {
+	mu      sync.Mutex
+	nonces  map[string]time.Time
+	sweepAt int
+}
+
+// minNonceSweep is the number of nonces a MemoryNonceStore holds before it first drops expired ones.
+const minNonceSweep = 64
+
+// Seen records nonce until expires, and reports whether it had already been recorded. Expired nonces are dropped
+// whenever the store has doubled in size since they were last dropped, so that each call does constant work on
+// average.
+func (s *MemoryNonceStore) Seen(_ context.Context, nonce string, now, expires time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.nonces == nil {
+		s.nonces = make(map[string]time.Time)
+	}
+
+	if exp, ok := s.nonces[nonce]; ok && !now.After(exp) {
+		return true, nil
+	}
+	s.nonces[nonce] = expires
+
+	if len(s.nonces) >= s.sweepAt {
+		for n, exp := range s.nonces {
+			if now.After(exp) {
+				delete(s.nonces, n)
+			}
+		}
+		s.sweepAt = max(2*len(s.nonces), minNonceSweep)
+	}
+
+	return false, nil
+}
@@ -0,0 +1,90 @@
+package ps
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var replayTests = []struct {
+	name          string
+	timestamp     func(now time.Time) string
+	nonce         string
+	errorExpected bool
+}{
+	{name: "valid", timestamp: unixTimestamp(0), nonce: "a"},
+	{name: "rfc 3339", timestamp: func(now time.Time) string { return now.Format(time.RFC3339) }, nonce: "b"},
+	{name: "replayed nonce", timestamp: unixTimestamp(time.Second), nonce: "a", errorExpected: true},
+	{name: "missing timestamp", nonce: "c", errorExpected: true},
+	{name: "invalid timestamp", timestamp: func(time.Time) string { return "yesterday" }, nonce: "d", errorExpected: true},
+	{name: "too old", timestamp: unixTimestamp(-10 * time.Minute), nonce: "e", errorExpected: true},
+	{name: "too far ahead", timestamp: unixTimestamp(10 * time.Minute), nonce: "f", errorExpected: true},
+	{name: "missing nonce", timestamp: unixTimestamp(0), errorExpected: true},
+}
+
+// unixTimestamp returns a timestamp offset from the current time.
+func unixTimestamp(offset time.Duration) func(now time.Time) string {
+	return func(now time.Time) string {
+		return strconv.FormatInt(now.Add(offset).Unix(), 10)
+	}
+}
+
+func TestParser_ReadJSONReplayGuard(t *testing.T) {
+	testParser := Parser{
+		ReplayGuard: &ReplayGuard{Nonces: &MemoryNonceStore{}},
+	}
+
+	for _, e := range replayTests {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(`{"foo": "bar"}`))
+		if e.timestamp != nil {
+			req.Header.Set("X-Timestamp", e.timestamp(time.Now()))
+		}
+		if e.nonce != "" {
+			req.Header.Set("X-Nonce", e.nonce)
+		}
+
+		var decodedJSON struct {
+			Foo string `json:"foo"`
+		}
+		err := testParser.ReadJSON(httptest.NewRecorder(), req, &decodedJSON)
+
+		var replayError *ReplayError
+		if e.errorExpected && !errors.As(err, &replayError) {
+			t.Errorf("%s: expected a *ReplayError, got %v", e.name, err)
+		}
+		if !e.errorExpected && err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+		}
+	}
+}
+
+func TestReplayGuard_Clock(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	guard := &ReplayGuard{Nonces: &MemoryNonceStore{}, Now: func() time.Time { return now }}
+
+	check := func(nonce string) error {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks", nil)
+		req.Header.Set("X-Timestamp", strconv.FormatInt(now.Unix(), 10))
+		req.Header.Set("X-Nonce", nonce)
+		return guard.Check(req)
+	}
+
+	// Enough nonces to have the store sweep, by the guard's clock rather than the real one.
+	for i := 0; i < 2*minNonceSweep; i++ {
+		if err := check(strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := check("0"); err == nil {
+		t.Error("expected a nonce still live by the guard's clock to be rejected")
+	}
+
+	now = now.Add(time.Hour)
+	if err := check("0"); err != nil {
+		t.Errorf("expected an expired nonce to be accepted again, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package ps
+
+import (
+	"context"
+	"net/http"
+)
+
+// defaultRequestIDHeader is the header carrying request IDs when Parser.RequestIDHeader is empty.
+const defaultRequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the longest request ID accepted from a client; longer ones are replaced.
+const maxRequestIDLength = 128
+
+// requestIDKey is the context key under which RequestID stores a request's ID.
+type requestIDKey struct{}
+
+// RequestIDFrom returns the request ID that RequestID, or ContextWithRequestID, attached to ctx, or "".
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// ContextWithRequestID returns a copy of ctx carrying id, for work that starts outside an HTTP handler, such as a
+// queue consumer, but should be traced like a request.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns middleware that gives every request an ID for tracing it across services. The ID the client
+// sent in the RequestIDHeader is kept if it is printable ASCII of at most 128 bytes, and a new UUID is used
+// otherwise. The ID is stored in the request's context, for RequestIDFrom and the outbound helpers, and echoed in
+// the same header on the response.
+func (p *Parser) RequestID() func(http.Handler) http.Handler {
+	header := p.requestIDHeader()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(header)
+			if !validRequestID(id) {
+				uuid, err := NewUUID()
+				if err != nil {
+					_ = p.ErrorJSON(w, err, http.StatusInternalServerError)
+					return
+				}
+				id = uuid.String()
+			}
+			w.Header().Set(header, id)
+			next.ServeHTTP(w, r.WithContext(ContextWithRequestID(r.Context(), id)))
+		})
+	}
+}
+
+// requestIDHeader returns the configured request ID header, or the default one.
+func (p *Parser) requestIDHeader() string {
+	if p.RequestIDHeader != "" {
+		return p.RequestIDHeader
+	}
+	return defaultRequestIDHeader
+}
+
+// validRequestID reports whether id is safe to keep: non-empty, short, and free of anything that could forge log
+// lines or headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestIDTransport is an http.RoundTripper that copies the request ID in each outbound request's context into
+// its Header, so that upstream services log the same ID:
+//
+//	client := &http.Client{Transport: &ps.RequestIDTransport{}}
+//	req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, upstream, nil)
+//	resp, err := client.Do(req)
+//
+// A request that already carries the header keeps its own value.
+type RequestIDTransport struct {
+	// Base makes the requests. If it is nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+	// Header carries the ID (default X-Request-ID).
+	Header string
+}
+
+// RoundTrip implements http.RoundTripper. The request is cloned before its header is changed, as RoundTrippers
+// must not modify the requests they are given.
+func (t *RequestIDTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	header := t.Header
+	if header == "" {
+		header = defaultRequestIDHeader
+	}
+	if id := RequestIDFrom(r.Context()); id != "" && r.Header.Get(header) == "" {
+		r = r.Clone(r.Context())
+		r.Header.Set(header, id)
+	}
+	return base.RoundTrip(r)
+}
@@ -0,0 +1,98 @@
+package ps
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var requestIDTests = []struct {
+	name string
+	sent string
+	kept bool
+}{
+	{name: "kept", sent: "req-123", kept: true},
+	{name: "missing", sent: ""},
+	{name: "spaces", sent: "req 123"},
+	{name: "too long", sent: strings.Repeat("a", 129)},
+}
+
+func TestParser_RequestID(t *testing.T) {
+	var testParser Parser
+
+	for _, e := range requestIDTests {
+		var seen string
+		handler := testParser.RequestID()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = RequestIDFrom(r.Context())
+			_ = testParser.WriteJSON(w, http.StatusOK, "ok")
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if e.sent != "" {
+			req.Header.Set("X-Request-ID", e.sent)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		echoed := rr.Header().Get("X-Request-ID")
+		if seen == "" || echoed != seen {
+			t.Errorf("%s: expected the ID %q in the context to be echoed, got %q", e.name, seen, echoed)
+		}
+		if e.kept && seen != e.sent {
+			t.Errorf("%s: expected the client's ID %q, got %q", e.name, e.sent, seen)
+		}
+		if _, err := ParseUUID(seen); !e.kept && err != nil {
+			t.Errorf("%s: expected a generated UUID, got %q", e.name, seen)
+		}
+	}
+}
+
+func TestRequestIDTransport(t *testing.T) {
+	var got []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("X-Correlation-ID"))
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: &RequestIDTransport{Header: "X-Correlation-ID"}}
+	ctx := ContextWithRequestID(context.Background(), "req-1")
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if req.Header.Get("X-Correlation-ID") != "" {
+		t.Error("expected the caller's request to be left alone")
+	}
+
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL, nil)
+	req.Header.Set("X-Correlation-ID", "own")
+	if resp, err = client.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if strings.Join(got, ",") != "req-1,own" {
+		t.Errorf("expected the IDs req-1 and own upstream, got %q", got)
+	}
+}
+
+func TestWebhookSender_RequestID(t *testing.T) {
+	var got string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("X-Trace")
+	}))
+	defer receiver.Close()
+
+	sender := &WebhookSender{Parser: &Parser{RequestIDHeader: "X-Trace"}, Secret: []byte("s")}
+	if _, err := sender.Send(ContextWithRequestID(context.Background(), "req-9"), receiver.URL, map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got != "req-9" {
+		t.Errorf("expected the request ID to reach the receiver, got %q", got)
+	}
+}
@@ -0,0 +1,60 @@
+package ps
+
+import "reflect"
+
+// Reset zeroes the value dst points to so that it can be reused, for handlers that keep their request structs in
+// a sync.Pool. Unlike assigning the zero value, it keeps the capacity of the slices and maps dst holds, directly or
+// in nested structs and arrays, so the next decode can reuse their memory. Slices are truncated to zero length
+// with their elements cleared, and maps are emptied, so nothing from the previous request survives. Pointers are
+// set to nil, since what they point to may be shared.
+//
+// ReadJSON leaves fields that are absent from the body untouched, so a pooled value must be Reset before it is
+// decoded into again. Reset panics if dst is not a non-nil pointer.
+func Reset(dst any) {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		panic("ps: Reset of non-pointer or nil pointer")
+	}
+	resetValue(v.Elem())
+}
+
+// resetValue zeroes v in place, keeping the capacity of slices and maps.
+func resetValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return
+		}
+		full := v.Slice(0, v.Cap())
+		full.Clear()
+		v.SetLen(0)
+
+	case reflect.Map:
+		if !v.IsNil() {
+			v.Clear()
+		}
+
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			resetValue(v.Index(i))
+		}
+
+	case reflect.Struct:
+		// Unexported fields cannot be set one by one, so build the result in a fresh zero value, carrying over the
+		// reset exported fields, and store it in one go.
+		t := v.Type()
+		fresh := reflect.New(t).Elem()
+		for i := 0; i < t.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			field := v.Field(i)
+			resetValue(field)
+			fresh.Field(i).Set(field)
+		}
+		v.Set(fresh)
+
+	default:
+		v.SetZero()
+	}
+}
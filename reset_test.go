@@ -0,0 +1,70 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type pooledRequest struct {
+	Name    string            `json:"name"`
+	Tags    []string          `json:"tags"`
+	Labels  map[string]string `json:"labels"`
+	Parent  *pooledRequest    `json:"parent"`
+	Items   []pooledItem      `json:"items"`
+	Limits  [2]int            `json:"limits"`
+	private int
+}
+
+type pooledItem struct {
+	SKU  string   `json:"sku"`
+	Tags []string `json:"tags"`
+}
+
+func TestReset(t *testing.T) {
+	req := pooledRequest{
+		Name:    "first",
+		Tags:    []string{"a", "b"},
+		Labels:  map[string]string{"env": "prod"},
+		Parent:  &pooledRequest{Name: "parent"},
+		Items:   []pooledItem{{SKU: "x", Tags: []string{"t"}}},
+		Limits:  [2]int{1, 2},
+		private: 7,
+	}
+	tags, items := req.Tags, req.Items
+
+	Reset(&req)
+
+	if req.Name != "" || req.Parent != nil || req.Limits != [2]int{} || req.private != 0 {
+		t.Errorf("expected scalar fields and pointers to be zeroed, got %+v", req)
+	}
+	if len(req.Tags) != 0 || cap(req.Tags) != 2 || tags[:2][1] != "" {
+		t.Errorf("expected tags to be emptied and cleared, keeping their capacity: %q", tags[:2])
+	}
+	if len(req.Labels) != 0 || req.Labels == nil {
+		t.Errorf("expected labels to be an empty map, got %v", req.Labels)
+	}
+	if len(req.Items) != 0 || items[:1][0].SKU != "" || items[:1][0].Tags != nil {
+		t.Errorf("expected items to be cleared, got %+v", items[:1])
+	}
+
+	// A reset value decodes as if it were new.
+	var testParser Parser
+	body := `{"name":"second","tags":["c"]}`
+	if err := testParser.ReadJSON(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Name != "second" || len(req.Tags) != 1 || req.Tags[0] != "c" || &req.Tags[:1][0] != &tags[:1][0] || len(req.Labels) != 0 {
+		t.Errorf("expected a clean decode reusing the tags array, got %+v", req)
+	}
+}
+
+func TestReset_NonPointer(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected Reset of a non-pointer to panic")
+		}
+	}()
+	Reset(pooledRequest{})
+}
@@ -0,0 +1,89 @@
+package ps
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// responseChunkSize is how much of an oversized response is written between flushes when it is streamed.
+const responseChunkSize = 32 << 10
+
+// OversizedResponse controls what WriteJSON does with a response larger than Parser.MaxResponseSize.
+type OversizedResponse int
+
+const (
+	// RejectOversizedResponse writes nothing and returns a *ResponseTooLargeError. This is the default.
+	RejectOversizedResponse OversizedResponse = iota
+	// TruncateOversizedResponse drops the data and writes a warning envelope with status 500 in its place, so the
+	// client learns why, and returns a *ResponseTooLargeError. JSON cannot be cut short and stay valid, so nothing
+	// of the original response is kept.
+	TruncateOversizedResponse
+	// StreamOversizedResponse writes the response anyway, in chunks that are flushed as they are written, so that
+	// proxies are not made to buffer all of it.
+	StreamOversizedResponse
+)
+
+// ResponseTooLargeError is returned when an encoded response exceeds Parser.MaxResponseSize.
+type ResponseTooLargeError struct {
+	// Size is the size of the encoded response, in bytes.
+	Size int
+	// Limit is the configured maximum.
+	Limit int
+}
+
+// Error implements the error interface.
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response of %d bytes exceeds the limit of %d bytes", e.Size, e.Limit)
+}
+
+// oversized reports whether out is larger than the configured maximum response size.
+func (p *Parser) oversized(out []byte) bool {
+	return p.MaxResponseSize > 0 && len(out) > p.MaxResponseSize
+}
+
+// writeOversized handles a response body that is larger than MaxResponseSize, according to the Parser's policy.
+// The response headers have not been written yet; contentType is the Content-Type of the full response.
+func (p *Parser) writeOversized(w http.ResponseWriter, contentType string, status int, out []byte) error {
+	tooLarge := &ResponseTooLargeError{Size: len(out), Limit: p.MaxResponseSize}
+
+	switch p.OversizedResponse {
+	case TruncateOversizedResponse:
+		envelope, err := p.marshal(JSONResponse{Error: true, Message: "response truncated: " + tooLarge.Error()})
+		if err != nil {
+			return err
+		}
+		p.setDigest(w, envelope)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		n, err := w.Write(envelope)
+		p.count(bytesWritten, int64(n))
+		if err != nil {
+			return err
+		}
+		return tooLarge
+
+	case StreamOversizedResponse:
+		p.setDigest(w, out)
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		rc := http.NewResponseController(w)
+		for len(out) > 0 {
+			n := min(len(out), responseChunkSize)
+			if err := p.writeDeadline(rc); err != nil {
+				return err
+			}
+			written, err := w.Write(out[:n])
+			p.count(bytesWritten, int64(written))
+			if err != nil {
+				return err
+			}
+			if err := rc.Flush(); err != nil && err != http.ErrNotSupported {
+				return err
+			}
+			out = out[n:]
+		}
+		return nil
+	}
+
+	return tooLarge
+}
@@ -0,0 +1,47 @@
+package ps
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var responseSizeTests = []struct {
+	name           string
+	policy         OversizedResponse
+	data           string
+	expectedStatus int
+	expectedBody   string
+	errorExpected  bool
+}{
+	{name: "within limit", data: "small", expectedStatus: http.StatusOK, expectedBody: `"small"`},
+	{name: "reject", data: strings.Repeat("x", 64), expectedStatus: http.StatusOK, errorExpected: true},
+	{name: "truncate", policy: TruncateOversizedResponse, data: strings.Repeat("x", 64), expectedStatus: http.StatusInternalServerError,
+		expectedBody: `{"error":true,"message":"response truncated: response of 66 bytes exceeds the limit of 32 bytes"}`, errorExpected: true},
+	{name: "stream", policy: StreamOversizedResponse, data: strings.Repeat("x", 64), expectedStatus: http.StatusOK,
+		expectedBody: `"` + strings.Repeat("x", 64) + `"`},
+}
+
+func TestParser_MaxResponseSize(t *testing.T) {
+	for _, e := range responseSizeTests {
+		testParser := Parser{MaxResponseSize: 32, OversizedResponse: e.policy}
+		rr := httptest.NewRecorder()
+		err := testParser.WriteJSON(rr, http.StatusOK, e.data)
+
+		var tooLarge *ResponseTooLargeError
+		if e.errorExpected && (!errors.As(err, &tooLarge) || tooLarge.Size != 66 || tooLarge.Limit != 32) {
+			t.Errorf("%s: expected a ResponseTooLargeError, got %v", e.name, err)
+		}
+		if !e.errorExpected && err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+		}
+		if rr.Code != e.expectedStatus {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.expectedStatus, rr.Code)
+		}
+		if rr.Body.String() != e.expectedBody {
+			t.Errorf("%s: expected body %s, got %s", e.name, e.expectedBody, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package ps
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var signedURLTests = []struct {
+	name          string
+	secret        string
+	expires       time.Duration
+	tamper        func(string) string
+	errorExpected bool
+}{
+	{name: "valid", secret: "secret", expires: time.Hour},
+	{name: "wrong secret", secret: "other", expires: time.Hour, errorExpected: true},
+	{name: "expired", secret: "secret", expires: -time.Minute, errorExpected: true},
+	{name: "changed parameter", secret: "secret", expires: time.Hour, tamper: func(u string) string { return strings.Replace(u, "id=7", "id=8", 1) }, errorExpected: true},
+	{name: "added parameter", secret: "secret", expires: time.Hour, tamper: func(u string) string { return u + "&admin=1" }, errorExpected: true},
+	{name: "changed path", secret: "secret", expires: time.Hour, tamper: func(u string) string { return strings.Replace(u, "/files/", "/other/", 1) }, errorExpected: true},
+	{name: "extended expiry", secret: "secret", expires: -time.Minute, tamper: func(u string) string { return strings.Replace(u, "expires=", "expires=9", 1) }, errorExpected: true},
+	{name: "unsigned", secret: "secret", expires: time.Hour, tamper: func(string) string { return "/files/report.pdf?id=7" }, errorExpected: true},
+}
+
+func TestVerifySignedURL(t *testing.T) {
+	now := time.Now()
+	for _, e := range signedURLTests {
+		link, err := SignURL([]byte(e.secret), "https://example.com/files/report.pdf?id=7", now.Add(e.expires))
+		if err != nil {
+			t.Fatalf("%s: %v", e.name, err)
+		}
+		if e.tamper != nil {
+			link = e.tamper(link)
+		}
+
+		err = VerifySignedURL([]byte("secret"), httptest.NewRequest(http.MethodGet, link, nil), now)
+
+		var signedURLError *SignedURLError
+		if e.errorExpected && !errors.As(err, &signedURLError) {
+			t.Errorf("%s: expected a *SignedURLError, got %v", e.name, err)
+		}
+		if !e.errorExpected && err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+		}
+	}
+}
+
+func TestParser_RequireSignedURL(t *testing.T) {
+	var testParser Parser
+	handler := testParser.RequireSignedURL([]byte("secret"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	link, err := SignURL([]byte("secret"), "/downloads/1", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, link, nil))
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("signed link: expected status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/downloads/1", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("unsigned link: expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "invalid signed URL") {
+		t.Errorf("unsigned link: expected the reason in the body, got %s", rr.Body.String())
+	}
+}
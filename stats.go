@@ -0,0 +1,165 @@
+package ps
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+)
+
+// Stats is a snapshot of a Parser's counters, for quick runtime introspection without a metrics system.
+type Stats struct {
+	// Decodes is the number of ReadJSON, Decode and other reading calls that succeeded, counting each message or
+	// element a FrameReader, ReadJSONMessage, StreamJSON or StreamJSONChan decodes as a call of its own
+	Decodes int64
+	// DecodeFailures is the number of those calls that failed, for any reason
+	DecodeFailures int64
+	// SyntaxErrors, TypeErrors, UnknownFields, EmptyBodies, TooLarge and ValidationErrors break the failures down
+	// by cause; failures with other causes, such as a wrong Content-Type, are only counted in DecodeFailures
+	SyntaxErrors     int64
+	TypeErrors       int64
+	UnknownFields    int64
+	EmptyBodies      int64
+	TooLarge         int64
+	ValidationErrors int64
+	// Encodes is the number of WriteJSON, Encode and WriteJSONMessage calls that succeeded, including those made by
+	// ErrorJSON and ErrorJSONMessage, and of StreamWriters and FrameWriters closed without a write having failed
+	Encodes int64
+	// EncodeFailures is the number of those calls and writers that failed
+	EncodeFailures int64
+	// BytesRead is the number of body bytes read by ReadJSON, Decode and the other readers
+	BytesRead int64
+	// BytesWritten is the number of body bytes written by WriteJSON, Encode, StreamWriter, FrameWriter and
+	// WriteJSONMessage
+	BytesWritten int64
+}
+
+// counter indexes the counters of a Parser.
+type counter int
+
+const (
+	decodes counter = iota
+	decodeFailures
+	syntaxErrors
+	typeErrors
+	unknownFields
+	emptyBodies
+	tooLarge
+	validationErrors
+	encodes
+	encodeFailures
+	bytesRead
+	bytesWritten
+	numCounters
+)
+
+// counters holds a Parser's counters. Being allocated on its own keeps them 64-bit aligned for atomic access on
+// 32-bit platforms.
+type counters [numCounters]int64
+
+// Stats returns the Parser's counters. Each is read atomically, but they are not read all at once, so a snapshot
+// taken under load may be a request or two out of step between counters. A Clone starts with zero counters.
+func (p *Parser) Stats() Stats {
+	c := p.counters()
+	load := func(i counter) int64 { return atomic.LoadInt64(&c[i]) }
+	return Stats{
+		Decodes:          load(decodes),
+		DecodeFailures:   load(decodeFailures),
+		SyntaxErrors:     load(syntaxErrors),
+		TypeErrors:       load(typeErrors),
+		UnknownFields:    load(unknownFields),
+		EmptyBodies:      load(emptyBodies),
+		TooLarge:         load(tooLarge),
+		ValidationErrors: load(validationErrors),
+		Encodes:          load(encodes),
+		EncodeFailures:   load(encodeFailures),
+		BytesRead:        load(bytesRead),
+		BytesWritten:     load(bytesWritten),
+	}
+}
+
+// counters returns the Parser's counters, allocating them on first use.
+func (p *Parser) counters() *counters {
+	if c := p.stats.Load(); c != nil {
+		return c
+	}
+	p.stats.CompareAndSwap(nil, new(counters))
+	return p.stats.Load()
+}
+
+// count adds n to a counter.
+func (p *Parser) count(i counter, n int64) {
+	atomic.AddInt64(&p.counters()[i], n)
+}
+
+// countDecode records the outcome of a ReadJSON call.
+func (p *Parser) countDecode(err error) {
+	if err == nil {
+		p.count(decodes, 1)
+		return
+	}
+	p.count(decodeFailures, 1)
+
+	var failure *decodeFailure
+	var tooLargeError *RequestTooLargeError
+	switch {
+	case errors.As(err, &failure):
+		if failure.class != otherFailure {
+			p.count(failure.class.counter(), 1)
+		}
+	case errors.As(err, &tooLargeError):
+		p.count(tooLarge, 1)
+	case fieldErrors(err) != nil:
+		p.count(validationErrors, 1)
+	}
+}
+
+// failureClass is the cause of a decode failure, for Stats.
+type failureClass int
+
+const (
+	otherFailure failureClass = iota
+	syntaxFailure
+	typeFailure
+	unknownFieldFailure
+	emptyFailure
+)
+
+// counter returns the counter for failures of class c.
+func (c failureClass) counter() counter {
+	switch c {
+	case syntaxFailure:
+		return syntaxErrors
+	case typeFailure:
+		return typeErrors
+	case unknownFieldFailure:
+		return unknownFields
+	default:
+		return emptyBodies
+	}
+}
+
+// decodeFailure is a human-readable decoding error, tagged with its cause.
+type decodeFailure struct {
+	class   failureClass
+	message string
+	// offset is where in the body decoding failed, if known.
+	offset int64
+}
+
+// Error implements the error interface.
+func (e *decodeFailure) Error() string {
+	return e.message
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read implements io.Reader.
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
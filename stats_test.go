@@ -0,0 +1,66 @@
+package ps
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParser_Stats(t *testing.T) {
+	testParser := Parser{MaxJSONSize: 64}
+
+	bodies := []string{
+		`{"name":"a"}`,
+		`{"name":"b"}`,
+		`{"name":`,
+		`{"name":1}`,
+		`{"name":"c","x":1}`,
+		``,
+		// Refused on its Content-Length, so none of it is read.
+		`{"name":"` + strings.Repeat("x", 64) + `"}`,
+		`{}`,
+	}
+	for _, body := range bodies {
+		var decoded struct {
+			Name string `json:"name,required"`
+		}
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		_ = testParser.ReadJSON(httptest.NewRecorder(), req, &decoded)
+	}
+
+	rr := httptest.NewRecorder()
+	_ = testParser.WriteJSON(rr, http.StatusOK, map[string]string{"name": "a"})
+	_ = testParser.WriteJSON(httptest.NewRecorder(), http.StatusOK, math.Inf(1))
+
+	expected := Stats{
+		Decodes: 2, DecodeFailures: 6, SyntaxErrors: 1, TypeErrors: 1, UnknownFields: 1, EmptyBodies: 1, TooLarge: 1,
+		ValidationErrors: 1, Encodes: 1, EncodeFailures: 1, BytesRead: 12 + 12 + 8 + 10 + 18 + 2, BytesWritten: int64(rr.Body.Len()),
+	}
+	if got := testParser.Stats(); got != expected {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+	if clone := testParser.Clone(); clone.Stats() != (Stats{}) {
+		t.Errorf("expected a clone to start with zero counters, got %+v", clone.Stats())
+	}
+}
+
+// TestParser_StatsConcurrent counts from many goroutines on a fresh Parser. Run it with -race.
+func TestParser_StatsConcurrent(t *testing.T) {
+	var testParser Parser
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = testParser.WriteJSON(httptest.NewRecorder(), http.StatusOK, "ok")
+		}()
+	}
+	wg.Wait()
+
+	if got := testParser.Stats(); got.Encodes != 16 || got.BytesWritten != 16*4 {
+		t.Errorf("expected 16 encodes of 4 bytes, got %+v", got)
+	}
+}
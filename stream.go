@@ -0,0 +1,270 @@
+package ps
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// streamContentTypes are the media types accepted for streamed request bodies.
+var streamContentTypes = []string{"application/json", "application/x-ndjson", "application/jsonl"}
+
+// errElementTooLarge is returned by elementLimit once an element has used up its budget.
+var errElementTooLarge = errors.New("element too large")
+
+// elementReader reads the elements of a streamed body one at a time: either the elements of a top-level JSON
+// array, or a sequence of JSON values such as newline-delimited JSON. Each element is decoded like a ReadJSON
+// body, and at most MaxJSONSize bytes are buffered for it.
+type elementReader struct {
+	p        *Parser
+	r        *http.Request
+	dec      *json.Decoder
+	limit    *elementLimit
+	maxBytes int
+	array    bool
+	index    int
+}
+
+// newElementReader checks r the way ReadJSON does and returns a reader for the elements of body. If unwrapArray
+// is false, a top-level array is read as one element rather than streamed.
+func (p *Parser) newElementReader(r *http.Request, body io.Reader, unwrapArray bool) (*elementReader, error) {
+	if p.ReplayGuard != nil {
+		if err := p.ReplayGuard.Check(r); err != nil {
+			return nil, err
+		}
+	}
+
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		if !containsFold(streamContentTypes, mediaType(contentType)) {
+			return nil, fmt.Errorf("the Content-Type header is not %s", strings.Join(streamContentTypes, " or "))
+		}
+	}
+
+	if err := p.checkEncoding(r); err != nil {
+		return nil, err
+	}
+
+	// Only MaxDecompressedSize limits a whole stream; MaxJSONSize applies to each element.
+	body, err := p.decompressed(r, body, int64(p.MaxDecompressedSize))
+	if err != nil {
+		return nil, err
+	}
+
+	maxBytes := p.maxPayload(r.Method)
+	limit := &elementLimit{r: body, n: int64(maxBytes)}
+	br := bufio.NewReader(limit)
+
+	// A body that opens with '[' is an array whose elements are streamed; anything else is a sequence of values.
+	first, err := peekNonSpace(br)
+	if err != nil && err != io.EOF {
+		return nil, decodeError(err, maxBytes)
+	}
+
+	e := &elementReader{p: p, r: r, dec: json.NewDecoder(br), limit: limit, maxBytes: maxBytes, array: unwrapArray && first == '['}
+	if e.array {
+		if _, err := e.dec.Token(); err != nil {
+			return nil, decodeError(err, maxBytes)
+		}
+	}
+	return e, nil
+}
+
+// next decodes the next element into data. It returns io.EOF when there are no more elements. Errors about an
+// element name its index.
+func (e *elementReader) next(data any) error {
+	e.limit.n = int64(e.maxBytes)
+
+	if e.array && !e.dec.More() {
+		if _, err := e.dec.Token(); err != nil {
+			return e.error(err)
+		}
+		if _, err := e.dec.Token(); err != io.EOF {
+			return errMultipleValues
+		}
+		return io.EOF
+	}
+
+	var raw json.RawMessage
+	if err := e.dec.Decode(&raw); err != nil {
+		if err == io.EOF && !e.array {
+			return io.EOF
+		}
+		return e.error(err)
+	}
+	e.p.count(bytesRead, int64(len(raw)))
+
+	if err := e.p.checkStructure(raw); err != nil {
+		return e.error(err)
+	}
+	if err := e.p.decodeBody(e.r, bytes.NewReader(raw), data, e.maxBytes); err != nil {
+		return e.error(err)
+	}
+	e.index++
+	return nil
+}
+
+// read decodes the next element into data, as next does, and counts it in Stats and reports it to the lifecycle
+// callbacks as a read of its own, for the streaming readers. The end of the elements is not a read.
+func (e *elementReader) read(data any) error {
+	err := e.next(data)
+	if err != io.EOF {
+		e.p.countDecode(err)
+		e.p.notifyDecode(e.r, data, err)
+	}
+	return err
+}
+
+// error names the current element in err.
+func (e *elementReader) error(err error) error {
+	if errors.Is(err, errElementTooLarge) {
+		return fmt.Errorf("element %d must not be larger than %d bytes", e.index, e.maxBytes)
+	}
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("element %d: %w", e.index, decodeError(err, e.maxBytes))
+}
+
+// elementLimit reads from r until n bytes have been read, then fails with errElementTooLarge. n is reset before
+// each element, so the limit applies per element rather than to the whole stream.
+type elementLimit struct {
+	r io.Reader
+	n int64
+}
+
+// Read implements io.Reader.
+func (l *elementLimit) Read(b []byte) (int, error) {
+	if l.n <= 0 {
+		return 0, errElementTooLarge
+	}
+	if int64(len(b)) > l.n {
+		b = b[:l.n]
+	}
+	n, err := l.r.Read(b)
+	l.n -= int64(n)
+	return n, err
+}
+
+// peekNonSpace skips JSON whitespace in br and returns the next byte without consuming it.
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b, br.UnreadByte()
+	}
+}
+
+// StreamJSONChan decodes successive JSON values from body onto the returned channel until body is exhausted or
+// ctx is done, for clients that keep one connection open and push documents continuously. body is usually r.Body;
+// after hijacking a connection, pass the hijacked reader instead. A nil body means r.Body. Values are read as
+// StreamJSON reads them, with MaxJSONSize limiting each one.
+//
+// The values channel is closed when decoding stops, after which the error channel yields the reason: nil at the
+// end of body, ctx.Err() on cancellation, or the first decoding error. A read already in progress is not
+// interrupted by ctx; closing the connection, or returning from the handler, ends it.
+//
+// Each value, and each decoding error, is counted in Stats and reported to the OnBind and OnDecodeError callbacks
+// as a read of its own; the callbacks run on the decoding goroutine rather than the handler's.
+func StreamJSONChan[T any](ctx context.Context, p *Parser, r *http.Request, body io.Reader) (<-chan T, <-chan error) {
+	values := make(chan T)
+	errc := make(chan error, 1)
+	if body == nil {
+		body = r.Body
+	}
+
+	go func() {
+		defer close(errc)
+		errc <- func() error {
+			defer close(values)
+			elements, err := p.newElementReader(r, body, true)
+			if err != nil {
+				p.countDecode(err)
+				p.notifyDecode(r, nil, err)
+				return err
+			}
+			for {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				var v T
+				if err := elements.read(&v); err != nil {
+					if err == io.EOF {
+						return nil
+					}
+					return err
+				}
+				select {
+				case values <- v:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}()
+	}()
+
+	return values, errc
+}
+
+// ReadJSONMulti reads a body of concatenated JSON documents, such as `{"id":1}{"id":2}` or one document per line,
+// where ReadJSON would refuse everything after the first. Each document is decoded into a new value from factory,
+// which must return a pointer, and passed to handle before the next one is read; a document that is itself an
+// array is decoded whole. MaxJSONSize limits each document rather than the whole body.
+//
+// Documents go through the same conversions, checks and AfterDecode hooks as a ReadJSON body. Reading stops at the
+// first decoding error, which names the document by its index, or at the first error from handle, which is
+// returned as it is. A body with no documents is an error, as it is for ReadJSON.
+func (p *Parser) ReadJSONMulti(w http.ResponseWriter, r *http.Request, factory func() any, handle func(any) error) error {
+	// An error from handle is the caller's own, not a failure to read the body, so it is neither counted nor
+	// reported as one.
+	var handleErr error
+	err := p.readJSONMulti(r, factory, func(v any) error {
+		handleErr = handle(v)
+		return handleErr
+	})
+	if handleErr != nil {
+		p.countDecode(nil)
+		return handleErr
+	}
+	p.countDecode(err)
+	if err != nil {
+		p.notifyDecode(r, nil, err)
+	}
+	return err
+}
+
+// readJSONMulti does the work of ReadJSONMulti.
+func (p *Parser) readJSONMulti(r *http.Request, factory func() any, handle func(any) error) error {
+	documents, err := p.newElementReader(r, r.Body, false)
+	if err != nil {
+		return err
+	}
+	for {
+		v := factory()
+		err := documents.next(v)
+		if err == io.EOF {
+			if documents.index == 0 {
+				return decodeError(io.EOF, documents.maxBytes)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		p.notifyDecode(r, v, nil)
+		if err := handle(v); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,51 @@
+//go:build go1.23
+
+package ps
+
+import (
+	"io"
+	"iter"
+	"net/http"
+)
+
+// StreamJSON returns an iterator over the elements of r's body, decoded one at a time with p's settings. The body
+// may be a JSON array or a sequence of JSON values such as newline-delimited JSON; either way only one element is
+// held in memory, and MaxJSONSize limits each element rather than the whole body.
+//
+// Each element goes through the same conversions, checks and AfterDecode hooks as a ReadJSON body; BeforeDecode
+// hooks, which rewrite a whole body, are not run. The first error ends the iteration. The body can only be read
+// once, so neither can the iterator. Each element, and the error that ends the iteration, is counted in Stats and
+// reported to the OnBind and OnDecodeError callbacks as a read of its own.
+//
+//	for item, err := range ps.StreamJSON[Item](parser, r) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func StreamJSON[T any](p *Parser, r *http.Request) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		elements, err := p.newElementReader(r, r.Body, true)
+		if err != nil {
+			p.countDecode(err)
+			p.notifyDecode(r, nil, err)
+			yield(zero, err)
+			return
+		}
+		for {
+			var v T
+			err := elements.read(&v)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+//go:build go1.23
+
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var streamTests = []struct {
+	name          string
+	body          string
+	contentType   string
+	expected      []int
+	errorExpected string
+}{
+	{name: "array", body: ` [{"id":1}, {"id":2},{"id":3}] `, contentType: "application/json", expected: []int{1, 2, 3}},
+	{name: "ndjson", body: "{\"id\":1}\n{\"id\":2}\n", contentType: "application/x-ndjson", expected: []int{1, 2}},
+	{name: "empty array", body: `[]`, expected: nil},
+	{name: "empty body", body: ``, expected: nil},
+	{name: "bad element", body: `[{"id":1},{"id":"two"}]`, expected: []int{1},
+		errorExpected: `element 1: body contains incorrect JSON type for field "id" at offset 11`},
+	{name: "unknown field", body: "{\"id\":1}\n{\"name\":\"x\"}", expected: []int{1}, errorExpected: `element 1: body contains unknown key "name"`},
+	{name: "truncated array", body: `[{"id":1}`, expected: []int{1}, errorExpected: "element 1: body contains badly-formed JSON (at character 9)"},
+	{name: "trailing value", body: `[{"id":1}] {}`, expected: []int{1}, errorExpected: "body must only contain a single JSON value"},
+	{name: "element too large", body: `[{"id":1},{"id":` + strings.Repeat("1", 100) + `}]`, expected: []int{1},
+		errorExpected: "element 1 must not be larger than 32 bytes"},
+	{name: "wrong content type", body: `[]`, contentType: "text/plain", errorExpected: "the Content-Type header is not application/json or application/x-ndjson or application/jsonl"},
+}
+
+func TestStreamJSON(t *testing.T) {
+	testParser := Parser{MaxJSONSize: 32}
+
+	for _, e := range streamTests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.body))
+		if e.contentType != "" {
+			req.Header.Set("Content-Type", e.contentType)
+		}
+
+		var ids []int
+		var lastErr error
+		for item, err := range StreamJSON[struct {
+			ID int `json:"id"`
+		}](&testParser, req) {
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			ids = append(ids, item.ID)
+		}
+
+		if e.errorExpected != "" && (lastErr == nil || lastErr.Error() != e.errorExpected) {
+			t.Errorf("%s: expected error %q, got %v", e.name, e.errorExpected, lastErr)
+		}
+		if e.errorExpected == "" && lastErr != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, lastErr)
+		}
+		if !slices.Equal(ids, e.expected) {
+			t.Errorf("%s: expected %v, got %v", e.name, e.expected, ids)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package ps
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamJSONChan(t *testing.T) {
+	var testParser Parser
+	pr, pw := io.Pipe()
+	req := httptest.NewRequest(http.MethodPost, "/", pr)
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	values, errc := StreamJSONChan[map[string]int](ctx, &testParser, req, nil)
+
+	// Documents arrive one at a time, as from a client holding the connection open.
+	for i := 1; i <= 3; i++ {
+		go io.WriteString(pw, `{"n":`+strings.Repeat("1", i)+"}\n")
+		select {
+		case v := <-values:
+			if v["n"] == 0 {
+				t.Errorf("unexpected value %v", v)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("document %d was not delivered", i)
+		}
+	}
+
+	pw.Close()
+	if _, ok := <-values; ok {
+		t.Error("expected the values channel to be closed at the end of the body")
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("expected no error at the end of the body, got %v", err)
+	}
+}
+
+func TestStreamJSONChan_Errors(t *testing.T) {
+	var testParser Parser
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{\"n\":1}\n{\"n\":\"x\"}\n"))
+	values, errc := StreamJSONChan[struct {
+		N int `json:"n"`
+	}](context.Background(), &testParser, req, nil)
+	count := 0
+	for range values {
+		count++
+	}
+	if err := <-errc; count != 1 || err == nil || !strings.HasPrefix(err.Error(), "element 1:") {
+		t.Errorf("expected one value and an error for element 1, got %d and %v", count, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}\n{}\n{}\n"))
+	empties, errc := StreamJSONChan[struct{}](ctx, &testParser, req, nil)
+	<-empties
+	cancel()
+	for range empties {
+	}
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+var readJSONMultiTests = []struct {
+	name          string
+	body          string
+	expected      []int
+	errorExpected string
+}{
+	{name: "concatenated", body: `{"id":1}{"id":2}{"id":3}`, expected: []int{1, 2, 3}},
+	{name: "newline delimited", body: "{\"id\":1}\n{\"id\":2}\n", expected: []int{1, 2}},
+	{name: "single", body: `{"id":1}`, expected: []int{1}},
+	{name: "empty", body: "  ", errorExpected: "body must not be empty"},
+	{name: "bad document", body: `{"id":1}{"id":"x"}`, expected: []int{1}, errorExpected: "element 1: body contains incorrect JSON type"},
+	{name: "unknown field", body: `{"id":1,"x":2}`, errorExpected: "element 0: body contains unknown key"},
+	{name: "handler error", body: `{"id":1}{"id":-1}{"id":2}`, expected: []int{1}, errorExpected: "negative id"},
+}
+
+func TestParser_ReadJSONMulti(t *testing.T) {
+	var testParser Parser
+
+	type document struct {
+		ID int `json:"id"`
+	}
+	for _, e := range readJSONMultiTests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.body))
+		req.Header.Set("Content-Type", "application/json")
+
+		var got []int
+		err := testParser.ReadJSONMulti(httptest.NewRecorder(), req, func() any { return &document{} }, func(v any) error {
+			d := v.(*document)
+			if d.ID < 0 {
+				return errors.New("negative id")
+			}
+			got = append(got, d.ID)
+			return nil
+		})
+
+		if e.errorExpected == "" && err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+		}
+		if e.errorExpected != "" && (err == nil || !strings.HasPrefix(err.Error(), e.errorExpected)) {
+			t.Errorf("%s: expected error %q, got %v", e.name, e.errorExpected, err)
+		}
+		if !reflect.DeepEqual(got, e.expected) {
+			t.Errorf("%s: expected documents %v, got %v", e.name, e.expected, got)
+		}
+	}
+}
+
+func TestParser_ReadJSONMultiArrays(t *testing.T) {
+	var testParser Parser
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`[1,2][3]`))
+
+	var got [][]int
+	err := testParser.ReadJSONMulti(httptest.NewRecorder(), req, func() any { return &[]int{} }, func(v any) error {
+		got = append(got, *v.(*[]int))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := [][]int{{1, 2}, {3}}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected each array decoded whole, %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,211 @@
+package ps
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Trailers written by a StreamWriter when it is closed, so that clients can tell a complete stream from one that
+// was cut short.
+const (
+	// TrailerCount holds the number of values written.
+	TrailerCount = "Stream-Count"
+	// TrailerChecksum holds "sha256=" and the hex SHA-256 of the body.
+	TrailerChecksum = "Stream-Checksum"
+	// TrailerStatus holds "complete", or "failed: " and the error passed to Fail.
+	TrailerStatus = "Stream-Status"
+)
+
+// errStreamClosed is returned by writes to a closed StreamWriter.
+var errStreamClosed = errors.New("stream is closed")
+
+// StreamFormat is the layout of a streamed response.
+type StreamFormat int
+
+const (
+	// NDJSONStream writes one JSON value per line, as application/x-ndjson. This is the default.
+	NDJSONStream StreamFormat = iota
+	// ArrayStream writes the values as the elements of a single JSON array, as application/json.
+	ArrayStream
+)
+
+// StreamWriter writes a response made of many JSON values, such as a long export, without holding them all in
+// memory. When it is closed it sets the Stream-Count, Stream-Checksum and Stream-Status trailers; a stream that
+// ends without them, or with a failed status, is incomplete.
+//
+// Values are buffered by the server until a Flush, or until the buffer fills; SetFlushInterval flushes
+// automatically, for progress updates that must reach the client promptly. The OnWrite callbacks run when the
+// stream is closed.
+type StreamWriter struct {
+	p         *Parser
+	w         http.ResponseWriter
+	rc        *http.ResponseController
+	format    StreamFormat
+	status    int
+	start     time.Time
+	size      int64
+	count     int
+	sum       hash.Hash
+	err       error
+	closed    bool
+	interval  time.Duration
+	lastFlush time.Time
+	deadline  bool
+}
+
+// NewStreamWriter starts a streamed response with the given status. trailers names any trailers besides the
+// standard three that the handler will set with SetTrailer; like all trailers, they must be declared before the
+// body is written.
+func (p *Parser) NewStreamWriter(w http.ResponseWriter, status int, format StreamFormat, trailers ...string) *StreamWriter {
+	h := w.Header()
+	for _, name := range append([]string{TrailerCount, TrailerChecksum, TrailerStatus}, trailers...) {
+		h.Add("Trailer", name)
+	}
+	if format == ArrayStream {
+		h.Set("Content-Type", "application/json")
+	} else {
+		h.Set("Content-Type", "application/x-ndjson")
+	}
+	rc := http.NewResponseController(w)
+	now := time.Now()
+	s := &StreamWriter{p: p, w: w, rc: rc, format: format, status: status, start: now, sum: sha256.New(), lastFlush: now}
+	s.err = p.fullDuplex(rc)
+	w.WriteHeader(status)
+
+	if format == ArrayStream && s.err == nil {
+		s.write([]byte("["))
+	}
+	return s
+}
+
+// Write encodes v as the next value in the stream. Once a write has failed, every later one returns the same
+// error.
+func (s *StreamWriter) Write(v any) error {
+	if s.closed {
+		return errStreamClosed
+	}
+	if s.err != nil {
+		return s.err
+	}
+
+	out, err := s.p.marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	switch s.format {
+	case ArrayStream:
+		if s.count > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(out)
+	default:
+		// Each value must stay on one line, even when the Parser indents its output.
+		if err := json.Compact(&buf, out); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+	}
+
+	if err := s.write(buf.Bytes()); err != nil {
+		return err
+	}
+	s.count++
+
+	if s.interval > 0 && time.Since(s.lastFlush) >= s.interval {
+		return s.Flush()
+	}
+	return nil
+}
+
+// Flush sends everything written so far to the client. It does nothing if the ResponseWriter cannot be flushed.
+func (s *StreamWriter) Flush() error {
+	if s.closed {
+		return errStreamClosed
+	}
+	if s.err != nil {
+		return s.err
+	}
+	s.lastFlush = time.Now()
+	if err := s.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		s.err = err
+		return err
+	}
+	return nil
+}
+
+// SetFlushInterval makes Write flush the stream whenever d has passed since the last flush. Zero, the default,
+// leaves flushing to the caller and the server's buffering.
+func (s *StreamWriter) SetFlushInterval(d time.Duration) {
+	s.interval = d
+}
+
+// SetWriteDeadline sets a deadline for writing the rest of the stream, replacing the Parser's WriteTimeout, which
+// otherwise gives each value its own. It returns http.ErrNotSupported if the ResponseWriter cannot set deadlines.
+func (s *StreamWriter) SetWriteDeadline(t time.Time) error {
+	s.deadline = true
+	return s.rc.SetWriteDeadline(t)
+}
+
+// SetTrailer sets a trailer declared in NewStreamWriter, to be sent when the stream is closed.
+func (s *StreamWriter) SetTrailer(name, value string) {
+	s.w.Header().Set(name, value)
+}
+
+// Close ends the stream and marks it complete.
+func (s *StreamWriter) Close() error {
+	return s.finish("complete")
+}
+
+// Fail ends the stream and marks it failed with err, for an export that cannot continue. Values already written
+// stay in the body, so clients must check the Stream-Status trailer. Under ProductionDisclosure the trailer reads
+// "failed: Internal Server Error" and err is logged instead.
+func (s *StreamWriter) Fail(err error) error {
+	return s.finish("failed: " + s.p.discloseMessage(err, http.StatusInternalServerError))
+}
+
+// finish closes the array, if any, and sets the trailers.
+func (s *StreamWriter) finish(status string) error {
+	if s.closed {
+		return errStreamClosed
+	}
+	if s.format == ArrayStream && s.err == nil {
+		s.write([]byte("]"))
+	}
+	s.closed = true
+
+	h := s.w.Header()
+	h.Set(TrailerCount, strconv.Itoa(s.count))
+	h.Set(TrailerChecksum, "sha256="+hex.EncodeToString(s.sum.Sum(nil)))
+	h.Set(TrailerStatus, status)
+	err := s.p.countWrite(s.err)
+	s.p.notifyWrite(s.w, WriteInfo{Status: s.status, Size: s.size, Duration: time.Since(s.start), Err: err})
+	return err
+}
+
+// write sends b to the client and adds it to the checksum.
+func (s *StreamWriter) write(b []byte) error {
+	if !s.deadline {
+		if err := s.p.writeDeadline(s.rc); err != nil {
+			s.err = err
+			return err
+		}
+	}
+	n, err := s.w.Write(b)
+	s.size += int64(n)
+	s.p.count(bytesWritten, int64(n))
+	if err != nil {
+		s.err = err
+		return err
+	}
+	s.sum.Write(b)
+	return nil
+}
@@ -0,0 +1,105 @@
+package ps
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var streamWriterTests = []struct {
+	name           string
+	format         StreamFormat
+	fail           error
+	expectedBody   string
+	expectedStatus string
+}{
+	{name: "ndjson", expectedBody: "{\"n\":1}\n{\"n\":2}\n", expectedStatus: "complete"},
+	{name: "array", format: ArrayStream, expectedBody: "[{\n  \"n\": 1\n},{\n  \"n\": 2\n}]", expectedStatus: "complete"},
+	{name: "failed", fail: errors.New("database went away"), expectedBody: "{\"n\":1}\n{\"n\":2}\n",
+		expectedStatus: "failed: database went away"},
+}
+
+func TestParser_NewStreamWriter(t *testing.T) {
+	// Indented output must not break NDJSON lines, though array elements keep it.
+	testParser := Parser{Pretty: true}
+
+	for _, e := range streamWriterTests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s := testParser.NewStreamWriter(w, http.StatusOK, e.format, "Export-Id")
+			for n := 1; n <= 2; n++ {
+				if err := s.Write(map[string]int{"n": n}); err != nil {
+					t.Errorf("%s: unexpected error: %v", e.name, err)
+				}
+			}
+			s.SetTrailer("Export-Id", "42")
+			if e.fail != nil {
+				s.Fail(e.fail)
+			} else {
+				s.Close()
+			}
+		}))
+
+		res, err := http.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		srv.Close()
+
+		if string(body) != e.expectedBody {
+			t.Errorf("%s: expected body %q, got %q", e.name, e.expectedBody, body)
+		}
+		sum := sha256.Sum256(body)
+		if got := res.Trailer.Get(TrailerChecksum); got != "sha256="+hex.EncodeToString(sum[:]) {
+			t.Errorf("%s: checksum trailer does not match the body: %s", e.name, got)
+		}
+		if res.Trailer.Get(TrailerCount) != "2" || res.Trailer.Get(TrailerStatus) != e.expectedStatus || res.Trailer.Get("Export-Id") != "42" {
+			t.Errorf("%s: unexpected trailers: %v", e.name, res.Trailer)
+		}
+	}
+}
+
+func TestStreamWriter_Flush(t *testing.T) {
+	var testParser Parser
+	received := make(chan struct{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := testParser.NewStreamWriter(w, http.StatusOK, NDJSONStream)
+		s.Write(map[string]int{"progress": 50})
+		if err := s.Flush(); err != nil {
+			t.Error(err)
+		}
+		// The report is still running; the client must already have the progress update.
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Error("progress update was not delivered before the stream ended")
+		}
+		s.SetFlushInterval(time.Nanosecond)
+		s.Write(map[string]int{"progress": 100})
+		<-received
+		s.Close()
+	}))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	lines := bufio.NewScanner(res.Body)
+	for _, expected := range []string{`{"progress":50}`, `{"progress":100}`} {
+		if !lines.Scan() || lines.Text() != expected {
+			t.Fatalf("expected %s, got %q", expected, lines.Text())
+		}
+		received <- struct{}{}
+	}
+}
@@ -0,0 +1,74 @@
+package ps
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// checksStructure reports whether the Parser limits the shape of bodies, which needs the whole body in memory.
+func (p *Parser) checksStructure() bool {
+	return p.MaxDepth > 0 || p.RejectDuplicateKeys
+}
+
+// checkStructure enforces MaxDepth and RejectDuplicateKeys on body. Syntax errors are left for the decoder to
+// report, so that they read the same whether or not these checks are enabled.
+func (p *Parser) checkStructure(body []byte) error {
+	if !p.checksStructure() {
+		return nil
+	}
+
+	// frame is an array or object being read; key records whether an object expects a key next.
+	type frame struct {
+		object bool
+		key    bool
+		seen   map[string]bool
+	}
+	var stack []frame
+
+	// valueDone notes that the current object, if any, now expects a key.
+	valueDone := func() {
+		if n := len(stack); n > 0 && stack[n-1].object {
+			stack[n-1].key = true
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil
+		}
+
+		if n := len(stack); n > 0 && stack[n-1].object && stack[n-1].key {
+			if key, ok := tok.(string); ok {
+				if p.RejectDuplicateKeys {
+					if stack[n-1].seen[key] {
+						return &decodeFailure{class: otherFailure, message: fmt.Sprintf("body contains duplicate key %q", key), offset: dec.InputOffset()}
+					}
+					stack[n-1].seen[key] = true
+				}
+				stack[n-1].key = false
+				continue
+			}
+		}
+
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			if p.MaxDepth > 0 && len(stack) >= p.MaxDepth {
+				return &decodeFailure{class: otherFailure, message: fmt.Sprintf("body must not be nested more than %d levels deep", p.MaxDepth), offset: dec.InputOffset()}
+			}
+			f := frame{object: tok == json.Delim('{'), key: true}
+			if f.object && p.RejectDuplicateKeys {
+				f.seen = make(map[string]bool)
+			}
+			stack = append(stack, f)
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+			valueDone()
+		default:
+			valueDone()
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package ps
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// defaultTenantHeader is the header a TenantGuard reads when its HeaderName is empty.
+const defaultTenantHeader = "X-Tenant-ID"
+
+// tenantKey is the context key under which RequireTenant stores a request's tenant.
+type tenantKey struct{}
+
+// TenantError is returned when a request names no tenant, or one it may not act for.
+type TenantError struct {
+	// Tenant is the tenant the request named, if any.
+	Tenant string
+	// Reason describes why the request was rejected.
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *TenantError) Error() string {
+	return "request rejected: " + e.Reason
+}
+
+// TenantGuard extracts the tenant a request acts for from a header, for multi-tenant services that scope every
+// query by it.
+type TenantGuard struct {
+	// HeaderName holds the tenant ID (default X-Tenant-ID).
+	HeaderName string
+	// Validate, if set, checks the tenant ID's format, and that the caller may act for the tenant; a request it
+	// returns an error for is refused. The error's message is sent to the client.
+	Validate func(r *http.Request, tenant string) error
+}
+
+// Tenant returns the tenant r names. It returns a *TenantError, with an empty Tenant, if the header is missing or
+// blank, and one naming the tenant if Validate rejects it.
+func (g *TenantGuard) Tenant(r *http.Request) (string, error) {
+	headerName := g.headerName()
+	tenant := strings.TrimSpace(r.Header.Get(headerName))
+	if tenant == "" {
+		return "", &TenantError{Reason: "missing " + headerName + " header"}
+	}
+	if g.Validate != nil {
+		if err := g.Validate(r, tenant); err != nil {
+			return "", &TenantError{Tenant: tenant, Reason: err.Error()}
+		}
+	}
+	return tenant, nil
+}
+
+// headerName returns the configured tenant header, or the default one.
+func (g *TenantGuard) headerName() string {
+	if g.HeaderName != "" {
+		return g.HeaderName
+	}
+	return defaultTenantHeader
+}
+
+// TenantFrom returns the tenant that RequireTenant stored in ctx, or "".
+func TenantFrom(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// RequireTenant returns middleware that runs guard.Tenant on every request and stores the tenant in the request's
+// context, for TenantFrom. A request without a tenant is answered with a 400 JSON error, and one whose tenant
+// Validate rejects with a 403. Responses vary on the tenant header, so that caches keep tenants apart.
+func (p *Parser) RequireTenant(guard *TenantGuard) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			AddVary(w, guard.headerName())
+			tenant, err := guard.Tenant(r)
+			if err != nil {
+				status := http.StatusForbidden
+				if err.(*TenantError).Tenant == "" {
+					status = http.StatusBadRequest
+				}
+				_ = p.ErrorJSON(w, err, status)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tenantKey{}, tenant)))
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package ps
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var tenantTests = []struct {
+	name           string
+	header         string
+	statusExpected int
+	messageFound   string
+}{
+	{name: "valid", header: "acme", statusExpected: http.StatusOK, messageFound: `"acme"`},
+	{name: "padded", header: "  acme ", statusExpected: http.StatusOK, messageFound: `"acme"`},
+	{name: "missing", header: "", statusExpected: http.StatusBadRequest, messageFound: "missing X-Org header"},
+	{name: "bad format", header: "ACME!", statusExpected: http.StatusForbidden, messageFound: "tenant ID must be lower-case letters"},
+	{name: "not a member", header: "globex", statusExpected: http.StatusForbidden, messageFound: "not a member of globex"},
+}
+
+func TestParser_RequireTenant(t *testing.T) {
+	var testParser Parser
+	guard := &TenantGuard{
+		HeaderName: "X-Org",
+		Validate: func(r *http.Request, tenant string) error {
+			if strings.Trim(tenant, "abcdefghijklmnopqrstuvwxyz") != "" {
+				return errors.New("tenant ID must be lower-case letters")
+			}
+			if tenant != "acme" {
+				return errors.New("not a member of " + tenant)
+			}
+			return nil
+		},
+	}
+	handler := testParser.RequireTenant(guard)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = testParser.WriteJSON(w, http.StatusOK, TenantFrom(r.Context()))
+	}))
+
+	for _, e := range tenantTests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if e.header != "" {
+			req.Header.Set("X-Org", e.header)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.statusExpected {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.statusExpected, rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), e.messageFound) {
+			t.Errorf("%s: expected %q in the body, got %s", e.name, e.messageFound, rr.Body.String())
+		}
+	}
+}
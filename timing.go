@@ -0,0 +1,111 @@
+package ps
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timingsKey is the context key under which ServerTiming stores a request's Timings.
+type timingsKey struct{}
+
+// Timings accumulates the Server-Timing metrics of one request, such as time spent parsing, querying a database or
+// rendering. It is safe for concurrent use, and a nil *Timings ignores everything, so handlers need not check
+// whether the ServerTiming middleware is installed.
+type Timings struct {
+	mu      sync.Mutex
+	metrics []timing
+}
+
+// timing is one Server-Timing metric.
+type timing struct {
+	name        string
+	duration    time.Duration
+	description string
+}
+
+// TimingsFrom returns the Timings that the ServerTiming middleware attached to ctx, or nil.
+func TimingsFrom(ctx context.Context) *Timings {
+	t, _ := ctx.Value(timingsKey{}).(*Timings)
+	return t
+}
+
+// Add records a metric. name must be an HTTP token; description is optional.
+func (t *Timings) Add(name string, d time.Duration, description ...string) {
+	if t == nil {
+		return
+	}
+	m := timing{name: name, duration: d}
+	if len(description) > 0 {
+		m.description = description[0]
+	}
+	t.mu.Lock()
+	t.metrics = append(t.metrics, m)
+	t.mu.Unlock()
+}
+
+// Start begins timing name and returns a function that records the metric when called, as in
+// defer timings.Start("db")().
+func (t *Timings) Start(name string, description ...string) func() {
+	if t == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() { t.Add(name, time.Since(start), description...) }
+}
+
+// header formats the metrics as a Server-Timing header value, with durations in milliseconds.
+func (t *Timings) header() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	parts := make([]string, 0, len(t.metrics))
+	for _, m := range t.metrics {
+		part := m.name + ";dur=" + strconv.FormatFloat(float64(m.duration)/float64(time.Millisecond), 'f', -1, 64)
+		if m.description != "" {
+			part += ";desc=" + strconv.Quote(m.description)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ServerTiming returns middleware that collects Server-Timing metrics for each request. Handlers add their own
+// with TimingsFrom(r.Context()); ReadJSON records "decode" and WriteJSON records "encode", and WriteJSON sends the
+// header with everything gathered so far.
+func (p *Parser) ServerTiming() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t := &Timings{}
+			next.ServeHTTP(&timingWriter{ResponseWriter: w, timings: t}, r.WithContext(context.WithValue(r.Context(), timingsKey{}, t)))
+		})
+	}
+}
+
+// timingWriter carries a request's Timings to WriteJSON, which only sees the ResponseWriter.
+type timingWriter struct {
+	http.ResponseWriter
+	timings *Timings
+}
+
+// Unwrap returns the wrapped ResponseWriter, for http.ResponseController.
+func (tw *timingWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// timingsOf finds the Timings carried by w or any ResponseWriter it wraps.
+func timingsOf(w http.ResponseWriter) *Timings {
+	for {
+		switch v := w.(type) {
+		case *timingWriter:
+			return v.timings
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = v.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
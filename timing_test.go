@@ -0,0 +1,40 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParser_ServerTiming(t *testing.T) {
+	var testParser Parser
+
+	handler := testParser.ServerTiming()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		if err := testParser.ReadJSON(w, r, &payload); err != nil {
+			t.Fatal(err)
+		}
+		timings := TimingsFrom(r.Context())
+		timings.Add("db", 12500*time.Microsecond, "orders query")
+		testParser.WriteJSON(w, http.StatusOK, payload)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"a":1}`)))
+
+	expected := regexp.MustCompile(`^decode;dur=[0-9.]+, db;dur=12.5;desc="orders query", encode;dur=[0-9.]+$`)
+	if got := rr.Header().Get("Server-Timing"); !expected.MatchString(got) {
+		t.Errorf("unexpected Server-Timing header %q", got)
+	}
+
+	// Without the middleware there is nothing to collect, and no header.
+	rr = httptest.NewRecorder()
+	TimingsFrom(httptest.NewRequest(http.MethodGet, "/", nil).Context()).Add("db", time.Second)
+	testParser.WriteJSON(rr, http.StatusOK, "ok")
+	if got := rr.Header().Get("Server-Timing"); got != "" {
+		t.Errorf("expected no Server-Timing header, got %q", got)
+	}
+}
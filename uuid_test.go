@@ -0,0 +1,74 @@
+package ps
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var uuidTests = []struct {
+	name          string
+	json          string
+	errorExpected string
+}{
+	{name: "valid", json: `{"id":"6BA7B810-9DAD-11D1-80B4-00C04FD430C8","owners":["6ba7b811-9dad-11d1-80b4-00c04fd430c8"]}`},
+	{name: "null", json: `{"id":null}`},
+	{name: "not hex", json: `{"id":"6ba7b810-9dad-11d1-80b4-00c04fd430zz"}`, errorExpected: "id must be a valid UUID"},
+	{name: "no hyphens", json: `{"id":"6ba7b8109dad11d180b400c04fd430c8"}`, errorExpected: "id must be a valid UUID"},
+	{name: "number", json: `{"id":42}`, errorExpected: "id must be a valid UUID"},
+	{name: "in slice", json: `{"owners":["x"]}`, errorExpected: "owners must be a valid UUID"},
+	{name: "in nested struct", json: `{"parent":{"id":""}}`, errorExpected: "parent.id must be a valid UUID"},
+	{name: "date", json: `{"parent":{"born":"1990-02-30"}}`, errorExpected: "parent.born must be a valid date (YYYY-MM-DD)"},
+}
+
+type uuidParent struct {
+	ID   UUID `json:"id"`
+	Born Date `json:"born"`
+}
+
+func TestParser_ReadJSONUUID(t *testing.T) {
+	var testParser Parser
+
+	for _, e := range uuidTests {
+		var decoded struct {
+			ID     *UUID       `json:"id"`
+			Owners []UUID      `json:"owners"`
+			Parent *uuidParent `json:"parent"`
+		}
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.json))
+		err := testParser.ReadJSON(httptest.NewRecorder(), req, &decoded)
+
+		if e.errorExpected != "" {
+			var fieldError *FieldError
+			if !errors.As(err, &fieldError) || err.Error() != e.errorExpected {
+				t.Errorf("%s: expected field error %q, got %v", e.name, e.errorExpected, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+			continue
+		}
+
+		if decoded.ID != nil && decoded.ID.String() != "6ba7b810-9dad-11d1-80b4-00c04fd430c8" {
+			t.Errorf("%s: wrong id decoded: %v", e.name, decoded.ID)
+		}
+	}
+}
+
+func TestNewUUID(t *testing.T) {
+	u, err := NewUUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Version() != 4 || u[8]&0xc0 != 0x80 {
+		t.Errorf("expected a version 4 UUID, got %v", u)
+	}
+
+	parsed, err := ParseUUID(u.String())
+	if err != nil || parsed != u {
+		t.Errorf("expected %v to round-trip, got %v (%v)", u, parsed, err)
+	}
+}
@@ -0,0 +1,39 @@
+package ps
+
+import "context"
+
+// Validator is implemented by request types with rules that tag checks cannot express, such as an end date that
+// must follow a start date. ReadJSON, and the other readers, call Validate on the value they decoded, after the
+// AfterDecode hooks, with the request's context. The field errors of a *FieldError or *ValidationError it returns
+// are merged with those of ValidateFields; any other error is returned as it is.
+type Validator interface {
+	Validate(ctx context.Context) error
+}
+
+// FieldsValidator is implemented by request types that report cross-field problems as a list. ReadJSON, and the
+// other readers, call ValidateFields on the value they decoded and return what it reports as a
+// *ValidationError, together with the field errors of Validate.
+type FieldsValidator interface {
+	ValidateFields() []FieldError
+}
+
+// validate runs the Validate and ValidateFields methods of data, if it has them.
+func validate(ctx context.Context, data any) error {
+	var fields []FieldError
+	if v, ok := data.(FieldsValidator); ok {
+		fields = append(fields, v.ValidateFields()...)
+	}
+	if v, ok := data.(Validator); ok {
+		if err := v.Validate(ctx); err != nil {
+			more := fieldErrors(err)
+			if more == nil {
+				return err
+			}
+			fields = append(fields, more...)
+		}
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package ps
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type bookingRequest struct {
+	From  int    `json:"from"`
+	To    int    `json:"to"`
+	Guest string `json:"guest"`
+}
+
+func (b *bookingRequest) ValidateFields() []FieldError {
+	if b.To < b.From {
+		return []FieldError{{Field: "to", Message: "must not be before from"}}
+	}
+	return nil
+}
+
+func (b *bookingRequest) Validate(ctx context.Context) error {
+	switch b.Guest {
+	case "":
+		return &FieldError{Field: "guest", Message: "must not be empty"}
+	case "blocked":
+		return errors.New("guest is not allowed to book")
+	}
+	return nil
+}
+
+var validatorTests = []struct {
+	name          string
+	json          string
+	errorExpected string
+}{
+	{name: "valid", json: `{"from": 1, "to": 2, "guest": "Ann"}`},
+	{name: "fields", json: `{"from": 2, "to": 1, "guest": "Ann"}`, errorExpected: "to must not be before from"},
+	{name: "validate", json: `{"from": 1, "to": 2}`, errorExpected: "guest must not be empty"},
+	{name: "merged", json: `{"from": 2, "to": 1}`, errorExpected: "to must not be before from; guest must not be empty"},
+	{name: "plain error", json: `{"from": 2, "to": 1, "guest": "blocked"}`, errorExpected: "guest is not allowed to book"},
+}
+
+func TestParser_ReadJSONValidator(t *testing.T) {
+	var testParser Parser
+
+	for _, e := range validatorTests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.json))
+		var got bookingRequest
+		err := testParser.ReadJSON(httptest.NewRecorder(), req, &got)
+
+		if e.errorExpected == "" && err != nil {
+			t.Errorf("%s: error not expected, but one received: %v", e.name, err)
+		}
+		if e.errorExpected != "" && (err == nil || err.Error() != e.errorExpected) {
+			t.Errorf("%s: expected error %q, got %v", e.name, e.errorExpected, err)
+		}
+	}
+}
+
+func TestParser_ReadJSONValidatorResponse(t *testing.T) {
+	var testParser Parser
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"from": 2, "to": 1}`))
+	rr := httptest.NewRecorder()
+
+	var got bookingRequest
+	if err := testParser.ReadJSON(rr, req, &got); err != nil {
+		_ = testParser.ErrorJSON(rr, err)
+	}
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), `"field":"guest"`) {
+		t.Errorf("expected a 400 listing the fields, got %d %s", rr.Code, rr.Body.String())
+	}
+}
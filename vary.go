@@ -0,0 +1,50 @@
+package ps
+
+import (
+	"net/http"
+	"strings"
+)
+
+// AddVary appends fields to the Vary header of the response, skipping any that are already listed (compared
+// case-insensitively). Existing values are folded into a single comma-separated header. Nothing is added if the
+// response already varies on "*".
+func AddVary(w http.ResponseWriter, fields ...string) {
+	h := w.Header()
+
+	merged := varyFields(h)
+	for _, field := range fields {
+		if containsFold(merged, "*") {
+			break
+		}
+		if !containsFold(merged, field) {
+			merged = append(merged, field)
+		}
+	}
+
+	if len(merged) > 0 {
+		h.Set("Vary", strings.Join(merged, ", "))
+	}
+}
+
+// varyFields returns the header names listed in the Vary header of h.
+func varyFields(h http.Header) []string {
+	var fields []string
+	for _, value := range h.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
+// containsFold reports whether list contains s, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var varyTests = []struct {
+	name     string
+	existing []string
+	add      []string
+	expected string
+}{
+	{name: "empty", add: []string{"Accept"}, expected: "Accept"},
+	{name: "append", existing: []string{"Origin"}, add: []string{"Accept", "Accept-Language"}, expected: "Origin, Accept, Accept-Language"},
+	{name: "duplicate", existing: []string{"accept"}, add: []string{"Accept"}, expected: "accept"},
+	{name: "folded", existing: []string{"Origin, Accept", "Accept-Encoding"}, add: []string{"Accept-Encoding"}, expected: "Origin, Accept, Accept-Encoding"},
+	{name: "wildcard", existing: []string{"*"}, add: []string{"Accept"}, expected: "*"},
+}
+
+func TestAddVary(t *testing.T) {
+	for _, e := range varyTests {
+		rr := httptest.NewRecorder()
+		for _, v := range e.existing {
+			rr.Header().Add("Vary", v)
+		}
+
+		AddVary(rr, e.add...)
+
+		if got := rr.Header().Get("Vary"); got != e.expected {
+			t.Errorf("%s: expected %q, got %q", e.name, e.expected, got)
+		}
+	}
+}
+
+func TestParser_WriteJSONVaryOnVersion(t *testing.T) {
+	var testParser Parser
+	testParser.RegisterVersion("1", JSONResponse{}, func(data any) (any, error) { return data, nil })
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	testParser.NegotiateVersion(rr, req)
+
+	if err := testParser.WriteJSON(rr, http.StatusOK, JSONResponse{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := rr.Header().Get("Vary"); got != "API-Version, Accept" {
+		t.Errorf("wrong Vary header: %q", got)
+	}
+}
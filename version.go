@@ -0,0 +1,175 @@
+package ps
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// defaultVersionHeader is the header used to negotiate the API version when Parser.VersionHeader is empty.
+const defaultVersionHeader = "API-Version"
+
+// VersionTransform converts a response payload into the shape expected by clients of one API version.
+type VersionTransform func(data any) (any, error)
+
+// versionKey identifies a registered transform.
+type versionKey struct {
+	version string
+	typ     reflect.Type
+}
+
+// RegisterVersion registers fn to transform payloads of the same type as sample whenever the negotiated API
+// version is version. This lets handlers always build the newest representation, while clients pinned to an
+// older version keep receiving the shape they were built against.
+func (p *Parser) RegisterVersion(version string, sample any, fn VersionTransform) {
+	p.mustNotBeFrozen("RegisterVersion")
+	if p.versions == nil {
+		p.versions = make(map[versionKey]VersionTransform)
+	}
+	p.versions[versionKey{version: version, typ: reflect.TypeOf(sample)}] = fn
+}
+
+// NegotiateVersion reads the API version requested by the client, either from the version header or from the
+// version parameter of an Accept media type (e.g. application/json; version=1), and echoes it on the response
+// so that WriteJSON can select the matching transform. It returns an empty string if no version was requested.
+func (p *Parser) NegotiateVersion(w http.ResponseWriter, r *http.Request) string {
+	header := p.versionHeader()
+	version := p.requestedVersion(r)
+
+	// The response now depends on both places a version can be requested from.
+	AddVary(w, header, "Accept")
+
+	if version != "" {
+		w.Header().Set(header, version)
+	}
+
+	return version
+}
+
+// UnsupportedVersionError is returned by SelectVersion when a client asks for an API version that APIVersions
+// does not list. Handlers should answer it with 406 Not Acceptable.
+type UnsupportedVersionError struct {
+	// Version is the version requested.
+	Version string
+	// Supported lists the versions that are.
+	Supported []string
+}
+
+// Error implements the error interface.
+func (e *UnsupportedVersionError) Error() string {
+	return "API version " + e.Version + " is not supported; use " + joinChoices(e.Supported)
+}
+
+// SelectVersion negotiates the API version as NegotiateVersion does, but accepts only the versions listed in
+// APIVersions, returning an *UnsupportedVersionError for others, and selects the first of them for clients that
+// ask for none. The selected version is echoed on the response, so WriteJSON applies its transforms even when
+// the client relied on the default. Without APIVersions, any version is accepted.
+func (p *Parser) SelectVersion(w http.ResponseWriter, r *http.Request) (string, error) {
+	version := p.requestedVersion(r)
+	if len(p.APIVersions) > 0 {
+		if version == "" {
+			version = p.APIVersions[0]
+		} else if !slices.Contains(p.APIVersions, version) {
+			return "", &UnsupportedVersionError{Version: version, Supported: p.APIVersions}
+		}
+	}
+
+	header := p.versionHeader()
+	AddVary(w, header, "Accept")
+	if version != "" {
+		w.Header().Set(header, version)
+	}
+	return version, nil
+}
+
+// versionContextKey is the context key under which Versioned stores a request's API version.
+type versionContextKey struct{}
+
+// VersionFrom returns the API version that Versioned selected for the request with context ctx, or "".
+func VersionFrom(ctx context.Context) string {
+	version, _ := ctx.Value(versionContextKey{}).(string)
+	return version
+}
+
+// Versioned returns middleware that runs SelectVersion on every request and stores the version in the request's
+// context, for VersionFrom, so that handlers can branch on it. Requests for unsupported versions are answered
+// with a 406 JSON error.
+func (p *Parser) Versioned() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			version, err := p.SelectVersion(w, r)
+			if err != nil {
+				_ = p.ErrorJSON(w, err, http.StatusNotAcceptable)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), versionContextKey{}, version)))
+		})
+	}
+}
+
+// requestedVersion returns the API version r asks for in the version header or, failing that, in the Accept
+// header, or "".
+func (p *Parser) requestedVersion(r *http.Request) string {
+	if version := strings.TrimSpace(r.Header.Get(p.versionHeader())); version != "" {
+		return version
+	}
+	return acceptVersion(r.Header.Get("Accept"))
+}
+
+// versionHeader returns the configured version header, or the default one.
+func (p *Parser) versionHeader() string {
+	if p.VersionHeader != "" {
+		return p.VersionHeader
+	}
+	return defaultVersionHeader
+}
+
+// acceptVersion returns the version parameter of the most preferred media range in an Accept header that has one.
+func acceptVersion(accept string) string {
+	for _, m := range ParseAccept(accept) {
+		if v := m.Params["version"]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// transformVersion applies the transform registered for the negotiated version to data. Payloads wrapped in a
+// JSONResponse have the transform applied to their Data field when the envelope itself has no transform.
+func (p *Parser) transformVersion(version string, data any) (any, error) {
+	if version == "" || len(p.versions) == 0 {
+		return data, nil
+	}
+
+	if fn, ok := p.versions[versionKey{version: version, typ: reflect.TypeOf(data)}]; ok {
+		return fn(data)
+	}
+
+	var envelope JSONResponse
+	switch v := data.(type) {
+	case JSONResponse:
+		envelope = v
+	case *JSONResponse:
+		if v == nil {
+			return data, nil
+		}
+		envelope = *v
+	default:
+		return data, nil
+	}
+
+	fn, ok := p.versions[versionKey{version: version, typ: reflect.TypeOf(envelope.Data)}]
+	if !ok {
+		return data, nil
+	}
+
+	transformed, err := fn(envelope.Data)
+	if err != nil {
+		return nil, err
+	}
+	envelope.Data = transformed
+
+	return envelope, nil
+}
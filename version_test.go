@@ -0,0 +1,110 @@
+package ps
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type versionedUser struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+var versionTests = []struct {
+	name     string
+	header   string
+	accept   string
+	payload  any
+	expected string
+}{
+	{name: "no version", payload: versionedUser{"Jack", "Smith"}, expected: `{"first_name":"Jack","last_name":"Smith"}`},
+	{name: "latest version", header: "2", payload: versionedUser{"Jack", "Smith"}, expected: `{"first_name":"Jack","last_name":"Smith"}`},
+	{name: "version header", header: "1", payload: versionedUser{"Jack", "Smith"}, expected: `{"name":"Jack Smith"}`},
+	{name: "accept parameter", accept: "application/json; version=1", payload: versionedUser{"Jack", "Smith"}, expected: `{"name":"Jack Smith"}`},
+	{name: "envelope data", header: "1", payload: JSONResponse{Message: "ok", Data: versionedUser{"Jack", "Smith"}}, expected: `{"error":false,"message":"ok","data":{"name":"Jack Smith"}}`},
+}
+
+func TestParser_NegotiateVersion(t *testing.T) {
+	var testParser Parser
+	testParser.RegisterVersion("1", versionedUser{}, func(data any) (any, error) {
+		u := data.(versionedUser)
+		return map[string]string{"name": u.FirstName + " " + u.LastName}, nil
+	})
+
+	for _, e := range versionTests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if e.header != "" {
+			req.Header.Set("API-Version", e.header)
+		}
+		if e.accept != "" {
+			req.Header.Set("Accept", e.accept)
+		}
+
+		rr := httptest.NewRecorder()
+		version := testParser.NegotiateVersion(rr, req)
+		if rr.Header().Get("API-Version") != version {
+			t.Errorf("%s: expected version %q to be echoed, got %q", e.name, version, rr.Header().Get("API-Version"))
+		}
+
+		if err := testParser.WriteJSON(rr, http.StatusOK, e.payload); err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+
+		if !json.Valid(rr.Body.Bytes()) || rr.Body.String() != e.expected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.expected, rr.Body.String())
+		}
+	}
+}
+
+var versionedTests = []struct {
+	name            string
+	header          string
+	accept          string
+	statusExpected  int
+	versionExpected string
+	bodyExpected    string
+}{
+	{name: "default", statusExpected: http.StatusOK, versionExpected: "1", bodyExpected: `{"name":"Jack Smith"}`},
+	{name: "header", header: "2", statusExpected: http.StatusOK, versionExpected: "2", bodyExpected: `{"first_name":"Jack","last_name":"Smith"}`},
+	{name: "accept parameter", accept: "application/json; version=2", statusExpected: http.StatusOK, versionExpected: "2", bodyExpected: `{"first_name":"Jack","last_name":"Smith"}`},
+	{name: "unsupported", header: "3", statusExpected: http.StatusNotAcceptable, bodyExpected: `{"error":true,"message":"API version 3 is not supported; use 1 or 2"}`},
+}
+
+func TestParser_Versioned(t *testing.T) {
+	testParser := New(WithAPIVersions("1", "2"), WithVersionHeader("Accept-Version"))
+	testParser.RegisterVersion("1", versionedUser{}, func(data any) (any, error) {
+		u := data.(versionedUser)
+		return map[string]string{"name": u.FirstName + " " + u.LastName}, nil
+	})
+
+	for _, e := range versionedTests {
+		var seen string
+		handler := testParser.Versioned()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = VersionFrom(r.Context())
+			_ = testParser.WriteJSON(w, http.StatusOK, versionedUser{"Jack", "Smith"})
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if e.header != "" {
+			req.Header.Set("Accept-Version", e.header)
+		}
+		if e.accept != "" {
+			req.Header.Set("Accept", e.accept)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.statusExpected {
+			t.Errorf("%s: expected status %d, got %d", e.name, e.statusExpected, rr.Code)
+		}
+		if seen != e.versionExpected {
+			t.Errorf("%s: expected version %q in the context, got %q", e.name, e.versionExpected, seen)
+		}
+		if rr.Body.String() != e.bodyExpected {
+			t.Errorf("%s: expected %s, got %s", e.name, e.bodyExpected, rr.Body.String())
+		}
+	}
+}
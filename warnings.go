@@ -0,0 +1,84 @@
+package ps
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// warnPrefix begins the Warning header values AddWarning writes: 299 is the code for a persistent miscellaneous
+// warning, and the agent is left anonymous.
+const warnPrefix = `299 - `
+
+// Warnings controls where WriteJSON reports the warnings handlers attach with AddWarning.
+type Warnings int
+
+const (
+	// HeaderAndBodyWarnings sends them in Warning headers, and in the warnings array of JSONResponse envelopes.
+	// This is the default.
+	HeaderAndBodyWarnings Warnings = iota
+	// BodyWarnings sends them only in the warnings array of JSONResponse envelopes. A response that is not one
+	// keeps them in Warning headers, so that they are not lost.
+	BodyWarnings
+	// HeaderWarnings sends them only in Warning headers.
+	HeaderWarnings
+)
+
+// AddWarning attaches a non-fatal warning to the response w will send, such as that a deprecated parameter was
+// used or a value was clamped, so that integrators learn about input the server put up with. It must be called
+// before the response is written. The warning is held in a Warning header until WriteJSON sends it where the
+// Parser's Warnings setting says.
+func AddWarning(w http.ResponseWriter, message string) {
+	w.Header().Add("Warning", warnPrefix+strconv.Quote(message))
+}
+
+// warningsOf returns the messages of the warnings added to w with AddWarning.
+func warningsOf(w http.ResponseWriter) []string {
+	var messages []string
+	for _, value := range w.Header().Values("Warning") {
+		quoted, ok := strings.CutPrefix(value, warnPrefix)
+		if !ok {
+			continue
+		}
+		if message, err := strconv.Unquote(quoted); err == nil {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// withWarnings places the warnings added to w as the Parser's Warnings setting says, returning data with them
+// added if it is a JSONResponse. The caller's envelope is copied rather than changed.
+func (p *Parser) withWarnings(w http.ResponseWriter, data any) any {
+	messages := warningsOf(w)
+	if len(messages) == 0 || p.Warnings == HeaderWarnings {
+		return data
+	}
+
+	data, moved := appendWarnings(data, messages)
+	// The headers only go once the warnings have somewhere else to be.
+	if moved && p.Warnings == BodyWarnings {
+		w.Header().Del("Warning")
+	}
+	return data
+}
+
+// appendWarnings returns data with messages added to its warnings, and whether it is an envelope that has them.
+func appendWarnings(data any, messages []string) (any, bool) {
+	switch payload := data.(type) {
+	case JSONResponse:
+		payload.Warnings = append(payload.Warnings[:len(payload.Warnings):len(payload.Warnings)], messages...)
+		return payload, true
+	case *JSONResponse:
+		if payload == nil {
+			return data, false
+		}
+		c := *payload
+		c.Warnings = append(c.Warnings[:len(c.Warnings):len(c.Warnings)], messages...)
+		return &c, true
+	case debugResponse:
+		payload.Warnings = append(payload.Warnings[:len(payload.Warnings):len(payload.Warnings)], messages...)
+		return payload, true
+	}
+	return data, false
+}
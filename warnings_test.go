@@ -0,0 +1,66 @@
+package ps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var warningsTests = []struct {
+	name           string
+	policy         Warnings
+	data           any
+	bodyExpected   string
+	headerExpected string
+}{
+	{name: "both", policy: HeaderAndBodyWarnings, data: JSONResponse{Data: 1, Warnings: []string{"first"}},
+		bodyExpected: `"warnings":["first","limit clamped to 100","\"sort\" is deprecated"]`, headerExpected: `299 - "limit clamped to 100", 299 - "\"sort\" is deprecated"`},
+	{name: "pointer envelope", policy: HeaderAndBodyWarnings, data: &JSONResponse{Data: 1},
+		bodyExpected: `"warnings":["limit clamped to 100","\"sort\" is deprecated"]`, headerExpected: `299 - "limit clamped to 100", 299 - "\"sort\" is deprecated"`},
+	{name: "body only", policy: BodyWarnings, data: JSONResponse{Data: 1},
+		bodyExpected: `"warnings":["limit clamped to 100","\"sort\" is deprecated"]`},
+	{name: "header only", policy: HeaderWarnings, data: JSONResponse{Data: 1},
+		headerExpected: `299 - "limit clamped to 100", 299 - "\"sort\" is deprecated"`},
+	{name: "not an envelope", policy: HeaderAndBodyWarnings, data: map[string]int{"a": 1},
+		headerExpected: `299 - "limit clamped to 100", 299 - "\"sort\" is deprecated"`},
+	{name: "body only, not an envelope", policy: BodyWarnings, data: struct{ Name string }{Name: "a"},
+		headerExpected: `299 - "limit clamped to 100", 299 - "\"sort\" is deprecated"`},
+	{name: "body only, debug envelope", policy: BodyWarnings, data: debugResponse{JSONResponse{Error: true}, &ErrorDebug{}},
+		bodyExpected: `"warnings":["limit clamped to 100","\"sort\" is deprecated"]`},
+}
+
+func TestParser_WriteJSONWarnings(t *testing.T) {
+	for _, e := range warningsTests {
+		testParser := Parser{Warnings: e.policy}
+
+		rr := httptest.NewRecorder()
+		AddWarning(rr, "limit clamped to 100")
+		AddWarning(rr, `"sort" is deprecated`)
+		if err := testParser.WriteJSON(rr, http.StatusOK, e.data); err != nil {
+			t.Fatal(err)
+		}
+
+		body := rr.Body.String()
+		if e.bodyExpected != "" && !strings.Contains(body, e.bodyExpected) || e.bodyExpected == "" && strings.Contains(body, "warnings") {
+			t.Errorf("%s: expected body warnings %s, got %s", e.name, e.bodyExpected, body)
+		}
+		if got := strings.Join(rr.Header().Values("Warning"), ", "); got != e.headerExpected {
+			t.Errorf("%s: expected Warning headers %q, got %q", e.name, e.headerExpected, got)
+		}
+	}
+}
+
+func TestParser_WriteJSONWarningsCopy(t *testing.T) {
+	var testParser Parser
+	payload := &JSONResponse{Data: 1}
+
+	rr := httptest.NewRecorder()
+	AddWarning(rr, "partial result")
+	if err := testParser.WriteJSON(rr, http.StatusOK, payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Warnings != nil {
+		t.Errorf("expected the caller's envelope to be left alone, got %v", payload.Warnings)
+	}
+}
@@ -0,0 +1,258 @@
+package ps
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults of a WebhookSender.
+const (
+	defaultWebhookAttempts = 5
+	defaultSignatureHeader = "X-Signature"
+	// maxRecordedResponse is how much of each response body a WebhookAttempt keeps.
+	maxRecordedResponse = 1024
+)
+
+// WebhookSignature returns the signature a WebhookSender sends for body with the given Webhook-Id, X-Timestamp
+// and X-Nonce header values: "sha256=" and the hex HMAC-SHA256, keyed with secret, of the ID, timestamp, nonce
+// and body, joined with dots. Receivers compute it the same way, or with VerifyWebhookSignature, and compare it
+// with hmac.Equal. Covering the nonce and ID means neither can be swapped to pass a captured request off as new.
+func WebhookSignature(secret []byte, id, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	for _, part := range []string{id, timestamp, nonce} {
+		mac.Write([]byte(part))
+		mac.Write([]byte("."))
+	}
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookSender delivers JSON webhooks. Each delivery is encoded once, then POSTed until the receiver accepts it or
+// the attempts run out. Every attempt carries a fresh timestamp and nonce, in the headers a ReplayGuard checks by
+// default, and a signature over them; the Webhook-Id header stays the same across attempts, so receivers can
+// drop duplicates. Requests go through a RequestIDTransport, so the request ID in the context passed to Send, if
+// any, is sent along in the Parser's RequestIDHeader.
+type WebhookSender struct {
+	// Parser encodes the payloads. If it is nil, the zero Parser is used.
+	Parser *Parser
+	// Client sends the requests. If it is nil, http.DefaultClient is used.
+	Client *http.Client
+	// Secret is the key the requests are signed with.
+	Secret []byte
+	// SignatureHeader holds the signature (default X-Signature).
+	SignatureHeader string
+	// MaxAttempts is the number of attempts before giving up (default 5).
+	MaxAttempts int
+	// Backoff returns how long to wait before the given retry, counting from 1. If it is nil, the wait starts at
+	// a second and doubles each time, up to a minute. A Retry-After header on a 429 or 503 response takes
+	// precedence.
+	Backoff func(retry int) time.Duration
+	// Now returns the current time. If it is nil, time.Now is used.
+	Now func() time.Time
+}
+
+// WebhookDelivery records a delivery and every attempt made, for persisting alongside the event it reports.
+type WebhookDelivery struct {
+	ID        string           `json:"id"`
+	URL       string           `json:"url"`
+	Payload   json.RawMessage  `json:"payload"`
+	Delivered bool             `json:"delivered"`
+	Attempts  []WebhookAttempt `json:"attempts"`
+}
+
+// WebhookAttempt records one attempt to deliver a webhook.
+type WebhookAttempt struct {
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"`
+	// StatusCode is the status of the response, or 0 if none was received.
+	StatusCode int `json:"status_code,omitempty"`
+	// Error describes why the attempt failed, if it did.
+	Error string `json:"error,omitempty"`
+	// Response holds the start of the response body.
+	Response string `json:"response,omitempty"`
+}
+
+// WebhookError is returned when a webhook could not be delivered.
+type WebhookError struct {
+	Delivery *WebhookDelivery
+}
+
+// Error describes the last attempt.
+func (e *WebhookError) Error() string {
+	last := e.Delivery.Attempts[len(e.Delivery.Attempts)-1]
+	return fmt.Sprintf("webhook %s not delivered after %d attempts: %s", e.Delivery.ID, len(e.Delivery.Attempts), last.Error)
+}
+
+// Send delivers payload to url. It returns the delivery record, which lists the attempts even when delivery
+// fails. A 2xx response is a success; network errors, 408, 429 and 5xx responses are retried, and any other
+// response ends the delivery at once, as a *WebhookError. Send gives up early, returning the context's error, if
+// ctx is done.
+func (s *WebhookSender) Send(ctx context.Context, url string, payload any) (*WebhookDelivery, error) {
+	body, err := s.parser().marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	id, err := NewUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := &WebhookDelivery{ID: id.String(), URL: url, Payload: body}
+	attempts := s.MaxAttempts
+	if attempts <= 0 {
+		attempts = defaultWebhookAttempts
+	}
+
+	// Send through the client's own transport, adding the request ID.
+	client := http.Client{}
+	if s.Client != nil {
+		client = *s.Client
+	}
+	client.Transport = &RequestIDTransport{Base: client.Transport, Header: s.parser().requestIDHeader()}
+
+	for n := 1; ; n++ {
+		attempt, retry, wait := s.attempt(ctx, &client, delivery, body)
+		delivery.Attempts = append(delivery.Attempts, attempt)
+		if attempt.Error == "" {
+			delivery.Delivered = true
+			return delivery, nil
+		}
+		if !retry || n == attempts {
+			return delivery, &WebhookError{Delivery: delivery}
+		}
+
+		if wait == 0 {
+			wait = s.backoff(n)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return delivery, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt makes one attempt to deliver body. It reports whether a failure may be retried, and how long the
+// receiver asked to wait first, if it did.
+func (s *WebhookSender) attempt(ctx context.Context, client *http.Client, delivery *WebhookDelivery, body []byte) (WebhookAttempt, bool, time.Duration) {
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	start := now()
+	attempt := WebhookAttempt{Time: start}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt, false, 0
+	}
+	nonce, err := NewUUID()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt, false, 0
+	}
+	timestamp := strconv.FormatInt(start.Unix(), 10)
+	signatureHeader := s.SignatureHeader
+	if signatureHeader == "" {
+		signatureHeader = defaultSignatureHeader
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Webhook-Id", delivery.ID)
+	req.Header.Set("X-Timestamp", timestamp)
+	req.Header.Set("X-Nonce", nonce.String())
+	req.Header.Set(signatureHeader, WebhookSignature(s.Secret, delivery.ID, timestamp, nonce.String(), body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		attempt.Duration = now().Sub(start)
+		attempt.Error = err.Error()
+		// A request cut short by the caller's context is not worth retrying.
+		return attempt, ctx.Err() == nil, 0
+	}
+	defer resp.Body.Close()
+	head, _ := io.ReadAll(io.LimitReader(resp.Body, maxRecordedResponse))
+	// Drain a little more, so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	attempt.Duration = now().Sub(start)
+	attempt.StatusCode = resp.StatusCode
+	attempt.Response = string(head)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return attempt, false, 0
+	}
+
+	attempt.Error = "receiver responded " + resp.Status
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		return attempt, true, retryAfter(resp.Header.Get("Retry-After"), now())
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode >= 500:
+		return attempt, true, 0
+	}
+	return attempt, false, 0
+}
+
+// parser returns the Parser that encodes payloads.
+func (s *WebhookSender) parser() *Parser {
+	if s.Parser == nil {
+		return &Parser{}
+	}
+	return s.Parser
+}
+
+// backoff returns the wait before the given retry.
+func (s *WebhookSender) backoff(retry int) time.Duration {
+	if s.Backoff != nil {
+		return s.Backoff(retry)
+	}
+	return min(time.Second<<min(retry-1, 6), time.Minute)
+}
+
+// retryAfter parses a Retry-After header, in seconds or as an HTTP date, into a wait from now. It returns 0 if the
+// header is missing or invalid.
+func retryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// errWebhookSignature is returned by VerifyWebhookSignature for a missing or wrong signature.
+var errWebhookSignature = errors.New("webhook signature does not match")
+
+// VerifyWebhookSignature checks the signature of a webhook request sent by a WebhookSender, given its body. Pair
+// it with a ReplayGuard, which checks the timestamp and nonce the signature covers.
+func VerifyWebhookSignature(r *http.Request, secret []byte, body []byte, signatureHeader string) error {
+	if signatureHeader == "" {
+		signatureHeader = defaultSignatureHeader
+	}
+	id, timestamp, nonce := r.Header.Get("Webhook-Id"), r.Header.Get("X-Timestamp"), r.Header.Get("X-Nonce")
+	// The signed parts are joined with dots, so a dot in one could move bytes between them, or into the body.
+	if strings.Contains(id+timestamp+nonce, ".") {
+		return errWebhookSignature
+	}
+	want := WebhookSignature(secret, id, timestamp, nonce, body)
+	if !hmac.Equal([]byte(want), []byte(r.Header.Get(signatureHeader))) {
+		return errWebhookSignature
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package ps
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookReceiver answers the deliveries it gets with the statuses given, in turn, and records their requests.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   []string
+}
+
+func (rcv *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	b, _ := io.ReadAll(r.Body)
+	rcv.requests = append(rcv.requests, r)
+	rcv.bodies = append(rcv.bodies, string(b))
+	status := rcv.statuses[len(rcv.requests)-1]
+	if status == http.StatusTooManyRequests {
+		w.Header().Set("Retry-After", "0")
+	}
+	w.WriteHeader(status)
+	io.WriteString(w, http.StatusText(status))
+}
+
+var webhookSenderTests = []struct {
+	name              string
+	statuses          []int
+	expectedAttempts  int
+	expectedDelivered bool
+}{
+	{name: "first attempt", statuses: []int{http.StatusAccepted}, expectedAttempts: 1, expectedDelivered: true},
+	{name: "retried", statuses: []int{http.StatusInternalServerError, http.StatusTooManyRequests, http.StatusOK}, expectedAttempts: 3, expectedDelivered: true},
+	{name: "not retried", statuses: []int{http.StatusBadRequest}, expectedAttempts: 1},
+	{name: "out of attempts", statuses: []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}, expectedAttempts: 3},
+}
+
+func TestWebhookSender_Send(t *testing.T) {
+	secret := []byte("s3cret")
+
+	for _, e := range webhookSenderTests {
+		receiver := &webhookReceiver{statuses: e.statuses}
+		server := httptest.NewServer(receiver)
+
+		sender := WebhookSender{Secret: secret, MaxAttempts: 3, Backoff: func(int) time.Duration { return 0 }}
+		delivery, err := sender.Send(ContextWithRequestID(context.Background(), "req-1"), server.URL, map[string]int{"order": 7})
+		server.Close()
+
+		if len(delivery.Attempts) != e.expectedAttempts || delivery.Delivered != e.expectedDelivered {
+			t.Errorf("%s: expected %d attempts and delivered %v, got %+v", e.name, e.expectedAttempts, e.expectedDelivered, delivery)
+			continue
+		}
+		var webhookError *WebhookError
+		if e.expectedDelivered != (err == nil) || (err != nil && !errors.As(err, &webhookError)) {
+			t.Errorf("%s: unexpected error %v", e.name, err)
+		}
+		last := delivery.Attempts[len(delivery.Attempts)-1]
+		if last.StatusCode != e.statuses[len(e.statuses)-1] || last.Response != http.StatusText(last.StatusCode) {
+			t.Errorf("%s: unexpected last attempt %+v", e.name, last)
+		}
+
+		// Every attempt is signed, with its own nonce, for the same delivery.
+		nonces := map[string]bool{}
+		for i, req := range receiver.requests {
+			if receiver.bodies[i] != `{"order":7}` || req.Header.Get("Webhook-Id") != delivery.ID ||
+				req.Header.Get("X-Request-ID") != "req-1" {
+				t.Errorf("%s: unexpected request %v %s", e.name, req.Header, receiver.bodies[i])
+			}
+			if err := VerifyWebhookSignature(req, secret, []byte(receiver.bodies[i]), ""); err != nil {
+				t.Errorf("%s: attempt %d: %v", e.name, i+1, err)
+			}
+			if err := (&ReplayGuard{}).Check(req); err != nil {
+				t.Errorf("%s: attempt %d: %v", e.name, i+1, err)
+			}
+			nonces[req.Header.Get("X-Nonce")] = true
+		}
+		if len(nonces) != len(receiver.requests) {
+			t.Errorf("%s: expected a nonce per attempt, got %v", e.name, nonces)
+		}
+	}
+}
+
+func TestWebhookSender_SendCanceled(t *testing.T) {
+	server := httptest.NewServer(&webhookReceiver{statuses: []int{http.StatusServiceUnavailable, http.StatusOK}})
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	sender := WebhookSender{Backoff: func(int) time.Duration { return time.Hour }}
+	delivery, err := sender.Send(ctx, server.URL, nil)
+
+	if !errors.Is(err, context.DeadlineExceeded) || len(delivery.Attempts) != 1 || delivery.Delivered {
+		t.Errorf("expected the deadline after one attempt, got %v %+v", err, delivery)
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Webhook-Id", "d1")
+	req.Header.Set("X-Timestamp", "1700000000")
+	req.Header.Set("X-Nonce", "n1")
+	req.Header.Set("X-Hook-Signature", WebhookSignature([]byte("k"), "d1", "1700000000", "n1", []byte(`{}`)))
+
+	if err := VerifyWebhookSignature(req, []byte("k"), []byte(`{}`), "X-Hook-Signature"); err != nil {
+		t.Errorf("expected a valid signature, got %v", err)
+	}
+	if err := VerifyWebhookSignature(req, []byte("k"), []byte(`{"a":1}`), "X-Hook-Signature"); err != errWebhookSignature {
+		t.Errorf("expected a mismatch for another body, got %v", err)
+	}
+	for _, header := range []string{"Webhook-Id", "X-Nonce"} {
+		swapped := req.Clone(req.Context())
+		swapped.Header.Set(header, "n2")
+		if err := VerifyWebhookSignature(swapped, []byte("k"), []byte(`{}`), "X-Hook-Signature"); err != errWebhookSignature {
+			t.Errorf("expected a mismatch for another %s, got %v", header, err)
+		}
+	}
+	moved := req.Clone(req.Context())
+	moved.Header.Set("Webhook-Id", "d1.1700000000")
+	moved.Header.Set("X-Timestamp", "n1")
+	moved.Header.Del("X-Nonce")
+	if err := VerifyWebhookSignature(moved, []byte("k"), []byte(`{}`), "X-Hook-Signature"); err != errWebhookSignature {
+		t.Errorf("expected a mismatch for a dot in a signed header, got %v", err)
+	}
+	if !strings.HasPrefix(req.Header.Get("X-Hook-Signature"), "sha256=") {
+		t.Errorf("unexpected signature %s", req.Header.Get("X-Hook-Signature"))
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for value, expected := range map[string]time.Duration{
+		"":                              0,
+		"30":                            30 * time.Second,
+		"Wed, 01 May 2024 10:01:00 GMT": time.Minute,
+		"soon":                          0,
+	} {
+		if got := retryAfter(value, now); got != expected {
+			t.Errorf("%q: expected %s, got %s", value, expected, got)
+		}
+	}
+}
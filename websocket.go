@@ -0,0 +1,100 @@
+package ps
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// textMessage is the WebSocket text message type, as numbered by RFC 6455 and gorilla/websocket.
+const textMessage = 1
+
+// MessageConn is a message-oriented connection, such as a *websocket.Conn from github.com/gorilla/websocket.
+// Other WebSocket libraries can be adapted with a small wrapper.
+type MessageConn interface {
+	// NextReader returns the type of the next message and a reader for its payload.
+	NextReader() (messageType int, r io.Reader, err error)
+	// NextWriter returns a writer for a new message of the given type, which is sent when it is closed.
+	NextWriter(messageType int) (io.WriteCloser, error)
+}
+
+// ReadJSONMessage reads the next message from conn into data, with the same size limit, strictness and
+// conversions as ReadJSON. r is the request that opened the connection; it is passed to the AfterDecode hooks, and
+// its method selects the size limit. Each message is counted in Stats and reported to the OnBind and OnDecodeError
+// callbacks; an error from conn itself, such as the connection closing, is not.
+func (p *Parser) ReadJSONMessage(conn MessageConn, r *http.Request, data any) error {
+	_, reader, err := conn.NextReader()
+	if err != nil {
+		return err
+	}
+
+	err = p.readJSONMessage(r, reader, data)
+	p.countDecode(err)
+	p.notifyDecode(r, data, err)
+	return err
+}
+
+// readJSONMessage does the work of ReadJSONMessage once a message has arrived.
+func (p *Parser) readJSONMessage(r *http.Request, reader io.Reader, data any) error {
+	maxBytes := p.maxPayload(r.Method)
+	// The limit allows one byte more than maxBytes, so that only a message that is actually too large trips it.
+	buf, err := readBody(&elementLimit{r: reader, n: int64(maxBytes) + 1}, 0)
+	if errors.Is(err, errElementTooLarge) {
+		return fmt.Errorf("message must not be larger than %d bytes", maxBytes)
+	}
+	if err != nil {
+		return err
+	}
+	defer releaseBody(buf)
+	p.count(bytesRead, int64(buf.Len()))
+
+	if err := p.checkStructure(buf.Bytes()); err != nil {
+		return err
+	}
+
+	return p.decodeBody(r, bytes.NewReader(buf.Bytes()), data, maxBytes)
+}
+
+// WriteJSONMessage sends data to conn as a JSON text message, encoded as WriteJSON would encode it. A message
+// larger than MaxResponseSize is not sent, and a *ResponseTooLargeError is returned. Messages are counted in
+// Stats and reported to the OnWrite callbacks as Encode calls are, with a nil ResponseWriter.
+func (p *Parser) WriteJSONMessage(conn MessageConn, data any) error {
+	start := time.Now()
+	n, err := p.writeJSONMessage(conn, data)
+	err = p.countWrite(err)
+	p.notifyWrite(nil, WriteInfo{Size: n, Duration: time.Since(start), Err: err})
+	return err
+}
+
+// writeJSONMessage does the work of WriteJSONMessage, returning the number of bytes sent.
+func (p *Parser) writeJSONMessage(conn MessageConn, data any) (int64, error) {
+	out, err := p.marshal(data)
+	if err != nil {
+		return 0, err
+	}
+	if p.oversized(out) {
+		return 0, &ResponseTooLargeError{Size: len(out), Limit: p.MaxResponseSize}
+	}
+
+	w, err := conn.NextWriter(textMessage)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(out)
+	p.count(bytesWritten, int64(n))
+	if err != nil {
+		w.Close()
+		return int64(n), err
+	}
+	return int64(n), w.Close()
+}
+
+// ErrorJSONMessage sends err to conn in the same envelope ErrorJSON writes, following the Disclosure policy as
+// though it were sent with status, which defaults as it does for ErrorJSON.
+func (p *Parser) ErrorJSONMessage(conn MessageConn, err error, status ...int) error {
+	payload := JSONResponse{Error: true, Message: err.Error(), Fields: fieldErrors(err)}
+	return p.WriteJSONMessage(conn, p.disclose(payload, err, errorStatus(err, status)))
+}
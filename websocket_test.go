@@ -0,0 +1,98 @@
+package ps
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeConn is a MessageConn that reads queued messages and records written ones.
+type fakeConn struct {
+	incoming [][]byte
+	sent     []string
+}
+
+func (c *fakeConn) NextReader() (int, io.Reader, error) {
+	if len(c.incoming) == 0 {
+		return 0, nil, io.EOF
+	}
+	msg := c.incoming[0]
+	c.incoming = c.incoming[1:]
+	return textMessage, bytes.NewReader(msg), nil
+}
+
+func (c *fakeConn) NextWriter(int) (io.WriteCloser, error) {
+	return &fakeWriter{conn: c}, nil
+}
+
+type fakeWriter struct {
+	conn *fakeConn
+	bytes.Buffer
+}
+
+func (w *fakeWriter) Close() error {
+	w.conn.sent = append(w.conn.sent, w.String())
+	return nil
+}
+
+var messageTests = []struct {
+	name          string
+	message       string
+	errorExpected string
+}{
+	{name: "valid", message: `{"name":"ping"}`},
+	{name: "unknown field", message: `{"name":"ping","extra":1}`, errorExpected: `body contains unknown key "extra"`},
+	{name: "too large", message: `{"name":"` + string(bytes.Repeat([]byte("x"), 40)) + `"}`, errorExpected: "message must not be larger than 32 bytes"},
+	{name: "exactly the limit", message: `{"name":"ping","pad":"xxxxxxxx"}`, errorExpected: `body contains unknown key "pad"`},
+	{name: "two values", message: `{"name":"a"}{}`, errorExpected: "body must only contain a single JSON value"},
+}
+
+func TestParser_ReadJSONMessage(t *testing.T) {
+	testParser := Parser{MaxJSONSize: 32}
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+
+	for _, e := range messageTests {
+		conn := &fakeConn{incoming: [][]byte{[]byte(e.message)}}
+		var decoded struct {
+			Name string `json:"name"`
+		}
+		err := testParser.ReadJSONMessage(conn, req, &decoded)
+
+		if e.errorExpected != "" {
+			if err == nil || err.Error() != e.errorExpected {
+				t.Errorf("%s: expected error %q, got %v", e.name, e.errorExpected, err)
+			}
+			continue
+		}
+		if err != nil || decoded.Name != "ping" {
+			t.Errorf("%s: expected name ping and no error, got %q and %v", e.name, decoded.Name, err)
+		}
+	}
+}
+
+func TestParser_WriteJSONMessage(t *testing.T) {
+	testParser := Parser{MaxResponseSize: 100}
+	conn := &fakeConn{}
+
+	if err := testParser.WriteJSONMessage(conn, map[string]string{"name": "pong"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := testParser.ErrorJSONMessage(conn, &FieldError{Field: "name", Message: "is required"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		`{"name":"pong"}`,
+		`{"error":true,"message":"name is required","fields":[{"field":"name","message":"is required"}]}`,
+	}
+	if len(conn.sent) != 2 || conn.sent[0] != expected[0] || conn.sent[1] != expected[1] {
+		t.Errorf("expected %q, got %q", expected, conn.sent)
+	}
+
+	var tooLarge *ResponseTooLargeError
+	if err := testParser.WriteJSONMessage(conn, string(bytes.Repeat([]byte("x"), 100))); !errors.As(err, &tooLarge) || len(conn.sent) != 2 {
+		t.Errorf("expected an oversized message to be refused, got %v", err)
+	}
+}